@@ -16,52 +16,227 @@ limitations under the License.
 package hvclient
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-
-	"github.com/vsglobalsign/hvclient/internal/httputils"
+	"strings"
+	"time"
 )
 
-// APIError is an error returned by the HVCA HTTP API.
+// APIError is an error returned by the HVCA HTTP API. Code and Description
+// are taken from the JSON error response body, and Code is empty if HVCA did
+// not provide one. If the response body is not valid JSON, Description
+// contains the start of the raw response body instead.
 type APIError struct {
 	StatusCode  int
+	Code        string
 	Description string
 }
 
-// hvcaError is the format of an HVCA error HTTP response body.
+// Errors for common HVCA failure modes. An APIError matches one of these
+// with errors.Is according to its HTTP status code and description, and the
+// APIError itself may be retrieved with errors.As to obtain the description
+// provided by HVCA.
+var (
+	// ErrAuthFailed indicates that HVCA rejected the credentials or the
+	// authentication token, or that the account is not authorized to
+	// perform the operation.
+	ErrAuthFailed = errors.New("hvclient: authentication failed")
+
+	// ErrNotFound indicates that the requested resource does not exist.
+	ErrNotFound = errors.New("hvclient: not found")
+
+	// ErrPolicyViolation indicates that a request did not comply with the
+	// account's validation policy.
+	ErrPolicyViolation = errors.New("hvclient: validation policy violation")
+
+	// ErrQuotaExceeded indicates that the account's issuance quota or
+	// request rate limit has been exceeded.
+	ErrQuotaExceeded = errors.New("hvclient: quota exceeded")
+
+	// ErrRateLimited indicates that HVCA rejected a request with a HTTP 429
+	// too many requests status code. The error also matches
+	// ErrQuotaExceeded, and a RateLimitError may be retrieved with
+	// errors.As to obtain the time to wait before trying again.
+	ErrRateLimited = errors.New("hvclient: rate limited")
+
+	// ErrServiceUnavailable indicates that HVCA rejected a request with a
+	// HTTP 503 service unavailable status code because it is undergoing
+	// maintenance. A ServiceUnavailableError may be retrieved with
+	// errors.As to obtain the maintenance message and the time to wait
+	// before trying again.
+	ErrServiceUnavailable = errors.New("hvclient: service unavailable for maintenance")
+)
+
+// RateLimitError is returned when HVCA rejects a request with a HTTP 429 too
+// many requests status code.
+type RateLimitError struct {
+	APIError
+	retryAfter time.Duration
+}
+
+// ServiceUnavailableError is returned when HVCA rejects a request with a HTTP
+// 503 service unavailable status code and an error description which
+// indicates that it is undergoing maintenance.
+type ServiceUnavailableError struct {
+	APIError
+	retryAfter time.Duration
+}
+
+// maxRawErrorDescription is the maximum number of bytes of a response body
+// which is not valid JSON which will be used as the description of an
+// APIError, since such a body may be a lengthy HTML page from a proxy.
+const maxRawErrorDescription = 256
+
+// hvcaError is the format of an HVCA error HTTP response body. The code may
+// be either a JSON string or a number.
 type hvcaError struct {
-	Description string `json:"description"`
+	Code        json.RawMessage `json:"code"`
+	Description string          `json:"description"`
 }
 
 // Error returns a string representation of the error.
 func (e APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Description)
+	}
+
 	return fmt.Sprintf("%d: %s", e.StatusCode, e.Description)
 }
 
-// NewAPIError creates a new APIError object from an HTTP response.
+// Is reports whether the error matches the target, which should be one of
+// the errors for common HVCA failure modes, such as ErrNotFound.
+func (e APIError) Is(target error) bool {
+	switch target {
+	case ErrAuthFailed:
+		return e.StatusCode == http.StatusUnauthorized ||
+			(e.StatusCode == http.StatusForbidden && !e.isQuotaError())
+
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+
+	case ErrPolicyViolation:
+		return e.StatusCode == http.StatusUnprocessableEntity
+
+	case ErrQuotaExceeded:
+		return e.StatusCode == http.StatusPaymentRequired ||
+			e.StatusCode == http.StatusTooManyRequests ||
+			(e.StatusCode == http.StatusForbidden && e.isQuotaError())
+	}
+
+	return false
+}
+
+// RetryAfter returns the time HVCA asked the client to wait before trying
+// again, capped at the maximum delay of the retry policy, or zero if the
+// response did not include a valid Retry-After header.
+func (e RateLimitError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// Is reports whether the error matches the target, which may be
+// ErrRateLimited or any error matched by the underlying APIError.
+func (e RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited || e.APIError.Is(target)
+}
+
+// Unwrap returns the underlying API error.
+func (e RateLimitError) Unwrap() error {
+	return e.APIError
+}
+
+// Message returns the maintenance message provided by HVCA.
+func (e ServiceUnavailableError) Message() string {
+	return e.Description
+}
+
+// RetryAfter returns the time HVCA asked the client to wait before trying
+// again, capped at the maximum delay of the retry policy, or zero if the
+// response did not include a valid Retry-After header.
+func (e ServiceUnavailableError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// Is reports whether the error matches the target, which may be
+// ErrServiceUnavailable or any error matched by the underlying APIError.
+func (e ServiceUnavailableError) Is(target error) bool {
+	return target == ErrServiceUnavailable || e.APIError.Is(target)
+}
+
+// Unwrap returns the underlying API error.
+func (e ServiceUnavailableError) Unwrap() error {
+	return e.APIError
+}
+
+// isMaintenance returns true if the error is a HTTP 503 service unavailable
+// response whose code or description indicates that HVCA is undergoing
+// maintenance, as distinct from being temporarily overloaded.
+func (e APIError) isMaintenance() bool {
+	return e.StatusCode == http.StatusServiceUnavailable &&
+		(strings.Contains(strings.ToLower(e.Description), "maintenance") ||
+			strings.Contains(strings.ToLower(e.Code), "maintenance"))
+}
+
+// isQuotaError returns true if the error description indicates that a quota
+// has been exceeded. A forbidden status may indicate either an exhausted
+// quota or an authorization failure, so the description is needed to tell
+// them apart.
+func (e APIError) isQuotaError() bool {
+	return strings.Contains(strings.ToLower(e.Description), "quota")
+}
+
+// NewAPIError creates a new APIError object from an HTTP response. The code
+// and description are parsed from the JSON response body if possible, and
+// otherwise the raw body, truncated if necessary, is used as the
+// description. A generic description is used if the body is empty or
+// cannot be read.
 func NewAPIError(r *http.Response) APIError {
-	// All HVCA error response bodies have a problem+json content type, so
-	// return a generic error if that's not the content type we have.
-	var err = httputils.VerifyResponseContentType(r, httputils.ContentTypeProblemJSON)
+	var apiErr = APIError{StatusCode: r.StatusCode, Description: "unknown API error"}
+
+	var data, err = ioutil.ReadAll(r.Body)
 	if err != nil {
-		return APIError{StatusCode: r.StatusCode, Description: "unknown API error"}
+		return apiErr
 	}
 
-	// Read and unmarshal the response body. Return a generic error on
-	// any failure.
-	var data []byte
-	data, err = ioutil.ReadAll(r.Body)
-	if err != nil {
-		return APIError{StatusCode: r.StatusCode, Description: "unknown API error"}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return apiErr
 	}
 
 	var hvErr hvcaError
-	err = json.Unmarshal(data, &hvErr)
-	if err != nil {
-		return APIError{StatusCode: r.StatusCode, Description: "unknown API error"}
+	if err = json.Unmarshal(data, &hvErr); err != nil {
+		if len(data) > maxRawErrorDescription {
+			data = append(data[:maxRawErrorDescription:maxRawErrorDescription], "..."...)
+		}
+
+		apiErr.Description = string(data)
+
+		return apiErr
+	}
+
+	apiErr.Code = errorCode(hvErr.Code)
+	if hvErr.Description != "" {
+		apiErr.Description = hvErr.Description
+	}
+
+	return apiErr
+}
+
+// errorCode returns the string form of an error code from an HVCA error
+// response body, which may be a JSON string or number.
+func errorCode(raw json.RawMessage) string {
+	var code string
+	if err := json.Unmarshal(raw, &code); err == nil {
+		return code
+	}
+
+	var num json.Number
+	if err := json.Unmarshal(raw, &num); err == nil {
+		return num.String()
 	}
 
-	return APIError{StatusCode: r.StatusCode, Description: hvErr.Description}
+	return ""
 }
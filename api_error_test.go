@@ -17,6 +17,7 @@ package hvclient
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -66,7 +67,7 @@ func TestAPIError(t *testing.T) {
 			},
 			want: APIError{
 				StatusCode:  http.StatusUnauthorized,
-				Description: "unknown API error",
+				Description: "custom message",
 			},
 		},
 		{
@@ -94,6 +95,75 @@ func TestAPIError(t *testing.T) {
 			},
 			want: APIError{
 				StatusCode:  http.StatusServiceUnavailable,
+				Description: `{"description":"custom mess`,
+			},
+		},
+		{
+			name: "Code",
+			in: &http.Response{
+				Body: ioutil.NopCloser(strings.NewReader(`{"code":"INVALID_SUBJECT","description":"custom message"}`)),
+				Header: http.Header{
+					httputils.ContentTypeHeader: []string{httputils.ContentTypeProblemJSON},
+				},
+				StatusCode: http.StatusUnprocessableEntity,
+			},
+			want: APIError{
+				StatusCode:  http.StatusUnprocessableEntity,
+				Code:        "INVALID_SUBJECT",
+				Description: "custom message",
+			},
+		},
+		{
+			name: "NumericCode",
+			in: &http.Response{
+				Body: ioutil.NopCloser(strings.NewReader(`{"code":40101,"description":"custom message"}`)),
+				Header: http.Header{
+					httputils.ContentTypeHeader: []string{httputils.ContentTypeProblemJSON},
+				},
+				StatusCode: http.StatusUnauthorized,
+			},
+			want: APIError{
+				StatusCode:  http.StatusUnauthorized,
+				Code:        "40101",
+				Description: "custom message",
+			},
+		},
+		{
+			name: "RawBody",
+			in: &http.Response{
+				Body: ioutil.NopCloser(strings.NewReader("  Bad Gateway\n")),
+				Header: http.Header{
+					httputils.ContentTypeHeader: []string{"text/plain"},
+				},
+				StatusCode: http.StatusBadGateway,
+			},
+			want: APIError{
+				StatusCode:  http.StatusBadGateway,
+				Description: "Bad Gateway",
+			},
+		},
+		{
+			name: "RawBodyTruncated",
+			in: &http.Response{
+				Body: ioutil.NopCloser(strings.NewReader(strings.Repeat("a", maxRawErrorDescription+1))),
+				Header: http.Header{
+					httputils.ContentTypeHeader: []string{"text/html"},
+				},
+				StatusCode: http.StatusBadGateway,
+			},
+			want: APIError{
+				StatusCode:  http.StatusBadGateway,
+				Description: strings.Repeat("a", maxRawErrorDescription) + "...",
+			},
+		},
+		{
+			name: "EmptyBody",
+			in: &http.Response{
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+				StatusCode: http.StatusInternalServerError,
+			},
+			want: APIError{
+				StatusCode:  http.StatusInternalServerError,
 				Description: "unknown API error",
 			},
 		},
@@ -129,6 +199,15 @@ func TestAPIErrorString(t *testing.T) {
 			},
 			want: "400: custom message",
 		},
+		{
+			name: "Code",
+			in: APIError{
+				StatusCode:  http.StatusUnprocessableEntity,
+				Code:        "INVALID_SUBJECT",
+				Description: "custom message",
+			},
+			want: "422 INVALID_SUBJECT: custom message",
+		},
 	}
 
 	for _, tc := range testcases {
@@ -143,3 +222,48 @@ func TestAPIErrorString(t *testing.T) {
 		})
 	}
 }
+
+func TestAPIErrorIs(t *testing.T) {
+	t.Parallel()
+
+	var sentinels = []error{ErrAuthFailed, ErrNotFound, ErrPolicyViolation, ErrQuotaExceeded}
+
+	var testcases = []struct {
+		name string
+		err  APIError
+		want error
+	}{
+		{"Unauthorized", APIError{StatusCode: http.StatusUnauthorized}, ErrAuthFailed},
+		{"Forbidden", APIError{StatusCode: http.StatusForbidden, Description: "access denied"}, ErrAuthFailed},
+		{"NotFound", APIError{StatusCode: http.StatusNotFound}, ErrNotFound},
+		{"Unprocessable", APIError{StatusCode: http.StatusUnprocessableEntity}, ErrPolicyViolation},
+		{"PaymentRequired", APIError{StatusCode: http.StatusPaymentRequired}, ErrQuotaExceeded},
+		{"TooManyRequests", APIError{StatusCode: http.StatusTooManyRequests}, ErrQuotaExceeded},
+		{"ForbiddenQuota", APIError{StatusCode: http.StatusForbidden, Description: "Issuance Quota exhausted"}, ErrQuotaExceeded},
+		{"BadRequest", APIError{StatusCode: http.StatusBadRequest}, nil},
+		{"InternalServerError", APIError{StatusCode: http.StatusInternalServerError}, nil},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Wrap the error to verify matching works through wrapping,
+			// as it would for errors returned from API methods.
+			var err = fmt.Errorf("wrapped: %w", tc.err)
+
+			for _, sentinel := range sentinels {
+				if got, want := errors.Is(err, sentinel), sentinel == tc.want; got != want {
+					t.Errorf("errors.Is(%v, %v) = %t, want %t", err, sentinel, got, want)
+				}
+			}
+
+			var apiErr APIError
+			if !errors.As(err, &apiErr) || apiErr != tc.err {
+				t.Errorf("errors.As got %v, want %v", apiErr, tc.err)
+			}
+		})
+	}
+}
@@ -16,6 +16,9 @@ limitations under the License.
 package hvclient
 
 import (
+	"bytes"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
@@ -88,3 +91,78 @@ func paginationString(
 
 	return builder.String()
 }
+
+// orderChain orders a chain of certificates so that each certificate is
+// followed by its issuer, and returns an error if they do not form a single
+// chain in which each certificate's signature is verified by the next.
+func orderChain(certs []*x509.Certificate) ([]*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("empty certificate chain")
+	}
+
+	// isIssuer reports whether parent is the issuer of child, and is not
+	// the same certificate.
+	var isIssuer = func(parent, child *x509.Certificate) bool {
+		return parent != child && bytes.Equal(child.RawIssuer, parent.RawSubject)
+	}
+
+	// The first certificate in the chain is the only one which did not
+	// issue any of the others.
+	var first *x509.Certificate
+	for _, cert := range certs {
+		var issued bool
+		for _, other := range certs {
+			if isIssuer(cert, other) {
+				issued = true
+				break
+			}
+		}
+
+		if !issued {
+			if first != nil {
+				return nil, fmt.Errorf("certificate chain has more than one end: %q and %q",
+					first.Subject, cert.Subject)
+			}
+
+			first = cert
+		}
+	}
+
+	if first == nil {
+		return nil, errors.New("certificate chain has no end")
+	}
+
+	var remaining = make(map[*x509.Certificate]bool, len(certs))
+	for _, cert := range certs {
+		remaining[cert] = true
+	}
+
+	var ordered = []*x509.Certificate{first}
+	delete(remaining, first)
+
+	for cur := first; len(remaining) > 0; {
+		var next *x509.Certificate
+		for _, cert := range certs {
+			if remaining[cert] && isIssuer(cert, cur) {
+				next = cert
+				break
+			}
+		}
+
+		if next == nil {
+			return nil, fmt.Errorf("certificate %q is not issued by any remaining certificate in the chain",
+				cur.Subject)
+		}
+
+		if err := cur.CheckSignatureFrom(next); err != nil {
+			return nil, fmt.Errorf("signature of certificate %q not verified by issuer %q: %w",
+				cur.Subject, next.Subject, err)
+		}
+
+		ordered = append(ordered, next)
+		delete(remaining, next)
+		cur = next
+	}
+
+	return ordered, nil
+}
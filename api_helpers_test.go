@@ -16,10 +16,13 @@ limitations under the License.
 package hvclient
 
 import (
+	"crypto/x509"
 	"errors"
 	"net/http"
 	"testing"
 	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/testhelpers"
 )
 
 func TestHeaderFromResponse(t *testing.T) {
@@ -255,3 +258,76 @@ func TestPaginationString(t *testing.T) {
 		})
 	}
 }
+
+func TestOrderChain(t *testing.T) {
+	t.Parallel()
+
+	var leaf = testhelpers.MustGetCertFromFile(t, "testdata/test_cert.pem")
+	var ica = testhelpers.MustGetCertFromFile(t, "testdata/test_ica_cert.pem")
+	var root = testhelpers.MustGetCertFromFile(t, "testdata/test_root_cert.pem")
+
+	var testcases = []struct {
+		name string
+		in   []*x509.Certificate
+		want []*x509.Certificate
+		err  bool
+	}{
+		{
+			name: "Ordered",
+			in:   []*x509.Certificate{leaf, ica, root},
+			want: []*x509.Certificate{leaf, ica, root},
+		},
+		{
+			name: "Reversed",
+			in:   []*x509.Certificate{root, ica, leaf},
+			want: []*x509.Certificate{leaf, ica, root},
+		},
+		{
+			name: "Shuffled",
+			in:   []*x509.Certificate{ica, root, leaf},
+			want: []*x509.Certificate{leaf, ica, root},
+		},
+		{
+			name: "NoRoot",
+			in:   []*x509.Certificate{ica, leaf},
+			want: []*x509.Certificate{leaf, ica},
+		},
+		{
+			name: "Single",
+			in:   []*x509.Certificate{root},
+			want: []*x509.Certificate{root},
+		},
+		{
+			name: "MissingLink",
+			in:   []*x509.Certificate{leaf, root},
+			err:  true,
+		},
+		{
+			name: "Empty",
+			err:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got, err = orderChain(tc.in)
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error %t", err, tc.err)
+			}
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d certificates, want %d", len(got), len(tc.want))
+			}
+
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("certificate %d: got %q, want %q", i, got[i].Subject, tc.want[i].Subject)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"time"
+)
+
+// CallOption overrides the client's defaults for a single call to a
+// long-running method such as ExportIssued or CertificatesRequest.
+type CallOption func(*callOptions)
+
+// callOptions holds the overrides made by call options.
+type callOptions struct {
+	timeout     time.Duration
+	retryPolicy *RetryPolicy
+}
+
+// WithCallTimeout returns a call option which limits the call to the
+// specified duration, in place of the timeout the caller would otherwise
+// derive from Client.DefaultTimeout. If the context passed to the call has
+// an earlier deadline, that deadline still applies. A zero or negative
+// duration is ignored.
+func WithCallTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithCallRetryPolicy returns a call option which causes every request made
+// during the call to use the specified retry policy instead of the one in
+// the configuration.
+func WithCallRetryPolicy(policy *RetryPolicy) CallOption {
+	return func(o *callOptions) {
+		o.retryPolicy = policy
+	}
+}
+
+// WithNoRetry returns a call option which disables the automatic retrying
+// of failed requests made during the call.
+func WithNoRetry() CallOption {
+	return WithCallRetryPolicy(&RetryPolicy{})
+}
+
+// withCallOptions returns a copy of the context which applies the specified
+// call options to the requests made with it, and a function which must be
+// called to release its resources when the call is complete.
+func withCallOptions(ctx context.Context, opts []CallOption) (context.Context, context.CancelFunc) {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.retryPolicy != nil {
+		ctx = withRetryPolicy(ctx, o.retryPolicy)
+	}
+
+	// context.WithTimeout keeps the parent's deadline if it is earlier.
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithCallOptionsDeadline(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name   string
+		parent time.Duration
+		opts   []CallOption
+		want   time.Duration
+	}{
+		{
+			name: "None",
+		},
+		{
+			name: "Timeout",
+			opts: []CallOption{WithCallTimeout(time.Minute)},
+			want: time.Minute,
+		},
+		{
+			name:   "ParentEarlier",
+			parent: time.Second * 10,
+			opts:   []CallOption{WithCallTimeout(time.Minute)},
+			want:   time.Second * 10,
+		},
+		{
+			name:   "TimeoutEarlier",
+			parent: time.Hour,
+			opts:   []CallOption{WithCallTimeout(time.Minute)},
+			want:   time.Minute,
+		},
+		{
+			name:   "ZeroTimeoutIgnored",
+			parent: time.Hour,
+			opts:   []CallOption{WithCallTimeout(0)},
+			want:   time.Hour,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var parent = context.Background()
+			if tc.parent > 0 {
+				var cancel context.CancelFunc
+				parent, cancel = context.WithTimeout(parent, tc.parent)
+				defer cancel()
+			}
+
+			var start = time.Now()
+			var ctx, cancel = withCallOptions(parent, tc.opts)
+			defer cancel()
+
+			var deadline, ok = ctx.Deadline()
+			if ok != (tc.want > 0) {
+				t.Fatalf("got deadline %t, want %t", ok, tc.want > 0)
+			}
+
+			if ok {
+				if got := deadline.Sub(start); got > tc.want+time.Second || got < tc.want-time.Second {
+					t.Errorf("got deadline in %v, want %v", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithCallOptionsRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	var conf = &Config{RetryPolicy: &RetryPolicy{MaxRetries: 3}}
+	var custom = &RetryPolicy{MaxRetries: 7}
+
+	var testcases = []struct {
+		name string
+		opts []CallOption
+		want int
+	}{
+		{
+			name: "Default",
+			want: 3,
+		},
+		{
+			name: "NoRetry",
+			opts: []CallOption{WithNoRetry()},
+			want: 0,
+		},
+		{
+			name: "Custom",
+			opts: []CallOption{WithCallRetryPolicy(custom)},
+			want: 7,
+		},
+		{
+			name: "LastWins",
+			opts: []CallOption{WithCallRetryPolicy(custom), WithNoRetry()},
+			want: 0,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var ctx, cancel = withCallOptions(context.Background(), tc.opts)
+			defer cancel()
+
+			if got := retryPolicyFromContext(ctx, conf).MaxRetries; got != tc.want {
+				t.Errorf("got max retries %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExportIssuedCallOptions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("NoRetry", func(t *testing.T) {
+		t.Parallel()
+
+		var hits int32
+		var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		var clnt = newTestClient(t, server.URL, &RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond})
+
+		var _, err = clnt.ExportIssued(context.Background(), time.Time{}, time.Time{}, io.Discard, WithNoRetry())
+		if err == nil {
+			t.Fatalf("unexpectedly succeeded")
+		}
+
+		if got := atomic.LoadInt32(&hits); got != 1 {
+			t.Errorf("got %d requests, want 1", got)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		t.Parallel()
+
+		var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+
+		var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+		var _, err = clnt.ExportIssued(context.Background(), time.Time{}, time.Time{}, io.Discard,
+			WithCallTimeout(time.Millisecond*50))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+// BundleOptions controls the contents of a PEM bundle returned by
+// CertificateRetrieveBundleWithOptions.
+type BundleOptions struct {
+	// IncludeRoot includes the self-signed root certificate at the end of
+	// the trust chain in the bundle. Servers normally omit it, since
+	// clients must already trust it.
+	IncludeRoot bool
+}
+
+// CertificateRetrieveBundle retrieves a certificate and returns it followed
+// by its issuing chain, excluding the root certificate, as a PEM bundle in
+// the order in which a server presents them, as described for
+// CertificateRetrieveBundleWithOptions.
+func (c *Client) CertificateRetrieveBundle(ctx context.Context, serial *big.Int) ([]byte, error) {
+	return c.CertificateRetrieveBundleWithOptions(ctx, serial, BundleOptions{})
+}
+
+// CertificateRetrieveBundleWithOptions retrieves a certificate and returns it
+// followed by the certificates of the trust chain for the certificates
+// issued by the calling account, from its issuer onwards, as a PEM bundle
+// suitable for serving with the certificate. The trust chain is cached as
+// described for VerifyChainWithOptions, so it is normally retrieved from
+// HVCA only once. An error is returned if the certificate was not issued by
+// a certificate in the trust chain.
+func (c *Client) CertificateRetrieveBundleWithOptions(
+	ctx context.Context,
+	serial *big.Int,
+	opts BundleOptions,
+) ([]byte, error) {
+	var info, err = c.CertificateRetrieve(ctx, serial)
+	if err != nil {
+		return nil, err
+	}
+
+	var leaf *x509.Certificate
+	if leaf, err = info.Certificate(); err != nil {
+		return nil, err
+	}
+
+	var chain []*x509.Certificate
+	if chain, err = c.cachedTrustChain(ctx); err != nil {
+		return nil, fmt.Errorf("failed to retrieve trust chain: %w", err)
+	}
+
+	return certBundle(leaf, chain, opts)
+}
+
+// certBundle returns a PEM bundle containing the leaf certificate followed
+// by the certificates of the ordered chain from its issuer onwards.
+func certBundle(leaf *x509.Certificate, chain []*x509.Certificate, opts BundleOptions) ([]byte, error) {
+	var start = -1
+	for i, cert := range chain {
+		if bytes.Equal(leaf.RawIssuer, cert.RawSubject) && leaf.CheckSignatureFrom(cert) == nil {
+			start = i
+			break
+		}
+	}
+
+	if start < 0 {
+		return nil, fmt.Errorf("certificate %s (%q) was not issued by the trust chain",
+			SerialToString(leaf.SerialNumber), leaf.Subject)
+	}
+
+	var certs = append([]*x509.Certificate{leaf}, chain[start:]...)
+
+	// Omit the last certificate only if it is a self-signed root, so that
+	// a chain ending in a cross-signed intermediate is preserved.
+	if last := certs[len(certs)-1]; !opts.IncludeRoot && len(certs) > 1 &&
+		bytes.Equal(last.RawIssuer, last.RawSubject) && last.CheckSignatureFrom(last) == nil {
+		certs = certs[:len(certs)-1]
+	}
+
+	var buf bytes.Buffer
+	for _, cert := range certs {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
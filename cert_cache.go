@@ -0,0 +1,137 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// certCache is a concurrency-safe, least recently used cache of retrieved
+// certificates, keyed by serial number. A nil cache caches nothing.
+type certCache struct {
+	mtx     sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// certCacheEntry is an entry in a certificate cache.
+type certCacheEntry struct {
+	key     string
+	info    CertInfo
+	expires time.Time
+}
+
+// newCertCache creates a certificate cache holding at most the specified
+// number of entries, each of which expires after the specified time.
+func newCertCache(size int, ttl time.Duration) *certCache {
+	return &certCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// cache returns the client's certificate cache, creating it if necessary,
+// or nil if caching is not enabled.
+func (c *Client) cache() *certCache {
+	c.certCacheOnce.Do(func() {
+		if c.Config != nil && c.Config.CacheSize > 0 {
+			c.certCache = newCertCache(c.Config.CacheSize, c.Config.cacheTTL())
+		}
+	})
+
+	return c.certCache
+}
+
+// get returns a copy of the cached certificate with the specified key, if
+// there is one which has not expired at the specified time.
+func (c *certCache) get(key string, now time.Time) (*CertInfo, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var elem, ok = c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	var entry = elem.Value.(*certCacheEntry)
+	if !now.Before(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+
+	var info = entry.info
+
+	return &info, true
+}
+
+// put adds a copy of a certificate to the cache, replacing any existing
+// entry with the same key, and evicting the least recently used entry if
+// the cache is full.
+func (c *certCache) put(key string, info *CertInfo, now time.Time) {
+	if c == nil {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var entry = &certCacheEntry{key: key, info: *info, expires: now.Add(c.ttl)}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+
+	if c.lru.Len() > c.size {
+		var oldest = c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*certCacheEntry).key)
+	}
+}
+
+// remove removes the entry with the specified key from the cache, if
+// present.
+func (c *certCache) remove(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+	}
+}
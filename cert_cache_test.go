@@ -0,0 +1,191 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+	"github.com/vsglobalsign/hvclient/internal/pki"
+	"github.com/vsglobalsign/hvclient/internal/testhelpers"
+)
+
+func TestCertCache(t *testing.T) {
+	t.Parallel()
+
+	var now = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	var cache = newCertCache(2, time.Minute)
+
+	cache.put("A", &CertInfo{Status: StatusIssued}, now)
+	cache.put("B", &CertInfo{Status: StatusIssued}, now)
+
+	// Use A so that B is the least recently used, and is evicted when C is
+	// added.
+	if _, ok := cache.get("A", now); !ok {
+		t.Fatalf("A not found in cache")
+	}
+
+	cache.put("C", &CertInfo{Status: StatusIssued}, now)
+
+	if _, ok := cache.get("B", now); ok {
+		t.Errorf("B unexpectedly found in cache")
+	}
+
+	// Replace A, and check that modifying the returned copy does not modify
+	// the cached entry.
+	cache.put("A", &CertInfo{Status: StatusRevoked}, now)
+
+	var info, ok = cache.get("A", now)
+	if !ok || info.Status != StatusRevoked {
+		t.Fatalf("got %v, %t, want revoked certificate", info, ok)
+	}
+
+	info.Status = StatusIssued
+
+	if info, _ = cache.get("A", now); info.Status != StatusRevoked {
+		t.Errorf("cached entry modified through returned copy")
+	}
+
+	if _, ok = cache.get("C", now.Add(time.Minute)); ok {
+		t.Errorf("expired entry unexpectedly found in cache")
+	}
+
+	cache.remove("A")
+
+	if _, ok = cache.get("A", now); ok {
+		t.Errorf("removed entry unexpectedly found in cache")
+	}
+
+	if len(cache.entries) != 0 || cache.lru.Len() != 0 {
+		t.Errorf("got %d entries and %d list elements, want none", len(cache.entries), cache.lru.Len())
+	}
+
+	// A nil cache caches nothing.
+	var nilCache *certCache
+	nilCache.put("A", &CertInfo{}, now)
+	nilCache.remove("A")
+
+	if _, ok = nilCache.get("A", now); ok {
+		t.Errorf("entry unexpectedly found in nil cache")
+	}
+}
+
+func TestCertificateRetrieveCache(t *testing.T) {
+	t.Parallel()
+
+	var cert = testhelpers.MustGetCertFromFile(t, "testdata/test_cert.pem")
+
+	var testcases = []struct {
+		name      string
+		size      int
+		revoke    bool
+		wantFetch int32
+	}{
+		{
+			name:      "Disabled",
+			wantFetch: 2,
+		},
+		{
+			name:      "Enabled",
+			size:      10,
+			wantFetch: 1,
+		},
+		{
+			name:      "Revoked",
+			size:      10,
+			revoke:    true,
+			wantFetch: 2,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var fetches int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPatch {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+
+				atomic.AddInt32(&fetches, 1)
+
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+				fmt.Fprintf(w, `{"certificate":%q,"status":"ISSUED","updated_at":1600000000}`, pki.CertToPEMString(cert))
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+			clnt.Config.CacheSize = tc.size
+
+			var ctx = context.Background()
+			var serial = big.NewInt(0x1234)
+
+			// Retrieve the certificate concurrently to exercise the locking.
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					if _, err := clnt.CertificateRetrieve(ctx, serial); err != nil {
+						t.Errorf("failed to retrieve certificate: %v", err)
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			if tc.revoke {
+				if err := clnt.CertificateRevoke(ctx, serial); err != nil {
+					t.Fatalf("failed to revoke certificate: %v", err)
+				}
+			}
+
+			var info, err = clnt.CertificateRetrieve(ctx, serial)
+			if err != nil {
+				t.Fatalf("failed to retrieve certificate: %v", err)
+			}
+
+			if info.Status != StatusIssued {
+				t.Errorf("got status %v, want %v", info.Status, StatusIssued)
+			}
+
+			// Two concurrent retrievals usually share an API call, but may
+			// not if the second starts after the first has finished.
+			var got = atomic.LoadInt32(&fetches)
+			if got == tc.wantFetch+1 {
+				got = tc.wantFetch
+			}
+
+			if got != tc.wantFetch {
+				t.Errorf("got %d retrievals from HVCA, want %d", got, tc.wantFetch)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CertFilter selects certificates issued by the calling account.
+type CertFilter struct {
+	// From and To specify the time window during which the certificates
+	// were issued.
+	From, To time.Time
+
+	// CommonName, if not empty, selects only certificates whose subject
+	// common name contains it, ignoring case.
+	CommonName string
+
+	// SAN, if not empty, selects only certificates with a DNS name, email
+	// address, IP address or URI subject alternative name equal to it,
+	// ignoring case.
+	SAN string
+}
+
+// CertIterator iterates over the certificates issued by the calling account
+// which match a filter, or which are expiring, transparently fetching
+// subsequent pages as required.
+// A typical usage is:
+//
+//	var iter = clnt.CertificatesFind(ctx, hvclient.CertFilter{
+//		From:       from,
+//		To:         to,
+//		CommonName: "tenant-1",
+//	})
+//	for iter.Next() {
+//		var info = iter.Item()
+//		// Do something with info.
+//	}
+//	if err := iter.Err(); err != nil {
+//		// Handle error.
+//	}
+type CertIterator struct {
+	// PageSize is the number of certificates to request in each page of
+	// issued certificates. It may be changed before the first call to Next.
+	// The HVCA API enforces a maximum number of certificates per page.
+	PageSize int
+
+	ctx         context.Context
+	client      *Client
+	filter      CertFilter
+	skipRevoked bool
+	stats       *StatsIterator
+	item        CertInfo
+	err         error
+}
+
+// CertificatesFind returns an iterator over the certificates issued by the
+// calling account which match the filter.
+//
+// HVCA does not support filtering certificates by subject or subject
+// alternative name, so the filtering is performed by the client. Every
+// certificate issued during the time window is retrieved with
+// CertificateRetrieve and checked against the filter, so a wide time window
+// may result in a large number of requests. Enabling the certificate cache
+// in the configuration avoids retrieving a certificate again when the same
+// certificates are searched repeatedly.
+func (c *Client) CertificatesFind(ctx context.Context, filter CertFilter) *CertIterator {
+	return &CertIterator{
+		PageSize: defaultStatsPageSize,
+		ctx:      ctx,
+		client:   c,
+		filter:   filter,
+		stats:    c.StatsIssuedIterator(ctx, filter.From, filter.To),
+	}
+}
+
+// ExpiringBefore returns an iterator over the unrevoked certificates issued
+// by the calling account which have not yet expired and which expire before
+// the cutoff, such as to find certificates to renew ahead of their expiry.
+// The serial number, subject and expiry time of each certificate are
+// available from the X509 field of the CertInfo returned by the iterator.
+//
+// The certificates are found with the expiring certificates statistics
+// endpoint, for the window from the current time according to HVCA's clock
+// until the cutoff. Since the statistics do not include the revocation
+// status, every expiring certificate is retrieved with CertificateRetrieve
+// so that revoked certificates can be skipped, as described for
+// CertificatesFind.
+func (c *Client) ExpiringBefore(ctx context.Context, cutoff time.Time) *CertIterator {
+	return &CertIterator{
+		PageSize:    defaultStatsPageSize,
+		ctx:         ctx,
+		client:      c,
+		skipRevoked: true,
+		stats:       c.StatsExpiringIterator(ctx, c.serverTime(), cutoff),
+	}
+}
+
+// Next advances the iterator to the next matching certificate, fetching and
+// retrieving certificates as necessary, and returns false when there are no
+// more matching certificates or an error occurred.
+func (s *CertIterator) Next() bool {
+	if s.err != nil {
+		return false
+	}
+
+	s.stats.PageSize = s.PageSize
+
+	for s.stats.Next() {
+		if s.err = s.ctx.Err(); s.err != nil {
+			return false
+		}
+
+		var serial = s.stats.Item().SerialNumber
+
+		var info, err = s.client.CertificateRetrieve(s.ctx, serial)
+		if err != nil {
+			s.err = fmt.Errorf("failed to retrieve certificate %X: %w", serial, err)
+			return false
+		}
+
+		var cert *x509.Certificate
+		if cert, err = info.Certificate(); err != nil {
+			s.err = fmt.Errorf("certificate %X: %w", serial, err)
+			return false
+		}
+
+		if s.skipRevoked && info.Status == StatusRevoked {
+			continue
+		}
+
+		if s.filter.matches(cert) {
+			s.item = *info
+			return true
+		}
+	}
+
+	s.err = s.stats.Err()
+
+	return false
+}
+
+// Item returns the current certificate. It should only be called after a
+// call to Next has returned true.
+func (s *CertIterator) Item() CertInfo {
+	return s.item
+}
+
+// Err returns the error, if any, which caused Next to return false.
+func (s *CertIterator) Err() error {
+	return s.err
+}
+
+// matches returns true if the certificate matches the filter.
+func (f CertFilter) matches(cert *x509.Certificate) bool {
+	if f.CommonName != "" &&
+		!strings.Contains(strings.ToLower(cert.Subject.CommonName), strings.ToLower(f.CommonName)) {
+		return false
+	}
+
+	if f.SAN == "" {
+		return true
+	}
+
+	var names = append(append([]string{}, cert.DNSNames...), cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+
+	for _, name := range names {
+		if strings.EqualFold(name, f.SAN) {
+			return true
+		}
+	}
+
+	return false
+}
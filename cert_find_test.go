@@ -0,0 +1,277 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+func TestCertificatesFind(t *testing.T) {
+	t.Parallel()
+
+	var key = mustGenerateECKey(t)
+
+	// Issue certificates with serial numbers 1 to 3, served in pages of
+	// two certificates.
+	var templates = []*x509.Certificate{
+		{
+			Subject:   pkix.Name{CommonName: "Tenant-1 Web"},
+			DNSNames:  []string{"www.tenant1.example.com"},
+			NotBefore: time.Now(),
+			NotAfter:  time.Now().Add(time.Hour),
+		},
+		{
+			Subject:        pkix.Name{CommonName: "tenant-1 mail"},
+			EmailAddresses: []string{"admin@tenant1.example.com"},
+			NotBefore:      time.Now(),
+			NotAfter:       time.Now().Add(time.Hour),
+		},
+		{
+			Subject:     pkix.Name{CommonName: "tenant-2 web"},
+			DNSNames:    []string{"www.tenant2.example.com"},
+			IPAddresses: []net.IP{net.ParseIP("10.0.0.2")},
+			NotBefore:   time.Now(),
+			NotAfter:    time.Now().Add(time.Hour),
+		},
+	}
+
+	var certPEMs = make(map[string]string)
+	var metas []CertMeta
+	for i, tmpl := range templates {
+		tmpl.SerialNumber = big.NewInt(int64(i + 1))
+
+		var der, err = x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+		if err != nil {
+			t.Fatalf("failed to create certificate: %v", err)
+		}
+
+		certPEMs[fmt.Sprintf("%X", tmpl.SerialNumber)] = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+		metas = append(metas, CertMeta{SerialNumber: tmpl.SerialNumber, NotBefore: tmpl.NotBefore, NotAfter: tmpl.NotAfter})
+	}
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+		switch {
+		case r.URL.Path == endpointStatsIssued:
+			var page, perPage = 1, 2
+			fmt.Sscan(r.URL.Query().Get("page"), &page)
+			fmt.Sscan(r.URL.Query().Get("per_page"), &perPage)
+
+			var start, end = (page - 1) * perPage, page * perPage
+			if start > len(metas) {
+				start = len(metas)
+			}
+
+			if end > len(metas) {
+				end = len(metas)
+			}
+
+			w.Header().Set(totalCountHeaderName, fmt.Sprint(len(metas)))
+			json.NewEncoder(w).Encode(metas[start:end])
+
+		case strings.HasPrefix(r.URL.Path, endpointCertificates+"/"):
+			json.NewEncoder(w).Encode(CertInfo{
+				PEM:       certPEMs[path.Base(r.URL.Path)],
+				Status:    StatusIssued,
+				UpdatedAt: time.Now(),
+			})
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	var testcases = []struct {
+		name   string
+		filter CertFilter
+		want   []int64
+	}{
+		{name: "All", want: []int64{1, 2, 3}},
+		{name: "CommonName", filter: CertFilter{CommonName: "TENANT-1"}, want: []int64{1, 2}},
+		{name: "DNSName", filter: CertFilter{SAN: "WWW.tenant2.example.com"}, want: []int64{3}},
+		{name: "Email", filter: CertFilter{SAN: "admin@tenant1.example.com"}, want: []int64{2}},
+		{name: "IPAddress", filter: CertFilter{SAN: "10.0.0.2"}, want: []int64{3}},
+		{name: "CommonNameAndSAN", filter: CertFilter{CommonName: "tenant-1", SAN: "www.tenant2.example.com"}},
+		{name: "NoMatch", filter: CertFilter{CommonName: "tenant-3"}},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+			var iter = clnt.CertificatesFind(context.Background(), tc.filter)
+			iter.PageSize = 2
+
+			var got []int64
+			for iter.Next() {
+				got = append(got, iter.Item().X509.SerialNumber.Int64())
+			}
+
+			if err := iter.Err(); err != nil {
+				t.Fatalf("failed to find certificates: %v", err)
+			}
+
+			if !cmp.Equal(got, tc.want) {
+				t.Errorf("got serial numbers %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpiringBefore(t *testing.T) {
+	t.Parallel()
+
+	var key = mustGenerateECKey(t)
+	var cutoff = time.Now().Add(time.Hour * 24 * 30).Truncate(time.Second)
+
+	// Certificate 2 has been revoked, and should be skipped.
+	var certPEMs = make(map[string]string)
+	var metas []CertMeta
+	for i := 1; i <= 3; i++ {
+		var tmpl = &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i)),
+			Subject:      pkix.Name{CommonName: fmt.Sprintf("device-%d", i)},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour * 24 * time.Duration(i)),
+		}
+
+		var der, err = x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+		if err != nil {
+			t.Fatalf("failed to create certificate: %v", err)
+		}
+
+		certPEMs[fmt.Sprintf("%X", tmpl.SerialNumber)] = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+		metas = append(metas, CertMeta{SerialNumber: tmpl.SerialNumber, NotBefore: tmpl.NotBefore, NotAfter: tmpl.NotAfter})
+	}
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+		switch {
+		case r.URL.Path == endpointStatsExpiring:
+			if got, want := r.URL.Query().Get("to"), fmt.Sprint(cutoff.Unix()); got != want {
+				t.Errorf("got to %s, want %s", got, want)
+			}
+
+			if r.URL.Query().Get("from") == "" {
+				t.Errorf("no from time in query")
+			}
+
+			var page, perPage = 1, 2
+			fmt.Sscan(r.URL.Query().Get("page"), &page)
+			fmt.Sscan(r.URL.Query().Get("per_page"), &perPage)
+
+			var start, end = (page - 1) * perPage, page * perPage
+			if start > len(metas) {
+				start = len(metas)
+			}
+
+			if end > len(metas) {
+				end = len(metas)
+			}
+
+			w.Header().Set(totalCountHeaderName, fmt.Sprint(len(metas)))
+			json.NewEncoder(w).Encode(metas[start:end])
+
+		case strings.HasPrefix(r.URL.Path, endpointCertificates+"/"):
+			var status = StatusIssued
+			if path.Base(r.URL.Path) == "2" {
+				status = StatusRevoked
+			}
+
+			json.NewEncoder(w).Encode(CertInfo{
+				PEM:       certPEMs[path.Base(r.URL.Path)],
+				Status:    status,
+				UpdatedAt: time.Now(),
+			})
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+	var iter = clnt.ExpiringBefore(context.Background(), cutoff)
+	iter.PageSize = 2
+
+	var got []string
+	for iter.Next() {
+		got = append(got, iter.Item().X509.Subject.CommonName)
+	}
+
+	if err := iter.Err(); err != nil {
+		t.Fatalf("failed to find expiring certificates: %v", err)
+	}
+
+	if want := []string{"device-1", "device-3"}; !cmp.Equal(got, want) {
+		t.Errorf("got certificates %v, want %v", got, want)
+	}
+
+	// Cancelling the context stops the iteration.
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	if iter = clnt.ExpiringBefore(ctx, cutoff); iter.Next() {
+		t.Fatal("unexpectedly found certificate")
+	}
+
+	if err := iter.Err(); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCertificatesFindCancelled(t *testing.T) {
+	t.Parallel()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	var clnt = newTestClient(t, "http://127.0.0.1:1", &RetryPolicy{})
+
+	var iter = clnt.CertificatesFind(ctx, CertFilter{})
+	if iter.Next() {
+		t.Fatal("unexpectedly found certificate")
+	}
+
+	if err := iter.Err(); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
@@ -16,6 +16,7 @@ limitations under the License.
 package hvclient
 
 import (
+	"bytes"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
@@ -25,37 +26,54 @@ import (
 	"time"
 )
 
-// CertStatus is the issued/revoked status of a certificate.
+// CertStatus is the issued/revoked status of a certificate. StatusExpired is
+// never returned by HVCA, and is reported only by Client.CertificateStatus
+// and Client.CertificateMeta, so it is rejected when a certificate status is
+// unmarshalled.
 type CertStatus int
 
-// CertInfo contains a certificate and associated information.
+// CertInfo contains a certificate and associated information. The
+// revocation fields are zero-valued unless the certificate has been revoked.
+// If HVCA does not report a revocation time, RevokedAt is the time the
+// certificate was last updated, which is when it was revoked. If HVCA reports
+// a revocation reason which is not recognized, RevocationReason is
+// ReasonUnknown and RawRevocationReason contains the reason as reported.
 type CertInfo struct {
-	PEM       string            // The PEM-encoded certificate
-	X509      *x509.Certificate // The parsed certificate
-	Status    CertStatus        // Issued or revoked
-	UpdatedAt time.Time         // When the certificate was last updated
+	PEM                 string            // The PEM-encoded certificate
+	X509                *x509.Certificate // The parsed certificate
+	Status              CertStatus        // Issued or revoked
+	UpdatedAt           time.Time         // When the certificate was last updated
+	Revoked             bool              // Whether the certificate is revoked
+	RevokedAt           time.Time         // When the certificate was revoked
+	RevocationReason    RevocationReason  // Why the certificate was revoked
+	RawRevocationReason string            // An unrecognized revocation reason
 }
 
 // jsonCertInfo is used internally for JSON marshalling/unmarshalling.
 type jsonCertInfo struct {
-	PEM       string     `json:"certificate"`
-	Status    CertStatus `json:"status"`
-	UpdatedAt int64      `json:"updated_at"`
+	PEM              string     `json:"certificate"`
+	Status           CertStatus `json:"status"`
+	UpdatedAt        int64      `json:"updated_at"`
+	RevocationReason string     `json:"revocation_reason,omitempty"`
+	RevocationTime   int64      `json:"revocation_time,omitempty"`
 }
 
 // Certificate status values.
 const (
 	StatusIssued CertStatus = iota + 1
 	StatusRevoked
+	StatusExpired
 )
 
 // certStatusNames maps certificate status values to their string descriptions.
 var certStatusNames = [...]string{
 	StatusIssued:  "ISSUED",
 	StatusRevoked: "REVOKED",
+	StatusExpired: "EXPIRED",
 }
 
-// certStatusCodes maps certificate status string descriptions to their values.
+// certStatusCodes maps the certificate status string descriptions reported
+// by HVCA to their values.
 var certStatusCodes = map[string]CertStatus{
 	"ISSUED":  StatusIssued,
 	"REVOKED": StatusRevoked,
@@ -63,7 +81,7 @@ var certStatusCodes = map[string]CertStatus{
 
 // isValid checks if a certificate status value is within a valid range.
 func (s CertStatus) isValid() bool {
-	return s >= StatusIssued && s <= StatusRevoked
+	return s >= StatusIssued && s <= StatusExpired
 }
 
 // String returns a description of the certificate status.
@@ -115,16 +133,31 @@ func (s CertInfo) Equal(other CertInfo) bool {
 
 	return s.PEM == other.PEM &&
 		s.Status == other.Status &&
-		s.UpdatedAt.Equal(other.UpdatedAt)
+		s.UpdatedAt.Equal(other.UpdatedAt) &&
+		s.Revoked == other.Revoked &&
+		s.RevokedAt.Equal(other.RevokedAt) &&
+		s.RevocationReason == other.RevocationReason &&
+		s.RawRevocationReason == other.RawRevocationReason
 }
 
 // MarshalJSON returns the JSON encoding of certificate metadata.
 func (s CertInfo) MarshalJSON() ([]byte, error) {
-	return json.Marshal(jsonCertInfo{
+	var data = jsonCertInfo{
 		PEM:       s.PEM,
 		Status:    s.Status,
 		UpdatedAt: s.UpdatedAt.Unix(),
-	})
+	}
+
+	if s.Revoked {
+		data.RevocationReason = s.RevocationReason.String()
+		if s.RevocationReason == ReasonUnknown {
+			data.RevocationReason = s.RawRevocationReason
+		}
+
+		data.RevocationTime = s.RevokedAt.Unix()
+	}
+
+	return json.Marshal(data)
 }
 
 // UnmarshalJSON parses JSON-encoded certificate metadata and stores the
@@ -136,23 +169,139 @@ func (s *CertInfo) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
-	var block, rest = pem.Decode([]byte(data.PEM))
-	if block == nil || len(block.Bytes) == 0 || len(rest) != 0 {
-		return errors.New("bad PEM data")
-	}
-
 	var cert *x509.Certificate
-	cert, err = x509.ParseCertificate(block.Bytes)
+	cert, err = parseCertPEM(data.PEM)
 	if err != nil {
 		return err
 	}
 
-	*s = CertInfo{
+	var info = CertInfo{
 		PEM:       data.PEM,
 		X509:      cert,
 		Status:    data.Status,
 		UpdatedAt: time.Unix(data.UpdatedAt, 0).UTC(),
 	}
 
+	if data.Status == StatusRevoked {
+		info.Revoked = true
+		info.RevokedAt = info.UpdatedAt
+
+		if data.RevocationTime != 0 {
+			info.RevokedAt = time.Unix(data.RevocationTime, 0).UTC()
+		}
+
+		// Record an unrecognized reason rather than failing, so that the
+		// certificate can still be retrieved if HVCA adds a new reason.
+		if data.RevocationReason != "" {
+			var reason, ok = parseRevocationReason(data.RevocationReason)
+			if !ok {
+				info.RawRevocationReason = data.RevocationReason
+			}
+
+			info.RevocationReason = reason
+		}
+	}
+
+	*s = info
+
 	return nil
 }
+
+// Certificate returns the parsed certificate. If the X509 field is nil, as
+// it may be if the object was not obtained from HVCA, the certificate is
+// parsed from the PEM field and stored in the X509 field, so the PEM data is
+// parsed at most once. Leading and trailing whitespace around the PEM block
+// is ignored. Certificate is not safe for concurrent use with other calls
+// which read or write the object.
+func (s *CertInfo) Certificate() (*x509.Certificate, error) {
+	if s.X509 != nil {
+		return s.X509, nil
+	}
+
+	var cert, err = parseCertPEM(s.PEM)
+	if err != nil {
+		return nil, err
+	}
+
+	s.X509 = cert
+
+	return cert, nil
+}
+
+// parseCertPEM parses a single PEM-encoded certificate, ignoring any
+// whitespace surrounding the PEM block.
+func parseCertPEM(data string) (*x509.Certificate, error) {
+	var trimmed = strings.TrimSpace(data)
+	if trimmed == "" {
+		return nil, errors.New("empty certificate PEM data")
+	}
+
+	var block, rest = pem.Decode([]byte(trimmed))
+	if block == nil || len(block.Bytes) == 0 {
+		return nil, errors.New("bad PEM data: no PEM block found")
+	} else if block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("bad PEM data: unexpected PEM block type %q", block.Type)
+	} else if len(bytes.TrimSpace(rest)) != 0 {
+		return nil, errors.New("bad PEM data: trailing data after certificate")
+	}
+
+	var cert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+// OCSPServers returns the URLs of the OCSP responders in the authority
+// information access extension of the certificate. An empty slice is
+// returned if the extension is absent or contains no OCSP responders, or if
+// the certificate cannot be parsed.
+func (s CertInfo) OCSPServers() []string {
+	var cert = s.parsedCert()
+	if cert == nil {
+		return []string{}
+	}
+
+	return append([]string{}, cert.OCSPServer...)
+}
+
+// IssuingCertificateURLs returns the URLs of the issuing certificate in the
+// authority information access extension of the certificate. An empty slice
+// is returned if the extension is absent or contains no such URLs, or if the
+// certificate cannot be parsed.
+func (s CertInfo) IssuingCertificateURLs() []string {
+	var cert = s.parsedCert()
+	if cert == nil {
+		return []string{}
+	}
+
+	return append([]string{}, cert.IssuingCertificateURL...)
+}
+
+// CRLDistributionPoints returns the URLs in the CRL distribution points
+// extension of the certificate. An empty slice is returned if the extension
+// is absent, or if the certificate cannot be parsed.
+func (s CertInfo) CRLDistributionPoints() []string {
+	var cert = s.parsedCert()
+	if cert == nil {
+		return []string{}
+	}
+
+	return append([]string{}, cert.CRLDistributionPoints...)
+}
+
+// parsedCert returns the parsed certificate, parsing it from the PEM field
+// if the X509 field is nil, or nil if the certificate cannot be parsed.
+func (s CertInfo) parsedCert() *x509.Certificate {
+	if s.X509 != nil {
+		return s.X509
+	}
+
+	var cert, err = parseCertPEM(s.PEM)
+	if err != nil {
+		return nil
+	}
+
+	return cert
+}
@@ -17,6 +17,7 @@ package hvclient_test
 
 import (
 	"bytes"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -24,6 +25,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/vsglobalsign/hvclient"
 	"github.com/vsglobalsign/hvclient/internal/testhelpers"
 )
@@ -194,6 +196,35 @@ func TestCertInfoMarshalJSON(t *testing.T) {
 			want: []byte(fmt.Sprintf(`{"certificate":"%s","status":"REVOKED","updated_at":1477958400}`,
 				strings.Replace(testPEM, "\n", "\\n", -1))),
 		},
+		{
+			name: "RevokedWithDetails",
+			info: hvclient.CertInfo{
+				PEM:              testPEM,
+				Status:           hvclient.StatusRevoked,
+				UpdatedAt:        time.Unix(1477958400, 0),
+				Revoked:          true,
+				RevokedAt:        time.Unix(1477950000, 0),
+				RevocationReason: hvclient.ReasonKeyCompromise,
+			},
+			want: []byte(fmt.Sprintf(`{"certificate":"%s","status":"REVOKED","updated_at":1477958400,`+
+				`"revocation_reason":"keyCompromise","revocation_time":1477950000}`,
+				strings.Replace(testPEM, "\n", "\\n", -1))),
+		},
+		{
+			name: "RevokedWithUnknownReason",
+			info: hvclient.CertInfo{
+				PEM:                 testPEM,
+				Status:              hvclient.StatusRevoked,
+				UpdatedAt:           time.Unix(1477958400, 0),
+				Revoked:             true,
+				RevokedAt:           time.Unix(1477950000, 0),
+				RevocationReason:    hvclient.ReasonUnknown,
+				RawRevocationReason: "bogus",
+			},
+			want: []byte(fmt.Sprintf(`{"certificate":"%s","status":"REVOKED","updated_at":1477958400,`+
+				`"revocation_reason":"bogus","revocation_time":1477950000}`,
+				strings.Replace(testPEM, "\n", "\\n", -1))),
+		},
 		{
 			name: "BadStatus",
 			info: hvclient.CertInfo{
@@ -252,6 +283,54 @@ func TestCertInfoUnmarshalJSON(t *testing.T) {
 				X509:      testhelpers.MustParseCert(t, testPEM),
 				Status:    hvclient.StatusRevoked,
 				UpdatedAt: time.Unix(1477958400, 0),
+				Revoked:   true,
+				RevokedAt: time.Unix(1477958400, 0),
+			},
+		},
+		{
+			name: "RevokedWithDetails",
+			data: []byte(fmt.Sprintf(`{"certificate":"%s","status":"REVOKED","updated_at":1477958400,`+
+				`"revocation_reason":"superseded","revocation_time":1477950000}`,
+				strings.Replace(testPEM, "\n", "\\n", -1))),
+			want: hvclient.CertInfo{
+				PEM:              testPEM,
+				X509:             testhelpers.MustParseCert(t, testPEM),
+				Status:           hvclient.StatusRevoked,
+				UpdatedAt:        time.Unix(1477958400, 0),
+				Revoked:          true,
+				RevokedAt:        time.Unix(1477950000, 0),
+				RevocationReason: hvclient.ReasonSuperseded,
+			},
+		},
+		{
+			name: "RevocationReasonDifferentCase",
+			data: []byte(fmt.Sprintf(`{"certificate":"%s","status":"REVOKED","updated_at":1477958400,`+
+				`"revocation_reason":"KeyCompromise","revocation_time":1477950000}`,
+				strings.Replace(testPEM, "\n", "\\n", -1))),
+			want: hvclient.CertInfo{
+				PEM:              testPEM,
+				X509:             testhelpers.MustParseCert(t, testPEM),
+				Status:           hvclient.StatusRevoked,
+				UpdatedAt:        time.Unix(1477958400, 0),
+				Revoked:          true,
+				RevokedAt:        time.Unix(1477950000, 0),
+				RevocationReason: hvclient.ReasonKeyCompromise,
+			},
+		},
+		{
+			name: "UnknownRevocationReason",
+			data: []byte(fmt.Sprintf(`{"certificate":"%s","status":"REVOKED","updated_at":1477958400,`+
+				`"revocation_reason":"bogus","revocation_time":1477950000}`,
+				strings.Replace(testPEM, "\n", "\\n", -1))),
+			want: hvclient.CertInfo{
+				PEM:                 testPEM,
+				X509:                testhelpers.MustParseCert(t, testPEM),
+				Status:              hvclient.StatusRevoked,
+				UpdatedAt:           time.Unix(1477958400, 0),
+				Revoked:             true,
+				RevokedAt:           time.Unix(1477950000, 0),
+				RevocationReason:    hvclient.ReasonUnknown,
+				RawRevocationReason: "bogus",
 			},
 		},
 		{
@@ -260,6 +339,12 @@ func TestCertInfoUnmarshalJSON(t *testing.T) {
 				strings.Replace(testPEM, "\n", "\\n", -1))),
 			err: errors.New("bad status value"),
 		},
+		{
+			name: "ExpiredStatus",
+			data: []byte(fmt.Sprintf(`{"certificate":"%s","status":"EXPIRED","updated_at":1477958400}`,
+				strings.Replace(testPEM, "\n", "\\n", -1))),
+			err: errors.New("expired status is never reported by HVCA"),
+		},
 		{
 			name: "BadStatusType",
 			data: []byte(fmt.Sprintf(`{"certificate":"%s","status":1234,"updated_at":1477958400}`,
@@ -276,6 +361,17 @@ func TestCertInfoUnmarshalJSON(t *testing.T) {
 			data: []byte(`{"certificate":"","status":"ISSUED","updated_at":1477958400}`),
 			err:  errors.New("missing PEM"),
 		},
+		{
+			name: "SurroundingWhitespace",
+			data: []byte(fmt.Sprintf(`{"certificate":"\n  %s  \n\n","status":"ISSUED","updated_at":1477958400}`,
+				strings.Replace(testPEM, "\n", "\\n", -1))),
+			want: hvclient.CertInfo{
+				PEM:       "\n  " + testPEM + "  \n\n",
+				X509:      testhelpers.MustParseCert(t, testPEM),
+				Status:    hvclient.StatusIssued,
+				UpdatedAt: time.Unix(1477958400, 0),
+			},
+		},
 		{
 			name: "InvalidCertificate",
 			data: []byte(fmt.Sprintf(`{"certificate":"%s","status":"ISSUED","updated_at":1477958400}`,
@@ -303,6 +399,146 @@ func TestCertInfoUnmarshalJSON(t *testing.T) {
 	}
 }
 
+func TestCertInfoCertificate(t *testing.T) {
+	t.Parallel()
+
+	var want = testhelpers.MustParseCert(t, testPEM)
+
+	var testcases = []struct {
+		name string
+		pem  string
+		err  string
+	}{
+		{
+			name: "OK",
+			pem:  testPEM,
+		},
+		{
+			name: "TrailingWhitespace",
+			pem:  testPEM + " \n\t\n",
+		},
+		{
+			name: "Empty",
+			pem:  "  \n",
+			err:  "empty certificate PEM data",
+		},
+		{
+			name: "NoPEMBlock",
+			pem:  "BAD PEM",
+			err:  "no PEM block found",
+		},
+		{
+			name: "WrongBlockType",
+			pem:  strings.Replace(testPEM, "CERTIFICATE", "PUBLIC KEY", -1),
+			err:  `unexpected PEM block type "PUBLIC KEY"`,
+		},
+		{
+			name: "TrailingData",
+			pem:  testPEM + "\n" + testPEM,
+			err:  "trailing data after certificate",
+		},
+		{
+			name: "InvalidCertificate",
+			pem:  strings.Replace(testPEM, "M", "N", -1),
+			err:  "couldn't parse certificate",
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var info = hvclient.CertInfo{PEM: tc.pem}
+			var got, err = info.Certificate()
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("got error %v, want error containing %q", err, tc.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to parse certificate: %v", err)
+			}
+
+			if !got.Equal(want) {
+				t.Errorf("got certificate %v, want %v", got.Subject, want.Subject)
+			}
+
+			// The parsed certificate should be stored and returned by
+			// subsequent calls without parsing the PEM data again.
+			if info.X509 != got {
+				t.Errorf("parsed certificate not stored")
+			}
+
+			info.PEM = ""
+
+			var again *x509.Certificate
+			if again, err = info.Certificate(); err != nil || again != got {
+				t.Errorf("got %p, %v on second call, want %p, nil", again, err, got)
+			}
+		})
+	}
+}
+
+func TestCertInfoAIA(t *testing.T) {
+	t.Parallel()
+
+	var noAIA = string(testhelpers.MustReadFile(t, "testdata/test_cert.pem"))
+
+	var testcases = []struct {
+		name          string
+		info          hvclient.CertInfo
+		ocsp, crtURLs []string
+	}{
+		{
+			name:    "Parsed",
+			info:    hvclient.CertInfo{X509: testhelpers.MustParseCert(t, testPEM)},
+			ocsp:    []string{"http://ocsp.globalsign.com/ca/gsnphvcademosha2g3"},
+			crtURLs: []string{"http://secure.globalsign.com/cacert/gsnphvcademosha2g3.crt"},
+		},
+		{
+			name:    "PEMOnly",
+			info:    hvclient.CertInfo{PEM: testPEM},
+			ocsp:    []string{"http://ocsp.globalsign.com/ca/gsnphvcademosha2g3"},
+			crtURLs: []string{"http://secure.globalsign.com/cacert/gsnphvcademosha2g3.crt"},
+		},
+		{
+			name:    "NoExtension",
+			info:    hvclient.CertInfo{PEM: noAIA},
+			ocsp:    []string{},
+			crtURLs: []string{},
+		},
+		{
+			name:    "Unparseable",
+			info:    hvclient.CertInfo{PEM: "BAD PEM"},
+			ocsp:    []string{},
+			crtURLs: []string{},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got = tc.info.OCSPServers()
+			if got == nil || !cmp.Equal(got, tc.ocsp) {
+				t.Errorf("got OCSP servers %#v, want %#v", got, tc.ocsp)
+			}
+
+			got = tc.info.IssuingCertificateURLs()
+			if got == nil || !cmp.Equal(got, tc.crtURLs) {
+				t.Errorf("got issuing certificate URLs %#v, want %#v", got, tc.crtURLs)
+			}
+		})
+	}
+}
+
 func TestCertStatusStringInvalidValue(t *testing.T) {
 	t.Parallel()
 
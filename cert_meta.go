@@ -16,24 +16,60 @@ limitations under the License.
 package hvclient
 
 import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 	"time"
 )
 
-// CertMeta contains certificate metadata.
+// CertMeta contains certificate metadata. Subject and Status are populated
+// only by Client.CertificateMeta, since HVCA's statistics endpoints do not
+// return them.
 type CertMeta struct {
-	SerialNumber *big.Int  // Certificate serial number
-	NotBefore    time.Time // Certificate not valid before this time
-	NotAfter     time.Time // Certificate not valid after this time
+	SerialNumber *big.Int   // Certificate serial number
+	NotBefore    time.Time  // Certificate not valid before this time
+	NotAfter     time.Time  // Certificate not valid after this time
+	Subject      string     // Certificate subject distinguished name
+	Status       CertStatus // Certificate status
 }
 
 // jsonCertMeta is used internally for JSON marshalling/unmarshalling.
 type jsonCertMeta struct {
-	SerialNumber string `json:"serial_number"`
-	NotBefore    int64  `json:"not_before"`
-	NotAfter     int64  `json:"not_after"`
+	SerialNumber string         `json:"serial_number"`
+	NotBefore    int64          `json:"not_before"`
+	NotAfter     int64          `json:"not_after"`
+	Subject      string         `json:"subject,omitempty"`
+	Status       metaStatusJSON `json:"status,omitempty"`
+}
+
+// metaStatusJSON is used internally for JSON marshalling/unmarshalling of
+// the status in certificate metadata, which unlike a status reported by HVCA
+// may be StatusExpired.
+type metaStatusJSON CertStatus
+
+// certLite and tbsCertLite mirror the leading fields of an X.509
+// certificate, allowing the validity period and subject to be extracted
+// without parsing extensions, public keys or signatures. Trailing fields of
+// the TBSCertificate are ignored by encoding/asn1.
+type certLite struct {
+	TBSCertificate asn1.RawValue
+}
+
+type tbsCertLite struct {
+	Version      int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber *big.Int
+	Signature    asn1.RawValue
+	Issuer       asn1.RawValue
+	Validity     struct {
+		NotBefore, NotAfter time.Time
+	}
+	Subject asn1.RawValue
 }
 
 // Equal checks if two certificate metadata objects are equivalent.
@@ -47,15 +83,19 @@ func (c CertMeta) Equal(other CertMeta) bool {
 	}
 
 	return c.NotBefore.Equal(other.NotBefore) &&
-		c.NotAfter.Equal(other.NotAfter)
+		c.NotAfter.Equal(other.NotAfter) &&
+		c.Subject == other.Subject &&
+		c.Status == other.Status
 }
 
 // MarshalJSON returns the JSON encoding of a certificate metadata object.
 func (c CertMeta) MarshalJSON() ([]byte, error) {
 	return json.Marshal(jsonCertMeta{
-		SerialNumber: fmt.Sprintf("%X", c.SerialNumber),
+		SerialNumber: SerialToString(c.SerialNumber),
 		NotBefore:    c.NotBefore.Unix(),
 		NotAfter:     c.NotAfter.Unix(),
+		Subject:      c.Subject,
+		Status:       metaStatusJSON(c.Status),
 	})
 }
 
@@ -67,16 +107,97 @@ func (c *CertMeta) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
-	var sn, ok = big.NewInt(0).SetString(data.SerialNumber, 16)
-	if !ok {
-		return fmt.Errorf("invalid serial number: %s", data.SerialNumber)
+	var sn, err = SerialFromString(data.SerialNumber)
+	if err != nil {
+		return err
 	}
 
 	*c = CertMeta{
 		SerialNumber: sn,
 		NotBefore:    time.Unix(data.NotBefore, 0).UTC(),
 		NotAfter:     time.Unix(data.NotAfter, 0).UTC(),
+		Subject:      data.Subject,
+		Status:       CertStatus(data.Status),
 	}
 
 	return nil
 }
+
+// MarshalJSON returns the JSON encoding of a certificate metadata status.
+func (s metaStatusJSON) MarshalJSON() ([]byte, error) {
+	return CertStatus(s).MarshalJSON()
+}
+
+// UnmarshalJSON parses a JSON-encoded certificate metadata status and stores
+// the result in the object.
+func (s *metaStatusJSON) UnmarshalJSON(b []byte) error {
+	var data string
+	if err := json.Unmarshal(b, &data); err == nil && strings.EqualFold(data, StatusExpired.String()) {
+		*s = metaStatusJSON(StatusExpired)
+
+		return nil
+	}
+
+	return (*CertStatus)(s).UnmarshalJSON(b)
+}
+
+// certMetaFromX509 returns the metadata for an already-parsed certificate
+// with the specified HVCA status at the specified time.
+func certMetaFromX509(cert *x509.Certificate, status CertStatus, now time.Time) CertMeta {
+	return CertMeta{
+		SerialNumber: cert.SerialNumber,
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		Subject:      cert.Subject.String(),
+		Status:       metaStatus(status, cert.NotAfter, now),
+	}
+}
+
+// certMetaFromPEM extracts the metadata from a PEM-encoded certificate with
+// the specified HVCA status at the specified time, decoding only the fields
+// it needs.
+func certMetaFromPEM(data string, status CertStatus, now time.Time) (CertMeta, error) {
+	var block, _ = pem.Decode([]byte(data))
+	if block == nil {
+		return CertMeta{}, errors.New("no PEM data found")
+	}
+
+	var cert certLite
+	if rest, err := asn1.Unmarshal(block.Bytes, &cert); err != nil {
+		return CertMeta{}, fmt.Errorf("failed to parse certificate: %w", err)
+	} else if len(rest) != 0 {
+		return CertMeta{}, errors.New("trailing data after certificate")
+	}
+
+	var tbs tbsCertLite
+	if _, err := asn1.Unmarshal(cert.TBSCertificate.FullBytes, &tbs); err != nil {
+		return CertMeta{}, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	var rdns pkix.RDNSequence
+	if _, err := asn1.Unmarshal(tbs.Subject.FullBytes, &rdns); err != nil {
+		return CertMeta{}, fmt.Errorf("failed to parse certificate subject: %w", err)
+	}
+
+	var subject pkix.Name
+	subject.FillFromRDNSequence(&rdns)
+
+	return CertMeta{
+		SerialNumber: tbs.SerialNumber,
+		NotBefore:    tbs.Validity.NotBefore,
+		NotAfter:     tbs.Validity.NotAfter,
+		Subject:      subject.String(),
+		Status:       metaStatus(status, tbs.Validity.NotAfter, now),
+	}, nil
+}
+
+// metaStatus returns StatusExpired for an issued certificate which is past
+// its expiry time, and status otherwise, consistent with
+// Client.CertificateStatus.
+func metaStatus(status CertStatus, notAfter, now time.Time) CertStatus {
+	if status == StatusIssued && now.After(notAfter) {
+		return StatusExpired
+	}
+
+	return status
+}
@@ -43,6 +43,17 @@ func TestCertMetaMarshalJSON(t *testing.T) {
 			},
 			want: []byte(`{"serial_number":"1234","not_before":1477958400,"not_after":1478958400}`),
 		},
+		{
+			name: "SubjectAndStatus",
+			entry: hvclient.CertMeta{
+				SerialNumber: big.NewInt(0x1234),
+				NotBefore:    time.Unix(1477958400, 0),
+				NotAfter:     time.Unix(1478958400, 0),
+				Subject:      "CN=example.com",
+				Status:       hvclient.StatusRevoked,
+			},
+			want: []byte(`{"serial_number":"1234","not_before":1477958400,"not_after":1478958400,"subject":"CN=example.com","status":"REVOKED"}`),
+		},
 	}
 
 	for _, tc := range testcases {
@@ -81,6 +92,32 @@ func TestCertMetaUnmarshalJSON(t *testing.T) {
 				NotAfter:     time.Unix(1478958400, 0),
 			},
 		},
+		{
+			name: "SubjectAndStatus",
+			json: []byte(`{"serial_number":"1234","not_before":1477958400,"not_after":1478958400,"subject":"CN=example.com","status":"ISSUED"}`),
+			want: hvclient.CertMeta{
+				SerialNumber: big.NewInt(0x1234),
+				NotBefore:    time.Unix(1477958400, 0),
+				NotAfter:     time.Unix(1478958400, 0),
+				Subject:      "CN=example.com",
+				Status:       hvclient.StatusIssued,
+			},
+		},
+		{
+			name: "ExpiredStatus",
+			json: []byte(`{"serial_number":"1234","not_before":1477958400,"not_after":1478958400,"status":"EXPIRED"}`),
+			want: hvclient.CertMeta{
+				SerialNumber: big.NewInt(0x1234),
+				NotBefore:    time.Unix(1477958400, 0),
+				NotAfter:     time.Unix(1478958400, 0),
+				Status:       hvclient.StatusExpired,
+			},
+		},
+		{
+			name: "BadStatus",
+			json: []byte(`{"serial_number":"1234","not_before":1477958400,"not_after":1478958400,"status":"BAD STATUS"}`),
+			err:  errors.New("bad status"),
+		},
 		{
 			name: "BadType",
 			json: []byte(`{"serial_number":1234}`),
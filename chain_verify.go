@@ -0,0 +1,104 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// VerifyChain verifies that a certificate chains to the trust chain for the
+// certificates issued by the calling account, as described for
+// VerifyChainWithOptions, using default verification options.
+func (c *Client) VerifyChain(ctx context.Context, leaf *x509.Certificate) error {
+	return c.VerifyChainWithOptions(ctx, leaf, x509.VerifyOptions{})
+}
+
+// VerifyChainWithOptions verifies that a certificate chains to the trust
+// chain for the certificates issued by the calling account. The last
+// certificate in the ordered trust chain, which is normally the root, is
+// used as the trust anchor, and any other certificates as intermediates,
+// unless Roots or Intermediates respectively are set in opts. Other options,
+// such as CurrentTime, are passed through unchanged, except that if no key
+// usages are specified, any extended key usage is accepted rather than only
+// server authentication. The trust chain is retrieved from HVCA on first use
+// and cached until the earliest expiry time of its certificates.
+func (c *Client) VerifyChainWithOptions(ctx context.Context, leaf *x509.Certificate, opts x509.VerifyOptions) error {
+	if leaf == nil {
+		return errors.New("no certificate to verify")
+	}
+
+	var chain, err = c.cachedTrustChain(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve trust chain: %w", err)
+	}
+
+	if opts.Roots == nil {
+		opts.Roots = x509.NewCertPool()
+		opts.Roots.AddCert(chain[len(chain)-1])
+	}
+
+	if opts.Intermediates == nil {
+		opts.Intermediates = x509.NewCertPool()
+		for _, cert := range chain[:len(chain)-1] {
+			opts.Intermediates.AddCert(cert)
+		}
+	}
+
+	if len(opts.KeyUsages) == 0 {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+
+	if _, err = leaf.Verify(opts); err != nil {
+		return fmt.Errorf("certificate %s (%q) does not chain to the trust chain: %w",
+			SerialToString(leaf.SerialNumber), leaf.Subject, err)
+	}
+
+	return nil
+}
+
+// cachedTrustChain returns the ordered trust chain, retrieving it from HVCA
+// if it has not been retrieved before or if any of its certificates has
+// since expired.
+func (c *Client) cachedTrustChain(ctx context.Context) ([]*x509.Certificate, error) {
+	c.trustChainMtx.Lock()
+	defer c.trustChainMtx.Unlock()
+
+	var now = time.Now()
+	if c.trustChain != nil && now.Before(c.trustChainExpiry) {
+		return c.trustChain, nil
+	}
+
+	var chain, err = c.TrustChainCerts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var expiry = chain[0].NotAfter
+	for _, cert := range chain[1:] {
+		if cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+
+	c.trustChain = chain
+	c.trustChainExpiry = expiry
+
+	return chain, nil
+}
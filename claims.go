@@ -61,13 +61,13 @@ type Claim struct {
 type jsonClaim struct {
 	ID                     string          `json:"id"`
 	Status                 ClaimStatus     `json:"status"`
-	Token                  string          `json:"token"`
+	Token                  string          `json:"token,omitempty"`
 	Domain                 string          `json:"domain"`
 	CreatedAt              int64           `json:"created_at"`
 	ExpiresAt              int64           `json:"expires_at"`
 	AssertBy               int64           `json:"assert_by"`
-	LastVerifiedAt         int64           `json:"last_verified_at"`
-	LastVerificationMethod string          `json:"last_verification_method"`
+	LastVerifiedAt         int64           `json:"last_verified_at,omitempty"`
+	LastVerificationMethod string          `json:"last_verification_method,omitempty"`
 	Log                    []ClaimLogEntry `json:"log"`
 }
 
@@ -235,9 +235,21 @@ func (c Claim) Equal(other Claim) bool {
 
 }
 
+// Expired returns true if the claim had expired at the specified time.
+func (c Claim) Expired(at time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !at.Before(c.ExpiresAt)
+}
+
 // MarshalJSON returns the JSON encoding of a domain claim and stores the
 // result in the object.
 func (c Claim) MarshalJSON() ([]byte, error) {
+	// A claim which has never been verified has no last verified time, so
+	// omit it from the encoding rather than encoding the zero time.
+	var lastVerifiedAt int64
+	if !c.LastVerifiedAt.IsZero() {
+		lastVerifiedAt = c.LastVerifiedAt.Unix()
+	}
+
 	return json.Marshal(jsonClaim{
 		ID:                     c.ID,
 		Status:                 c.Status,
@@ -246,7 +258,7 @@ func (c Claim) MarshalJSON() ([]byte, error) {
 		CreatedAt:              c.CreatedAt.Unix(),
 		ExpiresAt:              c.ExpiresAt.Unix(),
 		AssertBy:               c.AssertBy.Unix(),
-		LastVerifiedAt:         c.LastVerifiedAt.Unix(),
+		LastVerifiedAt:         lastVerifiedAt,
 		LastVerificationMethod: c.LastVerificationMethod,
 		Log:                    c.Log,
 	})
@@ -260,6 +272,11 @@ func (c *Claim) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
+	var lastVerifiedAt time.Time
+	if data.LastVerifiedAt != 0 {
+		lastVerifiedAt = time.Unix(data.LastVerifiedAt, 0).UTC()
+	}
+
 	*c = Claim{
 		ID:                     data.ID,
 		Status:                 data.Status,
@@ -268,7 +285,7 @@ func (c *Claim) UnmarshalJSON(b []byte) error {
 		CreatedAt:              time.Unix(data.CreatedAt, 0).UTC(),
 		ExpiresAt:              time.Unix(data.ExpiresAt, 0).UTC(),
 		AssertBy:               time.Unix(data.AssertBy, 0).UTC(),
-		LastVerifiedAt:         time.Unix(data.LastVerifiedAt, 0).UTC(),
+		LastVerifiedAt:         lastVerifiedAt,
 		LastVerificationMethod: data.LastVerificationMethod,
 		Log:                    data.Log,
 	}
@@ -0,0 +1,134 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+)
+
+// ClaimsIterator iterates over the domain claims with a particular status,
+// transparently fetching subsequent pages as required. A typical usage is:
+//
+//	var iter = clnt.ClaimsIterator(ctx, hvclient.StatusPending)
+//	for iter.Next() {
+//		var claim = iter.Item()
+//		// Do something with claim.
+//	}
+//	if err := iter.Err(); err != nil {
+//		// Handle error.
+//	}
+type ClaimsIterator struct {
+	// PageSize is the number of claims to request in each page. It may be
+	// changed before the first call to Next. The HVCA API enforces a maximum
+	// number of claims per page.
+	PageSize int
+
+	ctx    context.Context
+	client *Client
+	status ClaimStatus
+	page   int
+	items  []Claim
+	index  int
+	seen   int64
+	item   Claim
+	total  int64
+	done   bool
+	err    error
+}
+
+// defaultClaimsPageSize is the default number of claims to request in each
+// page when iterating over domain claims.
+const defaultClaimsPageSize = 100
+
+// ClaimsIterator returns an iterator over the domain claims with the
+// specified status. HVCA does not report expired claims separately, so to
+// find claims which have expired, iterate over the verified claims and
+// check their ExpiresAt fields, or use the Expired method.
+func (c *Client) ClaimsIterator(ctx context.Context, status ClaimStatus) *ClaimsIterator {
+	return &ClaimsIterator{
+		PageSize: defaultClaimsPageSize,
+		ctx:      ctx,
+		client:   c,
+		status:   status,
+	}
+}
+
+// Next advances the iterator to the next claim, fetching the next page if
+// necessary, and returns false when there are no more claims or an error
+// occurred.
+func (s *ClaimsIterator) Next() bool {
+	for {
+		if s.done || s.err != nil {
+			return false
+		}
+
+		// Return the next claim in the current page, if any remain.
+		if s.index < len(s.items) {
+			s.item = s.items[s.index]
+			s.index++
+			s.seen++
+
+			return true
+		}
+
+		// Stop if we've already seen all the claims. A partial page doesn't
+		// indicate the last page, since HVCA may return fewer claims per
+		// page than were requested.
+		if s.page > 0 && s.seen >= s.total {
+			s.done = true
+			return false
+		}
+
+		// Otherwise fetch the next page, unless the context is done.
+		if s.err = s.ctx.Err(); s.err != nil {
+			return false
+		}
+
+		s.page++
+
+		var items, total, err = s.client.ClaimsDomains(s.ctx, s.page, s.PageSize, s.status)
+		if err != nil {
+			s.err = err
+			return false
+		}
+
+		s.items = items
+		s.index = 0
+		s.total = total
+
+		if len(items) == 0 {
+			s.done = true
+			return false
+		}
+	}
+}
+
+// Item returns the current claim. It should only be called after a call to
+// Next has returned true.
+func (s *ClaimsIterator) Item() Claim {
+	return s.item
+}
+
+// Err returns the error, if any, which caused Next to return false.
+func (s *ClaimsIterator) Err() error {
+	return s.err
+}
+
+// Total returns the total count of claims as reported by HVCA in the most
+// recently fetched page. It returns zero before the first call to Next.
+func (s *ClaimsIterator) Total() int64 {
+	return s.total
+}
@@ -0,0 +1,81 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+func TestClaimsIteratorCappedPageSize(t *testing.T) {
+	t.Parallel()
+
+	const total = 5
+	const maxPerPage = 2
+
+	// Return fewer claims per page than requested, as HVCA does when the
+	// requested page size exceeds its maximum.
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+		var perPage, _ = strconv.Atoi(r.URL.Query().Get("per_page"))
+		if perPage > maxPerPage {
+			perPage = maxPerPage
+		}
+
+		var items = []map[string]interface{}{}
+		for i := (page - 1) * perPage; i < page*perPage && i < total; i++ {
+			items = append(items, map[string]interface{}{
+				"id":         strconv.Itoa(i + 1),
+				"status":     "PENDING",
+				"domain":     "example.com",
+				"created_at": 1600000000,
+				"expires_at": 1700000000,
+				"assert_by":  1600086400,
+				"log":        []interface{}{},
+			})
+		}
+
+		w.Header().Set("Total-Count", strconv.Itoa(total))
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+		json.NewEncoder(w).Encode(items)
+	}))
+	defer server.Close()
+
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+	var iter = clnt.ClaimsIterator(context.Background(), StatusPending)
+
+	var got []string
+	for iter.Next() {
+		got = append(got, iter.Item().ID)
+	}
+
+	if err := iter.Err(); err != nil {
+		t.Fatalf("failed to iterate: %v", err)
+	}
+
+	var want = []string{"1", "2", "3", "4", "5"}
+	if !cmp.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -356,6 +356,36 @@ func TestClaimUnmarshalJSON(t *testing.T) {
 	}
 }
 
+func TestClaimExpired(t *testing.T) {
+	t.Parallel()
+
+	var expiry = time.Date(2021, 6, 17, 22, 7, 4, 0, time.UTC)
+
+	var testcases = []struct {
+		name  string
+		claim hvclient.Claim
+		at    time.Time
+		want  bool
+	}{
+		{"Before", hvclient.Claim{ExpiresAt: expiry}, expiry.Add(-time.Second), false},
+		{"At", hvclient.Claim{ExpiresAt: expiry}, expiry, true},
+		{"After", hvclient.Claim{ExpiresAt: expiry}, expiry.Add(time.Second), true},
+		{"NoExpiry", hvclient.Claim{}, expiry, false},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.claim.Expired(tc.at); got != tc.want {
+				t.Errorf("got %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestClaimStatusStringInvalidValue(t *testing.T) {
 	var want = "ERROR: UNKNOWN STATUS"
 
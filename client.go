@@ -19,9 +19,9 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
-
-	// "errors"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -29,9 +29,11 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/vsglobalsign/hvclient/internal/httputils"
+	"golang.org/x/sync/singleflight"
 )
 
 // Client is a fully-featured client through which HVCA API calls can be made.
@@ -42,13 +44,26 @@ import (
 //
 // The user does not need to explicitly login. The client object will log the
 // user in automatically, and refresh their login if the authentication token
-// expires. In the event of a HTTP 503 service unavailable response, or a
+// expires. In the event of a HTTP 503 service unavailable response, a
 // response indicating that a request has been accepted but the corresponding
-// resource is not yet available, the client will automatically wait and retry
-// the call a predetermined number of times. The maximum wait time for this
-// process may be controlled through the context passed to each API call.
+// resource is not yet available, or a transient network error, the client
+// will automatically wait and retry idempotent calls as directed by the
+// retry policy in the configuration. The maximum wait time for this process
+// may be controlled through the context passed to each API call.
 //
-// It is safe to make concurrent API calls from a single client object.
+// A single client is safe for concurrent use by multiple goroutines, and
+// is intended to be shared. All state shared between API calls, including
+// the authentication token and its expiry, the certificate cache and the
+// cached CRL, trust chain and validation policy, is synchronized
+// internally, and concurrent logins are serialized so that only one is made
+// at a time. The exported
+// Token and LastLogin fields are guarded by TokenMtx, and should not be
+// accessed directly while the client is in use; GetToken,
+// TokenWithExpiry, SetToken and SetTokenWithExpiry should be used instead.
+// The configuration, HTTP client and base URL must not be modified once the
+// client has been created. Values returned by the client, such as a
+// CertIterator, are not themselves safe for concurrent use unless their
+// documentation says otherwise.
 type Client struct {
 	BaseURL       *url.URL
 	HTTPClient    *http.Client
@@ -56,22 +71,106 @@ type Client struct {
 	Token         string
 	LastLogin     time.Time
 	TokenMtx      sync.RWMutex
-	LoginMtx      sync.Mutex
 	ClientProfile *ClientProfile
-}
 
-const (
-	// numberOfRetries is the number of times to retry a request.
-	numberOfRetries = 5
-
-	// Initial time to wait before retrying. Subsequent retries will be more
-	// widely spaced
-	retryWaitDuration = time.Second
-)
+	// Deprecated: LoginMtx is no longer used to serialize logins, which
+	// are serialized by a context-aware semaphore instead.
+	LoginMtx sync.Mutex
+
+	// loginSem is a semaphore with a capacity of one which ensures only
+	// one goroutine at a time can login. It is created on first use.
+	loginSem     chan struct{}
+	loginSemOnce sync.Once
+
+	// tokenExpiry is the time at which the stored authentication token
+	// expires, according to HVCA's clock as estimated by serverTime. Access
+	// is synchronized by TokenMtx.
+	tokenExpiry time.Time
+
+	// refreshCancel stops the background token refresher, and refreshDone
+	// is closed when it has exited. Both are nil if the background token
+	// refresher is not running.
+	refreshCancel context.CancelFunc
+	refreshDone   chan struct{}
+	closeOnce     sync.Once
+
+	// closed is set when the client is closed.
+	closed atomic.Bool
+
+	// closing is closed when the client is closed, cancelling any API
+	// calls in progress. It is created on first use.
+	closing     chan struct{}
+	closingOnce sync.Once
+
+	// certCache caches retrieved certificates, and is created on first use
+	// if caching is enabled in the configuration.
+	certCache     *certCache
+	certCacheOnce sync.Once
+
+	// retrieveGroup coalesces concurrent retrievals of the same
+	// certificate into a single API call.
+	retrieveGroup singleflight.Group
+
+	// crl is the most recently downloaded CRL, if it has not passed its
+	// next update time. Access is synchronized by crlMtx, which is not held
+	// while downloading. crlGroup coalesces concurrent downloads.
+	crl      *x509.RevocationList
+	crlMtx   sync.Mutex
+	crlGroup singleflight.Group
+
+	// sharedHTTPClient is the HTTP client passed to NewThinClient, if any.
+	// Like Config.HTTPClient, its connections are left for the caller to
+	// manage.
+	sharedHTTPClient *http.Client
+
+	// crlClient is the HTTP client used to download CRLs, which is created
+	// on first use by crlHTTPClient.
+	crlClient     *http.Client
+	crlClientOnce sync.Once
+
+	// trustChain is the most recently retrieved ordered trust chain, which
+	// is used until trustChainExpiry. Access is synchronized by
+	// trustChainMtx.
+	trustChain       []*x509.Certificate
+	trustChainExpiry time.Time
+	trustChainMtx    sync.Mutex
+
+	// policyJSON is the JSON encoding of the most recently retrieved
+	// validation policy, which is used until policyExpiry if policy caching
+	// is enabled. It is stored encoded so that each caller can be given its
+	// own copy. Access is synchronized by policyMtx.
+	policyJSON   []byte
+	policyExpiry time.Time
+	policyMtx    sync.Mutex
+
+	// profiles is the most recently retrieved list of issuance profiles,
+	// and profilesSupported records whether HVCA supports listing them. They
+	// are used by validateProfile until profilesExpiry. Access is
+	// synchronized by profilesMtx.
+	profiles          []Profile
+	profilesSupported bool
+	profilesExpiry    time.Time
+	profilesMtx       sync.Mutex
+
+	// clockOffset is the offset in nanoseconds between HVCA's clock and
+	// the local clock, as observed in the most recent response.
+	clockOffset atomic.Int64
+
+	// inFlight is the number of API calls currently in progress.
+	inFlight atomic.Int64
+
+	// trustChainResource and policyResource are the most recently
+	// retrieved trust chain and validation policy, which are retrieved
+	// again with conditional requests.
+	trustChainResource conditionalResource
+	policyResource     conditionalResource
+}
 
 // makeRequest sends an API request to the HVCA server. If out is non-nil,
 // the HTTP response body will be unmarshalled into it. In all code paths,
 // the response body will be fully consumed and closed before returning.
+// If a tracer or a metrics observer was provided in the configuration, the
+// request is traced or observed.
 func (c *Client) makeRequest(
 	ctx context.Context,
 	path string,
@@ -79,22 +178,93 @@ func (c *Client) makeRequest(
 	in interface{},
 	out interface{},
 ) (*http.Response, error) {
-	var retriesRemaining = numberOfRetries
+	if c.closed.Load() {
+		return nil, ErrClientClosed
+	}
+
+	c.observeInFlight(c.inFlight.Add(1))
+	defer func() { c.observeInFlight(c.inFlight.Add(-1)) }()
+
+	var parent = ctx
+	var cancel context.CancelFunc
+	ctx, cancel = c.withLifecycle(ctx)
+	defer cancel()
+
+	var span Span
+	ctx, span = c.startSpan(ctx, method, path)
+
+	var start = time.Now()
+	var response, err = c.doRequest(ctx, path, method, in, out)
+
+	// Report a call aborted by Close as such, rather than as cancelled.
+	if err != nil && c.closed.Load() && parent.Err() == nil {
+		err = ErrClientClosed
+	}
+
+	c.observeRequest(method, path, response, time.Since(start), err)
+	endSpan(span, path, response, err)
+
+	return response, err
+}
+
+// doRequest sends an API request to the HVCA server, retrying and logging
+// in again as necessary, as described for makeRequest.
+func (c *Client) doRequest(
+	ctx context.Context,
+	path string,
+	method string,
+	in interface{},
+	out interface{},
+) (*http.Response, error) {
+	var policy = retryPolicyFromContext(ctx, c.Config)
+	var attempt int
 	var response *http.Response
+	var logger = c.Config.logger()
+	var op = operationName(method, path)
+
+	// token is the authentication token sent with the current attempt, and
+	// relogged records whether we have already logged in again after HVCA
+	// rejected a token, so that we do so at most once per call.
+	var token string
+	var relogged bool
+
+	// release drains and closes the body of the current response, if any,
+	// and releases the context of the current attempt. It is called before
+	// retrying, so a failed attempt doesn't hold its connection open while
+	// waiting, and on return for the final attempt, whose response body
+	// must remain readable until then.
+	var cancelAttempt context.CancelFunc = func() {}
+	var release = func() {
+		if response != nil {
+			httputils.ConsumeAndCloseResponseBody(response)
+			response = nil
+		}
+
+		cancelAttempt()
+		cancelAttempt = func() {}
+	}
+	defer func() { release() }()
 
 	// Loop so we can retry requests if necessary.
-	for {
+	for ; ; attempt++ {
 		var body io.Reader
+		var data []byte
 		if in != nil {
-			var data, err = json.Marshal(in)
-			if err != nil {
+			var err error
+			if data, err = json.Marshal(in); err != nil {
 				return nil, fmt.Errorf("failed to marshal request body: %w", err)
 			}
 
 			body = bytes.NewReader(data)
 		}
 
-		var request, err = http.NewRequestWithContext(ctx, method, c.BaseURL.String()+path, body)
+		// Give each attempt its own deadline if a request timeout was
+		// specified. The context must remain live until the response body
+		// has been read, so it is cancelled by release.
+		var attemptCtx context.Context
+		attemptCtx, cancelAttempt = c.Config.requestContext(ctx)
+
+		var request, err = http.NewRequestWithContext(attemptCtx, method, c.BaseURL.String()+path, body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create new HTTP request: %w", err)
 		}
@@ -112,34 +282,159 @@ func (c *Client) makeRequest(
 			request.Header.Add(key, value)
 		}
 
+		c.Config.setUserAgent(request)
+
+		// Forward the caller's correlation ID, if any.
+		if c.Config.RequestIDFromContext != nil {
+			if id := c.Config.RequestIDFromContext(ctx); id != "" {
+				request.Header.Set(requestIDHeaderName, id)
+			}
+		}
+
+		// Make GET requests conditional on any validators in the context.
+		var validators, conditional = validatorsFromContext(ctx)
+		conditional = conditional && method == http.MethodGet
+		if conditional {
+			validators.setHeaders(request)
+		}
+
+		// Send any idempotency key only with certificate requests, and not
+		// with any other requests made with the same context.
+		if key, ok := idempotencyKeyFromContext(ctx); ok && method == http.MethodPost && path == endpointCertificates {
+			request.Header.Set(idempotencyKeyHeaderName, key)
+		}
+
 		// Perform specific processing for non-login requests.
 		if !strings.HasPrefix(path, endpointLogin) {
-			// Since this is not a login request, preemptively login again if
-			// the stored authentication token is believed to be expired.
-			err = c.loginIfTokenHasExpired(ctx)
-			if err != nil {
-				return nil, err
+			// Since this is not a login request, obtain the authentication
+			// token from the token source if there is one. Otherwise,
+			// preemptively login again if the stored authentication token
+			// is believed to be expired, or fail immediately if automatic
+			// login is disabled.
+			if c.Config.TokenSource != nil {
+				if token, err = c.Config.TokenSource.Token(ctx); err != nil {
+					return nil, err
+				}
+			} else {
+				if c.Config.DisableAutoLogin {
+					if c.tokenHasExpired() {
+						return nil, ErrTokenExpired
+					}
+				} else if err = c.loginIfTokenHasExpired(ctx); err != nil {
+					return nil, err
+				}
+
+				token = c.GetToken()
 			}
 
 			// Add the authentication token to all requests except login requests.
-			request.Header.Set(httputils.AuthorizationHeader, "Bearer "+c.GetToken())
+			request.Header.Set(httputils.AuthorizationHeader, "Bearer "+token)
+		}
+
+		// Wait for the rate limiter, if there is one, aborting if the context
+		// is done first.
+		if c.Config.RateLimiter != nil {
+			var waitStart = time.Now()
+			if err = c.Config.RateLimiter.Wait(ctx); err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return nil, ctxErr
+				}
+
+				return nil, fmt.Errorf("failed to wait for rate limiter: %w", err)
+			}
+
+			if waited := time.Since(waitStart); waited >= rateLimitLogThreshold {
+				logger.Log(ctx, LogLevelDebug, "waited for rate limiter",
+					logKeyOperation, op, logKeyElapsed, waited)
+			}
 		}
 
-		// Execute the request.
-		if response, err = c.HTTPClient.Do(request); err != nil {
+		// Execute the request, retrying on transient network errors if the
+		// retry policy allows it.
+		var attemptStart = time.Now()
+		response, err = c.HTTPClient.Do(request)
+		c.Config.logIfSlow(ctx, op, path, time.Since(attemptStart), response)
+
+		if err != nil {
+			c.callDebugTap(path, request, data, nil)
+
+			// Distinguish the request timeout from the caller's context
+			// being done, since only the former should be retried.
+			if attemptCtx.Err() != nil && ctx.Err() == nil {
+				err = requestTimeoutError{timeout: c.Config.RequestTimeout}
+			}
+
+			if attempt < policy.MaxRetries && policy.retryable(method, 0, err) {
+				release()
+
+				var delay = policy.delay(attempt)
+				logger.Log(ctx, LogLevelWarn, "retrying HVCA request after error",
+					logKeyOperation, op, logKeyAttempt, attempt+1, logKeyDelay, delay, logKeyError, err)
+
+				if err = sleepContext(ctx, delay); err != nil {
+					return nil, fmt.Errorf("failed to execute HTTP request: %w", err)
+				}
+
+				continue
+			}
+
+			logger.Log(ctx, LogLevelError, "HVCA request failed", logKeyOperation, op, logKeyError, err)
+
 			return nil, fmt.Errorf("failed to execute HTTP request: %w", err)
 		}
-		defer httputils.ConsumeAndCloseResponseBody(response)
 
-		// HVCA doesn't return any 3XX HTTP status codes, so treat everything outside
-		// of the 2XX range as an error. Also treat 202 status codes as "errors",
-		// because we want to retry in that event.
+		c.observeServerTime(response, time.Now())
+
+		// Bound the amount of the response body which will be read, both
+		// to unmarshal it and to drain it before closing.
+		response.Body = &limitedBody{
+			ReadCloser: response.Body,
+			remaining:  c.Config.maxResponseBytes(),
+		}
+
+		c.callDebugTap(path, request, data, response)
+
+		// A conditional request for a resource which has not been modified
+		// has no response body, and the caller uses its stored copy.
+		if conditional && response.StatusCode == http.StatusNotModified {
+			return response, nil
+		}
+
+		// HVCA doesn't return any 3XX HTTP status codes other than to
+		// conditional requests, so treat everything outside of the 2XX range
+		// as an error. Also treat 202 status codes as "errors", because we
+		// want to retry in that event.
 		if response.StatusCode < 200 || response.StatusCode > 299 || response.StatusCode == http.StatusAccepted {
 			var apiErr = NewAPIError(response)
 
+			var keyvals = []interface{}{logKeyOperation, op, logKeyStatus, apiErr.StatusCode}
+			if apiErr.Code != "" {
+				keyvals = append(keyvals, logKeyCode, apiErr.Code)
+			}
+			keyvals = append(keyvals, logKeyDescription, apiErr.Description)
+
+			logger.Log(ctx, responseLogLevel(apiErr.StatusCode), "unsuccessful HVCA response", keyvals...)
+
+			// Surface any delay requested by a rate limited response or
+			// during maintenance, so callers can back off even if they don't
+			// retry automatically.
+			var resultErr error = apiErr
+			var retryAfter, hasRetryAfter = policy.retryAfter(response, time.Now())
+			var maintenance = apiErr.isMaintenance()
+			switch {
+			case apiErr.StatusCode == http.StatusTooManyRequests:
+				resultErr = RateLimitError{APIError: apiErr, retryAfter: retryAfter}
+			case maintenance:
+				resultErr = ServiceUnavailableError{APIError: apiErr, retryAfter: retryAfter}
+			}
+
+			// The error has been read from the response, so release it
+			// before any retry.
+			release()
+
 			// Depending on the status code, we may want to retry the request.
-			switch apiErr.StatusCode {
-			case http.StatusUnauthorized:
+			switch {
+			case apiErr.StatusCode == http.StatusUnauthorized:
 				// If we get an unauthorized status from a login request
 				// then we just have bad login credentials. This is a
 				// fatal error, so just stop and return it.
@@ -147,36 +442,75 @@ func (c *Client) makeRequest(
 					return nil, apiErr
 				}
 
+				// If the token came from a token source, tell the source it
+				// was rejected, if it can be told, and retry the original
+				// request once with a new token from the source.
+				if c.Config.TokenSource != nil {
+					var invalidator, ok = c.Config.TokenSource.(TokenInvalidator)
+					if !ok || relogged {
+						return nil, apiErr
+					}
+
+					relogged = true
+					invalidator.InvalidateToken(token)
+
+					break
+				}
+
+				// If automatic login is disabled, forget the rejected token
+				// so subsequent calls fail immediately, and leave it to the
+				// caller to login again.
+				if c.Config.DisableAutoLogin {
+					c.tokenReset()
+					return nil, tokenRejectedError{err: apiErr}
+				}
+
+				// Give up if HVCA rejected the token we obtained by logging
+				// in again, since logging in again won't help.
+				if relogged {
+					return nil, apiErr
+				}
+
 				// Otherwise, the token may have expired, so attempt to login
-				// again, and retry the original request on success. Note that
-				// this should be unusual, since we checked whether the token
-				// had expired before executing this request. However, since
-				// HVCA doesn't return information about the actual lifetime
-				// of the token, we're having to assume that the currently
-				// documented token lifetime will remain the same. If the
-				// lifetime ever is shortened, this will act as a safeguard
-				// and prevent otherwise fatal failures that a reactive
-				// re-login could easily resolve.
-				var err = c.login(ctx)
-				if err != nil {
+				// again, and retry the original request once on success.
+				// Note that this should be unusual, since we checked whether
+				// the token had expired before executing this request.
+				// However, since HVCA doesn't return information about the
+				// actual lifetime of the token, we're having to assume that
+				// the currently documented token lifetime will remain the
+				// same, and the token may also be invalidated by HVCA or
+				// appear to be valid due to clock skew. This acts as a
+				// safeguard and prevents otherwise fatal failures that a
+				// reactive re-login could easily resolve.
+				relogged = true
+
+				if err := c.reloginIfTokenRejected(ctx, token); err != nil {
 					return nil, err
 				}
 
-			case http.StatusServiceUnavailable, http.StatusAccepted:
-				// Return the error if we're out of retries.
-				if retriesRemaining <= 0 {
-					return nil, apiErr
+			case attempt < policy.MaxRetries && policy.retryable(method, apiErr.StatusCode, nil):
+				// Pause for a progressively increasing period of time before
+				// retrying, or for as long as the server asked, giving up
+				// early if the context is done. Back off for longer during
+				// maintenance, which won't be over in a few seconds.
+				var delay = policy.delay(attempt)
+				switch {
+				case hasRetryAfter:
+					delay = retryAfter
+				case maintenance:
+					delay = policy.maintenanceDelay()
 				}
 
-				// Otherwise we want to retry, so decrement the number of
-				// remaining retries and pause for a progressively increasing
-				// period of time.
-				retriesRemaining--
-				time.Sleep(retryWaitDuration * time.Duration((numberOfRetries - retriesRemaining)))
+				logger.Log(ctx, LogLevelWarn, "retrying HVCA request",
+					logKeyOperation, op, logKeyAttempt, attempt+1, logKeyDelay, delay, logKeyStatus, apiErr.StatusCode)
+
+				if err = sleepContext(ctx, delay); err != nil {
+					return nil, retryAbortedError{err: err, last: resultErr}
+				}
 
 			default:
 				// Return the error on any other status code.
-				return nil, apiErr
+				return nil, resultErr
 			}
 
 			// Continue around the loop to retry the request.
@@ -192,6 +526,12 @@ func (c *Client) makeRequest(
 		return response, nil
 	}
 
+	// Raw requests accept an empty response body, such as from a HTTP 201
+	// created response, which leaves the raw message nil.
+	if _, ok := out.(*json.RawMessage); ok && response.ContentLength == 0 {
+		return response, nil
+	}
+
 	// All response bodies from successful HVCA requests have a JSON content
 	// type, so verify that's what we have before reading the body.
 	var err = httputils.VerifyResponseContentType(response, httputils.ContentTypeJSON)
@@ -214,6 +554,75 @@ func (c *Client) makeRequest(
 	return response, nil
 }
 
+// ErrResponseTooLarge is returned when an HTTP response body from HVCA
+// exceeds the maximum size specified in the configuration.
+var ErrResponseTooLarge = errors.New("hvclient: response body too large")
+
+// limitedBody is an HTTP response body which returns ErrResponseTooLarge
+// if it contains more than a maximum number of bytes. Unlike an
+// io.LimitReader, it distinguishes a body which exceeds the limit from one
+// which ends exactly at it.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+// Read reads from the response body, returning ErrResponseTooLarge if the
+// body contains any data beyond the limit.
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		var probe [1]byte
+		var n, err = b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+
+		return 0, err
+	}
+
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+
+	var n, err = b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+
+	return n, err
+}
+
+// requestTimeoutError is the error returned when a single HTTP round trip
+// exceeds the request timeout specified in the configuration. It is a
+// net.Error which reports a timeout, so it is retried by DefaultRetryable.
+type requestTimeoutError struct {
+	timeout time.Duration
+}
+
+// Error returns a string representation of the error.
+func (e requestTimeoutError) Error() string {
+	return fmt.Sprintf("request timed out after %v", e.timeout)
+}
+
+// Timeout returns true, since the error is a timeout.
+func (e requestTimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary returns true, since a later attempt may succeed.
+func (e requestTimeoutError) Temporary() bool {
+	return true
+}
+
+// requestContext returns a child context for a single HTTP round trip, with
+// the request timeout specified in the configuration, if any. If the parent
+// context has an earlier deadline, that deadline applies instead.
+func (c *Config) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.RequestTimeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, c.RequestTimeout)
+}
+
 // DefaultTimeout returns the timeout specified in the configuration object or
 // file used to create the client, or the default timeout provided if no value
 // was specified. This is useful for honoring the timeout requested by the
@@ -223,58 +632,131 @@ func (c *Client) DefaultTimeout() time.Duration {
 	return c.Config.Timeout
 }
 
+// ErrClientClosed is returned by API calls made after the client has been
+// closed, and by API calls which were in progress when it was closed.
+var ErrClientClosed = errors.New("hvclient: client closed")
+
+// Close releases any resources held by the client, stopping the background
+// token refresher if one was started, and closing any idle connections
+// unless the HTTP client was provided in the configuration or to
+// NewThinClient, in which case
+// its connections are left for the caller to manage. API calls in progress
+// are cancelled, as if their contexts were cancelled, and fail promptly with
+// ErrClientClosed, as do API calls made after Close has been called. It is
+// safe to call Close more than once.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		c.closed.Store(true)
+		close(c.closingChan())
+		c.stopAutoRefresh()
+		c.invalidatePolicy()
+		c.trustChainResource.reset()
+
+		if c.HTTPClient != nil && c.callerHTTPClient() == nil {
+			c.HTTPClient.CloseIdleConnections()
+		}
+
+		if c.Config != nil && c.callerHTTPClient() == nil {
+			c.crlHTTPClient().CloseIdleConnections()
+		}
+	})
+
+	return nil
+}
+
+// callerHTTPClient returns the HTTP client provided by the caller, either in
+// the configuration or to NewThinClient, or nil if the client built its own.
+func (c *Client) callerHTTPClient() *http.Client {
+	if c.sharedHTTPClient != nil {
+		return c.sharedHTTPClient
+	}
+
+	if c.Config != nil {
+		return c.Config.HTTPClient
+	}
+
+	return nil
+}
+
+// closingChan returns the channel which is closed when the client is closed.
+func (c *Client) closingChan() chan struct{} {
+	c.closingOnce.Do(func() {
+		c.closing = make(chan struct{})
+	})
+
+	return c.closing
+}
+
+// withLifecycle returns a copy of the context which is also cancelled when
+// the client is closed, and a function which must be called to release its
+// resources when the API call is complete.
+func (c *Client) withLifecycle(ctx context.Context) (context.Context, context.CancelFunc) {
+	var closing = c.closingChan()
+	var callCtx, cancel = context.WithCancel(ctx)
+
+	go func() {
+		select {
+		case <-closing:
+			cancel()
+		case <-callCtx.Done():
+		}
+	}()
+
+	return callCtx, cancel
+}
+
 // NewThinClient creates a new client with no initial login client and a custom
-// http client to facilitate re-use between hvclients.
+// http client to facilitate re-use between hvclients. If httpClient is not
+// nil, it is used in the same way as Config.HTTPClient, taking precedence
+// over it, and its connections are left for the caller to manage. Otherwise,
+// the HTTP client built from the configuration keeps up to 1024 idle
+// connections open, unless the configuration specifies otherwise.
 func NewThinClient(profile *ClientProfile, httpClient *http.Client) (*Client, error) {
+	var err error
+	if profile.Config.url, err = url.Parse(profile.Config.URL); err != nil {
+		return nil, err
+	}
 
-	// Build an HTTP transport using any proxy settings from the environment.
-	// Experimentation suggests that the other values seem to reasonably
-	// maximally encourage the sharing of TCP connections.
-	var tnspt = &http.Transport{
-		MaxIdleConnsPerHost: 1024,
-		MaxIdleConns:        1024,
-		MaxConnsPerHost:     1024,
-		Proxy:               http.ProxyFromEnvironment,
+	var base = httpClient
+	if base == nil {
+		base = profile.Config.HTTPClient
 	}
 
-	profile.Config.url, _ = url.Parse(profile.Config.URL)
+	var hc *http.Client
+	if hc, err = profile.Config.newHTTPClientFrom(base); err != nil {
+		return nil, err
+	}
 
-	if profile.Config.url.Scheme == "https" {
-		// Populate TLS client certificates only if one was provided.
-		var tlsCerts []tls.Certificate
-		if profile.Config.TLSCert != nil {
-			tlsCerts = []tls.Certificate{
-				{
-					Certificate: [][]byte{profile.Config.TLSCert.Raw},
-					PrivateKey:  profile.Config.TLSKey,
-					Leaf:        profile.Config.TLSCert,
-				},
-			}
+	// Thin clients are typically created in large numbers to share
+	// connections, so the transport built by this package encourages that
+	// sharing as much as the configuration allows.
+	if base == nil {
+		var tnspt = hc.Transport.(*http.Transport)
+		if profile.Config.MaxIdleConns == 0 {
+			tnspt.MaxIdleConns = thinClientMaxIdleConns
 		}
-
-		tnspt.TLSClientConfig = &tls.Config{
-			RootCAs:            profile.Config.TLSRoots,
-			Certificates:       tlsCerts,
-			InsecureSkipVerify: profile.Config.InsecureSkipVerify,
+		if profile.Config.MaxIdleConnsPerHost == 0 {
+			tnspt.MaxIdleConnsPerHost = thinClientMaxIdleConns
 		}
 	}
 
 	// Build a new client.
 	var newClient = Client{
-		Config:        profile.Config,
-		Token:         profile.Token,
-		BaseURL:       profile.Config.url,
-		HTTPClient:    &http.Client{Transport: tnspt},
-		ClientProfile: profile,
-		// }
+		Config:           profile.Config,
+		Token:            profile.Token,
+		BaseURL:          profile.Config.url,
+		HTTPClient:       hc,
+		ClientProfile:    profile,
+		sharedHTTPClient: httpClient,
 	}
 
 	return &newClient, nil
 }
 
 // NewClient creates a new HVCA client from a configuration object. An initial
-// login is made, and the returned client is immediately ready to make API
-// calls.
+// login is made, unless an unexpired initial token or a token source was
+// provided in the configuration, and the returned client is immediately ready
+// to make API calls.
 func NewClient(ctx context.Context, conf *Config) (*Client, error) {
 	// Validate configuration object before continuing.
 	var err = conf.Validate()
@@ -282,52 +764,158 @@ func NewClient(ctx context.Context, conf *Config) (*Client, error) {
 		return nil, err
 	}
 
-	// Build an HTTP transport using any proxy settings from the environment.
-	// Experimentation suggests that the other values seem to reasonably
-	// maximally encourage the sharing of TCP connections.
-	var tnspt = &http.Transport{
-		MaxIdleConnsPerHost: 1024,
-		MaxIdleConns:        1024,
-		MaxConnsPerHost:     1024,
-		Proxy:               http.ProxyFromEnvironment,
+	var hc *http.Client
+	if hc, err = conf.newHTTPClient(); err != nil {
+		return nil, err
 	}
 
-	if conf.url.Scheme == "https" {
-		// Populate TLS client certificates only if one was provided.
-		var tlsCerts []tls.Certificate
-		if conf.TLSCert != nil {
-			tlsCerts = []tls.Certificate{
-				{
-					Certificate: [][]byte{conf.TLSCert.Raw},
-					PrivateKey:  conf.TLSKey,
-					Leaf:        conf.TLSCert,
-				},
-			}
-		}
-
-		tnspt.TLSClientConfig = &tls.Config{
-			RootCAs:            conf.TLSRoots,
-			Certificates:       tlsCerts,
-			InsecureSkipVerify: conf.InsecureSkipVerify,
-		}
-	}
+	conf.warnIfMTLSCertificateExpiring(ctx, time.Now())
 
 	// Build a new client.
 	var newClient = Client{
 		Config:     conf,
 		BaseURL:    conf.url,
-		HTTPClient: &http.Client{Transport: tnspt},
+		HTTPClient: hc,
 	}
 
-	// Perform the initial login and return the new client.
-	err = newClient.login(ctx)
-	if err != nil {
-		return nil, err
+	// Tokens are obtained from the token source as needed, if there is
+	// one.
+	if conf.TokenSource != nil {
+		return &newClient, nil
+	}
+
+	// Use the initial token if one was provided, and perform the initial
+	// login if it was not, or if it has already expired.
+	if conf.InitialToken != "" {
+		newClient.SetTokenWithExpiry(conf.InitialToken, conf.InitialTokenExpiry)
+	}
+
+	if newClient.tokenHasExpired() {
+		if err = newClient.login(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.AutoRefresh {
+		newClient.startAutoRefresh()
 	}
 
 	return &newClient, nil
 }
 
+// newHTTPClient returns the HTTP client to use for requests to HVCA. If the
+// configuration contains a custom HTTP client, a shallow copy of it is
+// returned, with the mTLS certificate and any server certificate pins added
+// to a copy of its transport if they were provided. Otherwise, a new HTTP
+// client is built from the TLS settings in the configuration.
+func (c *Config) newHTTPClient() (*http.Client, error) {
+	return c.newHTTPClientFrom(c.HTTPClient)
+}
+
+// newHTTPClientFrom returns the HTTP client to use for requests to HVCA, as
+// for newHTTPClient, but using the specified custom HTTP client, if any, in
+// place of the one in the configuration.
+func (c *Config) newHTTPClientFrom(custom *http.Client) (*http.Client, error) {
+	// Populate TLS client certificates only if one was provided.
+	var tlsCerts []tls.Certificate
+	if c.TLSCertificate != nil {
+		tlsCerts = []tls.Certificate{*c.TLSCertificate}
+	} else if c.TLSCert != nil {
+		tlsCerts = []tls.Certificate{
+			{
+				Certificate: [][]byte{c.TLSCert.Raw},
+				PrivateKey:  c.TLSKey,
+				Leaf:        c.TLSCert,
+			},
+		}
+	}
+
+	// Check any server certificate pins once the usual verification, if
+	// any, has succeeded.
+	var verifyConnection func(tls.ConnectionState) error
+	if len(c.ServerCertPins) > 0 {
+		verifyConnection = c.verifyServerCertPin
+	}
+
+	if custom != nil {
+		if tlsCerts == nil && verifyConnection == nil {
+			return custom, nil
+		}
+
+		var rt = custom.Transport
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+
+		var tnspt, ok = rt.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("cannot configure TLS for HTTP client transport of type %T", rt)
+		}
+
+		// Clone also clones any TLS configuration, but the certificates
+		// slice is copied so the caller's backing array is never shared.
+		tnspt = tnspt.Clone()
+		if tnspt.TLSClientConfig == nil {
+			tnspt.TLSClientConfig = &tls.Config{}
+		}
+		if tlsCerts != nil {
+			tnspt.TLSClientConfig.Certificates = append(
+				append([]tls.Certificate(nil), tnspt.TLSClientConfig.Certificates...), tlsCerts...)
+		}
+
+		// Any existing connection verification is preserved.
+		if prev := tnspt.TLSClientConfig.VerifyConnection; verifyConnection != nil && prev != nil {
+			tnspt.TLSClientConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+				if err := prev(cs); err != nil {
+					return err
+				}
+
+				return verifyConnection(cs)
+			}
+		} else if verifyConnection != nil {
+			tnspt.TLSClientConfig.VerifyConnection = verifyConnection
+		}
+
+		var hc = *custom
+		hc.Transport = tnspt
+
+		return &hc, nil
+	}
+
+	// Build an HTTP transport using any proxy settings from the environment,
+	// keeping idle connections open according to the configuration so they
+	// can be reused without repeating the TLS handshake, and bounding the
+	// time taken to establish new ones.
+	var tnspt = &http.Transport{
+		DialContext:         c.dialer().DialContext,
+		MaxIdleConns:        c.maxIdleConns(),
+		MaxIdleConnsPerHost: c.maxIdleConnsPerHost(),
+		IdleConnTimeout:     c.idleConnTimeout(),
+		MaxConnsPerHost:     1024,
+		Proxy:               http.ProxyFromEnvironment,
+	}
+
+	// A custom TLS configuration disables HTTP/2 unless it is explicitly
+	// attempted, and an empty map of protocol upgrades ensures it is never
+	// negotiated.
+	if c.ForceHTTP1 {
+		tnspt.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	} else {
+		tnspt.ForceAttemptHTTP2 = true
+	}
+
+	if c.url.Scheme == "https" {
+		tnspt.TLSClientConfig = &tls.Config{
+			RootCAs:            c.TLSRoots,
+			Certificates:       tlsCerts,
+			InsecureSkipVerify: c.InsecureSkipVerify,
+			VerifyConnection:   verifyConnection,
+		}
+	}
+
+	return &http.Client{Transport: tnspt}, nil
+}
+
 // NewClientFromFile returns a new HVCA client from a configuration file. An
 // initial login is made, and the returned client is immediately ready to make
 // API calls.
@@ -18,12 +18,14 @@ package hvclient
 import (
 	"context"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"math/big"
 	"net/http"
 	"net/url"
+	"path"
 	"time"
 )
 
@@ -68,36 +70,35 @@ type SOAResults struct {
 	Errors []string `json:"errors,omitempty"`
 }
 
-// RevocationReason is a type for specifying the reason why a certificate is being
-// revoked when requesting revocation.
-type RevocationReason string
-
-// Revocation reasons to provide when revoking a certificate and providing a
-// reason for its revocation.
-const (
-	RevocationReasonUnspecified          = RevocationReason("unspecified")
-	RevocationReasonAffiliationChanged   = RevocationReason("affiliationChanged")
-	RevocationReasonKeyCompromise        = RevocationReason("keyCompromise")
-	RevocationReasonSuperseded           = RevocationReason("superseded")
-	RevocationReasonCessationOfOperation = RevocationReason("cessationOfOperation")
-	RevocationReasonPrivilegeWithdrawn   = RevocationReason("privilegeWithdrawn")
-)
-
 const (
 	// certSNHeaderName is the name of the HTTP header in which the
 	// URL of a certificate can be found.
 	certSNHeaderName = "Location"
 
+	// dateHeaderName is the name of the HTTP header in which the time at
+	// which HVCA generated a response can be found.
+	dateHeaderName = "Date"
+
 	// claimLocationHeaderName is the name of the HTTP header in which the
 	// URL of a claim can be found.
 	claimLocationHeaderName = "Location"
 
+	// requestIDHeaderName is the name of the HTTP header in which an
+	// identifier for an API request may be found.
+	requestIDHeaderName = "X-Request-ID"
+
+	// retryAfterHeaderName is the name of the HTTP header in which the time
+	// to wait before retrying a rate limited request can be found.
+	retryAfterHeaderName = "Retry-After"
+
 	// totalCountHeaderName is the name of the HTTP header in which a total
 	// count field can be found.
 	totalCountHeaderName = "Total-Count"
 )
 
-// HVCA API endpoints.
+// HVCA API endpoints. endpointPolicies and endpointProfiles are not part of
+// the documented HVCA API, and callers fall back or skip their checks when
+// HVCA responds to them with 404 Not Found.
 const (
 	endpointCertificates                = "/certificates"
 	endpointClaimsDomains               = "/claims/domains"
@@ -109,20 +110,152 @@ const (
 	endpointStatsRevoked                = "/stats/revoked"
 	endpointTrustChain                  = "/trustchain"
 	endpointPolicy                      = "/validationpolicy"
+	endpointPolicies                    = "/validationpolicies"
+	endpointProfiles                    = "/profiles"
 	pathReassert                        = "/reassert"
 	pathDNS                             = "/dns"
 	pathHTTP                            = "/http"
 	pathEmail                           = "/email"
 )
 
+// CertificateRequestResult is the result of a successful certificate
+// request.
+type CertificateRequestResult struct {
+	// Serial is the serial number of the new certificate. It is nil if the
+	// location of the new certificate does not end with a serial number.
+	Serial *big.Int
+
+	// Location is the absolute URL of the new certificate, which may be
+	// persisted to retrieve the certificate later.
+	Location *url.URL
+
+	// RequestID is the identifier assigned to the request by HVCA, if one
+	// was returned.
+	RequestID string
+}
+
 // CertificateRequest requests a new certificate based. The HVCA API is
 // asynchronous, and on success this method returns the serial number of
 // the new certificate. After a short delay, the certificate itself may be
-// retrieved via the CertificateRetrieve method.
+// retrieved via the CertificateRetrieve method. If the request selects a
+// profile, an error matching ErrUnknownProfile is returned without making a
+// request if the profile is not among those returned by Profiles. The list of
+// profiles is cached as described for Config.PolicyCacheTTL.
 func (c *Client) CertificateRequest(
 	ctx context.Context,
 	req *Request,
 ) (*big.Int, error) {
+	var result, err = c.CertificateRequestWithResult(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Serial == nil {
+		return nil, fmt.Errorf("invalid serial number returned: %s", path.Base(result.Location.Path))
+	}
+
+	return result.Serial, nil
+}
+
+// CertificateRequestFromCSR requests a new certificate with the specified
+// validity period for a PEM-encoded PKCS#10 certificate signing request, in
+// the same way as CertificateRequest. The signature on the CSR is verified
+// before the request is made.
+//
+// The CSR itself is sent to HVCA in place of a public key, but HVCA examines
+// only its public key and signature, so the subject distinguished name and
+// subject alternative names in the CSR are also copied into the request as
+// described for RequestFromCSR, subject to any options provided. Any other
+// extensions requested in the CSR are not honored, since the contents of the
+// certificate are determined by the account's validation policy, unless the
+// WithCustomExtensionsFromCSR option is provided, in which case they are
+// requested as custom extensions and must be allowed by that policy.
+func (c *Client) CertificateRequestFromCSR(
+	ctx context.Context,
+	csrPEM []byte,
+	validity *Validity,
+	opts ...RequestOption,
+) (*big.Int, error) {
+	var csr, err = parseCSRPEM(csrPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	var req *Request
+	if req, err = RequestFromCSR(csr, opts...); err != nil {
+		return nil, err
+	}
+
+	req.Validity = validity
+	req.PublicKey = nil
+	req.CSR = csr
+
+	return c.CertificateRequest(ctx, req)
+}
+
+// CertificateRequestWithResult requests a new certificate in the same way as
+// CertificateRequest, but returns the location of the new certificate and
+// any request ID returned by HVCA along with its serial number. If the
+// context carries an idempotency key added with WithIdempotencyKey, and an
+// IdempotencyStore was provided in the configuration, a result previously
+// recorded for the key is returned without making a new request.
+func (c *Client) CertificateRequestWithResult(
+	ctx context.Context,
+	req *Request,
+) (*CertificateRequestResult, error) {
+	var key, hasKey = idempotencyKeyFromContext(ctx)
+	if hasKey {
+		if err := validateIdempotencyKey(key); err != nil {
+			return nil, err
+		}
+
+		if c.Config.IdempotencyStore != nil {
+			var recorded, err = c.Config.IdempotencyStore.Get(ctx, key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get result for idempotency key: %w", err)
+			}
+
+			if recorded != nil {
+				return recorded, nil
+			}
+		}
+	}
+
+	var result, err = c.certificateRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasKey && c.Config.IdempotencyStore != nil {
+		if err = c.Config.IdempotencyStore.Put(ctx, key, result); err != nil {
+			return nil, fmt.Errorf("failed to record result for idempotency key: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// certificateRequest requests a new certificate and returns the result.
+func (c *Client) certificateRequest(
+	ctx context.Context,
+	req *Request,
+) (*CertificateRequestResult, error) {
+	// Convert a relative validity period to absolute times using HVCA's
+	// clock, without modifying the caller's request.
+	if req.Validity != nil && req.Validity.Duration != 0 {
+		if err := req.Validity.validate(); err != nil {
+			return nil, err
+		}
+
+		var abs = *req
+		abs.Validity = req.Validity.absolute(c.serverTime())
+		req = &abs
+	}
+
+	if err := c.validateProfile(ctx, req.Profile); err != nil {
+		return nil, err
+	}
+
 	var r, err = c.makeRequest(
 		ctx,
 		endpointCertificates,
@@ -134,38 +267,160 @@ func (c *Client) CertificateRequest(
 		return nil, err
 	}
 
-	var snString string
-	snString, err = basePathHeaderFromResponse(r, certSNHeaderName)
+	var location string
+	location, err = headerFromResponse(r, certSNHeaderName)
 	if err != nil {
 		return nil, err
 	}
 
-	var sn, ok = big.NewInt(0).SetString(snString, 16)
-	if !ok {
-		return nil, fmt.Errorf("invalid serial number returned: %s", snString)
+	var locURL *url.URL
+	locURL, err = url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate location returned: %w", err)
+	}
+
+	// Resolve a relative location against the URL of the request.
+	if r.Request != nil && r.Request.URL != nil {
+		locURL = r.Request.URL.ResolveReference(locURL)
+	}
+
+	var result = CertificateRequestResult{
+		Location:  locURL,
+		RequestID: r.Header.Get(requestIDHeaderName),
+	}
+
+	if sn, err := SerialFromString(path.Base(locURL.Path)); err == nil {
+		result.Serial = sn
+	}
+
+	return &result, nil
+}
+
+// CertificateRequestValidate checks whether a certificate request complies
+// with the account's validation policy, without requesting a certificate.
+// HVCA has no validate-only mode, so this is a local check: the validation
+// policy is retrieved from HVCA, and the request is checked against it as
+// described for Policy.Validate. No issuance quota is consumed, but since
+// HVCA remains the final arbiter, a request which passes this check may
+// still be rejected. If the request does not comply with the policy, a
+// PolicyViolationsError is returned. An error is also returned if the
+// request cannot be encoded, for example because an other name has an
+// unsupported value type.
+func (c *Client) CertificateRequestValidate(ctx context.Context, req *Request) error {
+	return c.CertificateRequestValidateWithPolicy(ctx, req, "")
+}
+
+// CertificateRequestValidateWithPolicy checks whether a certificate request
+// complies with the account's validation policy with the specified
+// identifier, as returned by Policies, in the same way as
+// CertificateRequestValidate. If the identifier is empty, the request is
+// checked against the account's primary policy.
+func (c *Client) CertificateRequestValidateWithPolicy(ctx context.Context, req *Request, policyID string) error {
+	if _, err := json.Marshal(req); err != nil {
+		return fmt.Errorf("invalid certificate request: %w", err)
+	}
+
+	var pol, err = c.PolicyByID(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	if violations := pol.Validate(req); len(violations) > 0 {
+		return PolicyViolationsError{Violations: violations}
 	}
 
-	return sn, nil
+	return nil
 }
 
-// CertificateRetrieve retrieves a certificate.
+// CertificateRetrieve retrieves a certificate. If a certificate cache is
+// enabled in the configuration, a cached copy is returned if one has not
+// expired, and the retrieved certificate is cached otherwise. Concurrent
+// retrievals of the same certificate share a single API call.
 func (c *Client) CertificateRetrieve(
 	ctx context.Context,
 	serial *big.Int,
 ) (*CertInfo, error) {
-	var r CertInfo
+	var key = SerialToString(serial)
+
+	var cache = c.cache()
+	if info, ok := cache.get(key, time.Now()); ok {
+		return info, nil
+	}
+
+	return c.retrieveShared(ctx, key)
+}
+
+// CertificateMeta returns the subject, serial number, validity period and
+// status of a certificate without fully parsing it. The status is derived as
+// for CertificateStatus. Certificates already in the client's certificate
+// cache are served from it, but results are not added to the cache, since
+// the certificate PEM is discarded.
+func (c *Client) CertificateMeta(
+	ctx context.Context,
+	serial *big.Int,
+) (*CertMeta, error) {
+	var key = SerialToString(serial)
+
+	if info, ok := c.cache().get(key, time.Now()); ok && info.X509 != nil {
+		var meta = certMetaFromX509(info.X509, info.Status, time.Now())
+		return &meta, nil
+	}
+
+	var data jsonCertInfo
 	var _, err = c.makeRequest(
 		ctx,
-		endpointCertificates+"/"+url.QueryEscape(fmt.Sprintf("%X", serial)),
+		endpointCertificates+"/"+url.QueryEscape(key),
 		http.MethodGet,
 		nil,
-		&r,
+		&data,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return &r, nil
+	var meta CertMeta
+	meta, err = certMetaFromPEM(data.PEM, data.Status, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &meta, nil
+}
+
+// CertificateStatus returns the status of a certificate, which is
+// StatusExpired if the certificate has been issued, has not been revoked, and
+// is past its expiry time. If the certificate has been revoked, the time at
+// which its status was last updated, which is the revocation time, is also
+// returned. HVCA does not provide a status-only endpoint, so the status is
+// derived from the response to a certificate retrieval.
+func (c *Client) CertificateStatus(
+	ctx context.Context,
+	serial *big.Int,
+) (CertStatus, time.Time, error) {
+	var info, err = c.CertificateRetrieve(ctx, serial)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return certStatusFromInfo(info, time.Now())
+}
+
+// certStatusFromInfo returns the status of a certificate at the specified
+// time, and its revocation time if it has been revoked.
+func certStatusFromInfo(info *CertInfo, now time.Time) (CertStatus, time.Time, error) {
+	switch info.Status {
+	case StatusRevoked:
+		return StatusRevoked, info.UpdatedAt, nil
+
+	case StatusIssued:
+		if info.X509 != nil && now.After(info.X509.NotAfter) {
+			return StatusExpired, time.Time{}, nil
+		}
+
+		return StatusIssued, time.Time{}, nil
+	}
+
+	return 0, time.Time{}, fmt.Errorf("unexpected certificate status: %v", info.Status)
 }
 
 // CertificateRevoke revokes a certificate.
@@ -173,19 +428,25 @@ func (c *Client) CertificateRevoke(
 	ctx context.Context,
 	serial *big.Int,
 ) error {
-	return c.CertificateRevokeWithReason(ctx, serial, RevocationReasonUnspecified, 0)
+	return c.CertificateRevokeWithReason(ctx, serial, ReasonUnspecified, 0)
 }
 
 // CertificateRevokeWithReason revokes a certificate with a specified reason
 // and UTC UNIX timestamp indicating when the private key was compromised if
 // supported by the HVCA server. A special case holds when time is 0 which
-// indicates that the current time should be used.
+// indicates that the current time should be used. An error is returned
+// without contacting HVCA if the reason is not supported by HVCA, such as
+// ReasonRemoveFromCRL.
 func (c *Client) CertificateRevokeWithReason(
 	ctx context.Context,
 	serial *big.Int,
 	reason RevocationReason,
 	time int64,
 ) error {
+	if !reason.isSupported() {
+		return fmt.Errorf("unsupported revocation reason: %s", reason)
+	}
+
 	type certificatePatch struct {
 		RevocationReason RevocationReason `json:"revocation_reason"`
 		RevocationTime   int64            `json:"revocation_time,omitempty"`
@@ -196,9 +457,15 @@ func (c *Client) CertificateRevokeWithReason(
 		RevocationTime:   time,
 	}
 
+	var key = SerialToString(serial)
+
+	// Remove any cached copy whether or not the revocation succeeds, since
+	// the certificate's status may have changed even if an error occurred.
+	defer c.cache().remove(key)
+
 	var _, err = c.makeRequest(
 		ctx,
-		endpointCertificates+"/"+url.QueryEscape(fmt.Sprintf("%X", serial)),
+		endpointCertificates+"/"+url.QueryEscape(key),
 		http.MethodPatch,
 		&patch,
 		nil,
@@ -208,16 +475,13 @@ func (c *Client) CertificateRevokeWithReason(
 }
 
 // TrustChain returns the chain of trust for the certificates issued
-// by the calling account.
+// by the calling account. If HVCA returned an entity tag or last
+// modification time with the trust chain, it is retrieved again with a
+// conditional request, and the previously retrieved chain is returned if it
+// has not been modified.
 func (c *Client) TrustChain(ctx context.Context) ([]*x509.Certificate, error) {
 	var chain []string
-	var _, err = c.makeRequest(
-		ctx,
-		endpointTrustChain,
-		http.MethodGet,
-		nil,
-		&chain,
-	)
+	var err = c.getConditional(ctx, endpointTrustChain, &c.trustChainResource, &chain)
 	if err != nil {
 		return nil, err
 	}
@@ -242,16 +506,45 @@ func (c *Client) TrustChain(ctx context.Context) ([]*x509.Certificate, error) {
 	return certs, nil
 }
 
-// Policy returns the calling account's validation policy.
+// TrustChainCerts returns the chain of trust for the certificates issued by
+// the calling account, ordered so that each certificate is followed by the
+// certificate which issued it, with the root certificate, if present, last.
+// An error is returned if the certificates do not form a single unbroken
+// chain.
+func (c *Client) TrustChainCerts(ctx context.Context) ([]*x509.Certificate, error) {
+	var certs, err = c.TrustChain(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return orderChain(certs)
+}
+
+// Policy returns the calling account's validation policy. If policy caching
+// is enabled in the configuration, a cached copy is returned if it has not
+// expired, and the retrieved policy is cached otherwise. The policy is
+// retrieved with a conditional request, as described for TrustChain, if
+// HVCA returned an entity tag or last modification time with it before.
 func (c *Client) Policy(ctx context.Context) (*Policy, error) {
+	if c.Config.PolicyCacheTTL <= 0 {
+		return c.retrievePolicy(ctx)
+	}
+
+	c.policyMtx.Lock()
+	defer c.policyMtx.Unlock()
+
+	if c.policyJSON != nil && time.Now().Before(c.policyExpiry) {
+		return decodePolicy(c.policyJSON)
+	}
+
+	return c.refreshPolicyLocked(ctx)
+}
+
+// retrievePolicy retrieves the calling account's validation policy from
+// HVCA.
+func (c *Client) retrievePolicy(ctx context.Context) (*Policy, error) {
 	var pol Policy
-	var _, err = c.makeRequest(
-		ctx,
-		endpointPolicy,
-		http.MethodGet,
-		nil,
-		&pol,
-	)
+	var err = c.getConditional(ctx, endpointPolicy, &c.policyResource, &pol)
 	if err != nil {
 		return nil, err
 	}
@@ -367,6 +660,37 @@ func (c *Client) statsCommon(
 	return stats, count, nil
 }
 
+// CountIssued returns the number of certificates which were issued during
+// the specified time window. It makes a single request, using the total count
+// which HVCA reports with each page of statistics, rather than retrieving
+// every certificate.
+func (c *Client) CountIssued(ctx context.Context, from, to time.Time) (int64, error) {
+	return c.statsCount(ctx, endpointStatsIssued, from, to)
+}
+
+// CountRevoked returns the number of certificates which were revoked during
+// the specified time window, in the same way as CountIssued.
+func (c *Client) CountRevoked(ctx context.Context, from, to time.Time) (int64, error) {
+	return c.statsCount(ctx, endpointStatsRevoked, from, to)
+}
+
+// CountExpiring returns the number of certificates which expire during the
+// specified time window, in the same way as CountIssued.
+func (c *Client) CountExpiring(ctx context.Context, from, to time.Time) (int64, error) {
+	return c.statsCount(ctx, endpointStatsExpiring, from, to)
+}
+
+// statsCount returns the total count reported by a /stats endpoint,
+// requesting a page of a single certificate to minimize the response size.
+func (c *Client) statsCount(ctx context.Context, path string, from, to time.Time) (int64, error) {
+	var _, count, err = c.statsCommon(ctx, path, 1, 1, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
 // ClaimsDomains returns a slice of either pending or verified domain claims
 // along with the total count of domain claims in either category. The total
 // count may be higher than the number of claims in the slice if the total
@@ -465,7 +789,9 @@ func (c *Client) ClaimDelete(ctx context.Context, id string) error {
 // ClaimDNS requests assertion of domain control using DNS once the appropriate
 // token has been placed in the relevant DNS records. A return value of false
 // indicates that the assertion request was created. A return value of true
-// indicates that domain control was verified.
+// indicates that domain control was verified. The DNS TXT record to publish
+// may be obtained with the DNSRecord method of the claim or of the assertion
+// information returned by ClaimSubmit or ClaimReassert.
 func (c *Client) ClaimDNS(ctx context.Context, id, authDomain string) (bool, error) {
 	var body interface{}
 	// The HVCA API documentation indicates that the request body is
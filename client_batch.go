@@ -0,0 +1,240 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// defaultBatchConcurrency is the maximum number of requests in a batch
+// operation which will be made concurrently, if not specified in the
+// configuration.
+const defaultBatchConcurrency = 5
+
+// BatchResult is the result of a batch operation on multiple certificates.
+type BatchResult struct {
+	// Reason is the revocation reason used for a batch revocation.
+	Reason RevocationReason
+
+	// Results contains the result for each certificate, in the same order
+	// as the serial numbers passed to the batch operation.
+	Results []BatchItemResult
+}
+
+// BatchItemResult is the result of an operation on a single certificate in a
+// batch operation.
+type BatchItemResult struct {
+	Serial *big.Int
+
+	// Err is nil if the operation succeeded. If the operation was never
+	// started because the context was done, Err is the context's error.
+	Err error
+}
+
+// Succeeded returns the serial numbers of the certificates for which the
+// operation succeeded.
+func (r *BatchResult) Succeeded() []*big.Int {
+	var serials []*big.Int
+
+	for _, item := range r.Results {
+		if item.Err == nil {
+			serials = append(serials, item.Serial)
+		}
+	}
+
+	return serials
+}
+
+// Failed returns the results for the certificates for which the operation
+// failed.
+func (r *BatchResult) Failed() []BatchItemResult {
+	var items []BatchItemResult
+
+	for _, item := range r.Results {
+		if item.Err != nil {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Err returns the error for the certificate with the specified serial number,
+// or nil if the operation for that certificate succeeded or if the serial
+// number was not part of the batch.
+func (r *BatchResult) Err(serial *big.Int) error {
+	for _, item := range r.Results {
+		if item.Serial.Cmp(serial) == 0 {
+			return item.Err
+		}
+	}
+
+	return nil
+}
+
+// CertificatesRevoke revokes multiple certificates with the specified reason,
+// making up to Config.BatchConcurrency requests concurrently. A failure to
+// revoke one certificate does not prevent the others from being revoked, and
+// the outcome for each certificate is reported in the returned BatchResult.
+// If the context is done before all revocations have been started, no
+// further revocations are started, and the context's error is returned along
+// with the BatchResult. If the reason is not supported by HVCA, an error is
+// returned without attempting any revocations. Call options may be used to
+// give the batch its own timeout or retry policy.
+func (c *Client) CertificatesRevoke(
+	ctx context.Context,
+	serials []*big.Int,
+	reason RevocationReason,
+	opts ...CallOption,
+) (*BatchResult, error) {
+	if !reason.isSupported() {
+		return nil, fmt.Errorf("unsupported revocation reason: %s", reason)
+	}
+
+	var result = BatchResult{
+		Reason:  reason,
+		Results: make([]BatchItemResult, len(serials)),
+	}
+
+	var cancel context.CancelFunc
+	ctx, cancel = withCallOptions(ctx, opts)
+	defer cancel()
+
+	var errs, ctxErr = runBatch(ctx, len(serials), c.Config.batchConcurrency(), func(i int) error {
+		return c.CertificateRevokeWithReason(ctx, serials[i], reason, 0)
+	})
+
+	for i, serial := range serials {
+		result.Results[i] = BatchItemResult{Serial: serial, Err: errs[i]}
+	}
+
+	return &result, ctxErr
+}
+
+// BatchIssueResult is the result of a single certificate request in a batch
+// issuance.
+type BatchIssueResult struct {
+	// Serial is the serial number of the new certificate, or nil if the
+	// request failed.
+	Serial *big.Int
+
+	// Err is nil if the request succeeded. If the request was never made
+	// because the context was done, Err is the context's error.
+	Err error
+}
+
+// CertificatesRequest requests multiple certificates, making up to the
+// specified number of requests concurrently, or up to
+// Config.BatchConcurrency if it is zero. Each request is subject to the rate
+// limiter in the configuration, if any, in the same way as a single call to
+// CertificateRequest. A failed request does not prevent the others from
+// being made, and the outcome of each request is reported in the returned
+// slice, in the same order as the requests. If the context is done before
+// all requests have been started, no further requests are started, and the
+// context's error is returned along with the results. Call options may be
+// used to give the batch its own timeout or retry policy.
+func (c *Client) CertificatesRequest(
+	ctx context.Context,
+	reqs []*Request,
+	concurrency int,
+	opts ...CallOption,
+) ([]BatchIssueResult, error) {
+	if concurrency < 0 {
+		return nil, errors.New("negative concurrency")
+	} else if concurrency == 0 {
+		concurrency = c.Config.batchConcurrency()
+	}
+
+	var cancel context.CancelFunc
+	ctx, cancel = withCallOptions(ctx, opts)
+	defer cancel()
+
+	var results = make([]BatchIssueResult, len(reqs))
+
+	var errs, ctxErr = runBatch(ctx, len(reqs), concurrency, func(i int) error {
+		var serial, err = c.CertificateRequest(ctx, reqs[i])
+		results[i].Serial = serial
+
+		return err
+	})
+
+	for i := range results {
+		results[i].Err = errs[i]
+	}
+
+	return results, ctxErr
+}
+
+// runBatch calls fn with each index from zero to n-1, with up to the
+// specified number of calls running concurrently, and returns the error
+// from each call. If the context is done before all calls have been
+// started, no further calls are started, the error for each call which was
+// not made is the context's error, and the context's error is also
+// returned.
+func runBatch(ctx context.Context, n, concurrency int, fn func(i int) error) ([]error, error) {
+	var errs = make([]error, n)
+	var sem = make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var ctxErr error
+
+	for i := 0; i < n; i++ {
+		// Check the context first, since select chooses randomly between
+		// ready cases.
+		if ctxErr == nil {
+			ctxErr = ctx.Err()
+		}
+
+		if ctxErr == nil {
+			select {
+			case <-ctx.Done():
+				ctxErr = ctx.Err()
+			case sem <- struct{}{}:
+			}
+		}
+
+		if ctxErr != nil {
+			errs[i] = ctxErr
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			errs[i] = fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return errs, ctxErr
+}
+
+// batchConcurrency returns the maximum number of concurrent requests to make
+// in a batch operation.
+func (c *Config) batchConcurrency() int {
+	if c.BatchConcurrency > 0 {
+		return c.BatchConcurrency
+	}
+
+	return defaultBatchConcurrency
+}
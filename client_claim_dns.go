@@ -0,0 +1,301 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dnsVerificationPrefix is prefixed to a domain claim token to give the
+// value of the DNS TXT record which HVCA checks when verifying the claim.
+const dnsVerificationPrefix = "_globalsign-domain-verification="
+
+// DNSRecord is a DNS TXT record which must be published to assert control of
+// a domain using DNS.
+type DNSRecord struct {
+	Name  string
+	Value string
+}
+
+// ClaimVerificationError is returned by ClaimSubmitAndAwait if a domain claim
+// could not be verified. Reason contains the description of the most recent
+// verification error recorded by HVCA in the claim log, if any.
+type ClaimVerificationError struct {
+	ID     string
+	Reason string
+	Err    error
+}
+
+// Error returns a string representation of the error.
+func (e ClaimVerificationError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("domain claim %s not verified: %v", e.ID, e.Err)
+	}
+
+	return fmt.Sprintf("domain claim %s not verified: %s: %v", e.ID, e.Reason, e.Err)
+}
+
+// Unwrap returns the underlying context or API error.
+func (e ClaimVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// DNSRecordPendingError is returned by ClaimAwaitDNS if the DNS TXT record
+// for a domain claim was not observed before the context was done. LookupErr
+// contains the error from the most recent failed lookup, if any.
+type DNSRecordPendingError struct {
+	ID        string
+	Record    DNSRecord
+	LookupErr error
+	Err       error
+}
+
+// Error returns a string representation of the error.
+func (e DNSRecordPendingError) Error() string {
+	if e.LookupErr == nil {
+		return fmt.Sprintf("DNS TXT record for domain claim %s not found at %s: %v", e.ID, e.Record.Name, e.Err)
+	}
+
+	return fmt.Sprintf("DNS TXT record for domain claim %s not found at %s: %v (last lookup: %v)",
+		e.ID, e.Record.Name, e.Err, e.LookupErr)
+}
+
+// Unwrap returns the underlying context error.
+func (e DNSRecordPendingError) Unwrap() error {
+	return e.Err
+}
+
+// DNSRecord returns the DNS TXT record which must be published at the
+// specified domain, or at the authorization domain if one will be provided to
+// ClaimDNS, for HVCA to verify the claim.
+func (i ClaimAssertionInfo) DNSRecord(domain string) DNSRecord {
+	return newDNSRecord(domain, i.Token)
+}
+
+// DNSRecord returns the DNS TXT record which must be published for HVCA to
+// verify the claim. If authDomain is empty, the record is published at the
+// claimed domain.
+func (c Claim) DNSRecord(authDomain string) DNSRecord {
+	if authDomain == "" {
+		authDomain = c.Domain
+	}
+
+	return newDNSRecord(authDomain, c.Token)
+}
+
+// LastError returns the description of the most recent verification error in
+// the claim log, or the empty string if there is none.
+func (c Claim) LastError() string {
+	var latest *ClaimLogEntry
+
+	for i := range c.Log {
+		if c.Log[i].Status != VerificationError {
+			continue
+		}
+
+		if latest == nil || !c.Log[i].TimeStamp.Before(latest.TimeStamp) {
+			latest = &c.Log[i]
+		}
+	}
+
+	if latest == nil {
+		return ""
+	}
+
+	return latest.Description
+}
+
+// newDNSRecord returns the DNS TXT record for a domain claim token.
+func newDNSRecord(domain, token string) DNSRecord {
+	if !strings.HasPrefix(token, dnsVerificationPrefix) {
+		token = dnsVerificationPrefix + token
+	}
+
+	return DNSRecord{
+		Name:  strings.TrimSuffix(strings.TrimPrefix(domain, "*."), "."),
+		Value: token,
+	}
+}
+
+// ClaimSubmitAndAwait submits a claim for a domain, calls publish with the
+// DNS TXT record which must be published to verify the claim, and then
+// requests DNS verification, waiting for the specified interval between
+// attempts until the claim is verified. If pollInterval is zero, a reasonable
+// default will be used. Verification will typically fail until the record
+// has propagated, so attempts continue until the context is done, at which
+// point a ClaimVerificationError is returned containing the claim ID and the
+// most recent verification error reported by HVCA. A ClaimVerificationError
+// is also returned immediately if HVCA rejects the verification request, for
+// example because the assert-by time has passed, in which case ClaimReassert
+// may be used to obtain a new token.
+func (c *Client) ClaimSubmitAndAwait(
+	ctx context.Context,
+	domain string,
+	publish func(ctx context.Context, record DNSRecord) error,
+	pollInterval time.Duration,
+) (*Claim, error) {
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	var info, err = c.ClaimSubmit(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = publish(ctx, info.DNSRecord(domain)); err != nil {
+		return nil, fmt.Errorf("couldn't publish DNS record for domain claim %s: %w", info.ID, err)
+	}
+
+	var reason string
+
+	for {
+		var verified bool
+		verified, err = c.ClaimDNS(ctx, info.ID, "")
+
+		var apiErr APIError
+		switch {
+		case ctx.Err() != nil:
+			return nil, ClaimVerificationError{ID: info.ID, Reason: reason, Err: ctx.Err()}
+
+		case errors.As(err, &apiErr) && isClaimRejection(apiErr.StatusCode):
+			return nil, ClaimVerificationError{
+				ID:     info.ID,
+				Reason: c.claimLastError(ctx, info.ID, apiErr.Description),
+				Err:    apiErr,
+			}
+
+		case err != nil:
+			return nil, err
+		}
+
+		var claim *Claim
+		claim, err = c.ClaimRetrieve(ctx, info.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ClaimVerificationError{ID: info.ID, Reason: reason, Err: ctx.Err()}
+			}
+
+			return nil, err
+		}
+
+		if verified || claim.Status == StatusVerified {
+			return claim, nil
+		}
+
+		if lastErr := claim.LastError(); lastErr != "" {
+			reason = lastErr
+		}
+
+		if err = sleepContext(ctx, pollInterval); err != nil {
+			return nil, ClaimVerificationError{ID: info.ID, Reason: reason, Err: err}
+		}
+	}
+}
+
+// ClaimAwaitDNS waits for the DNS TXT record for the specified domain claim
+// to be published, and then requests DNS verification of the claim with
+// ClaimDNS. The record is looked up with the provided function, such as a
+// wrapper around net.Resolver.LookupTXT, waiting for the specified interval
+// between lookups until one of the returned values matches. If pollInterval
+// is zero, a reasonable default will be used. Failed lookups are treated in
+// the same way as lookups which do not return the record, since a missing
+// record is commonly reported as an error. If the context is done before the
+// record is observed, a DNSRecordPendingError is returned.
+//
+// Note that ClaimReassert is not called, since it issues a new token which
+// would invalidate the published record. Use ClaimReassert beforehand if the
+// assert-by time of the claim has passed, and publish the record it returns.
+func (c *Client) ClaimAwaitDNS(
+	ctx context.Context,
+	claimID string,
+	lookup func(ctx context.Context, fqdn string) ([]string, error),
+	pollInterval time.Duration,
+) error {
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	var claim, err = c.ClaimRetrieve(ctx, claimID)
+	if err != nil {
+		return err
+	}
+
+	var record = claim.DNSRecord("")
+	var lookupErr error
+
+	for {
+		var values []string
+		if values, err = lookup(ctx, record.Name); err != nil {
+			lookupErr = err
+		} else if containsTXT(values, record.Value) {
+			break
+		}
+
+		if err = sleepContext(ctx, pollInterval); err != nil {
+			return DNSRecordPendingError{ID: claimID, Record: record, LookupErr: lookupErr, Err: err}
+		}
+	}
+
+	_, err = c.ClaimDNS(ctx, claimID, "")
+
+	return err
+}
+
+// containsTXT returns true if one of the DNS TXT record values is equal to
+// the specified value, ignoring surrounding whitespace.
+func containsTXT(values []string, want string) bool {
+	for _, value := range values {
+		if strings.TrimSpace(value) == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+// claimLastError returns the most recent verification error in the log of
+// the specified domain claim, or fallback if there is none or the claim
+// could not be retrieved.
+func (c *Client) claimLastError(ctx context.Context, id, fallback string) string {
+	var claim, err = c.ClaimRetrieve(ctx, id)
+	if err != nil {
+		return fallback
+	}
+
+	if reason := claim.LastError(); reason != "" {
+		return reason
+	}
+
+	return fallback
+}
+
+// isClaimRejection returns true if a HTTP status code returned when
+// requesting verification of a domain claim indicates that HVCA refused to
+// verify it. Authentication failures are excluded.
+func isClaimRejection(statusCode int) bool {
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false
+	}
+
+	return isRejection(statusCode)
+}
@@ -0,0 +1,425 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+func TestClaimSubmitAndAwait(t *testing.T) {
+	t.Parallel()
+
+	const (
+		claimID     = "ABCD1234"
+		claimDomain = "example.com."
+		claimToken  = "claim_token"
+		failReason  = "TXT record not found"
+	)
+
+	var testcases = []struct {
+		name    string
+		pending int32
+		final   int
+		timeout time.Duration
+		check   func(t *testing.T, err error)
+	}{
+		{
+			name:    "VerifiedImmediately",
+			final:   http.StatusNoContent,
+			timeout: time.Second * 5,
+		},
+		{
+			name:    "VerifiedAfterPending",
+			pending: 3,
+			final:   http.StatusNoContent,
+			timeout: time.Second * 5,
+		},
+		{
+			name:    "Rejected",
+			pending: 1,
+			final:   http.StatusUnprocessableEntity,
+			timeout: time.Second * 5,
+			check: func(t *testing.T, err error) {
+				var verr ClaimVerificationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("got error %v, want %T", err, verr)
+				}
+
+				if verr.ID != claimID || verr.Reason != failReason {
+					t.Errorf("got ID %q and reason %q, want %q and %q", verr.ID, verr.Reason, claimID, failReason)
+				}
+
+				var apiErr APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+					t.Errorf("got error %v, want API error with status %d", err, http.StatusUnprocessableEntity)
+				}
+			},
+		},
+		{
+			name:    "StillPending",
+			pending: 1000,
+			timeout: time.Millisecond * 200,
+			check: func(t *testing.T, err error) {
+				var verr ClaimVerificationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("got error %v, want %T", err, verr)
+				}
+
+				if verr.Reason != failReason {
+					t.Errorf("got reason %q, want %q", verr.Reason, failReason)
+				}
+
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+				}
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts int32
+			var verified int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+				switch {
+				case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, pathDNS):
+					if atomic.AddInt32(&attempts, 1) <= tc.pending {
+						w.WriteHeader(http.StatusCreated)
+						return
+					}
+
+					if tc.final == http.StatusNoContent {
+						atomic.StoreInt32(&verified, 1)
+					}
+
+					w.WriteHeader(tc.final)
+
+				case r.Method == http.MethodPost:
+					w.Header().Set("Location", "http://local/claims/domains/"+claimID)
+					w.WriteHeader(http.StatusCreated)
+					json.NewEncoder(w).Encode(map[string]interface{}{
+						"token":     claimToken,
+						"assert_by": 1600000000,
+					})
+
+				default:
+					var status = "PENDING"
+					if atomic.LoadInt32(&verified) == 1 {
+						status = "VERIFIED"
+					}
+
+					json.NewEncoder(w).Encode(map[string]interface{}{
+						"id":     claimID,
+						"status": status,
+						"domain": claimDomain,
+						"token":  claimToken,
+						"log": []map[string]interface{}{
+							{"status": "INFO", "description": "verification requested", "timestamp": 1600000000},
+							{"status": "ERROR", "description": failReason, "timestamp": 1600000001},
+						},
+					})
+				}
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond})
+
+			var ctx, cancel = context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+
+			var published []DNSRecord
+			var claim, err = clnt.ClaimSubmitAndAwait(
+				ctx,
+				claimDomain,
+				func(ctx context.Context, record DNSRecord) error {
+					published = append(published, record)
+					return nil
+				},
+				time.Millisecond*10,
+			)
+
+			var want = DNSRecord{Name: "example.com", Value: dnsVerificationPrefix + claimToken}
+			if len(published) != 1 || published[0] != want {
+				t.Errorf("got published records %v, want [%v]", published, want)
+			}
+
+			if tc.check != nil {
+				if err == nil {
+					t.Fatal("unexpectedly succeeded")
+				}
+
+				tc.check(t, err)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to verify claim: %v", err)
+			}
+
+			if claim.Status != StatusVerified {
+				t.Errorf("got status %v, want %v", claim.Status, StatusVerified)
+			}
+
+			if got := atomic.LoadInt32(&attempts); got != tc.pending+1 {
+				t.Errorf("got %d verification attempts, want %d", got, tc.pending+1)
+			}
+		})
+	}
+}
+
+func TestClaimSubmitAndAwaitPublishFailure(t *testing.T) {
+	t.Parallel()
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, pathDNS) {
+			t.Errorf("unexpectedly requested verification")
+		}
+
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+		w.Header().Set("Location", "http://local/claims/domains/ABCD1234")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"token": "claim_token", "assert_by": 1600000000})
+	}))
+	defer server.Close()
+
+	var clnt = newTestClient(t, server.URL, nil)
+	var errPublish = errors.New("publish failed")
+
+	var _, err = clnt.ClaimSubmitAndAwait(
+		context.Background(),
+		"example.com",
+		func(ctx context.Context, record DNSRecord) error { return errPublish },
+		time.Millisecond,
+	)
+	if !errors.Is(err, errPublish) {
+		t.Errorf("got error %v, want %v", err, errPublish)
+	}
+}
+
+func TestClaimAwaitDNS(t *testing.T) {
+	t.Parallel()
+
+	const (
+		claimID    = "ABCD1234"
+		claimToken = "claim_token"
+	)
+
+	var errNXDomain = errors.New("no such host")
+
+	var testcases = []struct {
+		name    string
+		missing int32
+		timeout time.Duration
+		wantDNS int32
+		check   func(t *testing.T, err error)
+	}{
+		{
+			name:    "PublishedImmediately",
+			timeout: time.Second * 5,
+			wantDNS: 1,
+		},
+		{
+			name:    "PublishedAfterPropagation",
+			missing: 3,
+			timeout: time.Second * 5,
+			wantDNS: 1,
+		},
+		{
+			name:    "NeverPublished",
+			missing: 1000,
+			timeout: time.Millisecond * 200,
+			check: func(t *testing.T, err error) {
+				var perr DNSRecordPendingError
+				if !errors.As(err, &perr) {
+					t.Fatalf("got error %v, want %T", err, perr)
+				}
+
+				var want = DNSRecord{Name: "example.com", Value: dnsVerificationPrefix + claimToken}
+				if perr.ID != claimID || perr.Record != want {
+					t.Errorf("got ID %q and record %v, want %q and %v", perr.ID, perr.Record, claimID, want)
+				}
+
+				if !errors.Is(perr.LookupErr, errNXDomain) {
+					t.Errorf("got lookup error %v, want %v", perr.LookupErr, errNXDomain)
+				}
+
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+				}
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var asserted int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+				switch {
+				case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, pathDNS):
+					atomic.AddInt32(&asserted, 1)
+					w.WriteHeader(http.StatusNoContent)
+
+				case r.Method == http.MethodGet:
+					json.NewEncoder(w).Encode(map[string]interface{}{
+						"id":     claimID,
+						"status": "PENDING",
+						"domain": "example.com.",
+						"token":  claimToken,
+					})
+
+				default:
+					t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+					w.WriteHeader(http.StatusBadRequest)
+				}
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+			var ctx, cancel = context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+
+			var lookups int32
+			var err = clnt.ClaimAwaitDNS(
+				ctx,
+				claimID,
+				func(ctx context.Context, fqdn string) ([]string, error) {
+					if fqdn != "example.com" {
+						t.Errorf("got lookup of %q, want %q", fqdn, "example.com")
+					}
+
+					switch n := atomic.AddInt32(&lookups, 1); {
+					case n == 1 && tc.missing > 0:
+						return nil, errNXDomain
+					case n <= tc.missing:
+						return []string{"v=spf1 -all"}, nil
+					}
+
+					return []string{"v=spf1 -all", dnsVerificationPrefix + claimToken}, nil
+				},
+				time.Millisecond*10,
+			)
+
+			if got := atomic.LoadInt32(&asserted); got != tc.wantDNS {
+				t.Errorf("got %d verification requests, want %d", got, tc.wantDNS)
+			}
+
+			if tc.check != nil {
+				if err == nil {
+					t.Fatal("unexpectedly succeeded")
+				}
+
+				tc.check(t, err)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to await DNS record: %v", err)
+			}
+
+			if got, want := atomic.LoadInt32(&lookups), tc.missing+1; got != want {
+				t.Errorf("got %d lookups, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestClaimDNSRecord(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name       string
+		claim      Claim
+		authDomain string
+		want       DNSRecord
+	}{
+		{
+			name:  "ClaimedDomain",
+			claim: Claim{Domain: "example.com", Token: "abc"},
+			want:  DNSRecord{Name: "example.com", Value: "_globalsign-domain-verification=abc"},
+		},
+		{
+			name:       "AuthorizationDomain",
+			claim:      Claim{Domain: "www.example.com", Token: "abc"},
+			authDomain: "example.com",
+			want:       DNSRecord{Name: "example.com", Value: "_globalsign-domain-verification=abc"},
+		},
+		{
+			name:  "Wildcard",
+			claim: Claim{Domain: "*.example.com", Token: "abc"},
+			want:  DNSRecord{Name: "example.com", Value: "_globalsign-domain-verification=abc"},
+		},
+		{
+			name:  "PrefixedToken",
+			claim: Claim{Domain: "example.com", Token: "_globalsign-domain-verification=abc"},
+			want:  DNSRecord{Name: "example.com", Value: "_globalsign-domain-verification=abc"},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.claim.DNSRecord(tc.authDomain); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClaimLastError(t *testing.T) {
+	t.Parallel()
+
+	var claim = Claim{
+		Log: []ClaimLogEntry{
+			{Status: VerificationError, Description: "older", TimeStamp: time.Unix(10, 0)},
+			{Status: VerificationError, Description: "newer", TimeStamp: time.Unix(20, 0)},
+			{Status: VerificationInfo, Description: "info", TimeStamp: time.Unix(30, 0)},
+		},
+	}
+
+	if got := claim.LastError(); got != "newer" {
+		t.Errorf("got %q, want %q", got, "newer")
+	}
+
+	if got := (Claim{}).LastError(); got != "" {
+		t.Errorf("got %q, want empty string", got)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// idleClosingTransport is an HTTP round tripper which counts the number of
+// times its idle connections are closed.
+type idleClosingTransport struct {
+	http.RoundTripper
+	closes int32
+}
+
+func (rt *idleClosingTransport) CloseIdleConnections() {
+	atomic.AddInt32(&rt.closes, 1)
+}
+
+func TestClientClose(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		provided bool
+		want     int32
+	}{
+		{
+			name: "Owned",
+			want: 1,
+		},
+		{
+			name:     "Provided",
+			provided: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			var rt = &idleClosingTransport{RoundTripper: http.DefaultTransport}
+
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+			clnt.HTTPClient = &http.Client{Transport: rt}
+			if tc.provided {
+				clnt.Config.HTTPClient = clnt.HTTPClient
+			}
+
+			if _, err := clnt.makeRequest(context.Background(), "/test", http.MethodGet, nil, nil); err != nil {
+				t.Fatalf("failed to make request: %v", err)
+			}
+
+			// Closing more than once should be harmless.
+			for i := 0; i < 2; i++ {
+				if err := clnt.Close(); err != nil {
+					t.Fatalf("failed to close client: %v", err)
+				}
+			}
+
+			if got := atomic.LoadInt32(&rt.closes); got != tc.want {
+				t.Errorf("got %d idle connection closures, want %d", got, tc.want)
+			}
+
+			if _, err := clnt.makeRequest(context.Background(), "/test", http.MethodGet, nil, nil); !errors.Is(err, ErrClientClosed) {
+				t.Errorf("got error %v, want %v", err, ErrClientClosed)
+			}
+
+			if got := atomic.LoadInt32(&calls); got != 1 {
+				t.Errorf("got %d calls, want 1", got)
+			}
+		})
+	}
+}
+
+func TestClientCloseCancelsInFlight(t *testing.T) {
+	t.Parallel()
+
+	var received = make(chan struct{})
+	var release = make(chan struct{})
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{MaxRetries: 3})
+
+	var result = make(chan error, 1)
+	go func() {
+		var _, err = clnt.makeRequest(context.Background(), "/test", http.MethodGet, nil, nil)
+		result <- err
+	}()
+
+	<-received
+
+	if err := clnt.Close(); err != nil {
+		t.Fatalf("failed to close client: %v", err)
+	}
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrClientClosed) {
+			t.Errorf("got error %v, want %v", err, ErrClientClosed)
+		}
+
+	case <-time.After(time.Second * 5):
+		t.Fatal("request not cancelled by Close")
+	}
+
+	if got := clnt.InFlight(); got != 0 {
+		t.Errorf("got %d requests in flight, want 0", got)
+	}
+}
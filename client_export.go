@@ -0,0 +1,82 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ExportIssued writes the PEM encoding of every certificate issued during
+// the specified time window to w, and returns the number of certificates
+// written. It iterates over all pages of issued certificate statistics,
+// retrieving each certificate in turn before writing it, so a slow writer
+// naturally throttles the rate of requests to HVCA. A certificate which
+// appears twice, such as when a page boundary shifts during the export, is
+// written only once. If the context is done or an error occurs, the export
+// stops and the number of certificates written so far is returned along with
+// the error. Call options may be used to give the export its own timeout or
+// retry policy.
+func (c *Client) ExportIssued(
+	ctx context.Context,
+	from, to time.Time,
+	w io.Writer,
+	opts ...CallOption,
+) (int, error) {
+	var cancel context.CancelFunc
+	ctx, cancel = withCallOptions(ctx, opts)
+	defer cancel()
+
+	var iter = c.StatsIssuedIterator(ctx, from, to)
+	var seen = make(map[string]struct{})
+	var count int
+
+	for iter.Next() {
+		var serial = iter.Item().SerialNumber
+
+		var key = SerialToString(serial)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		seen[key] = struct{}{}
+
+		var info, err = c.CertificateRetrieve(ctx, serial)
+		if err != nil {
+			return count, fmt.Errorf("failed to retrieve certificate %s: %w", key, err)
+		}
+
+		var data = info.PEM
+		if !strings.HasSuffix(data, "\n") {
+			data += "\n"
+		}
+
+		if _, err = io.WriteString(w, data); err != nil {
+			return count, fmt.Errorf("failed to write certificate %s: %w", key, err)
+		}
+
+		count++
+	}
+
+	if err := iter.Err(); err != nil {
+		return count, err
+	}
+
+	return count, nil
+}
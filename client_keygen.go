@@ -0,0 +1,182 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// defaultRSAKeyLength is the length of generated RSA keys if the validation
+// policy does not restrict it, and the minimum length which will be chosen
+// from among the allowed lengths if any of them is long enough.
+const defaultRSAKeyLength = 2048
+
+// ErrKeygenUnsupported is returned by CertificateRequestWithKeygen if the
+// validation policy does not allow any key pair which can be generated.
+var ErrKeygenUnsupported = errors.New("hvclient: validation policy does not allow a key pair which can be generated")
+
+// CertificateRequestWithKeygen generates a new key pair, requests a new
+// certificate for it, waits for the certificate to be issued as described
+// for CertificateRequestAndWait, and returns the certificate along with the
+// private key.
+//
+// HVCA does not generate key pairs on behalf of clients, so the key pair is
+// generated locally and the private key is never sent to HVCA. The key type
+// and length are chosen to satisfy the public key section of the account's
+// validation policy, and proof of possession is provided by a signed CSR or
+// public key signature if the policy requires one. If the policy allows no
+// key pair which can be generated, ErrKeygenUnsupported is returned.
+//
+// If the context is done while the certificate is still pending, the private
+// key is returned along with the CertificatePendingError, since HVCA may still
+// issue the certificate, which can later be retrieved using the serial number
+// in the error and is unusable without the key.
+//
+// The request must not contain a public key, private key or CSR, and is not
+// modified.
+func (c *Client) CertificateRequestWithKeygen(
+	ctx context.Context,
+	req *Request,
+) (*CertInfo, crypto.PrivateKey, error) {
+	if req.PublicKey != nil || req.PrivateKey != nil || req.CSR != nil {
+		return nil, nil, errors.New("request for key generation must not contain a public key, private key or CSR")
+	}
+
+	var policy, err = c.Policy(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var key crypto.Signer
+	if key, err = generateKeyForPolicy(policy.PublicKey); err != nil {
+		return nil, nil, err
+	}
+
+	var keyReq = *req
+	if err = setRequestKey(&keyReq, key, policy); err != nil {
+		return nil, nil, err
+	}
+
+	var info *CertInfo
+	if info, err = c.CertificateRequestAndWait(ctx, &keyReq, 0); err != nil {
+		if errors.As(err, &CertificatePendingError{}) {
+			return nil, key, err
+		}
+
+		return nil, nil, err
+	}
+
+	return info, key, nil
+}
+
+// generateKeyForPolicy generates a new private key allowed by the specified
+// public key policy.
+func generateKeyForPolicy(p *PublicKeyPolicy) (crypto.Signer, error) {
+	if p == nil {
+		return nil, fmt.Errorf("%w: no public key policy", ErrKeygenUnsupported)
+	}
+
+	switch p.KeyType {
+	case RSA:
+		var length = defaultRSAKeyLength
+		if len(p.AllowedLengths) > 0 {
+			var lengths = append([]int(nil), p.AllowedLengths...)
+			sort.Ints(lengths)
+
+			length = lengths[len(lengths)-1]
+			for _, l := range lengths {
+				if l >= defaultRSAKeyLength {
+					length = l
+					break
+				}
+			}
+		}
+
+		return rsa.GenerateKey(rand.Reader, length)
+
+	case ECDSA:
+		var curves = map[int]elliptic.Curve{
+			256: elliptic.P256(),
+			384: elliptic.P384(),
+			521: elliptic.P521(),
+		}
+
+		var curve = elliptic.P256()
+		if len(p.AllowedLengths) > 0 {
+			curve = nil
+			for _, l := range p.AllowedLengths {
+				if candidate, ok := curves[l]; ok && (curve == nil || l < curve.Params().BitSize) {
+					curve = candidate
+				}
+			}
+
+			if curve == nil {
+				return nil, fmt.Errorf("%w: no supported ECDSA curve among allowed lengths %v",
+					ErrKeygenUnsupported, p.AllowedLengths)
+			}
+		}
+
+		return ecdsa.GenerateKey(curve, rand.Reader)
+
+	case ED25519:
+		var _, key, err = ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("%w: unsupported key type %v", ErrKeygenUnsupported, p.KeyType)
+}
+
+// setRequestKey sets the public key of the request to that of the specified
+// private key, with the proof of possession required by the policy.
+func setRequestKey(req *Request, key crypto.Signer, policy *Policy) error {
+	switch {
+	case policy.PublicKey.KeyFormat == PKCS10:
+		var der, err = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+		if err != nil {
+			return fmt.Errorf("failed to create CSR: %w", err)
+		}
+
+		if req.CSR, err = x509.ParseCertificateRequest(der); err != nil {
+			return fmt.Errorf("failed to parse CSR: %w", err)
+		}
+
+	case policy.PublicKeySignature == Required:
+		if _, ok := key.(ed25519.PrivateKey); ok {
+			return fmt.Errorf("%w: public key signatures are not supported for Ed25519 keys", ErrKeygenUnsupported)
+		}
+
+		req.PrivateKey = key
+
+	default:
+		req.PublicKey = key.Public()
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+func TestGenerateKeyForPolicy(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name   string
+		policy *PublicKeyPolicy
+		check  func(key interface{}) bool
+		err    error
+	}{
+		{
+			name:   "RSA",
+			policy: &PublicKeyPolicy{KeyType: RSA, AllowedLengths: []int{1024}},
+			check: func(key interface{}) bool {
+				var k, ok = key.(*rsa.PrivateKey)
+				return ok && k.N.BitLen() == 1024
+			},
+		},
+		{
+			name:   "ECDSASmallestCurve",
+			policy: &PublicKeyPolicy{KeyType: ECDSA, AllowedLengths: []int{521, 384}},
+			check: func(key interface{}) bool {
+				var k, ok = key.(*ecdsa.PrivateKey)
+				return ok && k.Curve.Params().BitSize == 384
+			},
+		},
+		{
+			name:   "ECDSADefault",
+			policy: &PublicKeyPolicy{KeyType: ECDSA},
+			check: func(key interface{}) bool {
+				var k, ok = key.(*ecdsa.PrivateKey)
+				return ok && k.Curve.Params().BitSize == 256
+			},
+		},
+		{
+			name:   "ED25519",
+			policy: &PublicKeyPolicy{KeyType: ED25519},
+			check: func(key interface{}) bool {
+				var _, ok = key.(ed25519.PrivateKey)
+				return ok
+			},
+		},
+		{
+			name:   "ECDSABadLengths",
+			policy: &PublicKeyPolicy{KeyType: ECDSA, AllowedLengths: []int{192}},
+			err:    ErrKeygenUnsupported,
+		},
+		{
+			name: "NoPolicy",
+			err:  ErrKeygenUnsupported,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var key, err = generateKeyForPolicy(tc.policy)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+
+			if tc.err == nil && !tc.check(key) {
+				t.Errorf("unexpected key %T", key)
+			}
+		})
+	}
+}
+
+func TestSetRequestKey(t *testing.T) {
+	t.Parallel()
+
+	var ecKey, err = generateKeyForPolicy(&PublicKeyPolicy{KeyType: ECDSA})
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var edKey crypto.Signer
+	if edKey, err = generateKeyForPolicy(&PublicKeyPolicy{KeyType: ED25519}); err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var testcases = []struct {
+		name   string
+		policy Policy
+		key    crypto.Signer
+		check  func(req *Request) bool
+		err    error
+	}{
+		{
+			name:   "CSR",
+			policy: Policy{PublicKey: &PublicKeyPolicy{KeyFormat: PKCS10}},
+			key:    ecKey,
+			check: func(req *Request) bool {
+				return req.CSR != nil && req.CSR.CheckSignature() == nil &&
+					req.PublicKey == nil && req.PrivateKey == nil
+			},
+		},
+		{
+			name:   "PublicKeySignature",
+			policy: Policy{PublicKey: &PublicKeyPolicy{KeyFormat: PKCS8}, PublicKeySignature: Required},
+			key:    ecKey,
+			check: func(req *Request) bool {
+				return req.PrivateKey == ecKey && req.PublicKey == nil && req.CSR == nil
+			},
+		},
+		{
+			name:   "PublicKey",
+			policy: Policy{PublicKey: &PublicKeyPolicy{KeyFormat: PKCS8}, PublicKeySignature: Optional},
+			key:    ecKey,
+			check: func(req *Request) bool {
+				return req.PublicKey != nil && req.PrivateKey == nil && req.CSR == nil
+			},
+		},
+		{
+			name:   "Ed25519PublicKeySignature",
+			policy: Policy{PublicKey: &PublicKeyPolicy{KeyFormat: PKCS8}, PublicKeySignature: Required},
+			key:    edKey,
+			err:    ErrKeygenUnsupported,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var req Request
+			var err = setRequestKey(&req, tc.key, &tc.policy)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+
+			if tc.err == nil && !tc.check(&req) {
+				t.Errorf("unexpected request keys: %+v", req)
+			}
+		})
+	}
+}
+
+func TestCertificateRequestWithKeygenPending(t *testing.T) {
+	t.Parallel()
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == endpointPolicy:
+			w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+			fmt.Fprint(w, `{"public_key":{"key_type":"ECDSA","allowed_lengths":[256],"key_format":"PKCS8"}}`)
+
+		case r.Method == http.MethodPost:
+			w.Header().Set("Location", "http://local/certificates/741DAF9EC2D5F7DC")
+			w.WriteHeader(http.StatusCreated)
+
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer server.Close()
+
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+
+	// The key should be returned with the error, since the pending
+	// certificate may still be issued for it.
+	var info, key, err = clnt.CertificateRequestWithKeygen(ctx, &Request{})
+
+	var pending CertificatePendingError
+	if !errors.As(err, &pending) {
+		t.Fatalf("got error %v, want %T", err, pending)
+	}
+
+	if info != nil {
+		t.Errorf("got certificate info %v, want nil", info)
+	}
+
+	if _, ok := key.(*ecdsa.PrivateKey); !ok {
+		t.Errorf("got key of type %T, want %T", key, &ecdsa.PrivateKey{})
+	}
+}
@@ -17,6 +17,7 @@ package hvclient
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -31,48 +32,175 @@ type loginRequest struct {
 // loginResponse is an HVCA POST /login response body.
 type loginResponse struct {
 	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in,omitempty"`
 }
 
 const (
-	// tokenLifetime is the assumed lifetime of an HVCA authentication token.
-	// The HVCA API appears to not return any information confirming the
-	// lifetime of the token, but at the time of writing the API documentation
-	// states it to be 10 minutes. We here set it to nine minutes just to
-	// leave some headroom.
-	tokenLifetime = time.Minute * 9
+	// defaultTokenLifetime is the assumed lifetime of an HVCA authentication
+	// token if the login response does not include one. At the time of
+	// writing the API documentation states it to be 10 minutes.
+	defaultTokenLifetime = time.Minute * 10
+
+	// maxTokenLifetime is the maximum token lifetime which may be specified
+	// in the configuration.
+	maxTokenLifetime = time.Hour
+
+	// autoRefreshFraction is the fraction of the lifetime of an
+	// authentication token after which the background refresher will
+	// login again.
+	autoRefreshFraction = 0.8
+
+	// autoRefreshRetryDelay is the time the background refresher will wait
+	// before trying again after a failed login.
+	autoRefreshRetryDelay = time.Second * 10
+
+	// defaultTokenExpiryMargin is the default amount of time before the
+	// expiry of an authentication token at which it is treated as expired,
+	// to leave some headroom.
+	defaultTokenExpiryMargin = time.Minute
+
+	// loginMaxAttempts is the maximum number of times a login will be
+	// attempted when it fails with a transient error.
+	loginMaxAttempts = 3
 )
 
+// loginRetryPolicy computes the delays between login attempts. Login
+// requests are retried according to it regardless of the retry policy in
+// the configuration, since logging in is idempotent and every other API
+// call depends on it.
+var loginRetryPolicy = RetryPolicy{
+	MaxRetries: loginMaxAttempts - 1,
+	BaseDelay:  time.Millisecond * 250,
+	MaxDelay:   time.Second * 2,
+}
+
 // HVCA API endpoints.
 const (
 	endpointLogin = "/login"
 )
 
-// login logs into the HVCA server and stores the authentication token.
+// ErrTokenExpired is returned by API calls when the stored authentication
+// token has expired, or was rejected by HVCA as unauthorized, and automatic
+// login has been disabled in the configuration.
+var ErrTokenExpired = errors.New("hvclient: authentication token expired")
+
+// ErrLoginFailed is returned when logging into HVCA fails. Errors matching it
+// wrap the error from the last login attempt.
+var ErrLoginFailed = errors.New("hvclient: login failed")
+
+// loginError is returned when logging into HVCA fails. It matches
+// ErrLoginFailed.
+type loginError struct {
+	err error
+}
+
+// Error returns a string representation of the error.
+func (e loginError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrLoginFailed, e.err)
+}
+
+// Is returns true if target is ErrLoginFailed.
+func (e loginError) Is(target error) bool {
+	return target == ErrLoginFailed
+}
+
+// Unwrap returns the error from the last login attempt.
+func (e loginError) Unwrap() error {
+	return e.err
+}
+
+// tokenRejectedError is returned instead of the underlying API error when
+// HVCA rejects the authentication token and automatic login has been
+// disabled in the configuration. It matches ErrTokenExpired.
+type tokenRejectedError struct {
+	err APIError
+}
+
+// Error returns a string representation of the error.
+func (e tokenRejectedError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrTokenExpired, e.err)
+}
+
+// Is returns true if target is ErrTokenExpired.
+func (e tokenRejectedError) Is(target error) bool {
+	return target == ErrTokenExpired
+}
+
+// Unwrap returns the underlying API error.
+func (e tokenRejectedError) Unwrap() error {
+	return e.err
+}
+
+// login logs into the HVCA server and stores the authentication token, and
+// then calls the login hook, if one was provided in the configuration.
 func (c *Client) login(ctx context.Context) error {
+	var start = time.Now()
+	var err = c.authenticate(ctx)
+	c.callLoginHook(ctx, time.Since(start), err)
+
+	return err
+}
+
+// authenticate logs into the HVCA server and stores the authentication token,
+// without calling the login hook. A login which fails with a transient error
+// is attempted up to loginMaxAttempts times, independently of the retry
+// policy in the configuration.
+func (c *Client) authenticate(ctx context.Context) error {
 	var req = loginRequest{
 		APIKey:    c.Config.APIKey,
 		APISecret: c.Config.APISecret,
 	}
 
+	// Disable the configured retry policy for the individual attempts, so
+	// that the number of attempts remains bounded.
+	var attemptCtx = withRetryPolicy(ctx, &RetryPolicy{})
+
 	var resp loginResponse
-	var _, err = c.makeRequest(
-		ctx,
-		endpointLogin,
-		http.MethodPost,
-		req,
-		&resp,
-	)
+	var err error
+	for attempt := 0; ; attempt++ {
+		if _, err = c.makeRequest(
+			attemptCtx,
+			endpointLogin,
+			http.MethodPost,
+			req,
+			&resp,
+		); err == nil || attempt >= loginRetryPolicy.MaxRetries || !loginRetryable(err) {
+			break
+		}
+
+		if sleepContext(ctx, loginRetryPolicy.delay(attempt)) != nil {
+			break
+		}
+	}
 	if err != nil {
 		c.tokenReset()
 
-		return fmt.Errorf("failed to login: %w", err)
+		return loginError{err: err}
+	}
+
+	// Use the token lifetime from the response if one was provided, and
+	// fall back to the configured or documented token lifetime otherwise.
+	var lifetime = c.Config.tokenLifetime()
+	if resp.ExpiresIn > 0 {
+		lifetime = time.Second * time.Duration(resp.ExpiresIn)
 	}
 
-	c.SetToken(resp.AccessToken)
+	c.setToken(resp.AccessToken, lifetime)
 
 	return nil
 }
 
+// loginRetryable returns true if a login which failed with the specified
+// error should be attempted again.
+func loginRetryable(err error) bool {
+	var apiErr APIError
+	if errors.As(err, &apiErr) {
+		return DefaultRetryable(apiErr.StatusCode, nil)
+	}
+
+	return DefaultRetryable(0, err)
+}
+
 // loginIfTokenHasExpired logs in if the stored authentication token has
 // expired, or if there is no stored authentication token. To avoid
 // unnecessary simultaneous re-logins, this method ensures only one goroutine
@@ -83,23 +211,207 @@ func (c *Client) loginIfTokenHasExpired(ctx context.Context) error {
 		return nil
 	}
 
-	// Token is believed to be expired, so lock the login mutex to ensure only
-	// one goroutine at a time can relogin. Note that it is perfectly safe for
-	// one goroutine to call login (which doesn't acquire the login mutex) while
-	// another calls this method (which does acquire it) - it's just somewhat
-	// inefficient. Also note that access to the token is sychronized using
-	// a different mutex, so attempting to acquire that mutex while holding
-	// this one won't cause a deadlock.
-	c.LoginMtx.Lock()
-	defer c.LoginMtx.Unlock()
+	// Call the login hook only after the login semaphore has been released,
+	// so a slow hook can't stall other goroutines waiting to login.
+	var elapsed, attempted, err = c.loginWithMutex(ctx)
+	if attempted {
+		c.callLoginHook(ctx, elapsed, err)
+	}
+
+	return err
+}
+
+// Login logs into the HVCA server and stores a new authentication token if
+// the stored token has expired, or if there is no stored token, and does
+// nothing otherwise. It is intended for clients created with automatic login
+// disabled in the configuration. Use RefreshToken to login regardless of
+// the remaining lifetime of the stored token. If the configuration has a
+// token source, Login instead obtains a token from it, which logs in only if
+// the source needs to.
+func (c *Client) Login(ctx context.Context) error {
+	if c.Config.TokenSource != nil {
+		var _, err = c.Config.TokenSource.Token(ctx)
+		return err
+	}
+
+	return c.loginIfTokenHasExpired(ctx)
+}
+
+// loginWithMutex logs in while holding the login semaphore, unless another
+// goroutine has logged in while this one was waiting to acquire it. It
+// returns the time taken to login and whether a login was attempted.
+func (c *Client) loginWithMutex(ctx context.Context) (time.Duration, bool, error) {
+	// Token is believed to be expired, so acquire the login semaphore to
+	// ensure only one goroutine at a time can relogin. Note that it is
+	// perfectly safe for one goroutine to call login (which doesn't acquire
+	// the login semaphore) while another calls this method (which does
+	// acquire it) - it's just somewhat inefficient. Also note that access to
+	// the token is sychronized using a mutex, so attempting to acquire that
+	// mutex while holding the semaphore won't cause a deadlock. A goroutine
+	// whose context is done while waiting gives up, rather than waiting for
+	// the login to finish.
+	if err := c.acquireLogin(ctx); err != nil {
+		return 0, false, err
+	}
+	defer c.releaseLogin()
 
 	// Check again if the token is believed to be expired, as another
-	// goroutine may have acquired the login mutex before we did.
+	// goroutine may have acquired the login semaphore before we did.
 	if !c.tokenHasExpired() {
+		return 0, false, nil
+	}
+
+	var start = time.Now()
+	var err = c.authenticate(ctx)
+
+	return time.Since(start), true, err
+}
+
+// reloginIfTokenRejected logs in again after HVCA rejected the specified
+// authentication token, even if the token was not believed to be expired,
+// unless another goroutine replaced the token while this one was waiting to
+// acquire the login semaphore.
+func (c *Client) reloginIfTokenRejected(ctx context.Context, rejected string) error {
+	var elapsed, attempted, err = func() (time.Duration, bool, error) {
+		if err := c.acquireLogin(ctx); err != nil {
+			return 0, false, err
+		}
+		defer c.releaseLogin()
+
+		if token := c.GetToken(); token != "" && token != rejected {
+			return 0, false, nil
+		}
+
+		var start = time.Now()
+		var err = c.authenticate(ctx)
+
+		return time.Since(start), true, err
+	}()
+	if attempted {
+		c.callLoginHook(ctx, elapsed, err)
+	}
+
+	return err
+}
+
+// RefreshToken logs into the HVCA server and stores a new authentication
+// token, regardless of the remaining lifetime of the currently stored token.
+// This can be used to avoid an API call having to wait for a login when the
+// stored token expires. If the configuration has a token source, RefreshToken
+// behaves like Login, and the source decides when to login again.
+func (c *Client) RefreshToken(ctx context.Context) error {
+	if c.Config.TokenSource != nil {
+		return c.Login(ctx)
+	}
+
+	var start = time.Now()
+	var err = func() error {
+		if err := c.acquireLogin(ctx); err != nil {
+			return err
+		}
+		defer c.releaseLogin()
+
+		return c.authenticate(ctx)
+	}()
+	c.callLoginHook(ctx, time.Since(start), err)
+
+	return err
+}
+
+// acquireLogin acquires the login semaphore, which ensures only one goroutine
+// at a time can login. Unlike locking a mutex, it returns the context error
+// if the context is done before the semaphore can be acquired.
+func (c *Client) acquireLogin(ctx context.Context) error {
+	var sem = c.loginSemaphore()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to login: %w", err)
+	}
+
+	select {
+	case sem <- struct{}{}:
 		return nil
+
+	case <-ctx.Done():
+		return fmt.Errorf("failed to login: %w", ctx.Err())
+	}
+}
+
+// releaseLogin releases the login semaphore acquired by acquireLogin.
+func (c *Client) releaseLogin() {
+	<-c.loginSemaphore()
+}
+
+// loginSemaphore returns the login semaphore, creating it on first use so
+// that clients not created by NewClient can still login.
+func (c *Client) loginSemaphore() chan struct{} {
+	c.loginSemOnce.Do(func() {
+		c.loginSem = make(chan struct{}, 1)
+	})
+
+	return c.loginSem
+}
+
+// startAutoRefresh starts a background goroutine which refreshes the stored
+// authentication token before it expires. The goroutine is stopped by Close.
+func (c *Client) startAutoRefresh() {
+	var ctx, cancel = context.WithCancel(context.Background())
+
+	c.refreshCancel = cancel
+	c.refreshDone = make(chan struct{})
+
+	go func() {
+		defer close(c.refreshDone)
+
+		for {
+			// Wait until the required fraction of the lifetime of the token
+			// has elapsed, or a short while if there is no valid token
+			// because the last login failed.
+			var wait = autoRefreshRetryDelay
+
+			var lastLogin, expiry = c.tokenTimes()
+			if !expiry.IsZero() {
+				var lifetime = expiry.Sub(lastLogin)
+				wait = time.Until(lastLogin.Add(time.Duration(float64(lifetime) * autoRefreshFraction)))
+			}
+
+			if err := sleepContext(ctx, wait); err != nil {
+				return
+			}
+
+			// Errors are deliberately ignored here. A failed login resets
+			// the stored token, so the next API call will login again
+			// itself if the token is not refreshed in the meantime.
+			var loginCtx, loginCancel = context.WithTimeout(ctx, c.Config.Timeout)
+			_ = c.RefreshToken(loginCtx)
+			loginCancel()
+		}
+	}()
+}
+
+// stopAutoRefresh stops the background token refresher, if it is running,
+// and waits for it to exit.
+func (c *Client) stopAutoRefresh() {
+	if c.refreshCancel == nil {
+		return
+	}
+
+	c.refreshCancel()
+	<-c.refreshDone
+}
+
+// callLoginHook logs the outcome of a login attempt, and calls the login
+// hook, if one was provided in the configuration.
+func (c *Client) callLoginHook(ctx context.Context, elapsed time.Duration, err error) {
+	if err != nil {
+		c.Config.logger().Log(ctx, LogLevelError, "failed to login to HVCA", logKeyElapsed, elapsed, logKeyError, err)
+	} else {
+		c.Config.logger().Log(ctx, LogLevelInfo, "logged in to HVCA", logKeyElapsed, elapsed)
 	}
 
-	return c.login(ctx)
+	if c.Config.OnLogin != nil {
+		c.Config.OnLogin(ctx, elapsed, err)
+	}
 }
 
 // tokenHasExpired returns true if the stored authentication token is believed
@@ -109,7 +421,20 @@ func (c *Client) tokenHasExpired() bool {
 	c.TokenMtx.RLock()
 	defer c.TokenMtx.RUnlock()
 
-	return time.Since(c.LastLogin) > tokenLifetime
+	if c.tokenExpiry.IsZero() {
+		return true
+	}
+
+	// Treat the token as expired a safety margin before its actual expiry.
+	// If the margin is not shorter than the lifetime of the token, use half
+	// the lifetime instead, to avoid logging in before every request. The
+	// expiry is judged by HVCA's clock rather than the local clock.
+	var margin = c.Config.tokenExpiryMargin()
+	if lifetime := c.tokenExpiry.Sub(c.serverTimeAt(c.LastLogin)); margin >= lifetime {
+		margin = lifetime / 2
+	}
+
+	return !c.serverTime().Before(c.tokenExpiry.Add(-margin))
 }
 
 // tokenReset clears the stored authentication token and the last login time.
@@ -119,16 +444,62 @@ func (c *Client) tokenReset() {
 
 	c.Token = ""
 	c.LastLogin = time.Time{}
+	c.tokenExpiry = time.Time{}
 }
 
 // SetToken sets the stored authentication token and sets the last login time
-// to the current time.
+// to the current time. The token is assumed to have the lifetime specified in
+// the configuration, or the lifetime currently documented for HVCA
+// authentication tokens if none was specified.
 func (c *Client) SetToken(token string) {
+	c.setToken(token, c.Config.tokenLifetime())
+}
+
+// SetTokenWithExpiry sets the stored authentication token, which expires at
+// the specified time according to HVCA's clock. This allows a token obtained
+// elsewhere, such as by another process, to be reused until it expires,
+// after which the client will login again. Since the time the token was
+// obtained is not known, the last login time is estimated from the expiry
+// time assuming the token lifetime used by SetToken, but is never later
+// than the current time.
+func (c *Client) SetTokenWithExpiry(token string, expiry time.Time) {
+	c.TokenMtx.Lock()
+	defer c.TokenMtx.Unlock()
+
+	var now = time.Now()
+
+	c.Token = token
+	c.LastLogin = expiry.Add(-c.ClockSkew() - c.Config.tokenLifetime())
+	if c.LastLogin.After(now) {
+		c.LastLogin = now
+	}
+	c.tokenExpiry = expiry
+}
+
+// setToken sets the stored authentication token, sets the last login time to
+// the current time, and sets the token expiry time based on the specified
+// lifetime.
+func (c *Client) setToken(token string, lifetime time.Duration) {
 	c.TokenMtx.Lock()
 	defer c.TokenMtx.Unlock()
 
 	c.Token = token
 	c.LastLogin = time.Now()
+	c.tokenExpiry = c.serverTimeAt(c.LastLogin).Add(lifetime)
+}
+
+// tokenTimes performs a synchronized read of the last login time and the
+// expiry time of the stored authentication token, both according to the
+// local clock. The expiry time is zero if there is no stored token.
+func (c *Client) tokenTimes() (time.Time, time.Time) {
+	c.TokenMtx.RLock()
+	defer c.TokenMtx.RUnlock()
+
+	if c.tokenExpiry.IsZero() {
+		return c.LastLogin, time.Time{}
+	}
+
+	return c.LastLogin, c.tokenExpiry.Add(-c.ClockSkew())
 }
 
 // GetToken performs a synchronized read of the stored authentication token.
@@ -139,4 +510,20 @@ func (c *Client) GetToken() string {
 	return c.Token
 }
 
+// TokenWithExpiry performs a synchronized read of the stored authentication
+// token and its expiry time according to HVCA's clock, so that the token
+// can be cached elsewhere and passed to SetTokenWithExpiry on another
+// client. The returned boolean is false, and the token and expiry time are
+// empty, if there is no stored token or if it has already expired.
+func (c *Client) TokenWithExpiry() (string, time.Time, bool) {
+	c.TokenMtx.RLock()
+	defer c.TokenMtx.RUnlock()
+
+	if c.Token == "" || !c.serverTime().Before(c.tokenExpiry) {
+		return "", time.Time{}, false
+	}
+
+	return c.Token, c.tokenExpiry, true
+}
+
 //
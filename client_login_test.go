@@ -0,0 +1,668 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+func TestLoginTokenLifetime(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name      string
+		body      string
+		margin    time.Duration
+		lifetime  time.Duration
+		want      time.Duration
+		wantFresh bool
+	}{
+		{
+			name:      "Default",
+			body:      `{"access_token":"token"}`,
+			want:      defaultTokenLifetime,
+			wantFresh: true,
+		},
+		{
+			name:      "ExpiresIn",
+			body:      `{"access_token":"token","expires_in":3600}`,
+			want:      time.Hour,
+			wantFresh: true,
+		},
+		{
+			name:      "ShortExpiresIn",
+			body:      `{"access_token":"token","expires_in":30}`,
+			want:      time.Second * 30,
+			wantFresh: true,
+		},
+		{
+			name:      "ConfiguredLifetime",
+			body:      `{"access_token":"token"}`,
+			lifetime:  time.Minute * 2,
+			want:      time.Minute * 2,
+			wantFresh: true,
+		},
+		{
+			name:      "ExpiresInOverridesConfigured",
+			body:      `{"access_token":"token","expires_in":3600}`,
+			lifetime:  time.Minute * 2,
+			want:      time.Hour,
+			wantFresh: true,
+		},
+		{
+			name:      "LargeMargin",
+			body:      `{"access_token":"token","expires_in":600}`,
+			margin:    time.Minute * 20,
+			want:      time.Minute * 10,
+			wantFresh: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, nil)
+			clnt.Config.TokenExpiryMargin = tc.margin
+			clnt.Config.TokenLifetime = tc.lifetime
+			clnt.tokenReset()
+
+			if !clnt.tokenHasExpired() {
+				t.Fatalf("token unexpectedly not expired before login")
+			}
+
+			if err := clnt.login(context.Background()); err != nil {
+				t.Fatalf("failed to login: %v", err)
+			}
+
+			// The expiry is in terms of the server's clock.
+			if got := clnt.tokenExpiry.Sub(clnt.serverTimeAt(clnt.LastLogin)); got != tc.want {
+				t.Errorf("got token lifetime %v, want %v", got, tc.want)
+			}
+
+			if got := !clnt.tokenHasExpired(); got != tc.wantFresh {
+				t.Errorf("got token fresh %t, want %t", got, tc.wantFresh)
+			}
+		})
+	}
+}
+
+func TestTokenHasExpired(t *testing.T) {
+	t.Parallel()
+
+	var clnt = &Client{Config: &Config{}}
+
+	clnt.setToken("token", time.Minute*10)
+	if clnt.tokenHasExpired() {
+		t.Fatalf("new token unexpectedly expired")
+	}
+
+	// Move the token expiry to within the default safety margin.
+	clnt.LastLogin = time.Now().Add(-time.Minute * 10)
+	clnt.tokenExpiry = time.Now().Add(defaultTokenExpiryMargin / 2)
+	if !clnt.tokenHasExpired() {
+		t.Fatalf("token within safety margin unexpectedly not expired")
+	}
+
+	clnt.tokenReset()
+	if !clnt.tokenHasExpired() {
+		t.Fatalf("reset token unexpectedly not expired")
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	var logins int32
+	var server = newLoginCountingServer(t, &logins, 600)
+	defer server.Close()
+
+	var clnt = newTestClient(t, server.URL, nil)
+	var lastLogin = clnt.LastLogin
+
+	if err := clnt.RefreshToken(context.Background()); err != nil {
+		t.Fatalf("failed to refresh token: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&logins); got != 1 {
+		t.Fatalf("got %d logins, want 1", got)
+	}
+
+	if !clnt.LastLogin.After(lastLogin) {
+		t.Errorf("last login time not updated")
+	}
+}
+
+func TestAutoRefresh(t *testing.T) {
+	t.Parallel()
+
+	var logins int32
+	var server = newLoginCountingServer(t, &logins, 1)
+	defer server.Close()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var clnt, err = NewClient(ctx, &Config{
+		URL:         server.URL,
+		APIKey:      "key",
+		APISecret:   "secret",
+		AutoRefresh: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	// With a one second token lifetime, the token should be refreshed
+	// after 800ms.
+	time.Sleep(time.Millisecond * 1300)
+
+	if err = clnt.Close(); err != nil {
+		t.Fatalf("failed to close client: %v", err)
+	}
+
+	var got = atomic.LoadInt32(&logins)
+	if got < 2 {
+		t.Fatalf("got %d logins, want at least 2", got)
+	}
+
+	// Verify no further logins occur after the client is closed.
+	time.Sleep(time.Millisecond * 1000)
+
+	if after := atomic.LoadInt32(&logins); after != got {
+		t.Errorf("got %d logins after close, want %d", after, got)
+	}
+
+	// Closing the client again should be harmless.
+	if err = clnt.Close(); err != nil {
+		t.Fatalf("failed to close client again: %v", err)
+	}
+}
+
+func TestInitialToken(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name      string
+		expiry    time.Duration
+		wantLogin int32
+		wantToken string
+	}{
+		{
+			name:      "Fresh",
+			expiry:    time.Minute * 5,
+			wantLogin: 0,
+			wantToken: "initial",
+		},
+		{
+			name:      "Expired",
+			expiry:    -time.Minute,
+			wantLogin: 1,
+			wantToken: "token",
+		},
+		{
+			name:      "WithinMargin",
+			expiry:    time.Second * 30,
+			wantLogin: 1,
+			wantToken: "token",
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var logins int32
+			var server = newLoginCountingServer(t, &logins, 600)
+			defer server.Close()
+
+			var clnt, err = NewClient(context.Background(), &Config{
+				URL:                server.URL,
+				APIKey:             "key",
+				APISecret:          "secret",
+				TokenExpiryMargin:  time.Minute,
+				InitialToken:       "initial",
+				InitialTokenExpiry: time.Now().Add(tc.expiry),
+			})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			if got := atomic.LoadInt32(&logins); got != tc.wantLogin {
+				t.Errorf("got %d logins, want %d", got, tc.wantLogin)
+			}
+
+			if got := clnt.GetToken(); got != tc.wantToken {
+				t.Errorf("got token %q, want %q", got, tc.wantToken)
+			}
+		})
+	}
+}
+
+func TestSetTokenWithExpiry(t *testing.T) {
+	t.Parallel()
+
+	var clnt = &Client{Config: &Config{}}
+
+	clnt.SetTokenWithExpiry("token", time.Now().Add(time.Minute*5))
+	if clnt.tokenHasExpired() {
+		t.Fatalf("token unexpectedly expired")
+	}
+
+	if got := clnt.GetToken(); got != "token" {
+		t.Errorf("got token %q, want %q", got, "token")
+	}
+
+	clnt.SetTokenWithExpiry("token", time.Now().Add(-time.Second))
+	if !clnt.tokenHasExpired() {
+		t.Fatalf("expired token unexpectedly not expired")
+	}
+}
+
+func TestTokenWithExpiry(t *testing.T) {
+	t.Parallel()
+
+	var clnt = &Client{Config: &Config{}}
+
+	if _, _, ok := clnt.TokenWithExpiry(); ok {
+		t.Fatalf("unexpectedly found token")
+	}
+
+	var expiry = time.Now().Add(time.Minute * 5)
+	clnt.SetTokenWithExpiry("token", expiry)
+
+	var token, gotExpiry, ok = clnt.TokenWithExpiry()
+	if !ok {
+		t.Fatalf("failed to find token")
+	}
+
+	if token != "token" {
+		t.Errorf("got token %q, want %q", token, "token")
+	}
+
+	if !gotExpiry.Equal(expiry) {
+		t.Errorf("got expiry %v, want %v", gotExpiry, expiry)
+	}
+
+	// A stale token is never returned.
+	clnt.SetTokenWithExpiry("token", time.Now().Add(-time.Second))
+	if token, _, ok = clnt.TokenWithExpiry(); ok || token != "" {
+		t.Errorf("got expired token %q", token)
+	}
+}
+
+func TestDisableAutoLogin(t *testing.T) {
+	t.Parallel()
+
+	var logins, calls int32
+	var unauthorized int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+		if r.URL.Path == endpointLogin {
+			atomic.AddInt32(&logins, 1)
+			fmt.Fprint(w, `{"access_token":"token","expires_in":600}`)
+			return
+		}
+
+		atomic.AddInt32(&calls, 1)
+
+		if atomic.LoadInt32(&unauthorized) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, `{"value":42}`)
+	}))
+	defer server.Close()
+
+	// The initial login should still be performed.
+	var clnt, err = NewClient(context.Background(), &Config{
+		URL:              server.URL,
+		APIKey:           "key",
+		APISecret:        "secret",
+		DisableAutoLogin: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&logins); got != 1 {
+		t.Fatalf("got %d logins, want 1", got)
+	}
+
+	if _, err = clnt.CounterCertsIssued(context.Background()); err != nil {
+		t.Fatalf("failed to get counter: %v", err)
+	}
+
+	// An expired token should cause an immediate failure without a login
+	// or a request.
+	clnt.SetTokenWithExpiry("token", time.Now().Add(-time.Second))
+
+	if _, err = clnt.CounterCertsIssued(context.Background()); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrTokenExpired)
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("got %d calls, want 1", got)
+	}
+
+	// An explicit login should allow calls to succeed again.
+	if err = clnt.Login(context.Background()); err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	if _, err = clnt.CounterCertsIssued(context.Background()); err != nil {
+		t.Fatalf("failed to get counter after login: %v", err)
+	}
+
+	// A token rejected by HVCA should also fail without a login, and should
+	// be forgotten so the next call fails immediately.
+	atomic.StoreInt32(&unauthorized, 1)
+
+	if _, err = clnt.CounterCertsIssued(context.Background()); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrTokenExpired)
+	}
+
+	var apiErr APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got error %v, want API error with status %d", err, http.StatusUnauthorized)
+	}
+
+	if !clnt.tokenHasExpired() {
+		t.Errorf("rejected token unexpectedly not expired")
+	}
+
+	if got := atomic.LoadInt32(&logins); got != 2 {
+		t.Errorf("got %d logins, want 2", got)
+	}
+}
+
+func TestLoginOnlyIfExpired(t *testing.T) {
+	t.Parallel()
+
+	var logins int32
+	var server = newLoginCountingServer(t, &logins, 600)
+	defer server.Close()
+
+	var clnt = newTestClient(t, server.URL, nil)
+
+	if err := clnt.Login(context.Background()); err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&logins); got != 0 {
+		t.Fatalf("got %d logins with unexpired token, want 0", got)
+	}
+
+	clnt.SetTokenWithExpiry("token", time.Now().Add(-time.Second))
+
+	if err := clnt.Login(context.Background()); err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&logins); got != 1 {
+		t.Errorf("got %d logins with expired token, want 1", got)
+	}
+}
+
+func TestReloginOnUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		rejected int32
+		calls    int32
+		err      error
+	}{
+		{
+			name:     "Recovers",
+			rejected: 1,
+			calls:    2,
+		},
+		{
+			name:     "RejectedAgain",
+			rejected: 10,
+			calls:    2,
+			err:      ErrAuthFailed,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var logins, calls int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == endpointLogin {
+					atomic.AddInt32(&logins, 1)
+					w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+					fmt.Fprint(w, `{"access_token":"new-token"}`)
+					return
+				}
+
+				// Reject the original token, and the new token as many
+				// times as requested.
+				if atomic.AddInt32(&calls, 1) <= tc.rejected ||
+					r.Header.Get(httputils.AuthorizationHeader) != "Bearer new-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			// The stored token is not believed to be expired.
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+			var _, err = clnt.makeRequest(context.Background(), "/test", http.MethodGet, nil, nil)
+			if tc.err == nil && err != nil {
+				t.Fatalf("failed to make request: %v", err)
+			} else if tc.err != nil && !errors.Is(err, tc.err) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+
+			if got := atomic.LoadInt32(&logins); got != 1 {
+				t.Errorf("got %d logins, want 1", got)
+			}
+
+			if got := atomic.LoadInt32(&calls); got != tc.calls {
+				t.Errorf("got %d calls, want %d", got, tc.calls)
+			}
+		})
+	}
+}
+
+func TestLoginRetry(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		failures int32
+		status   int
+		logins   int32
+		wantErr  bool
+	}{
+		{
+			name:     "Recovers",
+			failures: 1,
+			status:   http.StatusServiceUnavailable,
+			logins:   2,
+		},
+		{
+			name:     "Persistent",
+			failures: 10,
+			status:   http.StatusBadGateway,
+			logins:   loginMaxAttempts,
+			wantErr:  true,
+		},
+		{
+			name:     "NotRetryable",
+			failures: 10,
+			status:   http.StatusUnauthorized,
+			logins:   1,
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var logins int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&logins, 1) <= tc.failures {
+					w.WriteHeader(tc.status)
+					return
+				}
+
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+				fmt.Fprint(w, `{"access_token":"token"}`)
+			}))
+			defer server.Close()
+
+			// Login must be retried even though the configured retry
+			// policy disables retries.
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+			var err = clnt.RefreshToken(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+
+			if tc.wantErr {
+				if !errors.Is(err, ErrLoginFailed) {
+					t.Errorf("got error %v, want %v", err, ErrLoginFailed)
+				}
+
+				var apiErr APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tc.status {
+					t.Errorf("got error %v, want wrapped status %d", err, tc.status)
+				}
+			}
+
+			if got := atomic.LoadInt32(&logins); got != tc.logins {
+				t.Errorf("got %d logins, want %d", got, tc.logins)
+			}
+		})
+	}
+}
+
+func TestReloginIfTokenRejectedReplaced(t *testing.T) {
+	t.Parallel()
+
+	var logins int32
+	var server = newLoginCountingServer(t, &logins, 600)
+	defer server.Close()
+
+	var clnt = newTestClient(t, server.URL, nil)
+
+	// Another goroutine has already replaced the rejected token, so no
+	// login should be made.
+	if err := clnt.reloginIfTokenRejected(context.Background(), "old-token"); err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&logins); got != 0 {
+		t.Errorf("got %d logins, want 0", got)
+	}
+
+	if err := clnt.reloginIfTokenRejected(context.Background(), clnt.GetToken()); err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&logins); got != 1 {
+		t.Errorf("got %d logins, want 1", got)
+	}
+}
+
+func TestLoginWaitHonoursContext(t *testing.T) {
+	t.Parallel()
+
+	var logins int32
+	var server = newLoginCountingServer(t, &logins, 600)
+	defer server.Close()
+
+	var clnt = newTestClient(t, server.URL, nil)
+	clnt.SetTokenWithExpiry("token", time.Now().Add(-time.Second))
+
+	// Hold the login semaphore, as a goroutine performing a slow login
+	// would.
+	if err := clnt.acquireLogin(context.Background()); err != nil {
+		t.Fatalf("failed to acquire login semaphore: %v", err)
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	var start = time.Now()
+	if err := clnt.Login(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waited %v for login semaphore after context was done", elapsed)
+	}
+
+	// Once the semaphore is released, logins proceed as normal.
+	clnt.releaseLogin()
+
+	if err := clnt.Login(context.Background()); err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&logins); got != 1 {
+		t.Errorf("got %d logins, want 1", got)
+	}
+}
+
+// newLoginCountingServer returns a server which responds to every request
+// with a login response with the specified token lifetime in seconds, and
+// counts the number of requests made.
+func newLoginCountingServer(t *testing.T, count *int32, expiresIn int) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(count, 1)
+
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+		fmt.Fprintf(w, `{"access_token":"token","expires_in":%d}`, expiresIn)
+	}))
+}
@@ -16,9 +16,16 @@ limitations under the License.
 package hvclient_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"net/http"
 	"testing"
@@ -104,6 +111,67 @@ func TestClientMockNew(t *testing.T) {
 	}
 }
 
+func TestClientMockOnLogin(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name   string
+		apiKey string
+		err    bool
+	}{
+		{
+			name:   "OK",
+			apiKey: mockAPIKey,
+		},
+		{
+			name:   "WrongAPIKey",
+			apiKey: "wrong_key",
+			err:    true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var testServer = newMockServer(t)
+			defer testServer.Close()
+
+			var ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			var calls int
+			var hookErr error
+
+			var _, err = hvclient.NewClient(ctx, &hvclient.Config{
+				URL:       testServer.URL,
+				APIKey:    tc.apiKey,
+				APISecret: mockAPISecret,
+				ExtraHeaders: map[string]string{
+					sslClientSerialHeader: mockSSLClientSerial,
+				},
+				OnLogin: func(ctx context.Context, elapsed time.Duration, err error) {
+					calls++
+					hookErr = err
+				},
+			})
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error %t", err, tc.err)
+			}
+
+			if calls != 1 {
+				t.Fatalf("got %d calls to login hook, want 1", calls)
+			}
+
+			if (hookErr != nil) != tc.err {
+				t.Fatalf("got login hook error %v, want error %t", hookErr, tc.err)
+			}
+		})
+	}
+}
+
 func TestClientMockCertificatesRequest(t *testing.T) {
 	t.Parallel()
 
@@ -170,6 +238,305 @@ func TestClientMockCertificatesRequest(t *testing.T) {
 	}
 }
 
+func TestClientMockCertificateRequestWithKeygen(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var req = &hvclient.Request{
+		Validity: &hvclient.Validity{
+			NotBefore: time.Now(),
+			NotAfter:  time.Unix(0, 0),
+		},
+		Subject: &hvclient.DN{CommonName: "John Doe"},
+	}
+
+	var info, key, err = client.CertificateRequestWithKeygen(ctx, req)
+	if err != nil {
+		t.Fatalf("failed to request certificate: %v", err)
+	}
+
+	if !info.X509.Equal(mockCert) {
+		t.Errorf("got certificate %v, want %v", info.X509.SerialNumber, mockCert.SerialNumber)
+	}
+
+	// The mock policy allows ECDSA keys of 256, 384 or 521 bits.
+	if k, ok := key.(*ecdsa.PrivateKey); !ok || k.Curve != elliptic.P256() {
+		t.Errorf("got key %T, want P-256 ECDSA key", key)
+	}
+
+	if req.CSR != nil || req.PublicKey != nil || req.PrivateKey != nil {
+		t.Errorf("request was modified")
+	}
+
+	req.PublicKey = info.X509.PublicKey
+	if _, _, err = client.CertificateRequestWithKeygen(ctx, req); err == nil {
+		t.Errorf("unexpectedly requested certificate for request with public key")
+	}
+}
+
+func TestClientMockCertificateRequestFromCSR(t *testing.T) {
+	t.Parallel()
+
+	var csrPEM, err = ioutil.ReadFile("testdata/test_csr.pem")
+	if err != nil {
+		t.Fatalf("failed to read CSR: %v", err)
+	}
+
+	// Corrupt the signature, which is at the end of the CSR.
+	var block, _ = pem.Decode(csrPEM)
+	var der = append([]byte{}, block.Bytes...)
+	der[len(der)-1] ^= 0xff
+	var badSigPEM = pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})
+
+	var testcases = []struct {
+		name string
+		csr  []byte
+		err  bool
+	}{
+		{
+			name: "OK",
+			csr:  csrPEM,
+		},
+		{
+			name: "BadSignature",
+			csr:  badSigPEM,
+			err:  true,
+		},
+		{
+			name: "NotPEM",
+			csr:  []byte("not a CSR"),
+			err:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var client, closefunc = newMockClient(t)
+			defer closefunc()
+
+			var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var got, err = client.CertificateRequestFromCSR(ctx, tc.csr, &hvclient.Validity{
+				NotBefore: time.Now(),
+				NotAfter:  time.Unix(0, 0),
+			})
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error %t", err, tc.err)
+			}
+
+			if tc.err {
+				return
+			}
+
+			if fmt.Sprintf("%X", got) != mockCertSerial {
+				t.Fatalf("got %X, want %s", got, mockCertSerial)
+			}
+		})
+	}
+}
+
+func TestClientMockCertificateRequestWithResult(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		cn       string
+		location string
+	}{
+		{
+			name:     "Absolute",
+			cn:       "John Doe",
+			location: "http://local/certificates/" + mockCertSerial,
+		},
+		{
+			name: "Relative",
+			cn:   triggerRelativeLocation,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var client, closefunc = newMockClient(t)
+			defer closefunc()
+
+			var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var csr, err = pki.CSRFromFile("testdata/test_csr.pem")
+			if err != nil {
+				t.Fatalf("failed to read CSR: %v", err)
+			}
+
+			var got *hvclient.CertificateRequestResult
+			got, err = client.CertificateRequestWithResult(
+				ctx,
+				&hvclient.Request{
+					Subject: &hvclient.DN{CommonName: tc.cn},
+					CSR:     csr,
+				},
+			)
+			if err != nil {
+				t.Fatalf("failed to request certificate: %v", err)
+			}
+
+			// A relative location should be resolved against the URL of
+			// the mock server.
+			var wantLocation = tc.location
+			if wantLocation == "" {
+				wantLocation = client.BaseURL.String() + "/certificates/" + mockCertSerial
+			}
+
+			if got.Location.String() != wantLocation {
+				t.Errorf("got location %s, want %s", got.Location, wantLocation)
+			}
+
+			if fmt.Sprintf("%X", got.Serial) != mockCertSerial {
+				t.Errorf("got serial %X, want %s", got.Serial, mockCertSerial)
+			}
+
+			if got.RequestID != mockRequestID {
+				t.Errorf("got request ID %q, want %q", got.RequestID, mockRequestID)
+			}
+		})
+	}
+}
+
+func TestClientMockCertificateRequestValidate(t *testing.T) {
+	t.Parallel()
+
+	var csr, err = pki.CSRFromFile("testdata/test_csr.pem")
+	if err != nil {
+		t.Fatalf("failed to read CSR: %v", err)
+	}
+
+	var now = time.Now()
+
+	var testcases = []struct {
+		name   string
+		req    *hvclient.Request
+		fields []string
+	}{
+		{
+			name: "OK",
+			req: &hvclient.Request{
+				Validity: &hvclient.Validity{
+					NotBefore: now,
+					NotAfter:  now.Add(time.Hour * 24),
+				},
+				Subject: &hvclient.DN{CommonName: "John"},
+				CSR:     csr,
+			},
+		},
+		{
+			name: "Violations",
+			req: &hvclient.Request{
+				Validity: &hvclient.Validity{
+					NotBefore: now,
+					NotAfter:  now.Add(time.Minute),
+				},
+				Subject: &hvclient.DN{CommonName: "John Doe"},
+				CSR:     csr,
+			},
+			fields: []string{"validity", "subject_dn.common_name"},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var client, closefunc = newMockClient(t)
+			defer closefunc()
+
+			var observer recordingObserver
+			client.Config.MetricsObserver = &observer
+
+			var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var err = client.CertificateRequestValidate(ctx, tc.req)
+			if (err == nil) != (tc.fields == nil) {
+				t.Fatalf("got error %v, want violations of %v", err, tc.fields)
+			}
+
+			if tc.fields != nil {
+				if !errors.Is(err, hvclient.ErrPolicyViolation) {
+					t.Errorf("error %v does not match ErrPolicyViolation", err)
+				}
+
+				var verr hvclient.PolicyViolationsError
+				if !errors.As(err, &verr) {
+					t.Fatalf("got error %v, want PolicyViolationsError", err)
+				}
+
+				var got []string
+				for _, v := range verr.Violations {
+					var pv hvclient.PolicyViolation
+					if errors.As(v, &pv) {
+						got = append(got, pv.Field)
+					}
+				}
+
+				if !cmp.Equal(got, tc.fields) {
+					t.Errorf("got violations of %v, want %v", got, tc.fields)
+				}
+			}
+
+			// Only the validation policy should have been requested, so
+			// that no issuance quota is consumed.
+			for _, obs := range observer.observations {
+				if obs.endpoint != "Policy" {
+					t.Errorf("unexpected request to %s", obs.endpoint)
+				}
+			}
+		})
+	}
+}
+
+func TestClientMockCertificateRequestValidateBadRequest(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var err = client.CertificateRequestValidate(ctx, &hvclient.Request{
+		SAN: &hvclient.SAN{
+			OtherNames: []hvclient.OtherName{
+				{
+					OID:       asn1.ObjectIdentifier{1, 2, 3, 4},
+					ValueType: hvclient.Nil,
+				},
+			},
+		},
+	})
+	if err == nil {
+		t.Fatal("unexpectedly validated request")
+	}
+
+	if errors.Is(err, hvclient.ErrPolicyViolation) {
+		t.Errorf("error %v unexpectedly matches ErrPolicyViolation", err)
+	}
+}
+
 func TestClientMockCertificatesRetrieve(t *testing.T) {
 	t.Parallel()
 
@@ -189,11 +556,137 @@ func TestClientMockCertificatesRetrieve(t *testing.T) {
 				UpdatedAt: time.Date(2021, 6, 18, 16, 29, 51, 0, time.UTC),
 			},
 		},
+		{
+			name:   "Revoked",
+			serial: mockBigIntRevoked,
+			want: hvclient.CertInfo{
+				PEM:       pki.CertToPEMString(mockCert),
+				X509:      mockCert,
+				Status:    hvclient.StatusRevoked,
+				UpdatedAt: mockDateUpdated,
+				Revoked:   true,
+				RevokedAt: mockDateUpdated,
+			},
+		},
+		{
+			name:   "NotFound",
+			serial: mockBigIntNotFound,
+			err:    hvclient.APIError{StatusCode: http.StatusNotFound},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var client, closefunc = newMockClient(t)
+			defer closefunc()
+
+			var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var got, err = client.CertificateRetrieve(ctx, tc.serial)
+			if (err == nil) != (tc.err == nil) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+
+			if tc.err != nil {
+				verifyAPIError(t, err, tc.err)
+				return
+			}
+
+			if !got.Equal(tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClientMockCertificatesRevoke(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name   string
+		serial *big.Int
+		err    error
+	}{
+		{
+			name:   "OK",
+			serial: big.NewInt(0x741daf9ec2d5f7dc),
+		},
+		{
+			name:   "NotFound",
+			serial: mockBigIntNotFound,
+			err:    hvclient.APIError{StatusCode: http.StatusNotFound},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var client, closefunc = newMockClient(t)
+			defer closefunc()
+
+			var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var err = client.CertificateRevoke(ctx, tc.serial)
+			if (err == nil) != (tc.err == nil) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+
+			if tc.err != nil {
+				verifyAPIError(t, err, tc.err)
+				return
+			}
+		})
+	}
+}
+
+func TestClientMockCertificatesRevokeWithReason(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name   string
+		serial *big.Int
+		reason hvclient.RevocationReason
+		time   int64
+		err    error
+	}{
+		{
+			name:   "OK",
+			serial: big.NewInt(0x741daf9ec2d5f7dc),
+			reason: hvclient.RevocationReasonUnspecified,
+			time:   0,
+		},
+		{
+			name:   "Unspecified",
+			serial: big.NewInt(0x741daf9ec2d5f7dc),
+			reason: hvclient.ReasonUnspecified,
+			time:   0,
+		},
+		{
+			name:   "KeyCompromiseWithTime",
+			serial: big.NewInt(0x741daf9ec2d5f7dc),
+			reason: hvclient.ReasonKeyCompromise,
+			time:   1609459200,
+		},
 		{
 			name:   "NotFound",
 			serial: mockBigIntNotFound,
 			err:    hvclient.APIError{StatusCode: http.StatusNotFound},
 		},
+		{
+			name:   "UnsupportedReason",
+			serial: big.NewInt(0x741daf9ec2d5f7dc),
+			reason: hvclient.ReasonRemoveFromCRL,
+			err:    errors.New("unsupported revocation reason: removeFromCRL"),
+		},
 	}
 
 	for _, tc := range testcases {
@@ -208,34 +701,50 @@ func TestClientMockCertificatesRetrieve(t *testing.T) {
 			var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
 			defer cancel()
 
-			var got, err = client.CertificateRetrieve(ctx, tc.serial)
+			var err = client.CertificateRevokeWithReason(ctx, tc.serial, tc.reason, tc.time)
 			if (err == nil) != (tc.err == nil) {
 				t.Fatalf("got error %v, want %v", err, tc.err)
 			}
 
 			if tc.err != nil {
+				// Unsupported reasons are rejected before any request
+				// is made, so no API error is expected.
+				if !errors.As(tc.err, &hvclient.APIError{}) {
+					if err.Error() != tc.err.Error() {
+						t.Fatalf("got error %v, want %v", err, tc.err)
+					}
+
+					return
+				}
+
 				verifyAPIError(t, err, tc.err)
 				return
 			}
-
-			if !got.Equal(tc.want) {
-				t.Fatalf("got %v, want %v", got, tc.want)
-			}
 		})
 	}
 }
 
-func TestClientMockCertificatesRevoke(t *testing.T) {
+func TestClientMockCertificateStatus(t *testing.T) {
 	t.Parallel()
 
 	var testcases = []struct {
-		name   string
-		serial *big.Int
-		err    error
+		name      string
+		serial    *big.Int
+		want      hvclient.CertStatus
+		revokedAt time.Time
+		err       error
 	}{
 		{
-			name:   "OK",
+			// The mock certificate is past its expiry time.
+			name:   "Expired",
 			serial: big.NewInt(0x741daf9ec2d5f7dc),
+			want:   hvclient.StatusExpired,
+		},
+		{
+			name:      "Revoked",
+			serial:    mockBigIntRevoked,
+			want:      hvclient.StatusRevoked,
+			revokedAt: mockDateUpdated,
 		},
 		{
 			name:   "NotFound",
@@ -256,7 +765,7 @@ func TestClientMockCertificatesRevoke(t *testing.T) {
 			var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
 			defer cancel()
 
-			var err = client.CertificateRevoke(ctx, tc.serial)
+			var status, revokedAt, err = client.CertificateStatus(ctx, tc.serial)
 			if (err == nil) != (tc.err == nil) {
 				t.Fatalf("got error %v, want %v", err, tc.err)
 			}
@@ -265,25 +774,49 @@ func TestClientMockCertificatesRevoke(t *testing.T) {
 				verifyAPIError(t, err, tc.err)
 				return
 			}
+
+			if status != tc.want {
+				t.Errorf("got status %v, want %v", status, tc.want)
+			}
+
+			if !revokedAt.Equal(tc.revokedAt) {
+				t.Errorf("got revocation time %v, want %v", revokedAt, tc.revokedAt)
+			}
 		})
 	}
 }
 
-func TestClientMockCertificatesRevokeWithReason(t *testing.T) {
+func TestClientMockCertificateMeta(t *testing.T) {
 	t.Parallel()
 
 	var testcases = []struct {
 		name   string
 		serial *big.Int
-		reason hvclient.RevocationReason
-		time   int64
+		want   hvclient.CertMeta
 		err    error
 	}{
 		{
-			name:   "OK",
+			// The mock certificate is past its expiry time.
+			name:   "Expired",
 			serial: big.NewInt(0x741daf9ec2d5f7dc),
-			reason: hvclient.RevocationReasonUnspecified,
-			time:   0,
+			want: hvclient.CertMeta{
+				SerialNumber: mockCert.SerialNumber,
+				NotBefore:    mockCert.NotBefore,
+				NotAfter:     mockCert.NotAfter,
+				Subject:      mockCert.Subject.String(),
+				Status:       hvclient.StatusExpired,
+			},
+		},
+		{
+			name:   "Revoked",
+			serial: mockBigIntRevoked,
+			want: hvclient.CertMeta{
+				SerialNumber: mockCert.SerialNumber,
+				NotBefore:    mockCert.NotBefore,
+				NotAfter:     mockCert.NotAfter,
+				Subject:      mockCert.Subject.String(),
+				Status:       hvclient.StatusRevoked,
+			},
 		},
 		{
 			name:   "NotFound",
@@ -304,7 +837,7 @@ func TestClientMockCertificatesRevokeWithReason(t *testing.T) {
 			var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
 			defer cancel()
 
-			var err = client.CertificateRevokeWithReason(ctx, tc.serial, tc.reason, tc.time)
+			var got, err = client.CertificateMeta(ctx, tc.serial)
 			if (err == nil) != (tc.err == nil) {
 				t.Fatalf("got error %v, want %v", err, tc.err)
 			}
@@ -313,8 +846,171 @@ func TestClientMockCertificatesRevokeWithReason(t *testing.T) {
 				verifyAPIError(t, err, tc.err)
 				return
 			}
+
+			if !got.Equal(tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+
+			// Metadata served from the certificate cache must match.
+			if _, err = client.CertificateRetrieve(ctx, tc.serial); err != nil {
+				t.Fatalf("couldn't retrieve certificate: %v", err)
+			}
+
+			got, err = client.CertificateMeta(ctx, tc.serial)
+			if err != nil {
+				t.Fatalf("couldn't get cached certificate metadata: %v", err)
+			}
+
+			if !got.Equal(tc.want) {
+				t.Fatalf("got cached %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClientMockCertificatesRevokeBatch(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var serials = []*big.Int{
+		big.NewInt(0x741daf9ec2d5f7dc),
+		mockBigIntNotFound,
+		big.NewInt(0x741daf9ec2d5f7dd),
+	}
+
+	var result, err = client.CertificatesRevoke(ctx, serials, hvclient.ReasonKeyCompromise)
+	if err != nil {
+		t.Fatalf("failed to revoke certificates: %v", err)
+	}
+
+	if result.Reason != hvclient.ReasonKeyCompromise {
+		t.Errorf("got reason %v, want %v", result.Reason, hvclient.ReasonKeyCompromise)
+	}
+
+	if got := result.Succeeded(); len(got) != 2 {
+		t.Errorf("got %d successful revocations, want 2", len(got))
+	}
+
+	var failed = result.Failed()
+	if len(failed) != 1 || failed[0].Serial.Cmp(mockBigIntNotFound) != 0 {
+		t.Fatalf("got failed revocations %v, want only %v", failed, mockBigIntNotFound)
+	}
+
+	verifyAPIError(t, result.Err(mockBigIntNotFound), hvclient.APIError{StatusCode: http.StatusNotFound})
+
+	if err := result.Err(serials[0]); err != nil {
+		t.Errorf("got error %v for successful revocation", err)
+	}
+}
+
+func TestClientMockCertificatesRevokeBatchCancelled(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	var serials = []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}
+
+	var result, err = client.CertificatesRevoke(ctx, serials, hvclient.ReasonUnspecified)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+
+	if len(result.Results) != len(serials) {
+		t.Fatalf("got %d results, want %d", len(result.Results), len(serials))
+	}
+
+	for _, item := range result.Results {
+		if !errors.Is(item.Err, context.Canceled) {
+			t.Errorf("got error %v for serial %v, want %v", item.Err, item.Serial, context.Canceled)
+		}
+	}
+}
+
+func TestClientMockCertificatesRequestBatch(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var csr, err = pki.CSRFromFile("testdata/test_csr.pem")
+	if err != nil {
+		t.Fatalf("failed to read CSR: %v", err)
+	}
+
+	var reqs []*hvclient.Request
+	for _, cn := range []string{"John Doe", triggerError, "Jane Doe"} {
+		reqs = append(reqs, &hvclient.Request{
+			Validity: &hvclient.Validity{NotBefore: time.Now(), NotAfter: time.Unix(0, 0)},
+			Subject:  &hvclient.DN{CommonName: cn},
+			CSR:      csr,
 		})
 	}
+
+	var results []hvclient.BatchIssueResult
+	if results, err = client.CertificatesRequest(ctx, reqs, 2); err != nil {
+		t.Fatalf("failed to request certificates: %v", err)
+	}
+
+	if len(results) != len(reqs) {
+		t.Fatalf("got %d results, want %d", len(results), len(reqs))
+	}
+
+	for _, i := range []int{0, 2} {
+		if results[i].Err != nil {
+			t.Errorf("got error %v for request %d", results[i].Err, i)
+		} else if fmt.Sprintf("%X", results[i].Serial) != mockCertSerial {
+			t.Errorf("got serial %X for request %d, want %s", results[i].Serial, i, mockCertSerial)
+		}
+	}
+
+	if results[1].Serial != nil {
+		t.Errorf("got serial %X for failed request", results[1].Serial)
+	}
+
+	verifyAPIError(t, results[1].Err, hvclient.APIError{StatusCode: http.StatusUnprocessableEntity})
+
+	if _, err = client.CertificatesRequest(ctx, reqs, -1); err == nil {
+		t.Errorf("unexpectedly succeeded with negative concurrency")
+	}
+}
+
+func TestClientMockCertificatesRequestBatchCancelled(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	var reqs = []*hvclient.Request{{}, {}}
+
+	var results, err = client.CertificatesRequest(ctx, reqs, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+
+	if len(results) != len(reqs) {
+		t.Fatalf("got %d results, want %d", len(results), len(reqs))
+	}
+
+	for i, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("got error %v for request %d, want %v", result.Err, i, context.Canceled)
+		}
+	}
 }
 
 func TestClientMockClaimsDomains(t *testing.T) {
@@ -416,6 +1112,118 @@ func TestClientMockClaimsDomains(t *testing.T) {
 	}
 }
 
+func TestClientMockClaimsIterator(t *testing.T) {
+	t.Parallel()
+
+	var pending []string
+	var verified []string
+	for _, entry := range mockClaimsEntries {
+		if entry.Status == "VERIFIED" {
+			verified = append(verified, entry.ID)
+		} else {
+			pending = append(pending, entry.ID)
+		}
+	}
+
+	var testcases = []struct {
+		name     string
+		status   hvclient.ClaimStatus
+		pageSize int
+		want     []string
+	}{
+		{
+			name:   "PendingOnePage",
+			status: hvclient.StatusPending,
+			want:   pending,
+		},
+		{
+			name:     "PendingPartialPage",
+			status:   hvclient.StatusPending,
+			pageSize: 2,
+			want:     pending,
+		},
+		{
+			name:     "PendingSinglePages",
+			status:   hvclient.StatusPending,
+			pageSize: 1,
+			want:     pending,
+		},
+		{
+			name:     "Verified",
+			status:   hvclient.StatusVerified,
+			pageSize: 1,
+			want:     verified,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var client, closefunc = newMockClient(t)
+			defer closefunc()
+
+			var ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			var iter = client.ClaimsIterator(ctx, tc.status)
+			if tc.pageSize != 0 {
+				iter.PageSize = tc.pageSize
+			}
+
+			var got []string
+			for iter.Next() {
+				if iter.Item().Status != tc.status {
+					t.Errorf("got claim %s with status %v, want %v", iter.Item().ID, iter.Item().Status, tc.status)
+				}
+
+				got = append(got, iter.Item().ID)
+			}
+
+			if err := iter.Err(); err != nil {
+				t.Fatalf("failed to iterate: %v", err)
+			}
+
+			if !cmp.Equal(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+
+			if iter.Total() != int64(len(tc.want)) {
+				t.Fatalf("got total %d, want %d", iter.Total(), len(tc.want))
+			}
+		})
+	}
+}
+
+func TestClientMockClaimsIteratorCancelled(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var iter = client.ClaimsIterator(ctx, hvclient.StatusPending)
+	iter.PageSize = 1
+
+	if !iter.Next() {
+		t.Fatalf("failed to get first claim: %v", iter.Err())
+	}
+
+	cancel()
+
+	if iter.Next() {
+		t.Fatal("unexpectedly got claim after context was cancelled")
+	}
+
+	if !errors.Is(iter.Err(), context.Canceled) {
+		t.Fatalf("got error %v, want %v", iter.Err(), context.Canceled)
+	}
+}
+
 func TestClientMockClaimDelete(t *testing.T) {
 	t.Parallel()
 
@@ -852,19 +1660,92 @@ func TestClientMockClaimRetrieve(t *testing.T) {
 				t.Fatalf("got error %v, want %v", err, tc.err)
 			}
 
-			if tc.err != nil {
-				verifyAPIError(t, err, tc.err)
-				return
-			}
+			if tc.err != nil {
+				verifyAPIError(t, err, tc.err)
+				return
+			}
+
+			if !cmp.Equal(got, &tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClientMockCounterCertsIssued(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var got, err = client.CounterCertsIssued(ctx)
+	if err != nil {
+		t.Fatalf("failed to get count of certificates issued: %v", err)
+	}
+
+	if got != mockCounterIssued {
+		t.Fatalf("got %d, want %d", got, mockCounterIssued)
+	}
+}
+
+func TestClientMockCounterCertsRevoked(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var got, err = client.CounterCertsRevoked(ctx)
+	if err != nil {
+		t.Fatalf("failed to get count of certificates revoked: %v", err)
+	}
+
+	if got != mockCounterRevoked {
+		t.Fatalf("got %d, want %d", got, mockCounterRevoked)
+	}
+}
+
+func TestClientMockQuotaProjection(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var window = time.Hour * 24 * 30
+
+	var got, err = client.QuotaProjection(ctx, window)
+	if err != nil {
+		t.Fatalf("failed to get quota projection: %v", err)
+	}
+
+	var wantQuota = hvclient.Quota{
+		Used:  mockCounterIssued,
+		Total: mockCounterIssued + mockQuotaIssuance,
+	}
+
+	if got.Quota != wantQuota || got.Window != window || got.Issued != int64(len(mockStatsIssuedData)) || got.Never {
+		t.Fatalf("got %+v, want quota %+v, window %v and %d issued", *got, wantQuota, window, len(mockStatsIssuedData))
+	}
+
+	var want = got.At.Add(window * mockQuotaIssuance / time.Duration(len(mockStatsIssuedData)))
+	if !got.ExhaustedAt.Equal(want) {
+		t.Errorf("got exhausted at %v, want %v", got.ExhaustedAt, want)
+	}
 
-			if !cmp.Equal(got, &tc.want) {
-				t.Fatalf("got %v, want %v", got, tc.want)
-			}
-		})
+	if _, err = client.QuotaProjection(ctx, 0); err == nil {
+		t.Errorf("unexpectedly succeeded with zero window")
 	}
 }
 
-func TestClientMockCounterCertsIssued(t *testing.T) {
+func TestClientMockQuotasIssuance(t *testing.T) {
 	t.Parallel()
 
 	var client, closefunc = newMockClient(t)
@@ -873,17 +1754,17 @@ func TestClientMockCounterCertsIssued(t *testing.T) {
 	var ctx, cancel = context.WithCancel(context.Background())
 	defer cancel()
 
-	var got, err = client.CounterCertsIssued(ctx)
+	var got, err = client.QuotaIssuance(ctx)
 	if err != nil {
-		t.Fatalf("failed to get count of certificates issued: %v", err)
+		t.Fatalf("failed to get issuance quota: %v", err)
 	}
 
-	if got != mockCounterIssued {
-		t.Fatalf("got %d, want %d", got, mockCounterIssued)
+	if got != mockQuotaIssuance {
+		t.Fatalf("got %d, want %d", got, mockQuotaIssuance)
 	}
 }
 
-func TestClientMockCounterCertsRevoked(t *testing.T) {
+func TestClientMockIssuanceQuota(t *testing.T) {
 	t.Parallel()
 
 	var client, closefunc = newMockClient(t)
@@ -892,17 +1773,26 @@ func TestClientMockCounterCertsRevoked(t *testing.T) {
 	var ctx, cancel = context.WithCancel(context.Background())
 	defer cancel()
 
-	var got, err = client.CounterCertsRevoked(ctx)
+	var got, err = client.IssuanceQuota(ctx)
 	if err != nil {
-		t.Fatalf("failed to get count of certificates revoked: %v", err)
+		t.Fatalf("failed to get issuance quota: %v", err)
 	}
 
-	if got != mockCounterRevoked {
-		t.Fatalf("got %d, want %d", got, mockCounterRevoked)
+	var want = hvclient.Quota{
+		Used:  mockCounterIssued,
+		Total: mockCounterIssued + mockQuotaIssuance,
+	}
+
+	if *got != want {
+		t.Fatalf("got %+v, want %+v", *got, want)
+	}
+
+	if got.Remaining() != mockQuotaIssuance {
+		t.Errorf("got %d remaining, want %d", got.Remaining(), mockQuotaIssuance)
 	}
 }
 
-func TestClientMockQuotasIssuance(t *testing.T) {
+func TestClientMockCounters(t *testing.T) {
 	t.Parallel()
 
 	var client, closefunc = newMockClient(t)
@@ -911,13 +1801,25 @@ func TestClientMockQuotasIssuance(t *testing.T) {
 	var ctx, cancel = context.WithCancel(context.Background())
 	defer cancel()
 
-	var got, err = client.QuotaIssuance(ctx)
+	var got, err = client.Counters(ctx)
 	if err != nil {
-		t.Fatalf("failed to get issuance quota: %v", err)
+		t.Fatalf("failed to get counters: %v", err)
 	}
 
-	if got != mockQuotaIssuance {
-		t.Fatalf("got %d, want %d", got, mockQuotaIssuance)
+	var want = hvclient.Counters{
+		Issued:  mockCounterIssued,
+		Revoked: mockCounterRevoked,
+	}
+
+	if *got != want {
+		t.Errorf("got %+v, want %+v", *got, want)
+	}
+
+	// Both requests should fail if the context is already done.
+	cancel()
+
+	if _, err = client.Counters(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
 	}
 }
 
@@ -1092,6 +1994,234 @@ func TestClientMockStatsRevoked(t *testing.T) {
 	}
 }
 
+func TestClientMockStatsCount(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var testcases = []struct {
+		name  string
+		count func(ctx context.Context, from, to time.Time) (int64, error)
+		want  int
+	}{
+		{
+			name:  "Issued",
+			count: client.CountIssued,
+			want:  len(mockStatsIssuedData),
+		},
+		{
+			name:  "Revoked",
+			count: client.CountRevoked,
+			want:  len(mockStatsIssuedData) - 1,
+		},
+		{
+			name:  "Expiring",
+			count: client.CountExpiring,
+			want:  len(mockStatsExpiringData),
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			var ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			// The mock returns a single item per page, so the count must
+			// come from the total count header.
+			var got, err = tc.count(ctx, time.Time{}, time.Time{})
+			if err != nil {
+				t.Fatalf("failed to get count: %v", err)
+			}
+
+			if got != int64(tc.want) {
+				t.Errorf("got count %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClientMockStatsIterator(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		iter     func(*hvclient.Client, context.Context) *hvclient.StatsIterator
+		pageSize int
+		want     []mockCertMeta
+	}{
+		{
+			name: "ExpiringOnePage",
+			iter: func(c *hvclient.Client, ctx context.Context) *hvclient.StatsIterator {
+				return c.StatsExpiringIterator(ctx, time.Time{}, time.Time{})
+			},
+			want: mockStatsExpiringData,
+		},
+		{
+			name: "ExpiringFullPages",
+			iter: func(c *hvclient.Client, ctx context.Context) *hvclient.StatsIterator {
+				return c.StatsExpiringIterator(ctx, time.Time{}, time.Time{})
+			},
+			pageSize: 2,
+			want:     mockStatsExpiringData,
+		},
+		{
+			name: "IssuedPartialPage",
+			iter: func(c *hvclient.Client, ctx context.Context) *hvclient.StatsIterator {
+				return c.StatsIssuedIterator(ctx, time.Time{}, time.Time{})
+			},
+			pageSize: 2,
+			want:     mockStatsIssuedData,
+		},
+		{
+			name: "Revoked",
+			iter: func(c *hvclient.Client, ctx context.Context) *hvclient.StatsIterator {
+				return c.StatsRevokedIterator(ctx, time.Time{}, time.Time{})
+			},
+			pageSize: 1,
+			want:     mockStatsIssuedData[1:],
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var client, closefunc = newMockClient(t)
+			defer closefunc()
+
+			var ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			var iter = tc.iter(client, ctx)
+			if tc.pageSize != 0 {
+				iter.PageSize = tc.pageSize
+			}
+
+			var got []string
+			for iter.Next() {
+				got = append(got, fmt.Sprintf("%X", iter.Item().SerialNumber))
+			}
+
+			if err := iter.Err(); err != nil {
+				t.Fatalf("failed to iterate: %v", err)
+			}
+
+			var want []string
+			for _, meta := range tc.want {
+				want = append(want, meta.SerialNumber)
+			}
+
+			if !cmp.Equal(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+
+			if iter.Total() != int64(len(tc.want)) {
+				t.Fatalf("got total %d, want %d", iter.Total(), len(tc.want))
+			}
+		})
+	}
+}
+
+// failingWriter is a writer which fails after a number of successful writes.
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.writes <= 0 {
+		return 0, errors.New("write failed")
+	}
+
+	w.writes--
+
+	return len(p), nil
+}
+
+func TestClientMockExportIssued(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var buf bytes.Buffer
+	var count, err = client.ExportIssued(ctx, time.Time{}, time.Time{}, &buf)
+	if err != nil {
+		t.Fatalf("failed to export certificates: %v", err)
+	}
+
+	if count != len(mockStatsIssuedData) {
+		t.Errorf("got count %d, want %d", count, len(mockStatsIssuedData))
+	}
+
+	var blocks int
+	for rest := buf.Bytes(); ; blocks++ {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			if len(bytes.TrimSpace(rest)) != 0 {
+				t.Errorf("unexpected trailing data %q", rest)
+			}
+
+			break
+		}
+
+		if _, err = x509.ParseCertificate(block.Bytes); err != nil {
+			t.Errorf("failed to parse exported certificate: %v", err)
+		}
+	}
+
+	if blocks != count {
+		t.Errorf("got %d PEM blocks, want %d", blocks, count)
+	}
+
+	// A failing writer should stop the export.
+	count, err = client.ExportIssued(ctx, time.Time{}, time.Time{}, &failingWriter{writes: 1})
+	if err == nil || count != 1 {
+		t.Errorf("got count %d and error %v, want count 1 and an error", count, err)
+	}
+
+	// A done context should stop the export.
+	cancel()
+
+	if _, err = client.ExportIssued(ctx, time.Time{}, time.Time{}, &buf); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestClientMockStatsIteratorCancelled(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var iter = client.StatsIssuedIterator(ctx, time.Time{}, time.Time{})
+	iter.PageSize = 1
+
+	if !iter.Next() {
+		t.Fatalf("failed to get first item: %v", iter.Err())
+	}
+
+	cancel()
+
+	if iter.Next() {
+		t.Fatalf("unexpectedly got item after context cancelled")
+	}
+
+	if !errors.Is(iter.Err(), context.Canceled) {
+		t.Fatalf("got error %v, want %v", iter.Err(), context.Canceled)
+	}
+}
+
 func TestClientMockTrustChain(t *testing.T) {
 	t.Parallel()
 
@@ -1111,6 +2241,137 @@ func TestClientMockTrustChain(t *testing.T) {
 	}
 }
 
+func TestClientMockTrustChainCerts(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var got, err = client.TrustChainCerts(ctx)
+	if err != nil {
+		t.Fatalf("failed to get trust chain: %v", err)
+	}
+
+	if !cmp.Equal(got, mockTrustChainCerts) {
+		t.Fatalf("got %v, want %v", got, mockTrustChainCerts)
+	}
+}
+
+func TestClientMockVerifyChain(t *testing.T) {
+	t.Parallel()
+
+	var client, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	// The test certificate has expired, so only verifies as of a time
+	// within its validity period.
+	var err = client.VerifyChain(ctx, mockCert)
+	var invalid x509.CertificateInvalidError
+	if !errors.As(err, &invalid) || invalid.Reason != x509.Expired {
+		t.Fatalf("got error %v, want %v", err, x509.CertificateInvalidError{Reason: x509.Expired})
+	}
+
+	var opts = x509.VerifyOptions{CurrentTime: mockCert.NotBefore.Add(time.Hour)}
+	if err = client.VerifyChainWithOptions(ctx, mockCert, opts); err != nil {
+		t.Fatalf("failed to verify chain: %v", err)
+	}
+
+	// Roots specified in the options replace the root in the trust chain.
+	if err = client.VerifyChainWithOptions(ctx, mockTrustChainCerts[1], x509.VerifyOptions{
+		Roots: x509.NewCertPool(),
+	}); !errors.As(err, &x509.UnknownAuthorityError{}) {
+		t.Fatalf("got error %v, want %T", err, x509.UnknownAuthorityError{})
+	}
+
+	if err = client.VerifyChain(ctx, nil); err == nil {
+		t.Fatalf("unexpectedly verified nil certificate")
+	}
+}
+
+func TestClientMockCertificateRetrieveBundle(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name   string
+		serial *big.Int
+		opts   hvclient.BundleOptions
+		want   []*x509.Certificate
+		err    error
+	}{
+		{
+			name:   "OK",
+			serial: big.NewInt(0x741daf9ec2d5f7dc),
+			want:   []*x509.Certificate{mockCert, mockTrustChainCerts[0]},
+		},
+		{
+			name:   "IncludeRoot",
+			serial: big.NewInt(0x741daf9ec2d5f7dc),
+			opts:   hvclient.BundleOptions{IncludeRoot: true},
+			want:   []*x509.Certificate{mockCert, mockTrustChainCerts[0], mockTrustChainCerts[1]},
+		},
+		{
+			name:   "NotFound",
+			serial: mockBigIntNotFound,
+			err:    hvclient.APIError{StatusCode: http.StatusNotFound},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var client, closefunc = newMockClient(t)
+			defer closefunc()
+
+			var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var got, err = client.CertificateRetrieveBundleWithOptions(ctx, tc.serial, tc.opts)
+			if (err == nil) != (tc.err == nil) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+
+			if tc.err != nil {
+				verifyAPIError(t, err, tc.err)
+				return
+			}
+
+			var certs []*x509.Certificate
+			for rest := got; len(bytes.TrimSpace(rest)) > 0; {
+				var block *pem.Block
+				if block, rest = pem.Decode(rest); block == nil {
+					t.Fatalf("invalid PEM in bundle: %q", rest)
+				}
+
+				var cert, err = x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					t.Fatalf("failed to parse certificate in bundle: %v", err)
+				}
+
+				certs = append(certs, cert)
+			}
+
+			if len(certs) != len(tc.want) {
+				t.Fatalf("got %d certificates, want %d", len(certs), len(tc.want))
+			}
+
+			for i := range certs {
+				if !certs[i].Equal(tc.want[i]) {
+					t.Errorf("got certificate %d %q, want %q", i, certs[i].Subject, tc.want[i].Subject)
+				}
+			}
+		})
+	}
+}
+
 func TestClientMockValidationPolicy(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,87 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoMTLSCertificate is returned by MTLSCertificateExpiry if no mTLS
+// certificate was provided in the configuration.
+var ErrNoMTLSCertificate = errors.New("hvclient: no mTLS certificate configured")
+
+// MTLSCertificateExpiry returns the expiry time of the mTLS certificate
+// provided in the configuration, which is used to authenticate to HVCA. If
+// no mTLS certificate was provided, ErrNoMTLSCertificate is returned.
+func (c *Client) MTLSCertificateExpiry() (time.Time, error) {
+	if c.Config == nil {
+		return time.Time{}, ErrNoMTLSCertificate
+	}
+
+	var cert, err = c.Config.mtlsCertificate()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return cert.NotAfter, nil
+}
+
+// mtlsCertificate returns the parsed mTLS certificate provided in the
+// configuration, or ErrNoMTLSCertificate if none was provided.
+func (c *Config) mtlsCertificate() (*x509.Certificate, error) {
+	switch {
+	case c.TLSCertificate != nil:
+		if c.TLSCertificate.Leaf != nil {
+			return c.TLSCertificate.Leaf, nil
+		}
+
+		if len(c.TLSCertificate.Certificate) == 0 {
+			return nil, ErrNoMTLSCertificate
+		}
+
+		var cert, err = x509.ParseCertificate(c.TLSCertificate.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse mTLS certificate: %w", err)
+		}
+
+		return cert, nil
+
+	case c.TLSCert != nil:
+		return c.TLSCert, nil
+	}
+
+	return nil, ErrNoMTLSCertificate
+}
+
+// warnIfMTLSCertificateExpiring logs a warning and calls the
+// OnMTLSCertificateExpiring hook, if one was provided, if the mTLS
+// certificate expires within the warning period after the specified time.
+func (c *Config) warnIfMTLSCertificateExpiring(ctx context.Context, now time.Time) {
+	var cert, err = c.mtlsCertificate()
+	if err != nil || cert.NotAfter.Sub(now) >= c.mtlsExpiryWarning() {
+		return
+	}
+
+	c.logger().Log(ctx, LogLevelWarn, "mTLS certificate is expiring", logKeyNotAfter, cert.NotAfter)
+
+	if c.OnMTLSCertificateExpiring != nil {
+		c.OnMTLSCertificateExpiring(cert.NotAfter)
+	}
+}
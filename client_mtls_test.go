@@ -0,0 +1,223 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+// newMTLSCertificate returns a self-signed certificate and its private key,
+// expiring at the specified time.
+func newMTLSCertificate(t *testing.T, notAfter time.Time) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	var key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var template = &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    notAfter.Add(-time.Hour * 24 * 365),
+		NotAfter:     notAfter,
+	}
+
+	var der []byte
+	if der, err = x509.CreateCertificate(rand.Reader, template, template, key.Public(), key); err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	var cert *x509.Certificate
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	return cert, key
+}
+
+func TestMTLSCertificateExpiry(t *testing.T) {
+	t.Parallel()
+
+	var notAfter = time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	var cert, key = newMTLSCertificate(t, notAfter)
+
+	var testcases = []struct {
+		name string
+		conf *Config
+		err  error
+	}{
+		{
+			name: "TLSCert",
+			conf: &Config{TLSCert: cert, TLSKey: key},
+		},
+		{
+			name: "TLSCertificate",
+			conf: &Config{TLSCertificate: &tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key}},
+		},
+		{
+			name: "TLSCertificateLeaf",
+			conf: &Config{TLSCertificate: &tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}},
+		},
+		{
+			name: "None",
+			conf: &Config{},
+			err:  ErrNoMTLSCertificate,
+		},
+		{
+			name: "NoConfig",
+			err:  ErrNoMTLSCertificate,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var clnt = &Client{Config: tc.conf}
+
+			var got, err = clnt.MTLSCertificateExpiry()
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+
+			if tc.err == nil && !got.Equal(notAfter) {
+				t.Errorf("got expiry %v, want %v", got, notAfter)
+			}
+		})
+	}
+}
+
+func TestWarnIfMTLSCertificateExpiring(t *testing.T) {
+	t.Parallel()
+
+	var now = time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	var testcases = []struct {
+		name     string
+		notAfter time.Time
+		warning  time.Duration
+		want     bool
+	}{
+		{
+			name:     "DefaultNotExpiring",
+			notAfter: now.Add(time.Hour * 24 * 31),
+		},
+		{
+			name:     "DefaultExpiring",
+			notAfter: now.Add(time.Hour * 24 * 29),
+			want:     true,
+		},
+		{
+			name:     "Expired",
+			notAfter: now.Add(-time.Hour),
+			want:     true,
+		},
+		{
+			name:     "CustomNotExpiring",
+			notAfter: now.Add(time.Hour * 2),
+			warning:  time.Hour,
+		},
+		{
+			name:     "CustomExpiring",
+			notAfter: now.Add(time.Minute * 30),
+			warning:  time.Hour,
+			want:     true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var cert, key = newMTLSCertificate(t, tc.notAfter)
+
+			var got []time.Time
+			var conf = &Config{
+				TLSCert:           cert,
+				TLSKey:            key,
+				MTLSExpiryWarning: tc.warning,
+				OnMTLSCertificateExpiring: func(notAfter time.Time) {
+					got = append(got, notAfter)
+				},
+			}
+
+			conf.warnIfMTLSCertificateExpiring(context.Background(), now)
+
+			if tc.want && (len(got) != 1 || !got[0].Equal(tc.notAfter)) {
+				t.Errorf("got warnings %v, want one for %v", got, tc.notAfter)
+			} else if !tc.want && len(got) != 0 {
+				t.Errorf("got unexpected warnings %v", got)
+			}
+		})
+	}
+}
+
+func TestNewClientMTLSCertificateExpiring(t *testing.T) {
+	t.Parallel()
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+		fmt.Fprint(w, `{"access_token":"token"}`)
+	}))
+	defer server.Close()
+
+	var notAfter = time.Now().Add(time.Hour).Truncate(time.Second)
+	var cert, key = newMTLSCertificate(t, notAfter)
+
+	var got time.Time
+	var logger recordingLogger
+	var _, err = NewClient(context.Background(), &Config{
+		Logger:    &logger,
+		URL:       server.URL,
+		APIKey:    "key",
+		APISecret: "secret",
+		TLSCert:   cert,
+		TLSKey:    key,
+		OnMTLSCertificateExpiring: func(notAfter time.Time) {
+			got = notAfter
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if !got.Equal(notAfter) {
+		t.Errorf("got expiry warning for %v, want %v", got, notAfter)
+	}
+
+	if len(logger.entries) == 0 || logger.entries[0].level != LogLevelWarn ||
+		logger.entries[0].msg != "mTLS certificate is expiring" {
+		t.Errorf("got log entries %+v, want mTLS certificate expiry warning first", logger.entries)
+	}
+}
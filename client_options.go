@@ -0,0 +1,156 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/pki"
+)
+
+// Option configures a client created with NewClientWithOptions.
+type Option func(*Config) error
+
+// NewClientWithOptions returns a new HVCA client for the HVCA service at the
+// specified URL, configured by the specified options, in the same way as
+// NewClient. The options are applied in order to an otherwise empty
+// configuration, so a later option overrides an earlier one which sets the
+// same field. At least WithAPIKey is normally required.
+func NewClientWithOptions(ctx context.Context, url string, opts ...Option) (*Client, error) {
+	var conf = &Config{URL: url}
+
+	for _, opt := range opts {
+		if err := opt(conf); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewClient(ctx, conf)
+}
+
+// WithAPIKey returns an option which sets the API key and API secret for
+// the HVCA account.
+func WithAPIKey(key, secret string) Option {
+	return func(c *Config) error {
+		c.APIKey = key
+		c.APISecret = secret
+
+		return nil
+	}
+}
+
+// WithMTLSFiles returns an option which reads the certificate and private
+// key for mutual TLS authentication to HVCA from the specified PEM files.
+// The passphrase is used to decrypt the private key if it is encrypted, and
+// is otherwise ignored.
+func WithMTLSFiles(certFile, keyFile, passphrase string) Option {
+	return func(c *Config) error {
+		var cert, err = pki.CertFromFile(certFile)
+		if err != nil {
+			return fmt.Errorf("couldn't get mTLS certificate: %w", err)
+		}
+
+		var key interface{}
+		if key, err = pki.PrivateKeyFromFileWithPassword(keyFile, passphrase); err != nil {
+			return fmt.Errorf("couldn't get mTLS private key: %w", err)
+		}
+
+		c.TLSCert = cert
+		c.TLSKey = key
+
+		return nil
+	}
+}
+
+// WithMTLSCertificate returns an option which sets the client certificate
+// for mutual TLS authentication to HVCA, as described for the
+// TLSCertificate field of Config.
+func WithMTLSCertificate(cert *tls.Certificate) Option {
+	return func(c *Config) error {
+		c.TLSCertificate = cert
+
+		return nil
+	}
+}
+
+// WithHTTPClient returns an option which sets the HTTP client used to make
+// requests to HVCA, as described for the HTTPClient field of Config.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Config) error {
+		c.HTTPClient = hc
+
+		return nil
+	}
+}
+
+// WithRetryPolicy returns an option which sets the retry policy.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(c *Config) error {
+		c.RetryPolicy = policy
+
+		return nil
+	}
+}
+
+// WithLogger returns an option which sets the logger.
+func WithLogger(logger Logger) Option {
+	return func(c *Config) error {
+		c.Logger = logger
+
+		return nil
+	}
+}
+
+// WithTimeout returns an option which sets the time to wait before
+// cancelling an HVCA API request.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) error {
+		c.Timeout = timeout
+
+		return nil
+	}
+}
+
+// WithExtraHeaders returns an option which adds custom HTTP request headers
+// to be passed to HVCA with each request.
+func WithExtraHeaders(headers map[string]string) Option {
+	return func(c *Config) error {
+		if c.ExtraHeaders == nil {
+			c.ExtraHeaders = make(map[string]string, len(headers))
+		}
+
+		for key, value := range headers {
+			c.ExtraHeaders[key] = value
+		}
+
+		return nil
+	}
+}
+
+// WithConfig returns an option which calls the specified function to modify
+// the configuration directly, so that any field of Config without a
+// dedicated option may be set.
+func WithConfig(fn func(*Config)) Option {
+	return func(c *Config) error {
+		fn(c)
+
+		return nil
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+func TestNewClientWithOptions(t *testing.T) {
+	t.Parallel()
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Custom"); got != "value" {
+			t.Errorf("got custom header %q, want %q", got, "value")
+		}
+
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+		w.Write([]byte(`{"access_token":"token"}`))
+	}))
+	defer server.Close()
+
+	var logger = &recordingLogger{}
+	var policy = &RetryPolicy{MaxRetries: 1}
+	var hc = &http.Client{}
+
+	var clnt, err = NewClientWithOptions(context.Background(), server.URL,
+		WithAPIKey("1234", "abcdefgh"),
+		WithMTLSFiles("testdata/tls.cert", "testdata/rsa_priv.key", ""),
+		WithHTTPClient(hc),
+		WithRetryPolicy(policy),
+		WithLogger(logger),
+		WithTimeout(time.Second*5),
+		WithExtraHeaders(map[string]string{"X-Custom": "value"}),
+		WithConfig(func(c *Config) { c.CacheSize = 10 }),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer clnt.Close()
+
+	var conf = clnt.Config
+
+	if conf.APIKey != "1234" || conf.APISecret != "abcdefgh" {
+		t.Errorf("got API key %q and secret %q", conf.APIKey, conf.APISecret)
+	}
+
+	if conf.TLSCert == nil || conf.TLSKey == nil {
+		t.Errorf("mTLS certificate and key not set")
+	}
+
+	if conf.HTTPClient != hc || conf.RetryPolicy != policy || conf.Logger != logger {
+		t.Errorf("HTTP client, retry policy or logger not set")
+	}
+
+	if conf.Timeout != time.Second*5 {
+		t.Errorf("got timeout %v, want %v", conf.Timeout, time.Second*5)
+	}
+
+	if conf.CacheSize != 10 {
+		t.Errorf("got cache size %d, want 10", conf.CacheSize)
+	}
+
+	if got := clnt.GetToken(); got != "token" {
+		t.Errorf("got token %q, want %q", got, "token")
+	}
+}
+
+func TestNewClientWithOptionsFailure(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name string
+		opts []Option
+	}{
+		{
+			name: "NoAPIKey",
+		},
+		{
+			name: "MissingCertFile",
+			opts: []Option{
+				WithAPIKey("1234", "abcdefgh"),
+				WithMTLSFiles("testdata/no_such_file.cert", "testdata/rsa_priv.key", ""),
+			},
+		},
+		{
+			name: "BadPassphrase",
+			opts: []Option{
+				WithAPIKey("1234", "abcdefgh"),
+				WithMTLSFiles("testdata/tls.cert", "testdata/rsa_priv_enc.key", "wrong"),
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := NewClientWithOptions(context.Background(), "http://example.com/v2", tc.opts...); err == nil {
+				t.Fatalf("unexpectedly created client")
+			}
+		})
+	}
+}
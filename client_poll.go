@@ -0,0 +1,159 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// defaultPollInterval is the time to wait between attempts to retrieve a
+// pending certificate, if no interval is specified.
+const defaultPollInterval = time.Second * 5
+
+// CertificatePendingError is returned by CertificateRequestAndWait if the
+// context is done before a requested certificate has been issued. The
+// certificate may still be retrieved later using its serial number.
+//
+// HVCA provides no way to withdraw a pending request, so a request which is
+// abandoned may still be approved and issued. At the time of writing a
+// pending request can only be declined by its approver. A certificate
+// issued for an abandoned request should be revoked with CertificateRevoke
+// once it has been issued, using the serial number in the error.
+type CertificatePendingError struct {
+	Serial *big.Int
+	Err    error
+}
+
+// Error returns a string representation of the error.
+func (e CertificatePendingError) Error() string {
+	return fmt.Sprintf("certificate %X still pending: %v", e.Serial, e.Err)
+}
+
+// Unwrap returns the underlying context error.
+func (e CertificatePendingError) Unwrap() error {
+	return e.Err
+}
+
+// CertificateRejectedError is returned by CertificateRequestAndWait if HVCA
+// refuses to issue a requested certificate after accepting the request.
+type CertificateRejectedError struct {
+	Serial *big.Int
+	Err    APIError
+}
+
+// Error returns a string representation of the error.
+func (e CertificateRejectedError) Error() string {
+	return fmt.Sprintf("certificate %X rejected: %v", e.Serial, e.Err)
+}
+
+// Unwrap returns the underlying API error.
+func (e CertificateRejectedError) Unwrap() error {
+	return e.Err
+}
+
+// CertificateRequestAndWait requests a new certificate, and then retrieves it
+// once it has been issued, waiting for the specified interval between
+// attempts while issuance is pending, such as when the validation policy
+// requires manual approval. If pollInterval is zero, a reasonable default
+// will be used. If HVCA refuses to issue the certificate, a
+// CertificateRejectedError is returned. If the context is done while the
+// certificate is still pending, a CertificatePendingError is returned
+// containing the certificate's serial number. Call options may be used to
+// bound the whole wait, including the initial request, or to override the
+// retry policy.
+func (c *Client) CertificateRequestAndWait(
+	ctx context.Context,
+	req *Request,
+	pollInterval time.Duration,
+	opts ...CallOption,
+) (*CertInfo, error) {
+	var cancel context.CancelFunc
+	ctx, cancel = withCallOptions(ctx, opts)
+	defer cancel()
+
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	var serial, err = c.CertificateRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retrieve the certificate with a retry policy which does not retry
+	// while issuance is pending, since we poll at our own interval instead.
+	var base = retryPolicyFromContext(ctx, c.Config)
+	var policy = *base
+	policy.Retryable = func(statusCode int, err error) bool {
+		if statusCode == http.StatusAccepted {
+			return false
+		}
+
+		if base.Retryable != nil {
+			return base.Retryable(statusCode, err)
+		}
+
+		return DefaultRetryable(statusCode, err)
+	}
+
+	var pollCtx = withRetryPolicy(ctx, &policy)
+
+	for {
+		var info, err = c.CertificateRetrieve(pollCtx, serial)
+		if err == nil {
+			return info, nil
+		}
+
+		var apiErr APIError
+		switch {
+		case ctx.Err() != nil:
+			return nil, CertificatePendingError{Serial: serial, Err: ctx.Err()}
+
+		case !errors.As(err, &apiErr):
+			return nil, err
+
+		case apiErr.StatusCode == http.StatusAccepted:
+			// Issuance is still pending, so wait and poll again.
+
+		case isRejection(apiErr.StatusCode):
+			return nil, CertificateRejectedError{Serial: serial, Err: apiErr}
+
+		default:
+			return nil, err
+		}
+
+		if err = sleepContext(ctx, pollInterval); err != nil {
+			return nil, CertificatePendingError{Serial: serial, Err: err}
+		}
+	}
+}
+
+// isRejection returns true if a HTTP status code returned when retrieving a
+// certificate indicates that it will never be issued. Client errors which
+// may be resolved by trying again later are excluded.
+func isRejection(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+
+	return statusCode >= 400 && statusCode < 500
+}
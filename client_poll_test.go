@@ -0,0 +1,160 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+	"github.com/vsglobalsign/hvclient/internal/testhelpers"
+)
+
+func TestCertificateRequestAndWait(t *testing.T) {
+	t.Parallel()
+
+	var certPEM = string(testhelpers.MustReadFile(t, "testdata/test_cert.pem"))
+
+	var testcases = []struct {
+		name    string
+		pending int32
+		final   int
+		timeout time.Duration
+		want    int32
+		check   func(t *testing.T, err error)
+	}{
+		{
+			name:    "IssuedImmediately",
+			final:   http.StatusOK,
+			timeout: time.Second * 5,
+			want:    1,
+		},
+		{
+			name:    "IssuedAfterPending",
+			pending: 3,
+			final:   http.StatusOK,
+			timeout: time.Second * 5,
+			want:    4,
+		},
+		{
+			name:    "Rejected",
+			pending: 1,
+			final:   http.StatusUnprocessableEntity,
+			timeout: time.Second * 5,
+			want:    2,
+			check: func(t *testing.T, err error) {
+				var rejected CertificateRejectedError
+				if !errors.As(err, &rejected) {
+					t.Fatalf("got error %v, want %T", err, rejected)
+				}
+
+				if rejected.Err.StatusCode != http.StatusUnprocessableEntity {
+					t.Errorf("got status %d, want %d", rejected.Err.StatusCode, http.StatusUnprocessableEntity)
+				}
+			},
+		},
+		{
+			name:    "StillPending",
+			pending: 1000,
+			timeout: time.Millisecond * 200,
+			check: func(t *testing.T, err error) {
+				var pending CertificatePendingError
+				if !errors.As(err, &pending) {
+					t.Fatalf("got error %v, want %T", err, pending)
+				}
+
+				if pending.Serial == nil || pending.Serial.Int64() != 0x741daf9ec2d5f7dc {
+					t.Errorf("got serial number %v, want 741DAF9EC2D5F7DC", pending.Serial)
+				}
+
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+				}
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var retrievals int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost {
+					w.Header().Set("Location", "http://local/certificates/741DAF9EC2D5F7DC")
+					w.WriteHeader(http.StatusCreated)
+					return
+				}
+
+				if atomic.AddInt32(&retrievals, 1) <= tc.pending {
+					w.WriteHeader(http.StatusAccepted)
+					return
+				}
+
+				if tc.final != http.StatusOK {
+					w.WriteHeader(tc.final)
+					return
+				}
+
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"certificate": certPEM,
+					"status":      "ISSUED",
+					"updated_at":  1600000000,
+				})
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond})
+
+			var ctx, cancel = context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+
+			var info, err = clnt.CertificateRequestAndWait(ctx, &Request{}, time.Millisecond*10)
+
+			if tc.check != nil {
+				if err == nil {
+					t.Fatal("unexpectedly succeeded")
+				}
+
+				tc.check(t, err)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to request certificate: %v", err)
+			}
+
+			if info.Status != StatusIssued {
+				t.Errorf("got status %v, want %v", info.Status, StatusIssued)
+			}
+
+			// Pending responses should not also be retried by the retry
+			// policy, so there should be exactly one retrieval per poll.
+			if got := atomic.LoadInt32(&retrievals); got != tc.want {
+				t.Errorf("got %d retrievals, want %d", got, tc.want)
+			}
+		})
+	}
+}
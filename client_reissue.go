@@ -0,0 +1,80 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// CertificateReissue requests a new certificate with the same subject
+// distinguished name and subject alternative names as an existing
+// certificate, but with the specified public key and a validity period of
+// the same length as the existing certificate's starting from the current
+// time. It returns the serial number of the new certificate, which may be
+// retrieved in the same way as one requested with CertificateRequest. An
+// error is returned if the existing certificate cannot be retrieved, or if
+// it has been revoked.
+//
+// HVCA has no renewal operation, so the new certificate is an entirely new
+// issuance which consumes issuance quota, and the existing certificate
+// remains valid until it expires or is revoked. Any other fields of the
+// existing certificate, such as extended key usages, are not copied, and are
+// determined by the validation policy. Options such as WithoutSANTypes may
+// be provided to control which subject alternative names are copied.
+func (c *Client) CertificateReissue(
+	ctx context.Context,
+	serial *big.Int,
+	newKey crypto.PublicKey,
+	opts ...RequestOption,
+) (*big.Int, error) {
+	if newKey == nil {
+		return nil, errors.New("no public key provided")
+	}
+
+	var info, err = c.CertificateRetrieve(ctx, serial)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve certificate %X: %w", serial, err)
+	}
+
+	if info.Status == StatusRevoked {
+		return nil, fmt.Errorf("certificate %X has been revoked", serial)
+	}
+
+	var cert *x509.Certificate
+	if cert, err = info.Certificate(); err != nil {
+		return nil, fmt.Errorf("certificate %X: %w", serial, err)
+	}
+
+	var req *Request
+	if req, err = requestFromCertificate(cert, opts...); err != nil {
+		return nil, fmt.Errorf("certificate %X: %w", serial, err)
+	}
+
+	var now = time.Now()
+	req.Validity = &Validity{
+		NotBefore: now,
+		NotAfter:  now.Add(cert.NotAfter.Sub(cert.NotBefore)),
+	}
+	req.PublicKey = newKey
+
+	return c.CertificateRequest(ctx, req)
+}
@@ -0,0 +1,209 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+	"github.com/vsglobalsign/hvclient/internal/pki"
+)
+
+func TestCertificateReissue(t *testing.T) {
+	t.Parallel()
+
+	var key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var newKey *ecdsa.PrivateKey
+	if newKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var notBefore = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	var template = &x509.Certificate{
+		SerialNumber: big.NewInt(0x1234),
+		Subject: pkix.Name{
+			CommonName:         "John Doe",
+			Organization:       []string{"GMO GlobalSign"},
+			OrganizationalUnit: []string{"Operations", "Engineering"},
+			Country:            []string{"GB"},
+		},
+		DNSNames:       []string{"example.com", "www.example.com"},
+		EmailAddresses: []string{"john.doe@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("192.0.2.1").To4()},
+		NotBefore:      notBefore,
+		NotAfter:       notBefore.Add(time.Hour * 24 * 90),
+	}
+
+	var der []byte
+	if der, err = x509.CreateCertificate(rand.Reader, template, template, key.Public(), key); err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	var cert *x509.Certificate
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	var testcases = []struct {
+		name   string
+		status string
+		code   int
+		err    bool
+	}{
+		{
+			name:   "Issued",
+			status: "ISSUED",
+			code:   http.StatusOK,
+		},
+		{
+			name:   "Revoked",
+			status: "REVOKED",
+			code:   http.StatusOK,
+			err:    true,
+		},
+		{
+			name: "NotFound",
+			code: http.StatusNotFound,
+			err:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var submitted []byte
+
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost {
+					var body, _ = ioutil.ReadAll(r.Body)
+
+					mu.Lock()
+					submitted = body
+					mu.Unlock()
+
+					w.Header().Set(certSNHeaderName, "http://local/certificates/5678")
+					w.WriteHeader(http.StatusCreated)
+					return
+				}
+
+				if tc.code != http.StatusOK {
+					w.WriteHeader(tc.code)
+					return
+				}
+
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+				fmt.Fprintf(w, `{"certificate":%q,"status":%q,"updated_at":1600000000}`,
+					pki.CertToPEMString(cert), tc.status)
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+			var start = time.Now().Truncate(time.Second)
+			var serial, err = clnt.CertificateReissue(context.Background(), cert.SerialNumber, newKey.Public())
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if tc.err {
+				if err == nil {
+					t.Fatal("unexpectedly succeeded")
+				}
+
+				if submitted != nil {
+					t.Errorf("unexpectedly submitted certificate request")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to reissue certificate: %v", err)
+			}
+
+			if serial.Int64() != 0x5678 {
+				t.Errorf("got serial number %X, want 5678", serial)
+			}
+
+			var got Request
+			if err = json.Unmarshal(submitted, &got); err != nil {
+				t.Fatalf("failed to unmarshal submitted request: %v", err)
+			}
+
+			var wantSubject = &DN{
+				CommonName:         "John Doe",
+				Organization:       "GMO GlobalSign",
+				OrganizationalUnit: []string{"Operations", "Engineering"},
+				Country:            "GB",
+			}
+			if !cmp.Equal(got.Subject, wantSubject) {
+				t.Errorf("got subject %+v, want %+v", got.Subject, wantSubject)
+			}
+
+			var wantSAN = &SAN{
+				DNSNames:    template.DNSNames,
+				Emails:      template.EmailAddresses,
+				IPAddresses: template.IPAddresses,
+			}
+			if got.SAN == nil || !got.SAN.Equal(wantSAN) {
+				t.Errorf("got SAN %+v, want %+v", got.SAN, wantSAN)
+			}
+
+			if !newKey.PublicKey.Equal(got.PublicKey) {
+				t.Errorf("got public key %v, want the new key", got.PublicKey)
+			}
+
+			if got.Validity == nil || got.Validity.NotBefore.Before(start) ||
+				got.Validity.NotAfter.Sub(got.Validity.NotBefore) != cert.NotAfter.Sub(cert.NotBefore) {
+				t.Errorf("got validity %+v, want 90 days starting now", got.Validity)
+			}
+		})
+	}
+}
+
+func TestCertificateReissueNoKey(t *testing.T) {
+	t.Parallel()
+
+	var clnt = newTestClient(t, "http://127.0.0.1:0", nil)
+
+	if _, err := clnt.CertificateReissue(context.Background(), big.NewInt(1), nil); err == nil {
+		t.Fatal("unexpectedly succeeded")
+	}
+}
@@ -17,8 +17,21 @@ package hvclient_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/vsglobalsign/hvclient"
 	"github.com/vsglobalsign/hvclient/internal/testhelpers"
 )
@@ -126,3 +139,820 @@ func TestNewClientFromConfigError(t *testing.T) {
 		})
 	}
 }
+
+// countingLimiter is a rate limiter which counts calls to Wait, and which
+// fails if the context is done.
+type countingLimiter struct {
+	calls int32
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	atomic.AddInt32(&l.calls, 1)
+
+	return ctx.Err()
+}
+
+func TestClientRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	var testServer = newMockServer(t)
+	defer testServer.Close()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var limiter countingLimiter
+
+	var clnt, err = hvclient.NewClient(ctx, &hvclient.Config{
+		URL:       testServer.URL,
+		APIKey:    mockAPIKey,
+		APISecret: mockAPISecret,
+		ExtraHeaders: map[string]string{
+			sslClientSerialHeader: mockSSLClientSerial,
+		},
+		RateLimiter: &limiter,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err = clnt.CounterCertsIssued(ctx); err != nil {
+		t.Fatalf("failed to get counter: %v", err)
+	}
+
+	// Expect one call for the login, and one for the API call.
+	if got := atomic.LoadInt32(&limiter.calls); got != 2 {
+		t.Fatalf("got %d calls to rate limiter, want 2", got)
+	}
+
+	// Verify the request is aborted with the context error if the context
+	// is cancelled while waiting for the rate limiter.
+	cancel()
+
+	if _, err = clnt.CounterCertsIssued(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+// countingTransport is an HTTP round tripper which counts the number of
+// requests made through it.
+type countingTransport struct {
+	calls int32
+}
+
+func (rt *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&rt.calls, 1)
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestClientCustomHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	var testServer = newMockServer(t)
+	defer testServer.Close()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var rt countingTransport
+
+	var clnt, err = hvclient.NewClient(ctx, &hvclient.Config{
+		URL:       testServer.URL,
+		APIKey:    mockAPIKey,
+		APISecret: mockAPISecret,
+		ExtraHeaders: map[string]string{
+			sslClientSerialHeader: mockSSLClientSerial,
+		},
+		HTTPClient: &http.Client{Transport: &rt},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err = clnt.CounterCertsIssued(ctx); err != nil {
+		t.Fatalf("failed to get counter: %v", err)
+	}
+
+	// Expect one request for the login, and one for the API call.
+	if got := atomic.LoadInt32(&rt.calls); got != 2 {
+		t.Fatalf("got %d requests through custom transport, want 2", got)
+	}
+}
+
+func TestNewThinClientCustomHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	var testServer = newMockServer(t)
+	defer testServer.Close()
+
+	var rt countingTransport
+	var hc = &http.Client{Transport: &rt}
+
+	var clnt, err = hvclient.NewThinClient(&hvclient.ClientProfile{
+		Config: &hvclient.Config{
+			URL:       testServer.URL,
+			APIKey:    mockAPIKey,
+			APISecret: mockAPISecret,
+			ExtraHeaders: map[string]string{
+				sslClientSerialHeader: mockSSLClientSerial,
+			},
+		},
+		Token: mockToken,
+	}, hc)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer clnt.Close()
+
+	if _, err = clnt.CounterCertsIssued(context.Background()); err != nil {
+		t.Fatalf("failed to get counter: %v", err)
+	}
+
+	// Expect one request for the login, since the age of the token in the
+	// profile is unknown, and one for the API call.
+	if got := atomic.LoadInt32(&rt.calls); got != 2 {
+		t.Fatalf("got %d requests through custom transport, want 2", got)
+	}
+
+	if hc.Transport != &rt {
+		t.Errorf("custom HTTP client transport was replaced")
+	}
+}
+
+func TestNewThinClientConnectionPool(t *testing.T) {
+	t.Parallel()
+
+	var clnt, err = hvclient.NewThinClient(&hvclient.ClientProfile{
+		Config: &hvclient.Config{URL: "https://example.com/v2"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer clnt.Close()
+
+	var tnspt, ok = clnt.HTTPClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("got transport of type %T, want *http.Transport", clnt.HTTPClient.Transport)
+	}
+
+	if tnspt.MaxIdleConns != 1024 || tnspt.MaxIdleConnsPerHost != 1024 {
+		t.Errorf("got %d idle connections and %d per host, want 1024",
+			tnspt.MaxIdleConns, tnspt.MaxIdleConnsPerHost)
+	}
+}
+
+func TestNewThinClientInvalidURL(t *testing.T) {
+	t.Parallel()
+
+	var _, err = hvclient.NewThinClient(&hvclient.ClientProfile{
+		Config: &hvclient.Config{URL: "http://[::1"},
+	}, nil)
+	if err == nil {
+		t.Fatalf("unexpectedly created client with invalid URL")
+	}
+}
+
+// recordingTransport is an HTTP round tripper which records the URLs,
+// request ID and User-Agent headers of requests made through it, and
+// responds with a
+// successful login or counter response without making any network
+// connection.
+type recordingTransport struct {
+	sync.Mutex
+	urls       []string
+	requestIDs []string
+	userAgents []string
+}
+
+func (rt *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.Lock()
+	rt.urls = append(rt.urls, r.URL.String())
+	rt.requestIDs = append(rt.requestIDs, r.Header.Get("X-Request-ID"))
+	rt.userAgents = append(rt.userAgents, r.Header.Get("User-Agent"))
+	rt.Unlock()
+
+	var body = `{"value":42}`
+	if strings.HasSuffix(r.URL.Path, "/login") {
+		body = `{"access_token":"token"}`
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}, nil
+}
+
+func TestClientURLPathPrefix(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name string
+		url  string
+	}{
+		{
+			name: "NoTrailingSlash",
+			url:  "https://gw.example.com/pki/hvca/v2",
+		},
+		{
+			name: "TrailingSlash",
+			url:  "https://gw.example.com/pki/hvca/v2/",
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var rt recordingTransport
+
+			var clnt, err = hvclient.NewClient(context.Background(), &hvclient.Config{
+				URL:        tc.url,
+				APIKey:     mockAPIKey,
+				APISecret:  mockAPISecret,
+				HTTPClient: &http.Client{Transport: &rt},
+			})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			if _, err = clnt.CounterCertsIssued(context.Background()); err != nil {
+				t.Fatalf("failed to get counter: %v", err)
+			}
+
+			var want = []string{
+				"https://gw.example.com/pki/hvca/v2/login",
+				"https://gw.example.com/pki/hvca/v2/counters/certificates/issued",
+			}
+
+			if !cmp.Equal(rt.urls, want) {
+				t.Errorf("got URLs %v, want %v", rt.urls, want)
+			}
+		})
+	}
+}
+
+func TestClientRequestIDFromContext(t *testing.T) {
+	t.Parallel()
+
+	type requestIDKey struct{}
+
+	var rt recordingTransport
+
+	var clnt, err = hvclient.NewClient(context.Background(), &hvclient.Config{
+		URL:        "https://example.com/v2",
+		APIKey:     mockAPIKey,
+		APISecret:  mockAPISecret,
+		HTTPClient: &http.Client{Transport: &rt},
+		ExtraHeaders: map[string]string{
+			"X-Request-ID": "from-extra-headers",
+		},
+		RequestIDFromContext: func(ctx context.Context) string {
+			var id, _ = ctx.Value(requestIDKey{}).(string)
+			return id
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	var ctx = context.WithValue(context.Background(), requestIDKey{}, "correlation-id")
+	if _, err = clnt.CounterCertsIssued(ctx); err != nil {
+		t.Fatalf("failed to get counter: %v", err)
+	}
+
+	// The initial login is made without a request ID in its context, so
+	// the header from the extra headers is sent unchanged.
+	var want = []string{"from-extra-headers", "correlation-id"}
+
+	if !cmp.Equal(rt.requestIDs, want) {
+		t.Errorf("got request IDs %v, want %v", rt.requestIDs, want)
+	}
+}
+
+func TestClientUserAgent(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name      string
+		userAgent string
+		extra     map[string]string
+		want      string
+	}{
+		{
+			name: "Default",
+			want: "hvclient/",
+		},
+		{
+			name:      "Custom",
+			userAgent: "billing-service/1.2",
+			want:      "billing-service/1.2",
+		},
+		{
+			name:  "ExtraHeaders",
+			extra: map[string]string{"User-Agent": "from-extra-headers"},
+			want:  "from-extra-headers",
+		},
+		{
+			name:      "CustomOverridesExtraHeaders",
+			userAgent: "billing-service/1.2",
+			extra:     map[string]string{"User-Agent": "from-extra-headers"},
+			want:      "billing-service/1.2",
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var rt recordingTransport
+
+			var clnt, err = hvclient.NewClient(context.Background(), &hvclient.Config{
+				URL:          "https://example.com/v2",
+				APIKey:       mockAPIKey,
+				APISecret:    mockAPISecret,
+				HTTPClient:   &http.Client{Transport: &rt},
+				UserAgent:    tc.userAgent,
+				ExtraHeaders: tc.extra,
+			})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			if _, err = clnt.CounterCertsIssued(context.Background()); err != nil {
+				t.Fatalf("failed to get counter: %v", err)
+			}
+
+			// Both the login and the counter requests should carry the
+			// User-Agent header.
+			if len(rt.userAgents) != 2 {
+				t.Fatalf("got %d requests, want 2", len(rt.userAgents))
+			}
+
+			for _, got := range rt.userAgents {
+				if !strings.HasPrefix(got, tc.want) {
+					t.Errorf("got User-Agent %q, want %q", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestClientCustomHTTPClientMTLS(t *testing.T) {
+	t.Parallel()
+
+	var cert = testhelpers.MustGetCertFromFile(t, "testdata/tls.cert")
+	var key = testhelpers.MustGetPrivateKeyFromFile(t, "testdata/rsa_priv.key")
+
+	var testcases = []struct {
+		name      string
+		transport http.RoundTripper
+		err       bool
+	}{
+		{
+			name:      "NilTransport",
+			transport: nil,
+		},
+		{
+			name:      "Transport",
+			transport: &http.Transport{},
+		},
+		{
+			name:      "UnsupportedTransport",
+			transport: &countingTransport{},
+			err:       true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var testServer = newMockServer(t)
+			defer testServer.Close()
+
+			var ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			var hc = &http.Client{Transport: tc.transport}
+
+			var _, err = hvclient.NewClient(ctx, &hvclient.Config{
+				URL:       testServer.URL,
+				APIKey:    mockAPIKey,
+				APISecret: mockAPISecret,
+				ExtraHeaders: map[string]string{
+					sslClientSerialHeader: mockSSLClientSerial,
+				},
+				TLSCert:    cert,
+				TLSKey:     key,
+				HTTPClient: hc,
+			})
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error %t", err, tc.err)
+			}
+
+			// The caller's HTTP client and transport must not be modified.
+			if hc.Transport != tc.transport {
+				t.Errorf("custom HTTP client transport was replaced")
+			}
+
+			if tnspt, ok := tc.transport.(*http.Transport); ok &&
+				tnspt.TLSClientConfig != nil && len(tnspt.TLSClientConfig.Certificates) != 0 {
+				t.Errorf("mTLS certificate added to custom HTTP transport")
+			}
+		})
+	}
+}
+
+func TestClientServerCertPins(t *testing.T) {
+	t.Parallel()
+
+	// Discard the handshake errors logged by the server when a pin does not
+	// match.
+	var testServer = httptest.NewUnstartedServer(http.HandlerFunc(mockLogin))
+	testServer.Config.ErrorLog = log.New(io.Discard, "", 0)
+	testServer.StartTLS()
+	t.Cleanup(testServer.Close)
+
+	var roots = x509.NewCertPool()
+	roots.AddCert(testServer.Certificate())
+
+	var otherPin = make([]byte, sha256.Size)
+
+	var testcases = []struct {
+		name       string
+		pins       [][]byte
+		httpClient *http.Client
+		err        error
+	}{
+		{
+			name: "NoPins",
+		},
+		{
+			name: "Match",
+			pins: [][]byte{otherPin, hvclient.ServerCertPin(testServer.Certificate())},
+		},
+		{
+			name: "Mismatch",
+			pins: [][]byte{otherPin},
+			err:  hvclient.ErrServerCertPinMismatch,
+		},
+		{
+			name: "CustomHTTPClientMatch",
+			pins: [][]byte{hvclient.ServerCertPin(testServer.Certificate())},
+			httpClient: &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: roots},
+			}},
+		},
+		{
+			name: "CustomHTTPClientMismatch",
+			pins: [][]byte{otherPin},
+			httpClient: &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: roots},
+			}},
+			err: hvclient.ErrServerCertPinMismatch,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			var _, err = hvclient.NewClient(ctx, &hvclient.Config{
+				URL:       testServer.URL,
+				APIKey:    mockAPIKey,
+				APISecret: mockAPISecret,
+				ExtraHeaders: map[string]string{
+					sslClientSerialHeader: mockSSLClientSerial,
+				},
+				TLSRoots:       roots,
+				ServerCertPins: tc.pins,
+				HTTPClient:     tc.httpClient,
+				RetryPolicy:    &hvclient.RetryPolicy{},
+			})
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestClientForceHTTP1(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name       string
+		forceHTTP1 bool
+		want       int
+	}{
+		{
+			name: "HTTP2",
+			want: 2,
+		},
+		{
+			name:       "ForceHTTP1",
+			forceHTTP1: true,
+			want:       1,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var proto int32
+			var testServer = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.StoreInt32(&proto, int32(r.ProtoMajor))
+				mockLogin(w, r)
+			}))
+			testServer.EnableHTTP2 = true
+			testServer.StartTLS()
+			defer testServer.Close()
+
+			var roots = x509.NewCertPool()
+			roots.AddCert(testServer.Certificate())
+
+			var ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			var clnt, err = hvclient.NewClient(ctx, &hvclient.Config{
+				URL:       testServer.URL,
+				APIKey:    mockAPIKey,
+				APISecret: mockAPISecret,
+				ExtraHeaders: map[string]string{
+					sslClientSerialHeader: mockSSLClientSerial,
+				},
+				TLSRoots:   roots,
+				ForceHTTP1: tc.forceHTTP1,
+			})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+			defer clnt.Close()
+
+			if got := atomic.LoadInt32(&proto); got != int32(tc.want) {
+				t.Errorf("got HTTP/%d, want HTTP/%d", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestClientConcurrentUse makes API calls and token operations on a single
+// client from many goroutines, so that any unsynchronized access to shared
+// state is reported when the tests are run with the race detector.
+func TestClientConcurrentUse(t *testing.T) {
+	t.Parallel()
+
+	var clnt, closefunc = newMockClient(t)
+	defer closefunc()
+
+	var serial, _ = hvclient.SerialFromString(mockCertSerial)
+
+	var ops = []func(ctx context.Context) error{
+		func(ctx context.Context) error {
+			var _, err = clnt.CertificateRetrieve(ctx, serial)
+			return err
+		},
+		func(ctx context.Context) error {
+			var _, err = clnt.Policy(ctx)
+			return err
+		},
+		func(ctx context.Context) error {
+			var _, err = clnt.TrustChainCerts(ctx)
+			return err
+		},
+		func(ctx context.Context) error {
+			var _, err = clnt.QuotaIssuance(ctx)
+			return err
+		},
+		func(ctx context.Context) error {
+			return clnt.RefreshToken(ctx)
+		},
+		func(ctx context.Context) error {
+			if token, expiry, ok := clnt.TokenWithExpiry(); ok {
+				clnt.SetTokenWithExpiry(token, expiry)
+			}
+
+			clnt.GetToken()
+
+			return nil
+		},
+	}
+
+	const goroutines = 16
+	const iterations = 20
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var errs = make(chan error, goroutines*iterations)
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < iterations; j++ {
+				if err := ops[(i+j)%len(ops)](ctx); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent call failed: %v", err)
+	}
+}
+
+// observation is a single call to a MetricsObserver.
+type observation struct {
+	endpoint string
+	status   int
+	err      bool
+}
+
+// recordingObserver is a metrics observer which records its observations.
+type recordingObserver struct {
+	sync.Mutex
+	observations []observation
+}
+
+func (o *recordingObserver) ObserveRequest(endpoint string, status int, duration time.Duration, err error) {
+	o.Lock()
+	defer o.Unlock()
+
+	o.observations = append(o.observations, observation{endpoint: endpoint, status: status, err: err != nil})
+}
+
+func TestClientMetricsObserver(t *testing.T) {
+	t.Parallel()
+
+	var testServer = newMockServer(t)
+	defer testServer.Close()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var observer recordingObserver
+
+	var clnt, err = hvclient.NewClient(ctx, &hvclient.Config{
+		URL:       testServer.URL,
+		APIKey:    mockAPIKey,
+		APISecret: mockAPISecret,
+		ExtraHeaders: map[string]string{
+			sslClientSerialHeader: mockSSLClientSerial,
+		},
+		MetricsObserver: &observer,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err = clnt.CounterCertsIssued(ctx); err != nil {
+		t.Fatalf("failed to get counter: %v", err)
+	}
+
+	if _, err = clnt.CertificateRetrieve(ctx, mockBigIntNotFound); err == nil {
+		t.Fatalf("unexpectedly retrieved certificate")
+	}
+
+	var want = []observation{
+		{endpoint: "Login", status: http.StatusOK},
+		{endpoint: "CounterCertsIssued", status: http.StatusOK},
+		{endpoint: "CertificateRetrieve", status: http.StatusNotFound, err: true},
+	}
+
+	if !cmp.Equal(observer.observations, want, cmp.AllowUnexported(observation{})) {
+		t.Errorf("got observations %v, want %v", observer.observations, want)
+	}
+}
+
+// gaugeObserver is a metrics observer which records the highest number of
+// in-flight API calls it has observed.
+type gaugeObserver struct {
+	recordingObserver
+	max atomic.Int64
+}
+
+func (o *gaugeObserver) ObserveInFlight(n int) {
+	for {
+		var max = o.max.Load()
+		if int64(n) <= max || o.max.CompareAndSwap(max, int64(n)) {
+			return
+		}
+	}
+}
+
+func TestClientInFlight(t *testing.T) {
+	t.Parallel()
+
+	const calls = 3
+
+	// Block counter requests until released, so they remain in flight.
+	var release = make(chan struct{})
+	var testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Path == "/v2/login" {
+			io.WriteString(w, `{"access_token":"token"}`)
+			return
+		}
+
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+
+		io.WriteString(w, `{"value":42}`)
+	}))
+	defer testServer.Close()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var observer gaugeObserver
+
+	var clnt, err = hvclient.NewClient(ctx, &hvclient.Config{
+		URL:             testServer.URL + "/v2",
+		APIKey:          mockAPIKey,
+		APISecret:       mockAPISecret,
+		RetryPolicy:     &hvclient.RetryPolicy{},
+		MetricsObserver: &observer,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if got := clnt.InFlight(); got != 0 {
+		t.Fatalf("got %d calls in flight after login, want 0", got)
+	}
+
+	// One call is cancelled while in flight, and must no longer be counted
+	// once it has returned.
+	var cancelCtx, cancelCall = context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		var callCtx = ctx
+		if i == 0 {
+			callCtx = cancelCtx
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clnt.CounterCertsIssued(callCtx)
+		}()
+	}
+
+	var deadline = time.Now().Add(time.Second * 5)
+	for clnt.InFlight() != calls {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d calls in flight, want %d", clnt.InFlight(), calls)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	cancelCall()
+	for clnt.InFlight() != calls-1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d calls in flight after cancellation, want %d", clnt.InFlight(), calls-1)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if got := clnt.InFlight(); got != 0 {
+		t.Errorf("got %d calls in flight after completion, want 0", got)
+	}
+
+	if got := observer.max.Load(); got != calls {
+		t.Errorf("got maximum observed %d calls in flight, want %d", got, calls)
+	}
+}
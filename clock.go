@@ -0,0 +1,59 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"net/http"
+	"time"
+)
+
+// observeServerTime records the offset between HVCA's clock, as reported in
+// the Date header of a response, and the local clock at the specified time.
+// The response is ignored if it has no valid Date header.
+func (c *Client) observeServerTime(response *http.Response, now time.Time) {
+	var date, err = http.ParseTime(response.Header.Get(dateHeaderName))
+	if err != nil {
+		return
+	}
+
+	c.clockOffset.Store(int64(date.Sub(now)))
+}
+
+// serverTime returns the current time according to HVCA's clock, as
+// estimated from the Date header of its most recent response, or the local
+// time if no such response has been received. The Date header has a
+// resolution of one second, so the estimate may be up to a second behind.
+func (c *Client) serverTime() time.Time {
+	return c.serverTimeAt(time.Now())
+}
+
+// serverTimeAt converts a time according to the local clock to the
+// corresponding time according to HVCA's clock, as estimated by serverTime.
+func (c *Client) serverTimeAt(local time.Time) time.Time {
+	return local.Add(time.Duration(c.clockOffset.Load()))
+}
+
+// ClockSkew returns the offset between HVCA's clock and the local clock, as
+// observed in the Date header of HVCA's most recent response, which is
+// positive if HVCA's clock is ahead of the local clock. It is zero if no
+// such response has been received. The expiry of the authentication token
+// is judged by HVCA's clock, using this offset, so that a skewed local
+// clock does not cause a token to be used after HVCA considers it expired.
+// The Date header has a resolution of one second, so the offset may be up
+// to a second less than the actual skew.
+func (c *Client) ClockSkew() time.Duration {
+	return time.Duration(c.clockOffset.Load())
+}
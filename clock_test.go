@@ -0,0 +1,168 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+	"github.com/vsglobalsign/hvclient/internal/testhelpers"
+)
+
+func TestCertificateRequestRelativeValidity(t *testing.T) {
+	t.Parallel()
+
+	// Report a server time well away from the local clock.
+	var serverNow = time.Now().Add(time.Hour * 24 * 365).Truncate(time.Second)
+
+	var mu sync.Mutex
+	var got jsonValidity
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(dateHeaderName, serverNow.UTC().Format(http.TimeFormat))
+
+		// The token set by newTestClient is judged expired by the server's
+		// clock, so the client logs in again.
+		if r.URL.Path == endpointLogin {
+			w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+			w.Write([]byte(`{"access_token":"token"}`))
+			return
+		}
+
+		if r.URL.Path != endpointCertificates {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var body struct {
+			Validity jsonValidity `json:"validity"`
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		got = body.Validity
+
+		w.Header().Set(certSNHeaderName, "http://local/certificates/1234")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+	// Make a request to observe the server's clock.
+	if _, err := clnt.makeRequest(context.Background(), "/test", http.MethodGet, nil, nil); err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+
+	var validity = &Validity{Duration: time.Hour}
+	var _, err = clnt.CertificateRequest(context.Background(), &Request{
+		Validity:  validity,
+		PublicKey: testhelpers.MustGetPublicKeyFromFile(t, "testdata/rsa_pub.key"),
+	})
+	if err != nil {
+		t.Fatalf("failed to request certificate: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if nb := time.Unix(int64(got.NotBefore), 0); nb.Before(serverNow) || nb.After(serverNow.Add(time.Second*5)) {
+		t.Errorf("got not-before %v, want server time %v", nb, serverNow)
+	}
+
+	if d := got.NotAfter - got.NotBefore; d != 3600 {
+		t.Errorf("got duration of %d seconds, want 3600", d)
+	}
+
+	// The caller's request should not have been modified.
+	if validity.Duration != time.Hour || !validity.NotBefore.IsZero() {
+		t.Errorf("request validity unexpectedly modified: %v", validity)
+	}
+}
+
+func TestTokenExpiryClockSkew(t *testing.T) {
+	t.Parallel()
+
+	// Report a server time an hour behind the local clock.
+	var serverNow = time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(dateHeaderName, serverNow.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+	if got := clnt.ClockSkew(); got != 0 {
+		t.Errorf("got clock skew %v before any response, want 0", got)
+	}
+
+	// Make a request to observe the server's clock.
+	if _, err := clnt.makeRequest(context.Background(), "/test", http.MethodGet, nil, nil); err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+
+	if got := clnt.ClockSkew(); got > -time.Minute*59 || got < -time.Minute*61 {
+		t.Errorf("got clock skew %v, want about -1h", got)
+	}
+
+	// A token which expires in ten minutes by the server's clock has
+	// already expired by the local clock, but should still be used.
+	var expiry = serverNow.Add(time.Minute * 10)
+	clnt.SetTokenWithExpiry("token", expiry)
+
+	if clnt.tokenHasExpired() {
+		t.Fatalf("token unexpectedly expired")
+	}
+
+	var _, gotExpiry, ok = clnt.TokenWithExpiry()
+	if !ok || !gotExpiry.Equal(expiry) {
+		t.Errorf("got expiry %v, %t, want %v, true", gotExpiry, ok, expiry)
+	}
+
+	// The token times used by the background refresher are converted to
+	// the local clock.
+	var lastLogin, localExpiry = clnt.tokenTimes()
+	if d := localExpiry.Sub(time.Now()); d < time.Minute*9 || d > time.Minute*11 {
+		t.Errorf("got local expiry in %v, want about 10m", d)
+	}
+
+	if lastLogin.After(time.Now()) {
+		t.Errorf("got last login %v in the future", lastLogin)
+	}
+
+	// A token which expired by the server's clock is expired, even though
+	// it would not have expired by the local clock.
+	clnt.SetTokenWithExpiry("token", serverNow.Add(time.Minute*30))
+	clnt.clockOffset.Store(int64(time.Hour))
+
+	if !clnt.tokenHasExpired() {
+		t.Errorf("token unexpectedly not expired")
+	}
+}
@@ -19,7 +19,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"math/big"
 
 	"github.com/vsglobalsign/hvclient"
 )
@@ -30,13 +29,13 @@ func retrieveCert(clnt *hvclient.Client, serialNumber string) {
 	var ctx, cancel = context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	var sn, ok = big.NewInt(0).SetString(serialNumber, 16)
-	if !ok {
-		log.Fatalf("invalid serial number: %s", serialNumber)
+	var sn, err = hvclient.SerialFromString(serialNumber)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
 
-	var cert, err = clnt.CertificateRetrieve(ctx, sn)
-	if err != nil {
+	var cert *hvclient.CertInfo
+	if cert, err = clnt.CertificateRetrieve(ctx, sn); err != nil {
 		log.Fatalf("%v", err)
 	}
 
@@ -49,13 +48,13 @@ func retrieveCertStatus(clnt *hvclient.Client, serialNumber string) {
 	var ctx, cancel = context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	var sn, ok = big.NewInt(0).SetString(serialNumber, 16)
-	if !ok {
-		log.Fatalf("invalid serial number: %s", sn)
+	var sn, err = hvclient.SerialFromString(serialNumber)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
 
-	var cert, err = clnt.CertificateRetrieve(ctx, sn)
-	if err != nil {
+	var cert *hvclient.CertInfo
+	if cert, err = clnt.CertificateRetrieve(ctx, sn); err != nil {
 		log.Fatalf("%v", err)
 	}
 
@@ -68,13 +67,13 @@ func retrieveCertUpdatedAt(clnt *hvclient.Client, serialNumber string) {
 	var ctx, cancel = context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	var sn, ok = big.NewInt(0).SetString(serialNumber, 16)
-	if !ok {
-		log.Fatalf("invalid serial number: %s", serialNumber)
+	var sn, err = hvclient.SerialFromString(serialNumber)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
 
-	var cert, err = clnt.CertificateRetrieve(ctx, sn)
-	if err != nil {
+	var cert *hvclient.CertInfo
+	if cert, err = clnt.CertificateRetrieve(ctx, sn); err != nil {
 		log.Fatalf("%v", err)
 	}
 
@@ -86,9 +85,9 @@ func revokeCert(clnt *hvclient.Client, serialNumber string) {
 	var ctx, cancel = context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	var sn, ok = big.NewInt(0).SetString(serialNumber, 16)
-	if !ok {
-		log.Fatalf("invalid serial number: %s", serialNumber)
+	var sn, err = hvclient.SerialFromString(serialNumber)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
 
 	if err := clnt.CertificateRevoke(ctx, sn); err != nil {
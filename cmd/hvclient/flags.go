@@ -71,6 +71,10 @@ var (
 	fSubjectJOIState           = flag.String("joistate", "", "subject jurisdiction state or province")
 	fSubjectJOICountry         = flag.String("joicountry", "", "subject jurisdiction country")
 	fSubjectBusinessCategory   = flag.String("businesscategory", "", "subject business category")
+	fSubjectGivenName          = flag.String("givenname", "", "subject given name")
+	fSubjectSurname            = flag.String("surname", "", "subject surname")
+	fSubjectPostalCode         = flag.String("postalcode", "", "subject postal code")
+	fSubjectOrgIdentifier      = flag.String("orgidentifier", "", "subject organization identifier")
 	fSubjectExtraAttributes    = flag.String("extraattributes", "", "subject extra attributes in format \"2.5.4.4=surname,2.5.4.5=serial_number")
 )
 
@@ -97,6 +97,10 @@ Certificate request options:
     -email=<string>               Subject DN email address (deprecated, use
                                   subject alternative names instead)
     -businesscategory=<string>    Subject DN business category
+    -givenname=<string>           Subject DN given name
+    -surname=<string>             Subject DN surname
+    -postalcode=<string>          Subject DN postal code
+    -orgidentifier=<string>       Subject DN organization identifier
     -joilocality=<string>         Subject DN jurisdiction locality
     -joistate=<string>            Subject DN jurisdiction state or province
     -joicountry=<string>          Subject DN jurisdiction country
@@ -64,6 +64,10 @@ type subjectValues struct {
 	joiState           string
 	joiCountry         string
 	businessCategory   string
+	givenName          string
+	surname            string
+	postalCode         string
+	orgIdentifier      string
 	email              string
 	extraAttributes    string
 }
@@ -90,6 +94,10 @@ func (s subjectValues) isEmpty() bool {
 		s.joiState,
 		s.joiCountry,
 		s.businessCategory,
+		s.givenName,
+		s.surname,
+		s.postalCode,
+		s.orgIdentifier,
 		s.email,
 		s.extraAttributes,
 	)
@@ -311,6 +319,10 @@ func buildDN(dn *hvclient.DN, values subjectValues) (*hvclient.DN, error) {
 		{values.joiState, &dn.JOIState},
 		{values.joiCountry, &dn.JOICountry},
 		{values.businessCategory, &dn.BusinessCategory},
+		{values.givenName, &dn.GivenName},
+		{values.surname, &dn.Surname},
+		{values.postalCode, &dn.PostalCode},
+		{values.orgIdentifier, &dn.OrganizationIdentifier},
 	} {
 		if field.from != "" {
 			*field.to = field.from
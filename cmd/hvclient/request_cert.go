@@ -52,6 +52,10 @@ func requestCert(clnt *hvclient.Client) error {
 				joiState:           *fSubjectJOIState,
 				joiCountry:         *fSubjectJOICountry,
 				businessCategory:   *fSubjectBusinessCategory,
+				givenName:          *fSubjectGivenName,
+				surname:            *fSubjectSurname,
+				postalCode:         *fSubjectPostalCode,
+				orgIdentifier:      *fSubjectOrgIdentifier,
 				extraAttributes:    *fSubjectExtraAttributes,
 			},
 			san: sanValues{
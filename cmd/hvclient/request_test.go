@@ -1143,7 +1143,7 @@ func TestBuildRequest(t *testing.T) {
 						testhelpers.MustParseURI(t, "lizard.acme.com"),
 						testhelpers.MustParseURI(t, "rat.acme.com"),
 					},
-					OtherNames: []hvclient.OIDAndString{
+					OtherNames: []hvclient.OtherName{
 						{
 							OID:   asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2, 3},
 							Value: "template@domain.com",
@@ -0,0 +1,137 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Names of the HTTP headers used to make conditional requests for resources
+// which rarely change, such as the trust chain and validation policy.
+const (
+	etagHeaderName            = "ETag"
+	lastModifiedHeaderName    = "Last-Modified"
+	ifNoneMatchHeaderName     = "If-None-Match"
+	ifModifiedSinceHeaderName = "If-Modified-Since"
+)
+
+// validators are the entity tag and last modification time returned by HVCA
+// with a resource, with which a conditional request for it can be made.
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+// isZero returns true if neither validator is present.
+func (v validators) isZero() bool {
+	return v.etag == "" && v.lastModified == ""
+}
+
+// setHeaders adds the headers for a conditional request to the request.
+func (v validators) setHeaders(request *http.Request) {
+	if v.etag != "" {
+		request.Header.Set(ifNoneMatchHeaderName, v.etag)
+	}
+
+	if v.lastModified != "" {
+		request.Header.Set(ifModifiedSinceHeaderName, v.lastModified)
+	}
+}
+
+// validatorsFromResponse returns the validators in a response.
+func validatorsFromResponse(response *http.Response) validators {
+	return validators{
+		etag:         response.Header.Get(etagHeaderName),
+		lastModified: response.Header.Get(lastModifiedHeaderName),
+	}
+}
+
+// conditionalResource is the most recently retrieved body of a resource,
+// along with its validators, allowing the resource to be retrieved again
+// with a conditional request. Access is synchronized by mtx.
+type conditionalResource struct {
+	mtx        sync.Mutex
+	validators validators
+	body       json.RawMessage
+}
+
+// reset discards the stored body and validators.
+func (r *conditionalResource) reset() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.validators = validators{}
+	r.body = nil
+}
+
+// validatorsKey is the context key for the validators of a conditional
+// request.
+type validatorsKey struct{}
+
+// withValidators returns a copy of the context which causes GET requests
+// made with it to be conditional on the specified validators, and a HTTP
+// 304 not modified response to be treated as success.
+func withValidators(ctx context.Context, v validators) context.Context {
+	return context.WithValue(ctx, validatorsKey{}, v)
+}
+
+// validatorsFromContext returns the validators from the context, if any
+// were added with withValidators.
+func validatorsFromContext(ctx context.Context) (validators, bool) {
+	var v, ok = ctx.Value(validatorsKey{}).(validators)
+
+	return v, ok
+}
+
+// getConditional retrieves a resource which rarely changes and unmarshals
+// it into out. If the resource has been retrieved before with validators,
+// the request is made conditional on them, and the stored body is used if
+// HVCA responds that the resource has not been modified. Otherwise, the
+// resource is retrieved unconditionally, and the new body and validators
+// are stored.
+func (c *Client) getConditional(ctx context.Context, path string, res *conditionalResource, out interface{}) error {
+	res.mtx.Lock()
+	defer res.mtx.Unlock()
+
+	if !res.validators.isZero() && res.body != nil {
+		ctx = withValidators(ctx, res.validators)
+	}
+
+	var body json.RawMessage
+	var response, err = c.makeRequest(ctx, path, http.MethodGet, nil, &body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode == http.StatusNotModified {
+		return json.Unmarshal(res.body, out)
+	}
+
+	if err = json.Unmarshal(body, out); err != nil {
+		return err
+	}
+
+	res.validators = validatorsFromResponse(response)
+	res.body = body
+	if res.validators.isZero() {
+		res.body = nil
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	t.Parallel()
+
+	var rootPEM, err = os.ReadFile("testdata/test_root_cert.pem")
+	if err != nil {
+		t.Fatalf("failed to read certificate: %v", err)
+	}
+
+	var chainJSON []byte
+	if chainJSON, err = json.Marshal([]string{string(rootPEM)}); err != nil {
+		t.Fatalf("failed to encode trust chain: %v", err)
+	}
+
+	var lastModified = time.Date(2021, 6, 18, 16, 29, 51, 0, time.UTC).Format(http.TimeFormat)
+
+	var testcases = []struct {
+		name         string
+		etag         string
+		lastModified string
+		header       string
+		value        string
+		wantFull     int32
+	}{
+		{
+			name:     "ETag",
+			etag:     `"v1"`,
+			header:   ifNoneMatchHeaderName,
+			value:    `"v1"`,
+			wantFull: 1,
+		},
+		{
+			name:         "LastModified",
+			lastModified: lastModified,
+			header:       ifModifiedSinceHeaderName,
+			value:        lastModified,
+			wantFull:     1,
+		},
+		{
+			name:     "NoValidators",
+			wantFull: 3,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		for _, path := range []string{endpointTrustChain, endpointPolicy} {
+			var path = path
+
+			t.Run(tc.name+path, func(t *testing.T) {
+				t.Parallel()
+
+				var full int32
+				var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if tc.header != "" && r.Header.Get(tc.header) == tc.value {
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+
+					atomic.AddInt32(&full, 1)
+
+					if tc.etag != "" {
+						w.Header().Set(etagHeaderName, tc.etag)
+					}
+
+					if tc.lastModified != "" {
+						w.Header().Set(lastModifiedHeaderName, tc.lastModified)
+					}
+
+					w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+					if r.URL.Path == endpointTrustChain {
+						w.Write(chainJSON)
+					} else {
+						fmt.Fprint(w, `{"validity":{"secondsmin":60,"secondsmax":3600},"public_key_signature":"OPTIONAL"}`)
+					}
+				}))
+				defer server.Close()
+
+				var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+				var ctx = context.Background()
+
+				for i := 0; i < 3; i++ {
+					if path == endpointTrustChain {
+						var chain, err = clnt.TrustChain(ctx)
+						if err != nil {
+							t.Fatalf("failed to retrieve trust chain: %v", err)
+						}
+
+						if len(chain) != 1 {
+							t.Fatalf("got %d certificates, want 1", len(chain))
+						}
+					} else {
+						var pol, err = clnt.Policy(ctx)
+						if err != nil {
+							t.Fatalf("failed to retrieve policy: %v", err)
+						}
+
+						if pol.Validity == nil || pol.Validity.SecondsMin != 60 {
+							t.Fatalf("got validity %+v, want minimum of 60 seconds", pol.Validity)
+						}
+					}
+				}
+
+				if got := atomic.LoadInt32(&full); got != tc.wantFull {
+					t.Errorf("got %d full responses, want %d", got, tc.wantFull)
+				}
+			})
+		}
+	}
+}
@@ -16,12 +16,19 @@ limitations under the License.
 package hvclient
 
 import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/vsglobalsign/hvclient/internal/config"
@@ -30,7 +37,12 @@ import (
 
 // Config is a configuration object for an HVCA client.
 type Config struct {
-	// URL is the URL of the HVCA service, including any version number.
+	// URL is the URL of the HVCA service, including any version number. It
+	// may include a path prefix, such as when HVCA is served by an API
+	// gateway, in which case the prefix is preserved and the endpoint paths
+	// are appended to it. For example, with a URL of
+	// https://gw.example.com/pki/hvca/v2, logins are made to
+	// https://gw.example.com/pki/hvca/v2/login.
 	URL string
 
 	// version is the major version number of the HVCA service located at
@@ -52,6 +64,13 @@ type Config struct {
 	// included in a certificate request.
 	TLSKey interface{}
 
+	// TLSCertificate, if not nil, is the client certificate to use for mutual
+	// TLS authentication to HVCA, and takes precedence over TLSCert and
+	// TLSKey, which are ignored. Since its PrivateKey may be any
+	// crypto.Signer, this allows the use of a private key which cannot be
+	// exported, such as one held in a hardware security module.
+	TLSCertificate *tls.Certificate
+
 	// APIKey is the API key for the HVCA account, provided by GlobalSign when
 	// the account was set up.
 	APIKey string
@@ -64,10 +83,92 @@ type Config struct {
 	// server certificate. If nil, the system pool will be used.
 	TLSRoots *x509.CertPool
 
+	// ServerCertPins, if not empty, restricts the TLS server certificates
+	// accepted from HVCA to those whose SHA-256 subject public key info hash,
+	// as returned by ServerCertPin, is one of the pins. The pins are checked
+	// in addition to the usual verification against TLSRoots, and a
+	// connection to a server presenting any other certificate fails with an
+	// error matching ErrServerCertPinMismatch. If HTTPClient is provided, the
+	// pins are applied to a copy of its transport in the same way as an mTLS
+	// certificate. The pins are not checked when downloading CRLs, which are
+	// usually served by other hosts.
+	ServerCertPins [][]byte
+
+	// HTTPClient, if not nil, is used to make requests to HVCA instead of an
+	// HTTP client built by this package, allowing control of proxies,
+	// connection pooling and the TLS configuration. In this case TLSRoots and
+	// InsecureSkipVerify are ignored, and the TLS configuration of the HTTP
+	// client's transport is used instead. If an mTLS certificate or server
+	// certificate pins are also provided, they are added to a copy of the
+	// transport, which must then be nil or an *http.Transport, and the HTTP
+	// client itself is not modified.
+	HTTPClient *http.Client
+
+	// MaxIdleConns is the maximum number of idle keep-alive connections to
+	// HVCA which the HTTP client built by this package will keep open. If
+	// this is omitted or set to zero, a default of 100 will be used. It is
+	// ignored if HTTPClient is provided.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost is the maximum number of idle keep-alive
+	// connections to each HVCA host which the HTTP client built by this
+	// package will keep open. Since all requests are made to a single host,
+	// this usually determines how many connections are reused during bursts
+	// of requests. If this is omitted or set to zero, a default of 10 will be
+	// used. It is ignored if HTTPClient is provided.
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout is the time after which an idle keep-alive connection
+	// to HVCA will be closed by the HTTP client built by this package. If
+	// this is omitted or set to zero, a default of 90 seconds will be used.
+	// It is ignored if HTTPClient is provided.
+	IdleConnTimeout time.Duration
+
+	// DialTimeout is the maximum time the HTTP client built by this package
+	// will wait to establish a connection to HVCA, including resolving its
+	// host name, independently of the request timeout. If this is omitted
+	// or set to zero, a default of 30 seconds will be used. It is ignored if
+	// HTTPClient is provided.
+	DialTimeout time.Duration
+
+	// KeepAlive is the interval between TCP keep-alive probes on connections
+	// to HVCA made by the HTTP client built by this package. If this is
+	// omitted or set to zero, a default of 30 seconds will be used, and if
+	// it is negative, keep-alive probes are disabled. It is ignored if
+	// HTTPClient is provided.
+	KeepAlive time.Duration
+
+	// Resolver, if not nil, is used by the HTTP client built by this package
+	// to resolve the host name of HVCA, instead of the default resolver. It
+	// is ignored if HTTPClient is provided.
+	Resolver *net.Resolver
+
+	// ForceHTTP1, if true, prevents the HTTP client built by this package
+	// from using HTTP/2, so that all requests to HVCA are made with
+	// HTTP/1.1. This may be needed when a proxy or other intermediary
+	// mishandles HTTP/2. Otherwise, HTTP/2 is used if HVCA supports it. It
+	// is ignored if HTTPClient is provided, in which case the protocol is
+	// determined by the HTTP client's transport.
+	ForceHTTP1 bool
+
 	// ExtraHeaders contains custom HTTP request headers to be passed to the
 	// HVCA server with each request.
 	ExtraHeaders map[string]string
 
+	// RequestIDFromContext, if not nil, is called with the context of each
+	// HTTP request made to HVCA, including retries and logins, and a
+	// non-empty result is sent in the X-Request-ID header, so that a
+	// correlation ID can be traced into HVCA's logs. It takes precedence
+	// over any X-Request-ID header in ExtraHeaders.
+	RequestIDFromContext func(ctx context.Context) string
+
+	// UserAgent, if not empty, is sent as the User-Agent header of all
+	// requests, including login requests, so that requests from different
+	// applications sharing an account can be told apart. It takes precedence
+	// over any User-Agent header in ExtraHeaders. If this is omitted, a
+	// default including the version of this package is used.
+	UserAgent string
+
 	// If InsecureSkipVerify is true, TLS accepts any certificate
 	// presented by the server and any host name in that certificate.
 	// In this mode, TLS is susceptible to man-in-the-middle attacks.
@@ -78,6 +179,195 @@ type Config struct {
 	// request. If this is omitted or set to zero, a reasonable default will
 	// be used.
 	Timeout time.Duration
+
+	// RequestTimeout, if not zero, is the maximum time allowed for each
+	// individual HTTP round trip to HVCA, including reading the response
+	// body. Each retry of a request is allowed the full timeout, and a round
+	// trip which times out is retried if the retry policy permits it. If the
+	// context passed to an API method has an earlier deadline, that deadline
+	// applies instead.
+	RequestTimeout time.Duration
+
+	// SlowRequestThreshold, if not zero, causes a warning to be logged for
+	// each HTTP round trip to HVCA, including retries and logins, which
+	// takes longer than the threshold to receive a response, with the
+	// endpoint, the time taken and the HTTP status, if a response was
+	// received. It has no effect if no logger was provided.
+	SlowRequestThreshold time.Duration
+
+	// MaxResponseBytes is the maximum size in bytes of an HTTP response body
+	// which will be read from HVCA. A request whose response body exceeds it
+	// fails with an error matching ErrResponseTooLarge. If this is omitted or
+	// set to zero, a default of 4 MiB will be used, which comfortably
+	// accommodates any legitimate response, including large trust chains.
+	MaxResponseBytes int64
+
+	// CRLURLs are the URLs from which CRL downloads the certificate
+	// revocation list of the issuing CA, tried in order until one succeeds.
+	// If this is omitted, the CRL distribution points are discovered from a
+	// certificate recently issued by the account.
+	CRLURLs []string
+
+	// CacheSize is the maximum number of certificates retrieved with
+	// CertificateRetrieve which are kept in an in-memory cache, so that
+	// repeated retrievals of the same certificate within CacheTTL do not
+	// contact HVCA. When the cache is full, the least recently used entry is
+	// evicted. Revoking a certificate through the client removes it from
+	// the cache, but a change of status made elsewhere, such as through
+	// another client, is not seen until the entry expires. If this is
+	// omitted or set to zero, certificates are not cached.
+	CacheSize int
+
+	// CacheTTL is the time for which a cached certificate is used before it
+	// is retrieved from HVCA again. If this is omitted or set to zero, a
+	// default of one minute will be used. It is ignored if CacheSize is
+	// zero.
+	CacheTTL time.Duration
+
+	// PolicyCacheTTL, if not zero, is the time for which the validation
+	// policy returned by Policy is cached, so that repeated calls within
+	// that time do not contact HVCA. The cache may be refreshed early with
+	// RefreshPolicy. If this is omitted or set to zero, the policy is
+	// retrieved from HVCA on every call. It is also the time for which the
+	// list of issuance profiles used to check the profile selected by a
+	// certificate request is cached, which is otherwise five minutes.
+	PolicyCacheTTL time.Duration
+
+	// IdempotencyStore, if not nil, records the result of each certificate
+	// request made with an idempotency key added to the context with
+	// WithIdempotencyKey, so a repeated request with the same key returns
+	// the recorded result instead of requesting another certificate. Two
+	// concurrent requests with the same key may still both be made, unless
+	// the store's implementation prevents it.
+	IdempotencyStore IdempotencyStore
+
+	// DebugTap, if not nil, is called after each HTTP round trip to HVCA,
+	// including logins and retries, with copies of the request and
+	// response and the response body, for logging when diagnosing
+	// problems. The request body, if any, may be read from the copy of the
+	// request, and the body of the copy of the response is empty. The
+	// response and body are nil if no response was received. The tap must
+	// not modify its arguments or make any calls to the client.
+	//
+	// Unless DebugTapIncludeSensitive is true, the Authorization header
+	// is removed from the copy of the request, and the request and
+	// response bodies of logins, which contain the API secret and the
+	// authentication token, are omitted.
+	DebugTap func(req *http.Request, resp *http.Response, body []byte)
+
+	// DebugTapIncludeSensitive causes DebugTap to receive authentication
+	// credentials which are otherwise redacted. It should be used only
+	// for testing.
+	DebugTapIncludeSensitive bool
+
+	// RetryPolicy controls the automatic retrying of requests which fail
+	// with a transient error. If nil, a default policy will be used which
+	// retries idempotent requests up to five times.
+	RetryPolicy *RetryPolicy
+
+	// OnLogin, if not nil, is called after every attempt to login to HVCA,
+	// whether successful or not, with the time taken by the attempt and any
+	// error which occurred. It is called both for the initial login and for
+	// any subsequent re-logins. The hook must not make any calls to the
+	// client, or a deadlock may result.
+	OnLogin func(ctx context.Context, elapsed time.Duration, err error)
+
+	// OnMTLSCertificateExpiring, if not nil, is called by NewClient if the
+	// mTLS certificate expires within MTLSExpiryWarning, or has already
+	// expired, with the certificate's expiry time. It allows an upcoming
+	// expiry to be alerted on before it causes requests to fail. A warning
+	// is also logged if a logger was provided.
+	OnMTLSCertificateExpiring func(notAfter time.Time)
+
+	// MTLSExpiryWarning is the time before the expiry of the mTLS
+	// certificate within which OnMTLSCertificateExpiring is called. If this
+	// is omitted or set to zero, a default of 30 days will be used.
+	MTLSExpiryWarning time.Duration
+
+	// TokenExpiryMargin is the amount of time before the expiry of an
+	// authentication token at which the client will treat it as expired and
+	// login again. If this is omitted or set to zero, a default of one minute
+	// will be used.
+	TokenExpiryMargin time.Duration
+
+	// InitialToken, if not empty, is an authentication token obtained
+	// elsewhere, such as by another process using the same account, which
+	// the client will use instead of performing an initial login. It will
+	// be used until InitialTokenExpiry, after which the client will login
+	// again as usual. If the token has already expired when the client is
+	// created, an initial login is performed.
+	InitialToken string
+
+	// InitialTokenExpiry is the time at which InitialToken expires. It
+	// must be provided if InitialToken is provided.
+	InitialTokenExpiry time.Time
+
+	// TokenLifetime is the assumed lifetime of an authentication token if
+	// HVCA does not report one when logging in. If this is omitted or set
+	// to zero, the lifetime currently documented for HVCA authentication
+	// tokens of ten minutes will be used. It must not be greater than one
+	// hour. Setting it longer than the actual lifetime of the token will
+	// cause requests to fail with an unauthorized status and be retried
+	// after logging in again, adding latency.
+	TokenLifetime time.Duration
+
+	// AutoRefresh enables a background goroutine which logs in again after
+	// 80% of the lifetime of the authentication token has elapsed, so that
+	// API calls rarely need to wait for a login. A client created with this
+	// option should be closed with its Close method when no longer needed.
+	AutoRefresh bool
+
+	// DisableAutoLogin, if true, prevents API calls from logging in again
+	// when the stored authentication token has expired, or when HVCA
+	// rejects it as unauthorized. Such calls instead fail immediately with
+	// an error matching ErrTokenExpired, and the caller is responsible for
+	// logging in with Client.Login or Client.RefreshToken, for example from
+	// a dedicated goroutine, or by enabling AutoRefresh. The initial login
+	// made by NewClient is still performed unless an unexpired initial
+	// token was provided.
+	DisableAutoLogin bool
+
+	// TokenSource, if not nil, supplies the authentication tokens sent with
+	// API calls, instead of the client logging in with its own account
+	// credentials. Sharing one TokenSource, such as one returned by
+	// NewLoginTokenSource, between several clients using the same account
+	// lets them share a single login, and a single re-login when the token
+	// expires. When it is set, APIKey and APISecret are not required, no
+	// initial login is made by NewClient, and InitialToken, AutoRefresh,
+	// DisableAutoLogin and OnLogin are ignored.
+	TokenSource TokenSource
+
+	// RateLimiter, if not nil, is waited on before each HTTP request made to
+	// HVCA, including retries and logins, to avoid exceeding any limit on the
+	// rate of requests for the account. A *rate.Limiter from the
+	// golang.org/x/time/rate package may be used.
+	RateLimiter RateLimiter
+
+	// Logger, if not nil, receives log messages about logins, retries, waits
+	// for the rate limiter, slow requests and unsuccessful responses from
+	// HVCA. If nil, no messages are logged.
+	Logger Logger
+
+	// Tracer, if not nil, is used to start a tracing span around each HVCA
+	// API call, including any logins, which are traced as child spans of
+	// the call which triggered them.
+	Tracer Tracer
+
+	// MetricsObserver, if not nil, is notified of the outcome of each HVCA
+	// API call, including any logins, after it completes.
+	MetricsObserver MetricsObserver
+
+	// BatchConcurrency is the maximum number of requests which batch
+	// operations such as CertificatesRevoke will make concurrently. If this
+	// is omitted or set to zero, a default of five will be used.
+	BatchConcurrency int
+}
+
+// RateLimiter limits the rate at which HTTP requests are made to HVCA.
+type RateLimiter interface {
+	// Wait blocks until the next request may be made, or returns an error
+	// if the context is done first.
+	Wait(ctx context.Context) error
 }
 
 // ClientProfile is a configuration object for HVCA client and contains
@@ -98,6 +388,32 @@ const (
 
 var defaultTimeout = time.Second * 60
 
+// Default connection pooling settings for the HTTP client built by this
+// package, tuned for making requests to a single HVCA host.
+const (
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 10
+	defaultIdleConnTimeout     = time.Second * 90
+	defaultDialTimeout         = time.Second * 30
+	defaultKeepAlive           = time.Second * 30
+
+	// thinClientMaxIdleConns is the default maximum number of idle
+	// connections, both in total and per host, for the HTTP client built
+	// by NewThinClient.
+	thinClientMaxIdleConns = 1024
+)
+
+// defaultMaxResponseBytes is the default maximum size of an HTTP response
+// body which will be read from HVCA.
+const defaultMaxResponseBytes = 4 << 20
+
+// defaultCacheTTL is the default time for which a cached certificate is used.
+const defaultCacheTTL = time.Minute
+
+// defaultMTLSExpiryWarning is the default time before the expiry of the mTLS
+// certificate within which a warning is given.
+const defaultMTLSExpiryWarning = time.Hour * 24 * 30
+
 // Validate returns an error if any fields in the configuration object are
 // missing or malformed. It also calculates a default timeout, if the Timeout
 // field is zero.
@@ -114,6 +430,12 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	// Endpoint paths are appended to the URL, so any path prefix, such as
+	// one used by an API gateway, is preserved. Remove any trailing slash so
+	// the resulting paths don't contain an empty segment.
+	c.url.Path = strings.TrimRight(c.url.Path, "/")
+	c.url.RawPath = strings.TrimRight(c.url.RawPath, "/")
+
 	var versionstring = filepath.Base(c.url.Path)
 
 	switch versionstring {
@@ -128,25 +450,218 @@ func (c *Config) Validate() error {
 		c.Timeout = defaultTimeout
 	}
 
-	// Ensure API key and secret were provided.
-	if c.APIKey == "" {
-		return errors.New("no API key provided")
-	}
+	// Ensure API key and secret were provided, unless tokens will be
+	// obtained from a token source instead.
+	if c.TokenSource == nil {
+		if c.APIKey == "" {
+			return errors.New("no API key provided")
+		}
 
-	if c.APISecret == "" {
-		return errors.New("no API secret provided")
+		if c.APISecret == "" {
+			return errors.New("no API secret provided")
+		}
 	}
 
 	// Check TLS key and certificate are either both present, or both absent.
-	if c.TLSKey == nil && c.TLSCert != nil {
+	// They are ignored if a TLS certificate is provided.
+	if c.TLSCertificate != nil {
+		if len(c.TLSCertificate.Certificate) == 0 {
+			return errors.New("mTLS certificate contains no certificates")
+		}
+
+		if _, ok := c.TLSCertificate.PrivateKey.(crypto.Signer); !ok {
+			return errors.New("mTLS certificate private key is not a crypto.Signer")
+		}
+	} else if c.TLSKey == nil && c.TLSCert != nil {
 		return errors.New("mTLS certificate provided but mTLS private key not provided")
 	} else if c.TLSKey != nil && c.TLSCert == nil {
 		return errors.New("mTLS certificate not provided but mTLS private key provided")
 	}
 
+	for _, pin := range c.ServerCertPins {
+		if len(pin) != sha256.Size {
+			return fmt.Errorf("server certificate pin must be %d bytes", sha256.Size)
+		}
+	}
+
+	if c.RequestTimeout < 0 {
+		return errors.New("negative request timeout")
+	}
+
+	if c.SlowRequestThreshold < 0 {
+		return errors.New("negative slow request threshold")
+	}
+
+	if c.MaxResponseBytes < 0 {
+		return errors.New("negative maximum response size")
+	}
+
+	if c.MTLSExpiryWarning < 0 {
+		return errors.New("negative mTLS certificate expiry warning")
+	}
+
+	for _, u := range c.CRLURLs {
+		if parsed, err := url.Parse(u); err != nil || !parsed.IsAbs() {
+			return fmt.Errorf("invalid CRL URL: %q", u)
+		}
+	}
+
+	if c.CacheSize < 0 {
+		return errors.New("negative cache size")
+	}
+
+	if c.CacheTTL < 0 {
+		return errors.New("negative cache TTL")
+	}
+
+	if c.PolicyCacheTTL < 0 {
+		return errors.New("negative policy cache TTL")
+	}
+
+	if c.MaxIdleConns < 0 || c.MaxIdleConnsPerHost < 0 {
+		return errors.New("negative maximum number of idle connections")
+	}
+
+	if c.IdleConnTimeout < 0 {
+		return errors.New("negative idle connection timeout")
+	}
+
+	if c.DialTimeout < 0 {
+		return errors.New("negative dial timeout")
+	}
+
+	if c.TokenExpiryMargin < 0 {
+		return errors.New("negative token expiry margin")
+	}
+
+	if c.TokenLifetime < 0 {
+		return errors.New("negative token lifetime")
+	} else if c.TokenLifetime > maxTokenLifetime {
+		return fmt.Errorf("token lifetime must not be greater than %v", maxTokenLifetime)
+	}
+
+	if c.InitialToken != "" && c.InitialTokenExpiry.IsZero() {
+		return errors.New("initial token provided but initial token expiry not provided")
+	}
+
+	if c.BatchConcurrency < 0 {
+		return errors.New("negative batch concurrency")
+	}
+
+	// Check retry policy values are not negative.
+	if c.RetryPolicy != nil {
+		if c.RetryPolicy.MaxRetries < 0 {
+			return errors.New("negative maximum number of retries")
+		} else if c.RetryPolicy.BaseDelay < 0 || c.RetryPolicy.MaxDelay < 0 {
+			return errors.New("negative retry delay")
+		}
+	}
+
 	return nil
 }
 
+// maxResponseBytes returns the maximum response body size specified in the
+// configuration, or the default if none was specified.
+func (c *Config) maxResponseBytes() int64 {
+	if c.MaxResponseBytes > 0 {
+		return c.MaxResponseBytes
+	}
+
+	return defaultMaxResponseBytes
+}
+
+// mtlsExpiryWarning returns the time before the expiry of the mTLS
+// certificate within which a warning is given, or the default if none was
+// specified.
+func (c *Config) mtlsExpiryWarning() time.Duration {
+	if c.MTLSExpiryWarning > 0 {
+		return c.MTLSExpiryWarning
+	}
+
+	return defaultMTLSExpiryWarning
+}
+
+// cacheTTL returns the time for which a cached certificate is used, or the
+// default if none was specified.
+func (c *Config) cacheTTL() time.Duration {
+	if c.CacheTTL > 0 {
+		return c.CacheTTL
+	}
+
+	return defaultCacheTTL
+}
+
+// maxIdleConns returns the maximum number of idle connections specified in
+// the configuration, or the default if none was specified.
+func (c *Config) maxIdleConns() int {
+	if c.MaxIdleConns > 0 {
+		return c.MaxIdleConns
+	}
+
+	return defaultMaxIdleConns
+}
+
+// maxIdleConnsPerHost returns the maximum number of idle connections per
+// host specified in the configuration, or the default if none was specified.
+func (c *Config) maxIdleConnsPerHost() int {
+	if c.MaxIdleConnsPerHost > 0 {
+		return c.MaxIdleConnsPerHost
+	}
+
+	return defaultMaxIdleConnsPerHost
+}
+
+// idleConnTimeout returns the idle connection timeout specified in the
+// configuration, or the default timeout if none was specified.
+func (c *Config) idleConnTimeout() time.Duration {
+	if c.IdleConnTimeout > 0 {
+		return c.IdleConnTimeout
+	}
+
+	return defaultIdleConnTimeout
+}
+
+// dialer returns a dialer for connections to HVCA with the dial timeout,
+// keep-alive interval and resolver specified in the configuration, or the
+// defaults if none were specified.
+func (c *Config) dialer() *net.Dialer {
+	var d = &net.Dialer{
+		Timeout:   defaultDialTimeout,
+		KeepAlive: defaultKeepAlive,
+		Resolver:  c.Resolver,
+	}
+
+	if c.DialTimeout > 0 {
+		d.Timeout = c.DialTimeout
+	}
+
+	if c.KeepAlive != 0 {
+		d.KeepAlive = c.KeepAlive
+	}
+
+	return d
+}
+
+// tokenExpiryMargin returns the token expiry margin specified in the
+// configuration, or the default margin if none was specified.
+func (c *Config) tokenExpiryMargin() time.Duration {
+	if c.TokenExpiryMargin > 0 {
+		return c.TokenExpiryMargin
+	}
+
+	return defaultTokenExpiryMargin
+}
+
+// tokenLifetime returns the token lifetime specified in the configuration,
+// or the default lifetime if none was specified.
+func (c *Config) tokenLifetime() time.Duration {
+	if c.TokenLifetime > 0 {
+		return c.TokenLifetime
+	}
+
+	return defaultTokenLifetime
+}
+
 // NewConfigFromFile creates a new HVCA client configuration object from
 // a configuration file.
 func NewConfigFromFile(filename string) (*Config, error) {
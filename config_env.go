@@ -0,0 +1,137 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/vsglobalsign/hvclient/internal/pki"
+)
+
+// Environment variables from which configuration values may be read.
+const (
+	EnvURL           = "HVCLIENT_URL"
+	EnvAPIKey        = "HVCLIENT_API_KEY"
+	EnvAPISecret     = "HVCLIENT_API_SECRET"
+	EnvCertFile      = "HVCLIENT_CERT"
+	EnvKeyFile       = "HVCLIENT_KEY"
+	EnvCertPEM       = "HVCLIENT_CERT_PEM"
+	EnvKeyPEM        = "HVCLIENT_KEY_PEM"
+	EnvKeyPassphrase = "HVCLIENT_KEY_PASSPHRASE"
+)
+
+// MergeEnv overrides fields in the configuration object with any non-empty
+// values set in the environment. The mTLS certificate and private key may be
+// provided either as paths to PEM-encoded files, or as inline PEM-encoded
+// data, but not both. If the private key is encrypted, its passphrase may be
+// provided in the HVCLIENT_KEY_PASSPHRASE environment variable.
+func (c *Config) MergeEnv() error {
+	for _, field := range []struct {
+		name     string
+		location *string
+	}{
+		{EnvURL, &c.URL},
+		{EnvAPIKey, &c.APIKey},
+		{EnvAPISecret, &c.APISecret},
+	} {
+		if value := os.Getenv(field.name); value != "" {
+			*field.location = value
+		}
+	}
+
+	var certFile, certPEM, err = envPathOrPEM(EnvCertFile, EnvCertPEM)
+	if err != nil {
+		return err
+	}
+
+	var keyFile, keyPEM string
+	if keyFile, keyPEM, err = envPathOrPEM(EnvKeyFile, EnvKeyPEM); err != nil {
+		return err
+	}
+
+	var passphrase = os.Getenv(EnvKeyPassphrase)
+
+	// Get mTLS private key, if provided.
+	switch {
+	case keyFile != "":
+		if c.TLSKey, err = pki.PrivateKeyFromFileWithPassword(keyFile, passphrase); err != nil {
+			return fmt.Errorf("couldn't get mTLS private key: %v", err)
+		}
+
+	case keyPEM != "":
+		if c.TLSKey, err = pki.PrivateKeyFromBytesWithPassword([]byte(keyPEM), passphrase); err != nil {
+			return fmt.Errorf("couldn't get mTLS private key: %v", err)
+		}
+	}
+
+	// Get mTLS certificate, if provided.
+	switch {
+	case certFile != "":
+		if c.TLSCert, err = pki.CertFromFile(certFile); err != nil {
+			return fmt.Errorf("couldn't get mTLS certificate: %v", err)
+		}
+
+	case certPEM != "":
+		if c.TLSCert, err = pki.CertFromBytes([]byte(certPEM)); err != nil {
+			return fmt.Errorf("couldn't get mTLS certificate: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// NewConfigFromEnv creates a new HVCA client configuration object from
+// environment variables.
+func NewConfigFromEnv() (*Config, error) {
+	var conf = &Config{}
+
+	var err = conf.MergeEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// NewClientFromEnv returns a new HVCA client from configuration values set
+// in environment variables. An initial login is made, and the returned client
+// is immediately ready to make API calls.
+func NewClientFromEnv(ctx context.Context) (*Client, error) {
+	var conf, err = NewConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(ctx, conf)
+}
+
+// envPathOrPEM returns the values of a pair of environment variables which
+// respectively contain the path to a PEM-encoded file and inline PEM-encoded
+// data, returning an error if both are set.
+func envPathOrPEM(pathName, pemName string) (string, string, error) {
+	var path, pemData = os.Getenv(pathName), os.Getenv(pemName)
+	if path != "" && pemData != "" {
+		return "", "", fmt.Errorf("only one of %s and %s may be set", pathName, pemName)
+	}
+
+	return path, pemData, nil
+}
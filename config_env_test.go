@@ -0,0 +1,136 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"testing"
+
+	"github.com/vsglobalsign/hvclient/internal/testhelpers"
+)
+
+// Note: these tests modify the environment, so they cannot run in parallel.
+
+func TestConfigMergeEnv(t *testing.T) {
+	var testcases = []struct {
+		name    string
+		env     map[string]string
+		wantKey bool
+		err     bool
+	}{
+		{
+			name: "NoMTLS",
+			env: map[string]string{
+				EnvURL:       "https://example.com/v2",
+				EnvAPIKey:    "env_key",
+				EnvAPISecret: "env_secret",
+			},
+		},
+		{
+			name: "Paths",
+			env: map[string]string{
+				EnvURL:           "https://example.com/v2",
+				EnvAPIKey:        "env_key",
+				EnvAPISecret:     "env_secret",
+				EnvCertFile:      "testdata/tls.cert",
+				EnvKeyFile:       "testdata/rsa_priv_enc.key",
+				EnvKeyPassphrase: "strongpassword",
+			},
+			wantKey: true,
+		},
+		{
+			name: "InlinePEM",
+			env: map[string]string{
+				EnvURL:       "https://example.com/v2",
+				EnvAPIKey:    "env_key",
+				EnvAPISecret: "env_secret",
+				EnvCertPEM:   string(testhelpers.MustReadFile(t, "testdata/tls.cert")),
+				EnvKeyPEM:    string(testhelpers.MustReadFile(t, "testdata/rsa_priv.key")),
+			},
+			wantKey: true,
+		},
+		{
+			name: "CertPathAndPEM",
+			env: map[string]string{
+				EnvCertFile: "testdata/tls.cert",
+				EnvCertPEM:  string(testhelpers.MustReadFile(t, "testdata/tls.cert")),
+			},
+			err: true,
+		},
+		{
+			name: "KeyPathAndPEM",
+			env: map[string]string{
+				EnvKeyFile: "testdata/rsa_priv.key",
+				EnvKeyPEM:  string(testhelpers.MustReadFile(t, "testdata/rsa_priv.key")),
+			},
+			err: true,
+		},
+		{
+			name: "BadKeyPEM",
+			env: map[string]string{
+				EnvKeyPEM: "not a key",
+			},
+			err: true,
+		},
+		{
+			name: "BadPassphrase",
+			env: map[string]string{
+				EnvKeyFile:       "testdata/rsa_priv_enc.key",
+				EnvKeyPassphrase: "wrongpassword",
+			},
+			err: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range []string{
+				EnvURL, EnvAPIKey, EnvAPISecret, EnvCertFile,
+				EnvKeyFile, EnvCertPEM, EnvKeyPEM, EnvKeyPassphrase,
+			} {
+				t.Setenv(name, tc.env[name])
+			}
+
+			var conf = Config{
+				URL:       "https://file.example.com/v2",
+				APIKey:    "file_key",
+				APISecret: "file_secret",
+			}
+
+			var err = conf.MergeEnv()
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error %t", err, tc.err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if conf.URL != tc.env[EnvURL] || conf.APIKey != tc.env[EnvAPIKey] || conf.APISecret != tc.env[EnvAPISecret] {
+				t.Errorf("environment values did not override existing values: %+v", conf)
+			}
+
+			if (conf.TLSKey != nil) != tc.wantKey || (conf.TLSCert != nil) != tc.wantKey {
+				t.Errorf("got mTLS key %t and certificate %t, want %t", conf.TLSKey != nil, conf.TLSCert != nil, tc.wantKey)
+			}
+
+			if err = conf.Validate(); err != nil {
+				t.Errorf("failed to validate configuration: %v", err)
+			}
+		})
+	}
+}
@@ -16,11 +16,18 @@ limitations under the License.
 package hvclient
 
 import (
+	"context"
+	"crypto"
 	"crypto/rsa"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -235,6 +242,165 @@ func TestConfigValidateFailure(t *testing.T) {
 				TLSCert:   nil,
 			},
 		},
+		{
+			name: "TLSCertificateNoCertificates",
+			conf: Config{
+				URL:       "http://example.com/v2",
+				APIKey:    "1234",
+				APISecret: "abcdefgh",
+				TLSCertificate: &tls.Certificate{
+					PrivateKey: testhelpers.MustGetPrivateKeyFromFile(t, "testdata/rsa_priv.key"),
+				},
+			},
+		},
+		{
+			name: "TLSCertificateNotSigner",
+			conf: Config{
+				URL:       "http://example.com/v2",
+				APIKey:    "1234",
+				APISecret: "abcdefgh",
+				TLSCertificate: &tls.Certificate{
+					Certificate: [][]byte{testhelpers.MustGetCertFromFile(t, "testdata/tls.cert").Raw},
+					PrivateKey:  "not a private key",
+				},
+			},
+		},
+		{
+			name: "InitialTokenNoExpiry",
+			conf: Config{
+				URL:          "http://example.com/v2",
+				APIKey:       "1234",
+				APISecret:    "abcdefgh",
+				InitialToken: "token",
+			},
+		},
+		{
+			name: "NegativeTokenLifetime",
+			conf: Config{
+				URL:           "http://example.com/v2",
+				APIKey:        "1234",
+				APISecret:     "abcdefgh",
+				TokenLifetime: -time.Minute,
+			},
+		},
+		{
+			name: "TokenLifetimeTooLong",
+			conf: Config{
+				URL:           "http://example.com/v2",
+				APIKey:        "1234",
+				APISecret:     "abcdefgh",
+				TokenLifetime: time.Hour * 2,
+			},
+		},
+		{
+			name: "NegativeRequestTimeout",
+			conf: Config{
+				URL:            "http://example.com/v2",
+				APIKey:         "1234",
+				APISecret:      "abcdefgh",
+				RequestTimeout: -time.Second,
+			},
+		},
+		{
+			name: "NegativeSlowRequestThreshold",
+			conf: Config{
+				URL:                  "http://example.com/v2",
+				APIKey:               "1234",
+				APISecret:            "abcdefgh",
+				SlowRequestThreshold: -time.Second,
+			},
+		},
+		{
+			name: "BadServerCertPin",
+			conf: Config{
+				URL:            "http://example.com/v2",
+				APIKey:         "1234",
+				APISecret:      "abcdefgh",
+				ServerCertPins: [][]byte{[]byte("short")},
+			},
+		},
+		{
+			name: "NegativeMaxIdleConnsPerHost",
+			conf: Config{
+				URL:                 "http://example.com/v2",
+				APIKey:              "1234",
+				APISecret:           "abcdefgh",
+				MaxIdleConnsPerHost: -1,
+			},
+		},
+		{
+			name: "NegativeIdleConnTimeout",
+			conf: Config{
+				URL:             "http://example.com/v2",
+				APIKey:          "1234",
+				APISecret:       "abcdefgh",
+				IdleConnTimeout: -time.Second,
+			},
+		},
+		{
+			name: "NegativeDialTimeout",
+			conf: Config{
+				URL:         "http://example.com/v2",
+				APIKey:      "1234",
+				APISecret:   "abcdefgh",
+				DialTimeout: -time.Second,
+			},
+		},
+		{
+			name: "NegativeMTLSExpiryWarning",
+			conf: Config{
+				URL:               "http://example.com/v2",
+				APIKey:            "1234",
+				APISecret:         "abcdefgh",
+				MTLSExpiryWarning: -time.Hour,
+			},
+		},
+		{
+			name: "RelativeCRLURL",
+			conf: Config{
+				URL:       "http://example.com/v2",
+				APIKey:    "1234",
+				APISecret: "abcdefgh",
+				CRLURLs:   []string{"/crl"},
+			},
+		},
+		{
+			name: "NegativeCacheSize",
+			conf: Config{
+				URL:       "http://example.com/v2",
+				APIKey:    "1234",
+				APISecret: "abcdefgh",
+				CacheSize: -1,
+			},
+		},
+		{
+			name: "NegativeCacheTTL",
+			conf: Config{
+				URL:       "http://example.com/v2",
+				APIKey:    "1234",
+				APISecret: "abcdefgh",
+				CacheSize: 10,
+				CacheTTL:  -time.Second,
+			},
+		},
+		{
+			name: "NegativePolicyCacheTTL",
+			conf: Config{
+				URL:            "http://example.com/v2",
+				APIKey:         "1234",
+				APISecret:      "abcdefgh",
+				PolicyCacheTTL: -time.Second,
+			},
+		},
+		{
+			name: "NegativeBatchConcurrency",
+			conf: Config{
+				URL:              "http://example.com/v2",
+				APIKey:           "1234",
+				APISecret:        "abcdefgh",
+				BatchConcurrency: -1,
+			},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -249,3 +415,225 @@ func TestConfigValidateFailure(t *testing.T) {
 		})
 	}
 }
+
+// opaqueSigner is a crypto.Signer which does not expose its private key, in
+// the same way as a signer backed by a hardware security module.
+type opaqueSigner struct {
+	signer crypto.Signer
+}
+
+func (s opaqueSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+func (s opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.Sign(rand, digest, opts)
+}
+
+func TestConfigTLSCertificate(t *testing.T) {
+	t.Parallel()
+
+	var cert = testhelpers.MustGetCertFromFile(t, "testdata/tls.cert")
+	var key = testhelpers.MustGetPrivateKeyFromFile(t, "testdata/rsa_priv.key")
+	var signer = opaqueSigner{signer: key.(crypto.Signer)}
+
+	// The TLS certificate should take precedence over the TLS key and
+	// certificate. TLSCert without TLSKey would otherwise fail validation,
+	// so this also shows they are ignored.
+	var conf = Config{
+		URL:       "https://example.com/v2",
+		APIKey:    "1234",
+		APISecret: "abcdefgh",
+		TLSCert:   cert,
+		TLSCertificate: &tls.Certificate{
+			Certificate: [][]byte{cert.Raw},
+			PrivateKey:  signer,
+		},
+	}
+
+	if err := conf.Validate(); err != nil {
+		t.Fatalf("failed to validate configuration: %v", err)
+	}
+
+	var hc, err = conf.newHTTPClient()
+	if err != nil {
+		t.Fatalf("failed to create HTTP client: %v", err)
+	}
+
+	var certs = hc.Transport.(*http.Transport).TLSClientConfig.Certificates
+	if len(certs) != 1 {
+		t.Fatalf("got %d certificates, want 1", len(certs))
+	}
+
+	if certs[0].PrivateKey != signer {
+		t.Errorf("got private key %v, want %v", certs[0].PrivateKey, signer)
+	}
+}
+
+func TestConfigConnectionPooling(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name            string
+		conf            Config
+		maxIdle         int
+		maxIdlePerHost  int
+		idleConnTimeout time.Duration
+	}{
+		{
+			name:            "Defaults",
+			maxIdle:         defaultMaxIdleConns,
+			maxIdlePerHost:  defaultMaxIdleConnsPerHost,
+			idleConnTimeout: defaultIdleConnTimeout,
+		},
+		{
+			name: "Custom",
+			conf: Config{
+				MaxIdleConns:        20,
+				MaxIdleConnsPerHost: 5,
+				IdleConnTimeout:     time.Minute,
+			},
+			maxIdle:         20,
+			maxIdlePerHost:  5,
+			idleConnTimeout: time.Minute,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tc.conf.URL = "https://example.com/v2"
+			tc.conf.APIKey = "1234"
+			tc.conf.APISecret = "abcdefgh"
+
+			if err := tc.conf.Validate(); err != nil {
+				t.Fatalf("failed to validate configuration: %v", err)
+			}
+
+			var hc, err = tc.conf.newHTTPClient()
+			if err != nil {
+				t.Fatalf("failed to create HTTP client: %v", err)
+			}
+
+			var tnspt = hc.Transport.(*http.Transport)
+
+			if tnspt.MaxIdleConns != tc.maxIdle {
+				t.Errorf("got maximum idle connections %d, want %d", tnspt.MaxIdleConns, tc.maxIdle)
+			}
+
+			if tnspt.MaxIdleConnsPerHost != tc.maxIdlePerHost {
+				t.Errorf("got maximum idle connections per host %d, want %d",
+					tnspt.MaxIdleConnsPerHost, tc.maxIdlePerHost)
+			}
+
+			if tnspt.IdleConnTimeout != tc.idleConnTimeout {
+				t.Errorf("got idle connection timeout %v, want %v", tnspt.IdleConnTimeout, tc.idleConnTimeout)
+			}
+		})
+	}
+}
+
+func TestConfigDialer(t *testing.T) {
+	t.Parallel()
+
+	var resolver = &net.Resolver{}
+
+	var testcases = []struct {
+		name      string
+		conf      Config
+		timeout   time.Duration
+		keepAlive time.Duration
+	}{
+		{
+			name:      "Defaults",
+			timeout:   defaultDialTimeout,
+			keepAlive: defaultKeepAlive,
+		},
+		{
+			name: "Custom",
+			conf: Config{
+				DialTimeout: time.Second * 5,
+				KeepAlive:   time.Minute,
+				Resolver:    resolver,
+			},
+			timeout:   time.Second * 5,
+			keepAlive: time.Minute,
+		},
+		{
+			name:      "KeepAliveDisabled",
+			conf:      Config{KeepAlive: -1},
+			timeout:   defaultDialTimeout,
+			keepAlive: -1,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var d = tc.conf.dialer()
+
+			if d.Timeout != tc.timeout {
+				t.Errorf("got dial timeout %v, want %v", d.Timeout, tc.timeout)
+			}
+
+			if d.KeepAlive != tc.keepAlive {
+				t.Errorf("got keep-alive interval %v, want %v", d.KeepAlive, tc.keepAlive)
+			}
+
+			if d.Resolver != tc.conf.Resolver {
+				t.Errorf("got resolver %p, want %p", d.Resolver, tc.conf.Resolver)
+			}
+		})
+	}
+}
+
+func TestConfigResolver(t *testing.T) {
+	t.Parallel()
+
+	// A resolver which fails every lookup must be consulted for the HVCA
+	// host name when the HTTP client built by this package connects.
+	var lookups int32
+	var errNoDNS = errors.New("no DNS for you")
+
+	var conf = Config{
+		URL:       "http://hvca.example.com/v2",
+		APIKey:    "1234",
+		APISecret: "abcdefgh",
+		Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				atomic.AddInt32(&lookups, 1)
+				return nil, errNoDNS
+			},
+		},
+	}
+
+	if err := conf.Validate(); err != nil {
+		t.Fatalf("failed to validate configuration: %v", err)
+	}
+
+	var hc, err = conf.newHTTPClient()
+	if err != nil {
+		t.Fatalf("failed to create HTTP client: %v", err)
+	}
+
+	// Ignore any proxy settings from the environment, so the HVCA host
+	// name is resolved locally.
+	hc.Transport.(*http.Transport).Proxy = nil
+
+	var resp *http.Response
+	if resp, err = hc.Get(conf.URL); err == nil {
+		resp.Body.Close()
+		t.Fatalf("unexpectedly connected to %s", conf.URL)
+	}
+
+	if atomic.LoadInt32(&lookups) == 0 {
+		t.Errorf("custom resolver was not used")
+	}
+}
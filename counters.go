@@ -0,0 +1,73 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"sync"
+)
+
+// Counters contains the certificate counters of an HVCA account.
+type Counters struct {
+	// Issued is the number of certificates issued by the account.
+	Issued int64
+
+	// Revoked is the number of certificates revoked by the account.
+	Revoked int64
+}
+
+// Counters returns the numbers of certificates issued and revoked by the
+// calling account. HVCA has no endpoint which returns both counters, so they
+// are retrieved by concurrent requests. The result is therefore not an
+// atomic snapshot: a certificate issued or revoked while the requests are
+// in progress may be reflected in one counter and not the other. If either
+// request fails, the other is cancelled and the first error is returned.
+func (c *Client) Counters(ctx context.Context) (*Counters, error) {
+	var ctx2, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	var counters Counters
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	var fetch = func(path string, dst *int64) {
+		defer wg.Done()
+
+		var value, err = c.countersCommon(ctx2, path)
+		if err != nil {
+			once.Do(func() {
+				firstErr = err
+				cancel()
+			})
+
+			return
+		}
+
+		*dst = value
+	}
+
+	wg.Add(2)
+	go fetch(endpointCountersCertificatesIssued, &counters.Issued)
+	go fetch(endpointCountersCertificatesRevoked, &counters.Revoked)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return &counters, nil
+}
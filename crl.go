@@ -0,0 +1,299 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+// crlDiscoveryWindow is how far back to look for a recently issued
+// certificate from which to discover the CRL distribution points, if none
+// are specified in the configuration.
+const crlDiscoveryWindow = time.Hour * 24 * 30
+
+// pemTypeCRL is the type of a PEM block containing a CRL.
+const pemTypeCRL = "X509 CRL"
+
+// ErrNoCRLDistributionPoint is returned by CRL if no CRL distribution point
+// is specified in the configuration and none could be discovered.
+var ErrNoCRLDistributionPoint = errors.New("hvclient: no CRL distribution point found")
+
+// CRLDownloadError is returned by CRL if the CRL could not be downloaded
+// from any of the URLs tried. It contains the error for each URL, in the
+// order in which they were tried, and matches any of them with errors.Is and
+// errors.As.
+type CRLDownloadError struct {
+	URLs []string
+	Errs []error
+}
+
+// Error returns a string representation of the error.
+func (e CRLDownloadError) Error() string {
+	var msgs = make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = fmt.Sprintf("failed to download CRL from %s: %v", e.URLs[i], err)
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Is returns true if the error for any of the URLs matches the target.
+func (e CRLDownloadError) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first error for any of the URLs which matches the target, and
+// if one is found, sets the target to that error value and returns true.
+func (e CRLDownloadError) As(target interface{}) bool {
+	for _, err := range e.Errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CRL downloads and returns the current certificate revocation list of the
+// CA which issues the calling account's certificates, after verifying its
+// signature against the issuing CA certificate in the trust chain.
+//
+// The CRL is downloaded from the URLs in the CRLURLs field of the
+// configuration or, if there are none, from the CRL distribution points of
+// a certificate recently issued by the account. Each URL is tried in turn
+// until the CRL is successfully downloaded, without the mTLS certificate or
+// server certificate pins used for requests to HVCA, and each download is
+// bounded by the timeout in the configuration. If every URL fails, a
+// CRLDownloadError is returned. The CRL is cached until its next update
+// time.
+//
+// Concurrent calls share a single download, which is made with a context
+// which is not cancelled if the context of the first caller is cancelled,
+// and which instead times out after the timeout specified in the
+// configuration, so a caller whose context is done returns promptly without
+// failing the others.
+func (c *Client) CRL(ctx context.Context) (*x509.RevocationList, error) {
+	if crl := c.cachedCRL(time.Now()); crl != nil {
+		return crl, nil
+	}
+
+	var ch = c.crlGroup.DoChan("", func() (interface{}, error) {
+		var sharedCtx context.Context = detachedContext{ctx}
+		if c.Config != nil && c.Config.Timeout > 0 {
+			var cancel context.CancelFunc
+			sharedCtx, cancel = context.WithTimeout(sharedCtx, c.Config.Timeout)
+			defer cancel()
+		}
+
+		return c.fetchCRL(sharedCtx)
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		return res.Val.(*x509.RevocationList), nil
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// cachedCRL returns the cached CRL, or nil if there is none or if it has
+// passed its next update time.
+func (c *Client) cachedCRL(now time.Time) *x509.RevocationList {
+	c.crlMtx.Lock()
+	defer c.crlMtx.Unlock()
+
+	if c.crl != nil && now.Before(c.crl.NextUpdate) {
+		return c.crl
+	}
+
+	return nil
+}
+
+// fetchCRL downloads and verifies the current CRL as described for CRL, and
+// caches it if it has not passed its next update time.
+func (c *Client) fetchCRL(ctx context.Context) (*x509.RevocationList, error) {
+	var now = time.Now()
+
+	var chain, err = c.TrustChainCerts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("empty trust chain")
+	}
+
+	var urls []string
+	if urls, err = c.crlURLs(ctx, now); err != nil {
+		return nil, err
+	}
+
+	var downloadErr CRLDownloadError
+	for _, u := range urls {
+		var crl *x509.RevocationList
+		if crl, err = c.downloadCRL(ctx, u, chain[0]); err != nil {
+			downloadErr.URLs = append(downloadErr.URLs, u)
+			downloadErr.Errs = append(downloadErr.Errs, err)
+
+			continue
+		}
+
+		if now.Before(crl.NextUpdate) {
+			c.crlMtx.Lock()
+			c.crl = crl
+			c.crlMtx.Unlock()
+		}
+
+		return crl, nil
+	}
+
+	return nil, downloadErr
+}
+
+// crlURLs returns the URLs from which to download the CRL, either from the
+// configuration or discovered from a recently issued certificate.
+func (c *Client) crlURLs(ctx context.Context, now time.Time) ([]string, error) {
+	if len(c.Config.CRLURLs) > 0 {
+		return c.Config.CRLURLs, nil
+	}
+
+	var certs, _, err = c.StatsIssued(ctx, 1, 1, now.Add(-crlDiscoveryWindow), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recently issued certificate: %w", err)
+	}
+
+	if len(certs) == 0 {
+		return nil, ErrNoCRLDistributionPoint
+	}
+
+	var info *CertInfo
+	if info, err = c.CertificateRetrieve(ctx, certs[0].SerialNumber); err != nil {
+		return nil, fmt.Errorf("failed to retrieve recently issued certificate: %w", err)
+	}
+
+	var urls = info.CRLDistributionPoints()
+	if len(urls) == 0 {
+		return nil, ErrNoCRLDistributionPoint
+	}
+
+	return urls, nil
+}
+
+// downloadCRL downloads and parses the CRL at the specified URL, and
+// verifies that it was signed by the specified issuer.
+func (c *Client) downloadCRL(ctx context.Context, u string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	// Abort the download if the client is closed, or if it takes longer
+	// than the timeout in the configuration.
+	var cancel context.CancelFunc
+	ctx, cancel = c.withLifecycle(ctx)
+	defer cancel()
+
+	var timeout = defaultTimeout
+	if c.Config.Timeout > 0 {
+		timeout = c.Config.Timeout
+	}
+
+	ctx, cancel = context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var request, err = http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new HTTP request: %w", err)
+	}
+
+	c.Config.setUserAgent(request)
+
+	var response *http.Response
+	if response, err = c.crlHTTPClient().Do(request); err != nil {
+		return nil, fmt.Errorf("failed to execute HTTP request: %w", err)
+	}
+	defer httputils.ConsumeAndCloseResponseBody(response)
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %d", response.StatusCode)
+	}
+
+	response.Body = &limitedBody{
+		ReadCloser: response.Body,
+		remaining:  c.Config.maxResponseBytes(),
+	}
+
+	var data []byte
+	if data, err = ioutil.ReadAll(response.Body); err != nil {
+		return nil, fmt.Errorf("failed to read HTTP response body: %w", err)
+	}
+
+	// CRLs are normally DER encoded, but accept PEM encoding too.
+	if block, _ := pem.Decode(data); block != nil && block.Type == pemTypeCRL {
+		data = block.Bytes
+	}
+
+	var crl *x509.RevocationList
+	if crl, err = x509.ParseRevocationList(data); err != nil {
+		return nil, fmt.Errorf("failed to parse CRL: %w", err)
+	}
+
+	if err = crl.CheckSignatureFrom(issuer); err != nil {
+		return nil, fmt.Errorf("failed to verify CRL signature: %w", err)
+	}
+
+	return crl, nil
+}
+
+// crlHTTPClient returns the HTTP client used to download CRLs. CRLs are
+// usually served by hosts other than HVCA, so neither the mTLS certificate
+// nor any server certificate pins in the configuration are used. Any HTTP
+// client in the configuration, or passed to NewThinClient, is used as
+// provided.
+func (c *Client) crlHTTPClient() *http.Client {
+	c.crlClientOnce.Do(func() {
+		if hc := c.callerHTTPClient(); hc != nil {
+			c.crlClient = hc
+			return
+		}
+
+		c.crlClient = &http.Client{
+			Transport: &http.Transport{
+				DialContext:     c.Config.dialer().DialContext,
+				IdleConnTimeout: c.Config.idleConnTimeout(),
+				Proxy:           http.ProxyFromEnvironment,
+			},
+		}
+	})
+
+	return c.crlClient
+}
@@ -0,0 +1,357 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+// crlTestServer is a test server which serves a trust chain, a certificate
+// issued by the CA in the trust chain, and a CRL at the /crl path.
+type crlTestServer struct {
+	*httptest.Server
+	crlDownloads int32
+	stalled      chan struct{}
+}
+
+// newCRLTestServer creates a test server serving a CRL with the specified
+// next update time, signed by the CA in the trust chain or, if badSigner is
+// true, by a different key.
+func newCRLTestServer(t *testing.T, nextUpdate time.Time, badSigner bool) *crlTestServer {
+	t.Helper()
+
+	var caKey = mustGenerateECKey(t)
+	var caTemplate = &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour * 24),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	var caDER, err = x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+
+	var caCert *x509.Certificate
+	if caCert, err = x509.ParseCertificate(caDER); err != nil {
+		t.Fatalf("failed to parse CA certificate: %v", err)
+	}
+
+	var crlKey = caKey
+	if badSigner {
+		crlKey = mustGenerateECKey(t)
+	}
+
+	var crlDER []byte
+	if crlDER, err = x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: nextUpdate,
+		RevokedCertificates: []pkix.RevokedCertificate{
+			{SerialNumber: big.NewInt(0x741daf9ec2d5f7dc), RevocationTime: time.Now().Add(-time.Minute)},
+		},
+	}, caCert, crlKey); err != nil {
+		t.Fatalf("failed to create CRL: %v", err)
+	}
+
+	var server = &crlTestServer{stalled: make(chan struct{}, 1)}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/crl":
+			atomic.AddInt32(&server.crlDownloads, 1)
+			w.Write(crlDER)
+			return
+
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			return
+
+		case r.URL.Path == "/stall":
+			select {
+			case server.stalled <- struct{}{}:
+			default:
+			}
+
+			<-r.Context().Done()
+			return
+		}
+
+		var body interface{}
+
+		switch {
+		case r.URL.Path == endpointTrustChain:
+			body = []string{string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))}
+
+		case r.URL.Path == endpointStatsIssued:
+			w.Header().Set(totalCountHeaderName, "1")
+			body = []CertMeta{{SerialNumber: big.NewInt(2), NotBefore: time.Now(), NotAfter: time.Now()}}
+
+		case strings.HasPrefix(r.URL.Path, endpointCertificates+"/"):
+			var leafDER, err = x509.CreateCertificate(rand.Reader, &x509.Certificate{
+				SerialNumber:          big.NewInt(2),
+				Subject:               pkix.Name{CommonName: "leaf"},
+				NotBefore:             time.Now().Add(-time.Hour),
+				NotAfter:              time.Now().Add(time.Hour),
+				CRLDistributionPoints: []string{server.URL + "/missing", server.URL + "/crl"},
+			}, caCert, caKey.Public(), caKey)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			body = CertInfo{
+				PEM:       string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})),
+				Status:    StatusIssued,
+				UpdatedAt: time.Now(),
+			}
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+		json.NewEncoder(w).Encode(body)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func mustGenerateECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	var key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	return key
+}
+
+func TestClientCRL(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name       string
+		urls       []string
+		nextUpdate time.Duration
+		badSigner  bool
+		downloads  int32
+		err        bool
+	}{
+		{
+			name:       "Discovered",
+			nextUpdate: time.Hour,
+			downloads:  1,
+		},
+		{
+			name:       "Configured",
+			urls:       []string{"/missing", "/crl"},
+			nextUpdate: time.Hour,
+			downloads:  1,
+		},
+		{
+			name:       "Expired",
+			nextUpdate: -time.Second,
+			downloads:  2,
+		},
+		{
+			name:       "BadSignature",
+			nextUpdate: time.Hour,
+			badSigner:  true,
+			downloads:  2,
+			err:        true,
+		},
+		{
+			name:       "NotFound",
+			urls:       []string{"/missing"},
+			nextUpdate: time.Hour,
+			err:        true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var server = newCRLTestServer(t, time.Now().Add(tc.nextUpdate), tc.badSigner)
+
+			var clnt = newTestClient(t, server.URL, nil)
+			for _, u := range tc.urls {
+				clnt.Config.CRLURLs = append(clnt.Config.CRLURLs, server.URL+u)
+			}
+
+			// Fetch the CRL twice, to check that it is cached until its
+			// next update time.
+			for i := 0; i < 2; i++ {
+				var crl, err = clnt.CRL(context.Background())
+				if (err != nil) != tc.err {
+					t.Fatalf("got error %v, want error %t", err, tc.err)
+				}
+
+				if err != nil {
+					continue
+				}
+
+				if len(crl.RevokedCertificates) != 1 {
+					t.Fatalf("got %d revoked certificates, want 1", len(crl.RevokedCertificates))
+				}
+			}
+
+			if got := atomic.LoadInt32(&server.crlDownloads); got != tc.downloads {
+				t.Errorf("got %d CRL downloads, want %d", got, tc.downloads)
+			}
+		})
+	}
+}
+
+func TestClientCRLWithServerCertPins(t *testing.T) {
+	t.Parallel()
+
+	var server = newCRLTestServer(t, time.Now().Add(time.Hour), false)
+
+	// Serve the CRL over HTTPS from a host with a certificate which doesn't
+	// match the pins, discarding the handshake errors logged by the server
+	// when a pin does not match.
+	var target, err = url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse URL: %v", err)
+	}
+
+	var crlServer = httptest.NewUnstartedServer(httputil.NewSingleHostReverseProxy(target))
+	crlServer.Config.ErrorLog = log.New(io.Discard, "", 0)
+	crlServer.StartTLS()
+	t.Cleanup(crlServer.Close)
+
+	var clnt = newTestClient(t, server.URL, nil)
+	clnt.Config.CRLURLs = []string{crlServer.URL + "/crl"}
+	clnt.Config.HTTPClient = crlServer.Client()
+	clnt.Config.ServerCertPins = [][]byte{make([]byte, sha256.Size)}
+
+	if clnt.HTTPClient, err = clnt.Config.newHTTPClient(); err != nil {
+		t.Fatalf("failed to create HTTP client: %v", err)
+	}
+
+	// The pins apply to requests to HVCA, but not to CRL downloads.
+	var response *http.Response
+	if response, err = clnt.HTTPClient.Get(crlServer.URL + "/crl"); !errors.Is(err, ErrServerCertPinMismatch) {
+		if err == nil {
+			response.Body.Close()
+		}
+
+		t.Fatalf("got error %v, want %v", err, ErrServerCertPinMismatch)
+	}
+
+	var crl *x509.RevocationList
+	if crl, err = clnt.CRL(context.Background()); err != nil {
+		t.Fatalf("failed to get CRL: %v", err)
+	}
+
+	if len(crl.RevokedCertificates) != 1 {
+		t.Fatalf("got %d revoked certificates, want 1", len(crl.RevokedCertificates))
+	}
+}
+
+func TestClientCRLStalled(t *testing.T) {
+	t.Parallel()
+
+	var server = newCRLTestServer(t, time.Now().Add(time.Hour), false)
+
+	var clnt = newTestClient(t, server.URL, nil)
+	clnt.Config.Timeout = time.Millisecond * 200
+	clnt.Config.CRLURLs = []string{server.URL + "/missing", server.URL + "/stall"}
+
+	// The stalled download should time out even though the context has no
+	// deadline, and the errors from both URLs should be reported.
+	var _, err = clnt.CRL(context.Background())
+
+	var downloadErr CRLDownloadError
+	if !errors.As(err, &downloadErr) {
+		t.Fatalf("got error %v, want %T", err, downloadErr)
+	}
+
+	if len(downloadErr.Errs) != 2 {
+		t.Fatalf("got %d errors, want 2: %v", len(downloadErr.Errs), err)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if !strings.Contains(err.Error(), "unexpected HTTP status 404") {
+		t.Errorf("error %q does not report the missing CRL", err)
+	}
+}
+
+func TestClientCRLClose(t *testing.T) {
+	t.Parallel()
+
+	var server = newCRLTestServer(t, time.Now().Add(time.Hour), false)
+
+	var clnt = newTestClient(t, server.URL, nil)
+	clnt.Config.CRLURLs = []string{server.URL + "/stall"}
+
+	var done = make(chan error, 1)
+	go func() {
+		var _, err = clnt.CRL(context.Background())
+		done <- err
+	}()
+
+	// Closing the client should abort the stalled download.
+	<-server.stalled
+	clnt.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v, want %v", err, context.Canceled)
+		}
+
+	case <-time.After(time.Second * 5):
+		t.Fatal("CRL download not aborted when client closed")
+	}
+}
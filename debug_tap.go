@@ -0,0 +1,96 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+// callDebugTap calls the debug tap, if one was provided in the
+// configuration, with copies of a request for the specified path and its
+// response. If there is a
+// response, its body is read and replaced so it can still be read by the
+// caller, including any error which occurs while reading it.
+func (c *Client) callDebugTap(path string, request *http.Request, reqBody []byte, response *http.Response) {
+	if c.Config == nil || c.Config.DebugTap == nil {
+		return
+	}
+
+	var sensitive = c.Config.DebugTapIncludeSensitive
+	var isLogin = strings.HasPrefix(path, endpointLogin)
+
+	var reqCopy = request.Clone(request.Context())
+	reqCopy.Body = http.NoBody
+	reqCopy.GetBody = nil
+	if !sensitive {
+		reqCopy.Header.Del(httputils.AuthorizationHeader)
+	}
+
+	if reqBody != nil && (sensitive || !isLogin) {
+		reqCopy.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+		reqCopy.ContentLength = int64(len(reqBody))
+	}
+
+	if response == nil {
+		c.Config.DebugTap(reqCopy, nil, nil)
+		return
+	}
+
+	var body, err = ioutil.ReadAll(response.Body)
+	response.Body = &bufferedBody{
+		Reader: io.MultiReader(bytes.NewReader(body), bodyErrReader{err: err}),
+		Closer: response.Body,
+	}
+
+	var respCopy = *response
+	respCopy.Header = response.Header.Clone()
+	respCopy.Body = http.NoBody
+	respCopy.Request = reqCopy
+
+	if isLogin && !sensitive {
+		body = nil
+	}
+
+	c.Config.DebugTap(reqCopy, &respCopy, body)
+}
+
+// bufferedBody is a response body which has been read into memory, but
+// which is closed by closing the original body.
+type bufferedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyErrReader is a reader which returns an error, or io.EOF if the error
+// is nil.
+type bodyErrReader struct {
+	err error
+}
+
+// Read returns the error.
+func (r bodyErrReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	return 0, io.EOF
+}
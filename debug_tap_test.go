@@ -0,0 +1,169 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+// tappedCall is a request and response received by a debug tap.
+type tappedCall struct {
+	path          string
+	authorization string
+	reqBody       string
+	status        int
+	body          string
+}
+
+func TestDebugTap(t *testing.T) {
+	t.Parallel()
+
+	const problem = `{"status":422,"description":"common name not allowed"}`
+
+	var testcases = []struct {
+		name      string
+		sensitive bool
+		want      []tappedCall
+	}{
+		{
+			name: "Redacted",
+			want: []tappedCall{
+				{path: endpointLogin, status: http.StatusOK},
+				{path: endpointCertificates, reqBody: `{"value":1}`, status: http.StatusUnprocessableEntity, body: problem},
+			},
+		},
+		{
+			name:      "IncludeSensitive",
+			sensitive: true,
+			want: []tappedCall{
+				{
+					path:    endpointLogin,
+					reqBody: `{"api_key":"key","api_secret":"secret"}`,
+					status:  http.StatusOK,
+					body:    `{"access_token":"token"}`,
+				},
+				{
+					path:          endpointCertificates,
+					authorization: "Bearer token",
+					reqBody:       `{"value":1}`,
+					status:        http.StatusUnprocessableEntity,
+					body:          problem,
+				},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == endpointLogin {
+					w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+					fmt.Fprint(w, `{"access_token":"token"}`)
+					return
+				}
+
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeProblemJSON)
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				fmt.Fprint(w, problem)
+			}))
+			defer server.Close()
+
+			var mu sync.Mutex
+			var got []tappedCall
+
+			var clnt = newTestClient(t, server.URL, nil)
+			clnt.tokenReset()
+			clnt.Config.APIKey = "key"
+			clnt.Config.APISecret = "secret"
+			clnt.Config.DebugTapIncludeSensitive = tc.sensitive
+			clnt.Config.DebugTap = func(req *http.Request, resp *http.Response, body []byte) {
+				var reqBody, err = ioutil.ReadAll(req.Body)
+				if err != nil {
+					t.Errorf("failed to read request body: %v", err)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				got = append(got, tappedCall{
+					path:          req.URL.Path,
+					authorization: req.Header.Get(httputils.AuthorizationHeader),
+					reqBody:       string(reqBody),
+					status:        resp.StatusCode,
+					body:          string(body),
+				})
+			}
+
+			// The response body should still be decoded normally.
+			var _, err = clnt.makeRequest(context.Background(), endpointCertificates, http.MethodPost,
+				map[string]int{"value": 1}, nil)
+
+			var apiErr APIError
+			if !errors.As(err, &apiErr) || apiErr.Description != "common name not allowed" {
+				t.Fatalf("got error %v, want API error with description", err)
+			}
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d tapped calls, want %d", len(got), len(tc.want))
+			}
+
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("got tapped call %+v, want %+v", got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDebugTapNoResponse(t *testing.T) {
+	t.Parallel()
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	var serverURL = server.URL
+	server.Close()
+
+	var calls int
+	var clnt = newTestClient(t, serverURL, &RetryPolicy{})
+	clnt.Config.DebugTap = func(req *http.Request, resp *http.Response, body []byte) {
+		calls++
+
+		if resp != nil || body != nil {
+			t.Errorf("got response %v and body %q, want nil", resp, body)
+		}
+	}
+
+	if _, err := clnt.makeRequest(context.Background(), "/test", http.MethodGet, nil, nil); err == nil {
+		t.Fatal("unexpectedly succeeded")
+	}
+
+	if calls != 1 {
+		t.Errorf("got %d tapped calls, want 1", calls)
+	}
+}
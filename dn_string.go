@@ -0,0 +1,335 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vsglobalsign/hvclient/internal/oids"
+)
+
+// dnAttributeNames maps the names used for subject attribute types in string
+// representations of distinguished names to their OIDs. The first name for
+// each OID is used by DN.String. Names are matched case-insensitively.
+var dnAttributeNames = []struct {
+	names []string
+	oid   asn1.ObjectIdentifier
+}{
+	{[]string{"CN", "commonName"}, oids.OIDSubjectCommonName},
+	{[]string{"SERIALNUMBER"}, oids.OIDSubjectSerialNumber},
+	{[]string{"C", "countryName"}, oids.OIDSubjectCountry},
+	{[]string{"L", "localityName"}, oids.OIDSubjectLocality},
+	{[]string{"ST", "stateOrProvinceName"}, oids.OIDSubjectState},
+	{[]string{"STREET", "streetAddress"}, oids.OIDSubjectStreetAddress},
+	{[]string{"POSTALCODE"}, oids.OIDSubjectPostalCode},
+	{[]string{"O", "organizationName"}, oids.OIDSubjectOrganization},
+	{[]string{"OU", "organizationalUnitName"}, oids.OIDSubjectOrganizationalUnit},
+	{[]string{"organizationIdentifier"}, oids.OIDSubjectOrganizationIdentifier},
+	{[]string{"givenName", "GN"}, oids.OIDSubjectGivenName},
+	{[]string{"SN", "surname"}, oids.OIDSubjectSurname},
+	{[]string{"emailAddress", "E"}, oids.OIDSubjectEmail},
+	{[]string{"jurisdictionL", "jurisdictionLocalityName"}, oids.OIDSubjectJOILocality},
+	{[]string{"jurisdictionST", "jurisdictionStateOrProvinceName"}, oids.OIDSubjectJOIState},
+	{[]string{"jurisdictionC", "jurisdictionCountryName"}, oids.OIDSubjectJOICountry},
+	{[]string{"businessCategory"}, oids.OIDSubjectBusinessCategory},
+	{[]string{"DC", "domainComponent"}, asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}},
+	{[]string{"UID", "userId"}, asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}},
+}
+
+// ParseDN parses a string representation of a distinguished name as defined
+// in RFC 4514, such as "CN=John Doe,O=ACME\, Inc.,C=US", including escaped
+// characters, hex-encoded values and multi-valued RDNs. Attribute types may
+// be given by name, such as CN or organizationIdentifier, or as a dotted
+// OID. Attribute types with no corresponding field in DN are returned as
+// extra attributes. An error is returned if the string is malformed, if an
+// attribute type name is not recognized, if a hex-encoded value is not a
+// string, or if an attribute whose field in DN holds a single value, which
+// is every attribute other than organizational unit, appears more than
+// once.
+func ParseDN(s string) (*DN, error) {
+	var rdns, err = parseRDNSequence(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid distinguished name %q: %w", s, err)
+	}
+
+	// The string representation lists RDNs in the reverse of their order
+	// in the encoded distinguished name.
+	var name pkix.Name
+	for i := len(rdns) - 1; i >= 0; i-- {
+		name.Names = append(name.Names, rdns[i]...)
+	}
+
+	var dn *DN
+	if dn, err = dnFromName(name); err != nil {
+		return nil, fmt.Errorf("invalid distinguished name %q: %w", s, err)
+	} else if dn == nil {
+		dn = &DN{}
+	}
+
+	return dn, nil
+}
+
+// String returns a string representation of the distinguished name as
+// defined in RFC 4514, which can be parsed by ParseDN. Attributes appear in
+// the reverse of the order in which they are encoded by PKIXName, with each
+// in its own RDN.
+func (n *DN) String() string {
+	if n == nil {
+		return ""
+	}
+
+	var rdns = n.PKIXName().ToRDNSequence()
+
+	var parts = make([]string, 0, len(rdns))
+	for i := len(rdns) - 1; i >= 0; i-- {
+		var atvs = make([]string, 0, len(rdns[i]))
+		for _, atv := range rdns[i] {
+			atvs = append(atvs, formatAttribute(atv))
+		}
+
+		parts = append(parts, strings.Join(atvs, "+"))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// formatAttribute returns the string representation of a subject attribute.
+func formatAttribute(atv pkix.AttributeTypeAndValue) string {
+	var value, _ = atv.Value.(string)
+
+	for _, attr := range dnAttributeNames {
+		if attr.oid.Equal(atv.Type) {
+			return attr.names[0] + "=" + escapeDNValue(value)
+		}
+	}
+
+	// Values of attribute types without a name must be hex-encoded.
+	var der, err = asn1.MarshalWithParams(value, "utf8")
+	if err != nil {
+		return atv.Type.String() + "=" + escapeDNValue(value)
+	}
+
+	return atv.Type.String() + "=#" + hex.EncodeToString(der)
+}
+
+// escapeDNValue escapes an attribute value as required by RFC 4514 2.4.
+func escapeDNValue(value string) string {
+	var b strings.Builder
+
+	for i, r := range value {
+		switch {
+		case strings.ContainsRune(`,+"\<>;`, r),
+			i == 0 && (r == ' ' || r == '#'),
+			i == len(value)-1 && r == ' ':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+
+		case r == 0:
+			b.WriteString(`\00`)
+
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// parseRDNSequence parses the RDNs in a string representation of a
+// distinguished name, in the order in which they appear in the string.
+func parseRDNSequence(s string) ([][]pkix.AttributeTypeAndValue, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var rdns [][]pkix.AttributeTypeAndValue
+	var rdn []pkix.AttributeTypeAndValue
+
+	for rest := s; ; {
+		var eq = strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("missing '=' in attribute %q", strings.TrimSpace(rest))
+		}
+
+		var typ = strings.TrimSpace(rest[:eq])
+		var oid, err = parseAttributeType(typ)
+		if err != nil {
+			return nil, err
+		}
+
+		var value string
+		var sep byte
+		if value, sep, rest, err = parseAttributeValue(rest[eq+1:]); err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", typ, err)
+		}
+
+		rdn = append(rdn, pkix.AttributeTypeAndValue{Type: oid, Value: value})
+
+		// A plus sign separates the attributes of a multi-valued RDN.
+		if sep == '+' {
+			continue
+		}
+
+		rdns = append(rdns, rdn)
+		rdn = nil
+
+		if sep == 0 {
+			return rdns, nil
+		}
+	}
+}
+
+// parseAttributeType returns the OID of an attribute type given by name or
+// as a dotted OID.
+func parseAttributeType(t string) (asn1.ObjectIdentifier, error) {
+	if t == "" {
+		return nil, errors.New("empty attribute type")
+	}
+
+	for _, attr := range dnAttributeNames {
+		for _, name := range attr.names {
+			if strings.EqualFold(name, t) {
+				return attr.oid, nil
+			}
+		}
+	}
+
+	if t[0] >= '0' && t[0] <= '9' {
+		var oid, err = oids.StringToOID(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid attribute type %q: %w", t, err)
+		}
+
+		return oid, nil
+	}
+
+	return nil, fmt.Errorf("unsupported attribute type %q", t)
+}
+
+// parseAttributeValue parses an attribute value at the start of s, and
+// returns the value, the unescaped separator which ended it, or zero if it
+// ended the string, and the remainder of the string after the separator.
+// Unescaped spaces surrounding the value are ignored.
+func parseAttributeValue(s string) (string, byte, string, error) {
+	var trimmed = strings.TrimLeft(s, " ")
+
+	// A value beginning with a number sign is the hex encoding of its BER
+	// encoding.
+	if strings.HasPrefix(trimmed, "#") {
+		var encoded, sep, rest = trimmed[1:], byte(0), ""
+		if end := strings.IndexAny(trimmed, ",+"); end >= 0 {
+			encoded, sep, rest = trimmed[1:end], trimmed[end], trimmed[end+1:]
+		}
+
+		var value, err = decodeHexValue(strings.TrimRight(encoded, " "))
+
+		return value, sep, rest, err
+	}
+
+	var value []byte
+	var escaped int // Length of the value up to the last escaped character.
+
+	for i := 0; i < len(trimmed); i++ {
+		var ch = trimmed[i]
+
+		switch ch {
+		case ',', '+':
+			var v, err = finishValue(value, escaped)
+
+			return v, ch, trimmed[i+1:], err
+
+		case '\\':
+			switch {
+			case i+1 < len(trimmed) && strings.IndexByte(` "#+,;<=>\`, trimmed[i+1]) >= 0:
+				value = append(value, trimmed[i+1])
+				i++
+
+			case i+2 < len(trimmed) && isHexDigit(rune(trimmed[i+1])) && isHexDigit(rune(trimmed[i+2])):
+				var b, _ = hex.DecodeString(trimmed[i+1 : i+3])
+				value = append(value, b...)
+				i += 2
+
+			default:
+				var end = i + 3
+				if end > len(trimmed) {
+					end = len(trimmed)
+				}
+
+				return "", 0, "", fmt.Errorf("invalid escape sequence %q", trimmed[i:end])
+			}
+
+			escaped = len(value)
+
+		case '"', ';', '<', '>':
+			return "", 0, "", fmt.Errorf("unescaped %q", ch)
+
+		default:
+			value = append(value, ch)
+		}
+	}
+
+	var v, err = finishValue(value, escaped)
+
+	return v, 0, "", err
+}
+
+// finishValue removes any unescaped trailing spaces from a parsed attribute
+// value, and checks that it is a non-empty UTF-8 string.
+func finishValue(value []byte, escaped int) (string, error) {
+	var end = len(value)
+	for end > escaped && value[end-1] == ' ' {
+		end--
+	}
+
+	switch {
+	case end == 0:
+		return "", errors.New("empty value")
+
+	case !utf8.Valid(value[:end]):
+		return "", errors.New("value is not valid UTF-8")
+	}
+
+	return string(value[:end]), nil
+}
+
+// decodeHexValue decodes a hex-encoded BER attribute value, which must be a
+// string.
+func decodeHexValue(encoded string) (string, error) {
+	var der, err = hex.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid hex-encoded value: %w", err)
+	}
+
+	var value string
+	var rest []byte
+	if rest, err = asn1.Unmarshal(der, &value); err != nil {
+		return "", fmt.Errorf("hex-encoded value is not a string: %w", err)
+	} else if len(rest) != 0 {
+		return "", errors.New("trailing data after hex-encoded value")
+	}
+
+	if value == "" {
+		return "", errors.New("empty value")
+	}
+
+	return value, nil
+}
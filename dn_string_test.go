@@ -0,0 +1,223 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient_test
+
+import (
+	"encoding/asn1"
+	"testing"
+
+	"github.com/vsglobalsign/hvclient"
+)
+
+func TestParseDN(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name string
+		dn   string
+		want *hvclient.DN
+		err  bool
+	}{
+		{
+			name: "Simple",
+			dn:   "CN=John Doe,O=ACME,C=US",
+			want: &hvclient.DN{CommonName: "John Doe", Organization: "ACME", Country: "US"},
+		},
+		{
+			name: "EscapedComma",
+			dn:   `CN=Doe\, John,O=ACME\, Inc.`,
+			want: &hvclient.DN{CommonName: "Doe, John", Organization: "ACME, Inc."},
+		},
+		{
+			name: "EscapedSpecials",
+			dn:   `CN=\#1 \<best\> \"widgets\"\; \+more\\\ `,
+			want: &hvclient.DN{CommonName: `#1 <best> "widgets"; +more\ `},
+		},
+		{
+			name: "HexEscapes",
+			dn:   `CN=Lu\C4\8Di\C4\87`,
+			want: &hvclient.DN{CommonName: "Lučić"},
+		},
+		{
+			name: "MultiValuedRDN",
+			dn:   "CN=John Doe+SERIALNUMBER=1234,OU=Sales+OU=Marketing,O=ACME",
+			want: &hvclient.DN{
+				CommonName:         "John Doe",
+				SerialNumber:       "1234",
+				OrganizationalUnit: []string{"Sales", "Marketing"},
+				Organization:       "ACME",
+			},
+		},
+		{
+			name: "SurroundingSpaces",
+			dn:   " CN = John Doe , O = ACME ",
+			want: &hvclient.DN{CommonName: "John Doe", Organization: "ACME"},
+		},
+		{
+			name: "NamedFields",
+			dn: "givenName=John,SN=Doe,emailAddress=john@example.com,organizationIdentifier=VATGB-123," +
+				"jurisdictionC=GB,businessCategory=Private Organization,STREET=1 Main St,POSTALCODE=12345," +
+				"L=London,ST=Greater London",
+			want: &hvclient.DN{
+				GivenName:              "John",
+				Surname:                "Doe",
+				Email:                  "john@example.com",
+				OrganizationIdentifier: "VATGB-123",
+				JOICountry:             "GB",
+				BusinessCategory:       "Private Organization",
+				StreetAddress:          "1 Main St",
+				PostalCode:             "12345",
+				Locality:               "London",
+				State:                  "Greater London",
+			},
+		},
+		{
+			name: "HexValueAndOID",
+			dn:   "1.2.3.4=#0c0548656c6c6f,cn=John Doe",
+			want: &hvclient.DN{
+				CommonName:      "John Doe",
+				ExtraAttributes: []hvclient.OIDAndString{{OID: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: "Hello"}},
+			},
+		},
+		{
+			name: "Empty",
+			dn:   "",
+			want: &hvclient.DN{},
+		},
+		{
+			name: "UnknownAttribute",
+			dn:   "CN=John Doe,XYZ=foo",
+			err:  true,
+		},
+		{
+			name: "RepeatedSingleValue",
+			dn:   "CN=John Doe,CN=Jane Doe",
+			err:  true,
+		},
+		{
+			name: "HexValueNotString",
+			dn:   "CN=#020101",
+			err:  true,
+		},
+		{
+			name: "MissingEquals",
+			dn:   "CN=John Doe,ACME",
+			err:  true,
+		},
+		{
+			name: "EmptyValue",
+			dn:   "CN=,O=ACME",
+			err:  true,
+		},
+		{
+			name: "UnescapedSpecial",
+			dn:   "CN=John;Doe",
+			err:  true,
+		},
+		{
+			name: "BadEscape",
+			dn:   `CN=John\qDoe`,
+			err:  true,
+		},
+		{
+			name: "TrailingBackslash",
+			dn:   `CN=John\`,
+			err:  true,
+		},
+		{
+			name: "TrailingComma",
+			dn:   "CN=John Doe,",
+			err:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got, err = hvclient.ParseDN(tc.dn)
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error %t", err, tc.err)
+			}
+
+			if !tc.err && !got.Equal(tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDNString(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name string
+		dn   *hvclient.DN
+		want string
+	}{
+		{
+			name: "Simple",
+			dn:   &hvclient.DN{CommonName: "John Doe", Organization: "ACME", Country: "US"},
+			want: "CN=John Doe,O=ACME,C=US",
+		},
+		{
+			name: "Escaping",
+			dn:   &hvclient.DN{CommonName: " #1, \"best\" + <more>; \\ ", Organization: "#ACME"},
+			want: `CN=\ #1\, \"best\" \+ \<more\>\; \\\ ,O=\#ACME`,
+		},
+		{
+			name: "NamedAndExtraAttributes",
+			dn: &hvclient.DN{
+				CommonName:         "John Doe",
+				GivenName:          "John",
+				OrganizationalUnit: []string{"Sales", "Marketing"},
+				ExtraAttributes:    []hvclient.OIDAndString{{OID: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: "Hello"}},
+			},
+			want: "1.2.3.4=#0c0548656c6c6f,givenName=John,CN=John Doe,OU=Sales+OU=Marketing",
+		},
+		{
+			name: "Nil",
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got = tc.dn.String()
+			if got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+
+			if tc.dn == nil {
+				return
+			}
+
+			var parsed, err = hvclient.ParseDN(got)
+			if err != nil {
+				t.Fatalf("failed to parse DN string: %v", err)
+			}
+
+			if !parsed.Equal(tc.dn) {
+				t.Errorf("round trip got %#v, want %#v", parsed, tc.dn)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+)
+
+// FingerprintSHA256 returns the SHA-256 digest of the DER encoding of the
+// certificate, which identifies it uniquely. Fingerprints may be compared
+// with the == operator. The zero value is returned if cert is nil.
+func FingerprintSHA256(cert *x509.Certificate) [sha256.Size]byte {
+	if cert == nil {
+		return [sha256.Size]byte{}
+	}
+
+	return sha256.Sum256(cert.Raw)
+}
+
+// FingerprintSHA1 returns the SHA-1 digest of the DER encoding of the
+// certificate. The zero value is returned if cert is nil.
+//
+// Deprecated: SHA-1 is not collision resistant, so a SHA-1 fingerprint does
+// not identify a certificate reliably. Use FingerprintSHA256 unless
+// interoperating with a system which supports only SHA-1 fingerprints.
+func FingerprintSHA1(cert *x509.Certificate) [sha1.Size]byte {
+	if cert == nil {
+		return [sha1.Size]byte{}
+	}
+
+	return sha1.Sum(cert.Raw)
+}
+
+// FingerprintSHA256 returns the SHA-256 fingerprint of the certificate, as
+// described for the FingerprintSHA256 function. The zero value is returned if
+// the certificate cannot be parsed.
+func (s CertInfo) FingerprintSHA256() [sha256.Size]byte {
+	return FingerprintSHA256(s.parsedCert())
+}
+
+// FingerprintSHA256Hex returns the SHA-256 fingerprint of the certificate as
+// a lower case hexadecimal string without separators, or the empty string if
+// the certificate cannot be parsed.
+func (s CertInfo) FingerprintSHA256Hex() string {
+	var cert = s.parsedCert()
+	if cert == nil {
+		return ""
+	}
+
+	var sum = FingerprintSHA256(cert)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// FingerprintSHA1 returns the SHA-1 fingerprint of the certificate, as
+// described for the FingerprintSHA1 function. The zero value is returned if
+// the certificate cannot be parsed.
+//
+// Deprecated: Use FingerprintSHA256 unless interoperating with a system which
+// supports only SHA-1 fingerprints.
+func (s CertInfo) FingerprintSHA1() [sha1.Size]byte {
+	return FingerprintSHA1(s.parsedCert())
+}
+
+// FingerprintSHA1Hex returns the SHA-1 fingerprint of the certificate as a
+// lower case hexadecimal string without separators, or the empty string if
+// the certificate cannot be parsed.
+//
+// Deprecated: Use FingerprintSHA256Hex unless interoperating with a system
+// which supports only SHA-1 fingerprints.
+func (s CertInfo) FingerprintSHA1Hex() string {
+	var cert = s.parsedCert()
+	if cert == nil {
+		return ""
+	}
+
+	var sum = FingerprintSHA1(cert)
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,123 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/vsglobalsign/hvclient"
+	"github.com/vsglobalsign/hvclient/internal/testhelpers"
+)
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	// Known fingerprints of testdata/test_cert.pem, as reported by
+	// openssl x509 -fingerprint.
+	const (
+		wantSHA256 = "5c6c3c107c73d15e837c6726e1b4098198698605345aff95133dac4e6a18cb86"
+		wantSHA1   = "5cdb1891bce66ea45141ae67c26b515d3d89f50f"
+	)
+
+	var certPEM = string(testhelpers.MustReadFile(t, "testdata/test_cert.pem"))
+	var cert = testhelpers.MustParseCert(t, certPEM)
+
+	var testcases = []struct {
+		name   string
+		info   hvclient.CertInfo
+		sha256 string
+		sha1   string
+	}{
+		{
+			name:   "Parsed",
+			info:   hvclient.CertInfo{X509: cert},
+			sha256: wantSHA256,
+			sha1:   wantSHA1,
+		},
+		{
+			name:   "PEMOnly",
+			info:   hvclient.CertInfo{PEM: certPEM},
+			sha256: wantSHA256,
+			sha1:   wantSHA1,
+		},
+		{
+			name: "Unparseable",
+			info: hvclient.CertInfo{PEM: "BAD PEM"},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.info.FingerprintSHA256Hex(); got != tc.sha256 {
+				t.Errorf("got SHA-256 fingerprint %s, want %s", got, tc.sha256)
+			}
+
+			if got := tc.info.FingerprintSHA1Hex(); got != tc.sha1 {
+				t.Errorf("got SHA-1 fingerprint %s, want %s", got, tc.sha1)
+			}
+
+			var sum256 = tc.info.FingerprintSHA256()
+			if tc.sha256 == "" {
+				if sum256 != [32]byte{} {
+					t.Errorf("got SHA-256 fingerprint %x, want zero value", sum256)
+				}
+			} else if got := hex.EncodeToString(sum256[:]); got != tc.sha256 {
+				t.Errorf("got SHA-256 fingerprint %s, want %s", got, tc.sha256)
+			}
+
+			var sum1 = tc.info.FingerprintSHA1()
+			if tc.sha1 == "" {
+				if sum1 != [20]byte{} {
+					t.Errorf("got SHA-1 fingerprint %x, want zero value", sum1)
+				}
+			} else if got := hex.EncodeToString(sum1[:]); got != tc.sha1 {
+				t.Errorf("got SHA-1 fingerprint %s, want %s", got, tc.sha1)
+			}
+		})
+	}
+}
+
+func TestFingerprintFunctions(t *testing.T) {
+	t.Parallel()
+
+	var cert = testhelpers.MustParseCert(t, string(testhelpers.MustReadFile(t, "testdata/test_cert.pem")))
+	var other = testhelpers.MustParseCert(t, string(testhelpers.MustReadFile(t, "testdata/test_ica_cert.pem")))
+
+	if hvclient.FingerprintSHA256(cert) != (hvclient.CertInfo{X509: cert}).FingerprintSHA256() {
+		t.Errorf("function and method SHA-256 fingerprints differ")
+	}
+
+	if hvclient.FingerprintSHA256(cert) == hvclient.FingerprintSHA256(other) {
+		t.Errorf("different certificates have the same SHA-256 fingerprint")
+	}
+
+	if hvclient.FingerprintSHA1(cert) == hvclient.FingerprintSHA1(other) {
+		t.Errorf("different certificates have the same SHA-1 fingerprint")
+	}
+
+	if got := hvclient.FingerprintSHA256(nil); got != [32]byte{} {
+		t.Errorf("got SHA-256 fingerprint %x for nil certificate, want zero value", got)
+	}
+
+	if got := hvclient.FingerprintSHA1(nil); got != [20]byte{} {
+		t.Errorf("got SHA-1 fingerprint %x for nil certificate, want zero value", got)
+	}
+}
@@ -1,11 +1,12 @@
 module github.com/vsglobalsign/hvclient
 
-go 1.18
+go 1.19
 
 require (
 	github.com/go-chi/chi v4.1.2+incompatible
 	github.com/google/go-cmp v0.5.8
 	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d
+	golang.org/x/sync v0.1.0
 )
 
 require (
@@ -0,0 +1,42 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package hvclienttest provides a fake HVCA server for use in tests of code
+which uses the hvclient package.
+
+The fake server keeps its state in memory, and implements logging in,
+requesting, retrieving and revoking certificates, the certificates issued
+and revoked counters, and retrieving the trust chain. Certificates are
+issued by a CA created when the server is started. Errors and latency may
+be injected into the responses to any operation, so that retry, timeout and
+error handling code can be tested deterministically.
+
+A typical test looks like:
+
+	var server = hvclienttest.NewServer()
+	defer server.Close()
+
+	var clnt, err = server.NewClient(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	server.InjectFault(hvclienttest.CertificateRequest, hvclienttest.Fault{
+		StatusCode: http.StatusServiceUnavailable,
+		Times:      2,
+	})
+*/
+package hvclienttest
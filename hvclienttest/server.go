@@ -0,0 +1,649 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclienttest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vsglobalsign/hvclient"
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+	"github.com/vsglobalsign/hvclient/internal/pki"
+)
+
+// Operation is an HVCA API operation implemented by the fake server. The
+// names are the same as those of the corresponding hvclient.Client methods.
+type Operation string
+
+// Operations implemented by the fake server.
+const (
+	Login               Operation = "Login"
+	CertificateRequest  Operation = "CertificateRequest"
+	CertificateRetrieve Operation = "CertificateRetrieve"
+	CertificateRevoke   Operation = "CertificateRevoke"
+	CounterCertsIssued  Operation = "CounterCertsIssued"
+	CounterCertsRevoked Operation = "CounterCertsRevoked"
+	TrustChain          Operation = "TrustChain"
+)
+
+// Default credentials accepted by the fake server.
+const (
+	DefaultAPIKey    = "hvclienttest_api_key"
+	DefaultAPISecret = "hvclienttest_api_secret"
+)
+
+// defaultValidity is the validity period of an issued certificate if the
+// request asks for the maximum duration allowed by the validation policy.
+const defaultValidity = time.Hour * 24 * 90
+
+// Fault is an error or a delay to inject into the fake server's responses to
+// an operation.
+type Fault struct {
+	// Delay is how long to wait before responding. The wait ends early, and
+	// no response is written, if the client cancels the request.
+	Delay time.Duration
+
+	// StatusCode is the HTTP status code of an error response to return
+	// instead of performing the operation. If zero, the operation is
+	// performed normally after any delay.
+	StatusCode int
+
+	// Description is the description in the error response. If empty, the
+	// standard text for the status code is used.
+	Description string
+
+	// CloseConnection closes the connection without writing a response, to
+	// simulate a network failure. It takes precedence over StatusCode.
+	CloseConnection bool
+
+	// Times is the number of requests to which the fault applies, after
+	// which it is removed. If zero, it applies to every request until it is
+	// removed with ClearFaults.
+	Times int
+}
+
+// Server is a fake HVCA server. It should be created with NewServer, and is
+// safe for concurrent use.
+type Server struct {
+	// URL is the base URL of the fake server.
+	URL string
+
+	// APIKey and APISecret are the credentials which the fake server
+	// accepts. They may be changed before any clients login.
+	APIKey    string
+	APISecret string
+
+	server *httptest.Server
+	caKey  *ecdsa.PrivateKey
+	caCert *x509.Certificate
+
+	mtx      sync.Mutex
+	tokens   map[string]bool
+	certs    map[string]*certificate
+	issued   int64
+	revoked  int64
+	faults   map[Operation]*Fault
+	requests map[Operation]int
+}
+
+// certificate is a certificate issued by the fake server.
+type certificate struct {
+	cert      *x509.Certificate
+	status    string
+	updatedAt time.Time
+	reason    hvclient.RevocationReason
+	revokedAt time.Time
+}
+
+// certInfo is the JSON encoding of a retrieved certificate.
+type certInfo struct {
+	PEM              string `json:"certificate"`
+	Status           string `json:"status"`
+	UpdatedAt        int64  `json:"updated_at"`
+	RevocationReason string `json:"revocation_reason,omitempty"`
+	RevocationTime   int64  `json:"revocation_time,omitempty"`
+}
+
+// NewServer starts and returns a new fake HVCA server, which accepts the
+// default credentials. The caller should call Close when finished, to shut
+// it down. Like httptest.NewServer, it panics on failure.
+func NewServer() *Server {
+	var s = &Server{
+		APIKey:    DefaultAPIKey,
+		APISecret: DefaultAPISecret,
+		tokens:    make(map[string]bool),
+		certs:     make(map[string]*certificate),
+		faults:    make(map[Operation]*Fault),
+		requests:  make(map[Operation]int),
+	}
+
+	var err error
+	if s.caKey, s.caCert, err = newCA(); err != nil {
+		panic(fmt.Sprintf("hvclienttest: failed to create CA: %v", err))
+	}
+
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+
+	return s
+}
+
+// Close shuts down the server and blocks until all outstanding requests on
+// it have completed.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// NewClient returns a new HVCA client which is logged into the fake server.
+// The configuration may be nil. Otherwise it is copied, and its URL and
+// credentials are replaced with those of the fake server, so that retry
+// policies, timeouts and other options may be set.
+func (s *Server) NewClient(ctx context.Context, conf *hvclient.Config) (*hvclient.Client, error) {
+	var c hvclient.Config
+	if conf != nil {
+		c = *conf
+	}
+
+	c.URL = s.URL
+	c.APIKey = s.APIKey
+	c.APISecret = s.APISecret
+	c.TLSCert = nil
+	c.TLSKey = nil
+
+	return hvclient.NewClient(ctx, &c)
+}
+
+// CACertificate returns the certificate of the CA which issues the fake
+// server's certificates.
+func (s *Server) CACertificate() *x509.Certificate {
+	return s.caCert
+}
+
+// InjectFault injects a fault into the server's responses to an operation,
+// replacing any fault previously injected for that operation.
+func (s *Server) InjectFault(op Operation, fault Fault) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.faults[op] = &fault
+}
+
+// ClearFaults removes all injected faults.
+func (s *Server) ClearFaults() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.faults = make(map[Operation]*Fault)
+}
+
+// ExpireTokens invalidates all authentication tokens issued by the server,
+// so that clients must login again.
+func (s *Server) ExpireTokens() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.tokens = make(map[string]bool)
+}
+
+// Requests returns the number of requests the server has received for an
+// operation, including those to which a fault was injected.
+func (s *Server) Requests(op Operation) int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.requests[op]
+}
+
+// serveHTTP routes a request to the handler for its operation, after
+// applying any injected fault and checking the authentication token.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var op, serial = route(r)
+	if op == "" {
+		writeError(w, http.StatusNotFound, "")
+		return
+	}
+
+	var fault = s.takeFault(op)
+	if fault != nil {
+		if fault.Delay > 0 {
+			var timer = time.NewTimer(fault.Delay)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		switch {
+		case fault.CloseConnection:
+			closeConnection(w)
+			return
+
+		case fault.StatusCode != 0:
+			writeError(w, fault.StatusCode, fault.Description)
+			return
+		}
+	}
+
+	if op != Login && !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "")
+		return
+	}
+
+	switch op {
+	case Login:
+		s.login(w, r)
+
+	case CertificateRequest:
+		s.certificateRequest(w, r)
+
+	case CertificateRetrieve:
+		s.certificateRetrieve(w, serial)
+
+	case CertificateRevoke:
+		s.certificateRevoke(w, r, serial)
+
+	case CounterCertsIssued, CounterCertsRevoked:
+		s.counter(w, op)
+
+	case TrustChain:
+		writeResponse(w, http.StatusOK, []string{pki.CertToPEMString(s.caCert)})
+	}
+}
+
+// route returns the operation for a request, and the certificate serial
+// number for operations on a single certificate. It returns an empty
+// operation if the request is not for an implemented operation.
+func route(r *http.Request) (Operation, string) {
+	var path = strings.TrimSuffix(r.URL.Path, "/")
+
+	switch {
+	case r.Method == http.MethodPost && path == "/login":
+		return Login, ""
+
+	case r.Method == http.MethodPost && path == "/certificates":
+		return CertificateRequest, ""
+
+	case r.Method == http.MethodGet && path == "/counters/certificates/issued":
+		return CounterCertsIssued, ""
+
+	case r.Method == http.MethodGet && path == "/counters/certificates/revoked":
+		return CounterCertsRevoked, ""
+
+	case r.Method == http.MethodGet && path == "/trustchain":
+		return TrustChain, ""
+
+	case strings.HasPrefix(path, "/certificates/"):
+		var serial = strings.ToUpper(strings.TrimPrefix(path, "/certificates/"))
+
+		switch r.Method {
+		case http.MethodGet:
+			return CertificateRetrieve, serial
+
+		case http.MethodPatch:
+			return CertificateRevoke, serial
+		}
+	}
+
+	return "", ""
+}
+
+// takeFault records a request for an operation, and returns the fault
+// injected for it, if any, removing the fault if it has been applied the
+// requested number of times.
+func (s *Server) takeFault(op Operation) *Fault {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.requests[op]++
+
+	var fault, ok = s.faults[op]
+	if !ok {
+		return nil
+	}
+
+	if fault.Times > 0 {
+		fault.Times--
+		if fault.Times == 0 {
+			delete(s.faults, op)
+		}
+	}
+
+	var result = *fault
+
+	return &result
+}
+
+// authorized returns true if a request bears a token issued by the server.
+func (s *Server) authorized(r *http.Request) bool {
+	var token = strings.TrimPrefix(r.Header.Get(httputils.AuthorizationHeader), "Bearer ")
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.tokens[token]
+}
+
+// login implements the login operation.
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		APIKey    string `json:"api_key"`
+		APISecret string `json:"api_secret"`
+	}
+	if !readBody(w, r, &body) {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if body.APIKey != s.APIKey || body.APISecret != s.APISecret {
+		writeError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+
+	var token = randomHex(16)
+	s.tokens[token] = true
+
+	writeResponse(w, http.StatusOK, map[string]string{"access_token": token})
+}
+
+// certificateRequest implements the certificate request operation.
+func (s *Server) certificateRequest(w http.ResponseWriter, r *http.Request) {
+	var req hvclient.Request
+	if !readBody(w, r, &req) {
+		return
+	}
+
+	var cert, err = s.issue(&req)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	var serial = fmt.Sprintf("%X", cert.SerialNumber)
+
+	s.mtx.Lock()
+	s.certs[serial] = &certificate{cert: cert, status: "ISSUED", updatedAt: time.Now()}
+	s.issued++
+	s.mtx.Unlock()
+
+	w.Header().Set("Location", s.URL+"/certificates/"+serial)
+	w.Header().Set("X-Request-ID", randomHex(16))
+	writeResponse(w, http.StatusCreated, nil)
+}
+
+// certificateRetrieve implements the certificate retrieve operation.
+func (s *Server) certificateRetrieve(w http.ResponseWriter, serial string) {
+	s.mtx.Lock()
+	var cert, ok = s.certs[serial]
+	var info certInfo
+	if ok {
+		info = certInfo{
+			PEM:       pki.CertToPEMString(cert.cert),
+			Status:    cert.status,
+			UpdatedAt: cert.updatedAt.Unix(),
+		}
+
+		if cert.status == "REVOKED" {
+			info.RevocationReason = cert.reason.String()
+			info.RevocationTime = cert.revokedAt.Unix()
+		}
+	}
+	s.mtx.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "certificate not found")
+		return
+	}
+
+	writeResponse(w, http.StatusOK, info)
+}
+
+// certificateRevoke implements the certificate revoke operation.
+func (s *Server) certificateRevoke(w http.ResponseWriter, r *http.Request, serial string) {
+	var body struct {
+		RevocationReason hvclient.RevocationReason `json:"revocation_reason"`
+		RevocationTime   int64                     `json:"revocation_time"`
+	}
+	if !readBody(w, r, &body) {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var cert, ok = s.certs[serial]
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, "certificate not found")
+		return
+
+	case cert.status == "REVOKED":
+		writeError(w, http.StatusUnprocessableEntity, "certificate is already revoked")
+		return
+	}
+
+	cert.status = "REVOKED"
+	cert.updatedAt = time.Now()
+	cert.reason = body.RevocationReason
+	cert.revokedAt = cert.updatedAt
+	if body.RevocationTime != 0 {
+		cert.revokedAt = time.Unix(body.RevocationTime, 0)
+	}
+	s.revoked++
+
+	writeResponse(w, http.StatusNoContent, nil)
+}
+
+// counter implements the certificates issued and revoked counter operations.
+func (s *Server) counter(w http.ResponseWriter, op Operation) {
+	s.mtx.Lock()
+	var value = s.issued
+	if op == CounterCertsRevoked {
+		value = s.revoked
+	}
+	s.mtx.Unlock()
+
+	writeResponse(w, http.StatusOK, map[string]int64{"value": value})
+}
+
+// issue issues a certificate for a request.
+func (s *Server) issue(req *hvclient.Request) (*x509.Certificate, error) {
+	var pub interface{}
+	switch {
+	case req.PublicKey != nil:
+		pub = req.PublicKey
+
+	case req.CSR != nil:
+		pub = req.CSR.PublicKey
+
+	default:
+		return nil, errors.New("public key is required")
+	}
+
+	var serial, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return nil, err
+	}
+
+	var template = &x509.Certificate{
+		SerialNumber:       serial.Add(serial, big.NewInt(1)),
+		NotBefore:          time.Now().Truncate(time.Second),
+		KeyUsage:           req.KeyUsages,
+		UnknownExtKeyUsage: req.EKUs,
+	}
+
+	if template.KeyUsage == 0 {
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+	}
+
+	if req.Validity != nil && !req.Validity.NotBefore.IsZero() {
+		template.NotBefore = req.Validity.NotBefore
+	}
+
+	template.NotAfter = template.NotBefore.Add(defaultValidity)
+	if req.Validity != nil && !req.Validity.NotAfter.Equal(time.Unix(0, 0)) {
+		template.NotAfter = req.Validity.NotAfter
+	}
+
+	if req.Subject != nil {
+		template.Subject = req.Subject.PKIXName()
+	}
+
+	if req.SAN != nil {
+		template.DNSNames = req.SAN.DNSNames
+		template.EmailAddresses = req.SAN.Emails
+		template.IPAddresses = req.SAN.IPAddresses
+		template.URIs = req.SAN.URIs
+	}
+
+	var der []byte
+	if der, err = x509.CreateCertificate(rand.Reader, template, s.caCert, pub, s.caKey); err != nil {
+		return nil, fmt.Errorf("couldn't issue certificate: %v", err)
+	}
+
+	return x509.ParseCertificate(der)
+}
+
+// newCA creates a self-signed CA certificate and its private key.
+func newCA() (*ecdsa.PrivateKey, *x509.Certificate, error) {
+	var key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var now = time.Now().Truncate(time.Second)
+	var template = &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "hvclienttest Fake CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour * 24 * 365 * 10),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	var der []byte
+	if der, err = x509.CreateCertificate(rand.Reader, template, template, key.Public(), key); err != nil {
+		return nil, nil, err
+	}
+
+	var cert *x509.Certificate
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		return nil, nil, err
+	}
+
+	return key, cert, nil
+}
+
+// readBody unmarshals a JSON request body, and writes an error response and
+// returns false on failure.
+func readBody(w http.ResponseWriter, r *http.Request, out interface{}) bool {
+	if err := httputils.VerifyRequestContentType(r, httputils.ContentTypeJSON); err != nil {
+		writeError(w, http.StatusUnsupportedMediaType, "")
+		return false
+	}
+
+	var data, err = ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "")
+		return false
+	}
+
+	if err = json.Unmarshal(data, out); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+
+	return true
+}
+
+// writeError writes an HVCA error response. If the description is empty,
+// the standard text for the status code is used.
+func writeError(w http.ResponseWriter, status int, description string) {
+	if description == "" {
+		description = http.StatusText(status)
+	}
+
+	var data, _ = json.Marshal(map[string]string{"description": description})
+
+	w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeProblemJSON)
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// writeResponse writes an HTTP response. If obj is not nil, it is marshalled
+// to JSON and used as the response body.
+func writeResponse(w http.ResponseWriter, status int, obj interface{}) {
+	if obj == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	var data, err = json.Marshal(obj)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// closeConnection closes the connection on which a request was received
+// without writing a response.
+func closeConnection(w http.ResponseWriter) {
+	var hj, ok = w.(http.Hijacker)
+	if !ok {
+		panic("hvclienttest: response writer does not support hijacking")
+	}
+
+	var conn, _, err = hj.Hijack()
+	if err != nil {
+		panic(fmt.Sprintf("hvclienttest: failed to hijack connection: %v", err))
+	}
+
+	_ = conn.Close()
+}
+
+// randomHex returns a random hexadecimal string encoding the specified
+// number of bytes.
+func randomHex(n int) string {
+	var b = make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("hvclienttest: failed to generate random bytes: %v", err))
+	}
+
+	return hex.EncodeToString(b)
+}
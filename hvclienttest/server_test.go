@@ -0,0 +1,257 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclienttest_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient"
+	"github.com/vsglobalsign/hvclient/hvclienttest"
+)
+
+// newRequest returns a certificate request with a newly generated key.
+func newRequest(t *testing.T) *hvclient.Request {
+	t.Helper()
+
+	var key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	return &hvclient.Request{
+		Validity: &hvclient.Validity{
+			NotBefore: time.Now(),
+			NotAfter:  time.Now().Add(time.Hour),
+		},
+		Subject:           &hvclient.DN{CommonName: "John Doe"},
+		SAN:               &hvclient.SAN{DNSNames: []string{"example.com"}},
+		ExtendedKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		PublicKey:         key.Public(),
+	}
+}
+
+func TestServerLifecycle(t *testing.T) {
+	t.Parallel()
+
+	var server = hvclienttest.NewServer()
+	defer server.Close()
+
+	var ctx = context.Background()
+
+	var clnt, err = server.NewClient(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	var serial *big.Int
+	if serial, err = clnt.CertificateRequest(ctx, newRequest(t)); err != nil {
+		t.Fatalf("failed to request certificate: %v", err)
+	}
+
+	var info *hvclient.CertInfo
+	if info, err = clnt.CertificateRetrieve(ctx, serial); err != nil {
+		t.Fatalf("failed to retrieve certificate: %v", err)
+	}
+
+	if info.Status != hvclient.StatusIssued {
+		t.Errorf("got status %v, want %v", info.Status, hvclient.StatusIssued)
+	}
+
+	var cert = info.X509
+	if cert.SerialNumber.Cmp(serial) != 0 {
+		t.Errorf("got serial number %X, want %X", cert.SerialNumber, serial)
+	}
+
+	if cert.Subject.CommonName != "John Doe" || len(cert.DNSNames) != 1 || cert.DNSNames[0] != "example.com" {
+		t.Errorf("got subject %v and DNS names %v", cert.Subject, cert.DNSNames)
+	}
+
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageServerAuth {
+		t.Errorf("got extended key usages %v, want server authentication", cert.ExtKeyUsage)
+	}
+
+	if err = cert.CheckSignatureFrom(server.CACertificate()); err != nil {
+		t.Errorf("certificate not signed by CA: %v", err)
+	}
+
+	var chain []*x509.Certificate
+	if chain, err = clnt.TrustChain(ctx); err != nil {
+		t.Fatalf("failed to retrieve trust chain: %v", err)
+	}
+
+	if len(chain) != 1 || !chain[0].Equal(server.CACertificate()) {
+		t.Errorf("got trust chain %v, want CA certificate", chain)
+	}
+
+	var revokedAt = time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err = clnt.CertificateRevokeWithReason(ctx, serial, hvclient.ReasonKeyCompromise, revokedAt.Unix()); err != nil {
+		t.Fatalf("failed to revoke certificate: %v", err)
+	}
+
+	if info, err = clnt.CertificateRetrieve(ctx, serial); err != nil {
+		t.Fatalf("failed to retrieve certificate: %v", err)
+	}
+
+	if info.Status != hvclient.StatusRevoked {
+		t.Errorf("got status %v, want %v", info.Status, hvclient.StatusRevoked)
+	}
+
+	if !info.Revoked || !info.RevokedAt.Equal(revokedAt) || info.RevocationReason != hvclient.ReasonKeyCompromise {
+		t.Errorf("got revocation %t at %v for %v, want %v for %v",
+			info.Revoked, info.RevokedAt, info.RevocationReason, revokedAt, hvclient.ReasonKeyCompromise)
+	}
+
+	if err = clnt.CertificateRevoke(ctx, serial); err == nil {
+		t.Errorf("unexpectedly revoked certificate twice")
+	}
+
+	var counters *hvclient.Counters
+	if counters, err = clnt.Counters(ctx); err != nil {
+		t.Fatalf("failed to get counters: %v", err)
+	}
+
+	if counters.Issued != 1 || counters.Revoked != 1 {
+		t.Errorf("got counters %+v, want 1 issued and 1 revoked", *counters)
+	}
+
+	if _, err = clnt.CertificateRetrieve(ctx, big.NewInt(1)); !errors.Is(err, hvclient.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, hvclient.ErrNotFound)
+	}
+}
+
+func TestServerFaults(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name    string
+		fault   hvclienttest.Fault
+		policy  *hvclient.RetryPolicy
+		timeout time.Duration
+		wantErr bool
+		want    error
+		calls   int
+	}{
+		{
+			name:   "RetriedStatus",
+			fault:  hvclienttest.Fault{StatusCode: http.StatusServiceUnavailable, Times: 2},
+			policy: &hvclient.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+			calls:  3,
+		},
+		{
+			name:    "Status",
+			fault:   hvclienttest.Fault{StatusCode: http.StatusTooManyRequests, Description: "quota exceeded"},
+			policy:  &hvclient.RetryPolicy{},
+			wantErr: true,
+			want:    hvclient.ErrQuotaExceeded,
+			calls:   1,
+		},
+		{
+			name:    "Latency",
+			fault:   hvclienttest.Fault{Delay: time.Minute},
+			policy:  &hvclient.RetryPolicy{},
+			timeout: time.Millisecond * 50,
+			wantErr: true,
+			want:    context.DeadlineExceeded,
+			calls:   1,
+		},
+		{
+			// The number of requests is not checked, since the HTTP
+			// transport may itself retry on a new connection.
+			name:    "Network",
+			fault:   hvclienttest.Fault{CloseConnection: true},
+			policy:  &hvclient.RetryPolicy{},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var server = hvclienttest.NewServer()
+			defer server.Close()
+
+			var clnt, err = server.NewClient(context.Background(), &hvclient.Config{RetryPolicy: tc.policy})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			server.InjectFault(hvclienttest.CounterCertsIssued, tc.fault)
+
+			var ctx = context.Background()
+			if tc.timeout != 0 {
+				var cancel func()
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			_, err = clnt.CounterCertsIssued(ctx)
+			switch {
+			case (err != nil) != tc.wantErr:
+				t.Errorf("got error %v, want error %t", err, tc.wantErr)
+
+			case tc.want != nil && !errors.Is(err, tc.want):
+				t.Errorf("got error %v, want %v", err, tc.want)
+			}
+
+			if got := server.Requests(hvclienttest.CounterCertsIssued); tc.calls != 0 && got != tc.calls {
+				t.Errorf("got %d requests, want %d", got, tc.calls)
+			}
+		})
+	}
+}
+
+func TestServerExpireTokens(t *testing.T) {
+	t.Parallel()
+
+	var server = hvclienttest.NewServer()
+	defer server.Close()
+
+	var ctx = context.Background()
+
+	var clnt, err = server.NewClient(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	server.ExpireTokens()
+
+	if _, err = clnt.CounterCertsRevoked(ctx); err != nil {
+		t.Fatalf("failed to get counter after token expiry: %v", err)
+	}
+
+	if got := server.Requests(hvclienttest.Login); got != 2 {
+		t.Errorf("got %d logins, want 2", got)
+	}
+
+	server.APISecret = "changed"
+	server.ExpireTokens()
+
+	if _, err = clnt.CounterCertsRevoked(ctx); !errors.Is(err, hvclient.ErrAuthFailed) {
+		t.Errorf("got error %v, want %v", err, hvclient.ErrAuthFailed)
+	}
+}
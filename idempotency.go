@@ -0,0 +1,89 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// idempotencyKeyHeaderName is the name of the HTTP header in which an
+// idempotency key is sent with a certificate request.
+const idempotencyKeyHeaderName = "Idempotency-Key"
+
+// maxIdempotencyKeyLength is the maximum length of an idempotency key.
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyStore records the results of certificate requests made with an
+// idempotency key, allowing duplicate requests to be detected by the client
+// rather than by HVCA. Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Get returns the result recorded for the idempotency key, or nil if
+	// there is none.
+	Get(ctx context.Context, key string) (*CertificateRequestResult, error)
+
+	// Put records the result of a successful certificate request made with
+	// the idempotency key.
+	Put(ctx context.Context, key string, result *CertificateRequestResult) error
+}
+
+// idempotencyKeyKey is the context key for an idempotency key.
+type idempotencyKeyKey struct{}
+
+// WithIdempotencyKey returns a copy of the context which causes certificate
+// requests made with it to carry the specified idempotency key, identifying
+// repeated attempts to make the same request, for example after a timeout.
+// The key must consist of between 1 and 255 printable ASCII characters,
+// excluding spaces, and is validated when the request is made.
+//
+// At the time of writing HVCA does not document support for idempotency
+// keys. The key is sent in an Idempotency-Key HTTP header, which HVCA
+// ignores but which an intermediary such as an API gateway may honour. To
+// detect duplicate requests in the client instead, provide an
+// IdempotencyStore in the configuration.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// idempotencyKeyFromContext returns the idempotency key from the context,
+// if one was added with WithIdempotencyKey.
+func idempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	var key, ok = ctx.Value(idempotencyKeyKey{}).(string)
+
+	return key, ok
+}
+
+// validateIdempotencyKey returns an error if an idempotency key is empty,
+// too long, or contains characters other than printable ASCII characters
+// excluding spaces.
+func validateIdempotencyKey(key string) error {
+	if key == "" {
+		return errors.New("invalid idempotency key: empty")
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return fmt.Errorf("invalid idempotency key: longer than %d characters", maxIdempotencyKeyLength)
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] > '~' {
+			return fmt.Errorf("invalid idempotency key: invalid character at position %d", i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// memoryIdempotencyStore is an in-memory IdempotencyStore.
+type memoryIdempotencyStore struct {
+	sync.Mutex
+	results map[string]*CertificateRequestResult
+}
+
+func (s *memoryIdempotencyStore) Get(ctx context.Context, key string) (*CertificateRequestResult, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.results[key], nil
+}
+
+func (s *memoryIdempotencyStore) Put(ctx context.Context, key string, result *CertificateRequestResult) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.results == nil {
+		s.results = make(map[string]*CertificateRequestResult)
+	}
+
+	s.results[key] = result
+
+	return nil
+}
+
+func TestValidateIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name  string
+		key   string
+		valid bool
+	}{
+		{"OK", "job-1234:attempt_1", true},
+		{"MaxLength", strings.Repeat("a", maxIdempotencyKeyLength), true},
+		{"Empty", "", false},
+		{"TooLong", strings.Repeat("a", maxIdempotencyKeyLength+1), false},
+		{"Space", "job 1234", false},
+		{"ControlCharacter", "job\n1234", false},
+		{"NonASCII", "jöb-1234", false},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := validateIdempotencyKey(tc.key); (err == nil) != tc.valid {
+				t.Errorf("got error %v, want valid %t", err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestCertificateRequestIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		key      string
+		store    bool
+		wantKey  string
+		wantPOST int32
+		err      bool
+	}{
+		{
+			name:     "NoKey",
+			wantPOST: 2,
+		},
+		{
+			name:     "KeyWithoutStore",
+			key:      "job-1234",
+			wantKey:  "job-1234",
+			wantPOST: 2,
+		},
+		{
+			name:     "KeyWithStore",
+			key:      "job-1234",
+			store:    true,
+			wantKey:  "job-1234",
+			wantPOST: 1,
+		},
+		{
+			name: "InvalidKey",
+			key:  "job 1234",
+			err:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var posts int32
+			var gotKey atomic.Value
+			gotKey.Store("")
+
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&posts, 1)
+				gotKey.Store(r.Header.Get(idempotencyKeyHeaderName))
+
+				w.Header().Set(certSNHeaderName, "/v2/certificates/741DAF9EC2D5F7DC")
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, nil)
+			if tc.store {
+				clnt.Config.IdempotencyStore = &memoryIdempotencyStore{}
+			}
+
+			var ctx = context.Background()
+			if tc.key != "" {
+				ctx = WithIdempotencyKey(ctx, tc.key)
+			}
+
+			// Make the same request twice.
+			for i := 0; i < 2; i++ {
+				var result, err = clnt.CertificateRequestWithResult(ctx, &Request{})
+				if tc.err {
+					if err == nil {
+						t.Fatal("unexpectedly succeeded")
+					}
+
+					continue
+				}
+
+				if err != nil {
+					t.Fatalf("failed to request certificate: %v", err)
+				}
+
+				if result.Serial == nil || result.Serial.Int64() != 0x741daf9ec2d5f7dc {
+					t.Errorf("got serial number %v, want 741DAF9EC2D5F7DC", result.Serial)
+				}
+			}
+
+			if got := atomic.LoadInt32(&posts); got != tc.wantPOST {
+				t.Errorf("got %d requests, want %d", got, tc.wantPOST)
+			}
+
+			if got := gotKey.Load().(string); got != tc.wantKey {
+				t.Errorf("got idempotency key %q, want %q", got, tc.wantKey)
+			}
+		})
+	}
+}
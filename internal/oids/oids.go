@@ -25,6 +25,19 @@ import (
 var (
 	OIDKeyUsage                      = asn1.ObjectIdentifier{2, 5, 29, 15}
 	OIDExtendedKeyUsage              = asn1.ObjectIdentifier{2, 5, 29, 37}
+	OIDSubjectAltName                = asn1.ObjectIdentifier{2, 5, 29, 17}
+	OIDSubjectCommonName             = asn1.ObjectIdentifier{2, 5, 4, 3}
+	OIDSubjectSerialNumber           = asn1.ObjectIdentifier{2, 5, 4, 5}
+	OIDSubjectCountry                = asn1.ObjectIdentifier{2, 5, 4, 6}
+	OIDSubjectLocality               = asn1.ObjectIdentifier{2, 5, 4, 7}
+	OIDSubjectState                  = asn1.ObjectIdentifier{2, 5, 4, 8}
+	OIDSubjectStreetAddress          = asn1.ObjectIdentifier{2, 5, 4, 9}
+	OIDSubjectOrganization           = asn1.ObjectIdentifier{2, 5, 4, 10}
+	OIDSubjectOrganizationalUnit     = asn1.ObjectIdentifier{2, 5, 4, 11}
+	OIDSubjectPostalCode             = asn1.ObjectIdentifier{2, 5, 4, 17}
+	OIDSubjectSurname                = asn1.ObjectIdentifier{2, 5, 4, 4}
+	OIDSubjectGivenName              = asn1.ObjectIdentifier{2, 5, 4, 42}
+	OIDSubjectOrganizationIdentifier = asn1.ObjectIdentifier{2, 5, 4, 97}
 	OIDSubjectEmail                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
 	OIDSubjectJOILocality            = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 60, 2, 1, 1}
 	OIDSubjectJOIState               = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 60, 2, 1, 2}
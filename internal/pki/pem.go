@@ -25,6 +25,8 @@ import (
 
 var errExtraneousPEMData = errors.New("extraneous data in PEM file")
 
+var errNoPEMData = errors.New("no PEM data found")
+
 // PEMBlockFromFile reads a PEM-encoded file and returns a pem.Block.
 func PEMBlockFromFile(filename string) (*pem.Block, error) {
 	var data, err = ioutil.ReadFile(filename)
@@ -32,9 +34,16 @@ func PEMBlockFromFile(filename string) (*pem.Block, error) {
 		return nil, err
 	}
 
+	return PEMBlockFromBytes(data)
+}
+
+// PEMBlockFromBytes decodes PEM-encoded data and returns a pem.Block.
+func PEMBlockFromBytes(data []byte) (*pem.Block, error) {
 	var block, rest = pem.Decode(data)
 	if len(rest) != 0 {
 		return nil, errExtraneousPEMData
+	} else if block == nil {
+		return nil, errNoPEMData
 	}
 
 	return block, nil
@@ -65,7 +74,27 @@ func PrivateKeyFromFileWithPassword(filename, password string) (interface{}, err
 		return nil, err
 	}
 
+	return privateKeyFromBlockWithPassword(block, password)
+}
+
+// PrivateKeyFromBytesWithPassword decodes PEM-encoded data and returns the
+// private key it contains, decrypting it with the supplied password if
+// necessary. If the data does not contain a PEM-encoded private key, an error
+// is returned.
+func PrivateKeyFromBytesWithPassword(data []byte, password string) (interface{}, error) {
+	var block, err = PEMBlockFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return privateKeyFromBlockWithPassword(block, password)
+}
+
+// privateKeyFromBlockWithPassword returns the private key contained in a PEM
+// block, decrypting it with the supplied password if necessary.
+func privateKeyFromBlockWithPassword(block *pem.Block, password string) (interface{}, error) {
 	var keybytes []byte
+	var err error
 
 	if x509.IsEncryptedPEMBlock(block) {
 		if keybytes, err = x509.DecryptPEMBlock(block, []byte(password)); err != nil {
@@ -130,6 +159,18 @@ func CertFromFile(filename string) (*x509.Certificate, error) {
 	return x509.ParseCertificate(block.Bytes)
 }
 
+// CertFromBytes decodes PEM-encoded data and returns the X509 certificate it
+// contains. If the data does not contain a PEM-encoded X509 certificate, an
+// error is returned.
+func CertFromBytes(data []byte) (*x509.Certificate, error) {
+	var block, err = PEMBlockFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
 // CertToPEMString encodes a certificate to a PEM-encoded string.
 func CertToPEMString(cert *x509.Certificate) string {
 	return string(pem.EncodeToMemory(&pem.Block{
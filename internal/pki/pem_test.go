@@ -155,7 +155,7 @@ func TestPublicKeyFromFileBad(t *testing.T) {
 	}
 
 	for n, tc := range testcases {
-		var tc = tc
+		var n, tc = n, tc
 
 		t.Run(tc, func(t *testing.T) {
 			t.Parallel()
@@ -176,7 +176,7 @@ func TestCSRFromFile(t *testing.T) {
 	}
 
 	for n, tc := range testcases {
-		var tc = tc
+		var n, tc = n, tc
 
 		t.Run(tc, func(t *testing.T) {
 			t.Parallel()
@@ -197,7 +197,7 @@ func TestCSRFromFileBad(t *testing.T) {
 	}
 
 	for n, tc := range testcases {
-		var tc = tc
+		var n, tc = n, tc
 
 		t.Run(tc, func(t *testing.T) {
 			t.Parallel()
@@ -218,7 +218,7 @@ func TestCertFromFile(t *testing.T) {
 	}
 
 	for n, tc := range testcases {
-		var tc = tc
+		var n, tc = n, tc
 
 		t.Run(tc, func(t *testing.T) {
 			t.Parallel()
@@ -239,7 +239,7 @@ func TestCertFromFileBad(t *testing.T) {
 	}
 
 	for n, tc := range testcases {
-		var tc = tc
+		var n, tc = n, tc
 
 		t.Run(tc, func(t *testing.T) {
 			t.Parallel()
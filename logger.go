@@ -0,0 +1,135 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// LogLevel is the severity of a log message. The values are the same as
+// those of the corresponding log/slog levels.
+type LogLevel int
+
+// Log level constants.
+const (
+	LogLevelDebug LogLevel = -4
+	LogLevelInfo  LogLevel = 0
+	LogLevelWarn  LogLevel = 4
+	LogLevelError LogLevel = 8
+)
+
+// logLevelNames maps log levels to their descriptions.
+var logLevelNames = map[LogLevel]string{
+	LogLevelDebug: "DEBUG",
+	LogLevelInfo:  "INFO",
+	LogLevelWarn:  "WARN",
+	LogLevelError: "ERROR",
+}
+
+// Logger receives log messages from the client. It is deliberately minimal
+// so that this package does not depend on any particular logging library.
+// A *slog.Logger from the log/slog package can be adapted with a few lines
+// of code, for example:
+//
+//	type slogLogger struct{ *slog.Logger }
+//
+//	func (l slogLogger) Log(ctx context.Context, level hvclient.LogLevel, msg string, keyvals ...interface{}) {
+//		l.Logger.Log(ctx, slog.Level(level), msg, keyvals...)
+//	}
+type Logger interface {
+	// Log logs a message at the specified level. The key/value pairs are
+	// alternating string keys and values of any type, as for slog.
+	Log(ctx context.Context, level LogLevel, msg string, keyvals ...interface{})
+}
+
+// Keys used in log messages.
+const (
+	logKeyOperation   = "operation"
+	logKeyEndpoint    = "endpoint"
+	logKeyStatus      = "status"
+	logKeyCode        = "code"
+	logKeyDescription = "description"
+	logKeyAttempt     = "attempt"
+	logKeyDelay       = "delay"
+	logKeyElapsed     = "elapsed"
+	logKeyError       = "error"
+	logKeyNotAfter    = "not_after"
+)
+
+// rateLimitLogThreshold is the shortest wait for the rate limiter which is
+// logged, so that requests which the limiter did not delay are not logged.
+const rateLimitLogThreshold = time.Millisecond
+
+// logIfSlow logs a warning if a single HTTP round trip to HVCA took longer
+// than the slow request threshold specified in the configuration. The
+// response is nil if none was received.
+func (c *Config) logIfSlow(ctx context.Context, op, endpoint string, elapsed time.Duration, response *http.Response) {
+	if c.SlowRequestThreshold <= 0 || elapsed <= c.SlowRequestThreshold {
+		return
+	}
+
+	var keyvals = []interface{}{logKeyOperation, op, logKeyEndpoint, endpoint, logKeyElapsed, elapsed}
+	if response != nil {
+		keyvals = append(keyvals, logKeyStatus, response.StatusCode)
+	}
+
+	c.logger().Log(ctx, LogLevelWarn, "slow HVCA request", keyvals...)
+}
+
+// nopLogger is a logger which discards all messages. It is used if no
+// logger was provided in the configuration.
+type nopLogger struct{}
+
+// Log discards the message.
+func (nopLogger) Log(context.Context, LogLevel, string, ...interface{}) {}
+
+// String returns a description of the log level.
+func (l LogLevel) String() string {
+	if name, ok := logLevelNames[l]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// logger returns the logger provided in the configuration, or a logger which
+// discards all messages if none was provided.
+func (c *Config) logger() Logger {
+	if c == nil || c.Logger == nil {
+		return nopLogger{}
+	}
+
+	return c.Logger
+}
+
+// responseLogLevel returns the level at which to log an unsuccessful
+// response with the specified HTTP status code. Pending certificate
+// issuance is expected, server errors are the most serious, and client
+// errors are usually handled by the caller.
+func responseLogLevel(statusCode int) LogLevel {
+	switch {
+	case statusCode == http.StatusAccepted:
+		return LogLevelDebug
+
+	case statusCode >= 500:
+		return LogLevelError
+	}
+
+	return LogLevelWarn
+}
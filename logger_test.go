@@ -0,0 +1,253 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+// recordingLogger is a logger which records the messages it receives.
+type recordingLogger struct {
+	sync.Mutex
+	entries []logEntry
+}
+
+// logEntry is a message received by a recordingLogger, with the values of
+// the key/value pairs omitted, except for status codes.
+type logEntry struct {
+	level  LogLevel
+	msg    string
+	keys   []string
+	status interface{}
+}
+
+func (l *recordingLogger) Log(ctx context.Context, level LogLevel, msg string, keyvals ...interface{}) {
+	var entry = logEntry{level: level, msg: msg}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		entry.keys = append(entry.keys, keyvals[i].(string))
+		if keyvals[i] == logKeyStatus {
+			entry.status = keyvals[i+1]
+		}
+	}
+
+	l.Lock()
+	l.entries = append(l.entries, entry)
+	l.Unlock()
+}
+
+// sleepLimiter is a rate limiter which sleeps before every request.
+type sleepLimiter time.Duration
+
+func (l sleepLimiter) Wait(ctx context.Context) error {
+	return sleepContext(ctx, time.Duration(l))
+}
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == endpointLogin {
+			w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+			fmt.Fprint(w, `{"access_token":"token"}`)
+			return
+		}
+
+		calls++
+
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+		case 2:
+			w.WriteHeader(http.StatusUnauthorized)
+
+		default:
+			w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+			fmt.Fprint(w, `{"value":42}`)
+		}
+	}))
+	defer server.Close()
+
+	var logger recordingLogger
+
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond})
+	clnt.Config.Logger = &logger
+
+	if _, err := clnt.CounterCertsIssued(context.Background()); err != nil {
+		t.Fatalf("failed to get counter: %v", err)
+	}
+
+	var want = []logEntry{
+		{
+			level:  LogLevelError,
+			msg:    "unsuccessful HVCA response",
+			keys:   []string{logKeyOperation, logKeyStatus, logKeyDescription},
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			level:  LogLevelWarn,
+			msg:    "retrying HVCA request",
+			keys:   []string{logKeyOperation, logKeyAttempt, logKeyDelay, logKeyStatus},
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			level:  LogLevelWarn,
+			msg:    "unsuccessful HVCA response",
+			keys:   []string{logKeyOperation, logKeyStatus, logKeyDescription},
+			status: http.StatusUnauthorized,
+		},
+		{
+			level: LogLevelInfo,
+			msg:   "logged in to HVCA",
+			keys:  []string{logKeyElapsed},
+		},
+	}
+
+	if !cmp.Equal(logger.entries, want, cmp.AllowUnexported(logEntry{})) {
+		t.Errorf("got log entries %+v, want %+v", logger.entries, want)
+	}
+}
+
+func TestLoggerNetworkError(t *testing.T) {
+	t.Parallel()
+
+	var server = httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	var logger recordingLogger
+
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond})
+	clnt.Config.Logger = &logger
+	clnt.Config.RateLimiter = sleepLimiter(time.Millisecond * 2)
+
+	if _, err := clnt.CounterCertsIssued(context.Background()); err == nil {
+		t.Fatal("unexpectedly got counter")
+	}
+
+	var want = []logEntry{
+		{
+			level: LogLevelDebug,
+			msg:   "waited for rate limiter",
+			keys:  []string{logKeyOperation, logKeyElapsed},
+		},
+		{
+			level: LogLevelWarn,
+			msg:   "retrying HVCA request after error",
+			keys:  []string{logKeyOperation, logKeyAttempt, logKeyDelay, logKeyError},
+		},
+		{
+			level: LogLevelDebug,
+			msg:   "waited for rate limiter",
+			keys:  []string{logKeyOperation, logKeyElapsed},
+		},
+		{
+			level: LogLevelError,
+			msg:   "HVCA request failed",
+			keys:  []string{logKeyOperation, logKeyError},
+		},
+	}
+
+	if !cmp.Equal(logger.entries, want, cmp.AllowUnexported(logEntry{})) {
+		t.Errorf("got log entries %+v, want %+v", logger.entries, want)
+	}
+}
+
+func TestLoggerSlowRequest(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		// Only the first attempt is slow, and is retried.
+		if calls == 1 {
+			time.Sleep(time.Millisecond * 50)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+		fmt.Fprint(w, `{"value":42}`)
+	}))
+	defer server.Close()
+
+	var logger recordingLogger
+
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond})
+	clnt.Config.Logger = &logger
+	clnt.Config.SlowRequestThreshold = time.Millisecond * 20
+
+	if _, err := clnt.CounterCertsIssued(context.Background()); err != nil {
+		t.Fatalf("failed to get counter: %v", err)
+	}
+
+	var got []logEntry
+	for _, entry := range logger.entries {
+		if entry.msg == "slow HVCA request" {
+			got = append(got, entry)
+		}
+	}
+
+	var want = []logEntry{
+		{
+			level:  LogLevelWarn,
+			msg:    "slow HVCA request",
+			keys:   []string{logKeyOperation, logKeyEndpoint, logKeyElapsed, logKeyStatus},
+			status: http.StatusServiceUnavailable,
+		},
+	}
+
+	if !cmp.Equal(got, want, cmp.AllowUnexported(logEntry{})) {
+		t.Errorf("got log entries %+v, want %+v", got, want)
+	}
+}
+
+func TestLogLevelString(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		level LogLevel
+		want  string
+	}{
+		{LogLevelDebug, "DEBUG"},
+		{LogLevelInfo, "INFO"},
+		{LogLevelWarn, "WARN"},
+		{LogLevelError, "ERROR"},
+		{LogLevel(2), "LEVEL(2)"},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.want, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.level.String(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// MetricsObserver is notified of the outcome of HVCA API calls, so that
+// request rate, error and latency metrics can be collected using any metrics
+// library.
+type MetricsObserver interface {
+	// ObserveRequest is called after each HVCA API call completes. The
+	// endpoint is the name of the API operation, such as
+	// "CertificateRequest", or "Login" for a login, and never contains
+	// certificate serial numbers or other request-specific values. The
+	// status is the HTTP status code of the final response, or zero if no
+	// response was received. The duration includes the time spent on any
+	// retries and logins triggered by the call. ObserveRequest may be
+	// called concurrently, and should return quickly.
+	ObserveRequest(endpoint string, status int, duration time.Duration, err error)
+}
+
+// InFlightObserver may optionally be implemented by a MetricsObserver to be
+// notified of the number of HVCA API calls in progress, for use as a
+// concurrency gauge.
+type InFlightObserver interface {
+	// ObserveInFlight is called with the new number of API calls in
+	// progress whenever a call starts or completes. It may be called
+	// concurrently, and should return quickly. Since calls may start and
+	// complete concurrently, observations may arrive out of order, and
+	// Client.InFlight should be used if an exact value is needed.
+	ObserveInFlight(n int)
+}
+
+// InFlight returns the number of HVCA API calls currently in progress. Each
+// call is counted once, however many times it is retried, and until it
+// completes, whether it succeeds, fails or its context is cancelled. A login
+// triggered by a call is counted as a separate call while it is in
+// progress.
+func (c *Client) InFlight() int {
+	return int(c.inFlight.Load())
+}
+
+// observeInFlight notifies the metrics observer, if one was provided in the
+// configuration and it implements InFlightObserver, of the number of API
+// calls in progress.
+func (c *Client) observeInFlight(n int64) {
+	if c.Config == nil {
+		return
+	}
+
+	if o, ok := c.Config.MetricsObserver.(InFlightObserver); ok {
+		o.ObserveInFlight(int(n))
+	}
+}
+
+// observeRequest notifies the metrics observer, if one was provided in the
+// configuration, of the outcome of an HVCA API call.
+func (c *Client) observeRequest(
+	method string,
+	path string,
+	response *http.Response,
+	duration time.Duration,
+	err error,
+) {
+	if c.Config == nil || c.Config.MetricsObserver == nil {
+		return
+	}
+
+	c.Config.MetricsObserver.ObserveRequest(operationName(method, path), responseStatus(response, err), duration, err)
+}
+
+// responseStatus returns the HTTP status code of the response to an HVCA API
+// call, or zero if no response was received.
+func responseStatus(response *http.Response, err error) int {
+	if response != nil {
+		return response.StatusCode
+	}
+
+	var apiErr APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+
+	return 0
+}
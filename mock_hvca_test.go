@@ -24,6 +24,7 @@ import (
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
@@ -140,14 +141,17 @@ const (
 	mockClaimID             = "113FED08"
 	mockClaimToken          = "mock_claim_token"
 	mockQuotaIssuance       = 42
+	mockRequestID           = "b5a8c1f3-93d2-4e3c-a1d7-2f0e6c9d4b11"
 	mockSSLClientSerial     = "0123456789"
 	mockToken               = "mock_token"
+	triggerRelativeLocation = "triggerrelativelocation"
 	sslClientSerialHeader   = "X-SSL-Client-Serial"
 	triggerError            = "triggererror"
 )
 
 var (
 	mockBigIntNotFound = big.NewInt(999999)
+	mockBigIntRevoked  = big.NewInt(888888)
 	mockCert           = mustReadCertFromFile("testdata/test_cert.pem")
 	mockClaimAssert    = mockClaimAssertionInfo{
 		Token:    mockClaimToken,
@@ -377,7 +381,14 @@ func mockCertificatesRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Location", fmt.Sprintf("http://local/certificates/%X", mockCert.SerialNumber))
+	// Return a relative location for a specific common name.
+	if body.Subject != nil && body.Subject.CommonName == triggerRelativeLocation {
+		w.Header().Set("Location", fmt.Sprintf("/certificates/%X", mockCert.SerialNumber))
+	} else {
+		w.Header().Set("Location", fmt.Sprintf("http://local/certificates/%X", mockCert.SerialNumber))
+	}
+
+	w.Header().Set("X-Request-ID", mockRequestID)
 	mockWriteResponse(w, http.StatusCreated, nil)
 }
 
@@ -396,9 +407,14 @@ func mockCertificatesRetrieve(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var status = "ISSUED"
+	if sn.Cmp(mockBigIntRevoked) == 0 {
+		status = "REVOKED"
+	}
+
 	mockWriteResponse(w, http.StatusOK, mockCertInfo{
 		PEM:       pki.CertToPEMString(mockCert),
-		Status:    "ISSUED",
+		Status:    status,
 		UpdatedAt: mockDateUpdated.Unix(),
 	})
 }
@@ -426,6 +442,15 @@ func mockCertificatesRevoke(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Return 422 for reasons not supported by HVCA.
+	switch body.RevocationReason {
+	case "unspecified", "keyCompromise", "affiliationChanged", "superseded",
+		"cessationOfOperation", "privilegeWithdrawn":
+	default:
+		mockWriteError(w, http.StatusUnprocessableEntity)
+		return
+	}
+
 	mockWriteResponse(w, http.StatusNoContent, nil)
 }
 
@@ -562,7 +587,7 @@ func mockClaimsDomains(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Total-Count", fmt.Sprintf("%d", len(entries)))
-	mockWriteResponse(w, http.StatusOK, entries)
+	mockWriteResponse(w, http.StatusOK, mockPaginateClaims(r, entries))
 }
 
 // mockClaimsSubmit mocks a POST /claims/domains/{domain} operation.
@@ -653,19 +678,19 @@ func mockQuotasIssuance(w http.ResponseWriter, r *http.Request) {
 // mockStatsExpiring mocks a GET /stats/expiring operation.
 func mockStatsExpiring(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Total-Count", fmt.Sprintf("%d", len(mockStatsExpiringData)))
-	mockWriteResponse(w, http.StatusOK, mockStatsExpiringData)
+	mockWriteResponse(w, http.StatusOK, mockPaginate(r, mockStatsExpiringData))
 }
 
 // mockStatsIssued mocks a GET /stats/issued operation.
 func mockStatsIssued(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Total-Count", fmt.Sprintf("%d", len(mockStatsIssuedData)))
-	mockWriteResponse(w, http.StatusOK, mockStatsIssuedData)
+	mockWriteResponse(w, http.StatusOK, mockPaginate(r, mockStatsIssuedData))
 }
 
 // mockStatsRevoked mocks a GET /stats/revoked operation.
 func mockStatsRevoked(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Total-Count", fmt.Sprintf("%d", len(mockStatsIssuedData[1:])))
-	mockWriteResponse(w, http.StatusOK, mockStatsIssuedData[1:])
+	mockWriteResponse(w, http.StatusOK, mockPaginate(r, mockStatsIssuedData[1:]))
 }
 
 // mockTrustChain mocks a GET /trustchain operation.
@@ -678,6 +703,52 @@ func mockTrustChain(w http.ResponseWriter, r *http.Request) {
 	mockWriteResponse(w, http.StatusOK, chain)
 }
 
+// mockPaginate returns the requested page of certificate metadata, or all of
+// it if no page size was requested.
+func mockPaginate(r *http.Request, data []mockCertMeta) []mockCertMeta {
+	var page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	var perPage, _ = strconv.Atoi(r.URL.Query().Get("per_page"))
+
+	if page < 1 || perPage < 1 {
+		return data
+	}
+
+	var start = (page - 1) * perPage
+	if start >= len(data) {
+		return []mockCertMeta{}
+	}
+
+	var end = start + perPage
+	if end > len(data) {
+		end = len(data)
+	}
+
+	return data[start:end]
+}
+
+// mockPaginateClaims returns the requested page of domain claims, or all of
+// them if no page size was requested.
+func mockPaginateClaims(r *http.Request, data []mockClaim) []mockClaim {
+	var page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	var perPage, _ = strconv.Atoi(r.URL.Query().Get("per_page"))
+
+	if page < 1 || perPage < 1 {
+		return data
+	}
+
+	var start = (page - 1) * perPage
+	if start >= len(data) {
+		return []mockClaim{}
+	}
+
+	var end = start + perPage
+	if end > len(data) {
+		end = len(data)
+	}
+
+	return data[start:end]
+}
+
 // mockUnmarshalBody unmarshals an HTTP request body, and writes an appropriate
 // HTTP error response on failure.
 func mockUnmarshalBody(w http.ResponseWriter, r *http.Request, out interface{}) error {
@@ -0,0 +1,94 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"net/http"
+	"strings"
+)
+
+// operationNames maps HTTP methods and HVCA API endpoint templates to the
+// names of the corresponding API operations.
+var operationNames = map[string]string{
+	http.MethodPost + " " + endpointLogin:                                  "Login",
+	http.MethodPost + " " + endpointCertificates:                           "CertificateRequest",
+	http.MethodGet + " " + endpointCertificates + "/{serial}":              "CertificateRetrieve",
+	http.MethodPatch + " " + endpointCertificates + "/{serial}":            "CertificateRevoke",
+	http.MethodGet + " " + endpointTrustChain:                              "TrustChain",
+	http.MethodGet + " " + endpointPolicy:                                  "Policy",
+	http.MethodGet + " " + endpointPolicies:                                "Policies",
+	http.MethodGet + " " + endpointPolicies + "/{id}":                      "PolicyByID",
+	http.MethodGet + " " + endpointProfiles:                                "Profiles",
+	http.MethodGet + " " + endpointCountersCertificatesIssued:              "CounterCertsIssued",
+	http.MethodGet + " " + endpointCountersCertificatesRevoked:             "CounterCertsRevoked",
+	http.MethodGet + " " + endpointQuotasIssuance:                          "QuotaIssuance",
+	http.MethodGet + " " + endpointStatsExpiring:                           "StatsExpiring",
+	http.MethodGet + " " + endpointStatsIssued:                             "StatsIssued",
+	http.MethodGet + " " + endpointStatsRevoked:                            "StatsRevoked",
+	http.MethodGet + " " + endpointClaimsDomains:                           "ClaimsDomains",
+	http.MethodPost + " " + endpointClaimsDomains + "/{id}":                "ClaimSubmit",
+	http.MethodGet + " " + endpointClaimsDomains + "/{id}":                 "ClaimRetrieve",
+	http.MethodDelete + " " + endpointClaimsDomains + "/{id}":              "ClaimDelete",
+	http.MethodPost + " " + endpointClaimsDomains + "/{id}" + pathDNS:      "ClaimDNS",
+	http.MethodGet + " " + endpointClaimsDomains + "/{id}" + pathDNS:       "ClaimADNRetrieve",
+	http.MethodPost + " " + endpointClaimsDomains + "/{id}" + pathHTTP:     "ClaimHTTP",
+	http.MethodPost + " " + endpointClaimsDomains + "/{id}" + pathEmail:    "ClaimEmail",
+	http.MethodGet + " " + endpointClaimsDomains + "/{id}" + pathEmail:     "ClaimEmailRetrieve",
+	http.MethodPost + " " + endpointClaimsDomains + "/{id}" + pathReassert: "ClaimReassert",
+}
+
+// endpointTemplate returns the HVCA API endpoint for a request path, with any
+// query string removed, and any certificate serial number, claim ID or
+// validation policy ID replaced with a placeholder, so the result is suitable for use as a label
+// with low cardinality.
+func endpointTemplate(path string) string {
+	if i := strings.IndexByte(path, '?'); i != -1 {
+		path = path[:i]
+	}
+
+	switch {
+	case strings.HasPrefix(path, endpointCertificates+"/"):
+		return endpointCertificates + "/{serial}"
+
+	case strings.HasPrefix(path, endpointPolicies+"/"):
+		return endpointPolicies + "/{id}"
+
+	case strings.HasPrefix(path, endpointClaimsDomains+"/"):
+		var rest = strings.TrimPrefix(path, endpointClaimsDomains+"/")
+		var suffix string
+		if i := strings.IndexByte(rest, '/'); i != -1 {
+			suffix = rest[i:]
+		}
+
+		return endpointClaimsDomains + "/{id}" + suffix
+	}
+
+	return path
+}
+
+// operationName returns the name of the HVCA API operation for a request
+// with the specified method and path, such as "CertificateRequest". If the
+// request does not match a known operation, the method and endpoint template
+// are returned.
+func operationName(method, path string) string {
+	var key = method + " " + endpointTemplate(path)
+
+	if name, ok := operationNames[key]; ok {
+		return name
+	}
+
+	return key
+}
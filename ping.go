@@ -0,0 +1,99 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// PingErrorKind is the kind of failure reported by a PingError.
+type PingErrorKind int
+
+// Ping error kind constants.
+const (
+	// PingNetworkError indicates that HVCA could not be reached, or that no
+	// valid response was received, such as when a request timed out.
+	PingNetworkError PingErrorKind = iota + 1
+
+	// PingAuthError indicates that HVCA rejected the credentials or the
+	// authentication token.
+	PingAuthError
+
+	// PingServerError indicates that HVCA returned any other error.
+	PingServerError
+)
+
+// pingErrorKindNames maps ping error kinds to their descriptions.
+var pingErrorKindNames = [...]string{
+	PingNetworkError: "network error",
+	PingAuthError:    "authentication error",
+	PingServerError:  "server error",
+}
+
+// PingError is the error returned by Ping.
+type PingError struct {
+	Kind PingErrorKind
+	Err  error
+}
+
+// String returns a description of the ping error kind.
+func (k PingErrorKind) String() string {
+	if k < PingNetworkError || k > PingServerError {
+		return "unknown error"
+	}
+
+	return pingErrorKindNames[k]
+}
+
+// Error returns a string representation of the error.
+func (e PingError) Error() string {
+	return fmt.Sprintf("ping failed: %v: %v", e.Kind, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e PingError) Unwrap() error {
+	return e.Err
+}
+
+// Ping checks that HVCA can be reached and that the client's credentials are
+// valid, logging in first if the stored authentication token has expired,
+// and then retrieving the count of issued certificates. It has no side
+// effects and consumes no issuance quota, so it is suitable for use in a
+// health check. On failure it returns a PingError indicating whether the
+// failure was a network, authentication or server error.
+func (c *Client) Ping(ctx context.Context) error {
+	var err = c.Login(ctx)
+	if err == nil {
+		_, err = c.CounterCertsIssued(ctx)
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	var apiErr APIError
+	switch {
+	case errors.Is(err, ErrAuthFailed), errors.Is(err, ErrTokenExpired):
+		return PingError{Kind: PingAuthError, Err: err}
+
+	case errors.As(err, &apiErr):
+		return PingError{Kind: PingServerError, Err: err}
+	}
+
+	return PingError{Kind: PingNetworkError, Err: err}
+}
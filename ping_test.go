@@ -0,0 +1,165 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		counter  int
+		login    int
+		closed   bool
+		wantKind PingErrorKind
+	}{
+		{
+			name:    "OK",
+			counter: http.StatusOK,
+			login:   http.StatusOK,
+		},
+		{
+			name:     "AuthFailed",
+			counter:  http.StatusUnauthorized,
+			login:    http.StatusUnauthorized,
+			wantKind: PingAuthError,
+		},
+		{
+			name:     "Forbidden",
+			counter:  http.StatusForbidden,
+			login:    http.StatusOK,
+			wantKind: PingAuthError,
+		},
+		{
+			name:     "ServerError",
+			counter:  http.StatusInternalServerError,
+			login:    http.StatusOK,
+			wantKind: PingServerError,
+		},
+		{
+			name:     "NotFound",
+			counter:  http.StatusNotFound,
+			login:    http.StatusOK,
+			wantKind: PingServerError,
+		},
+		{
+			name:     "NetworkError",
+			closed:   true,
+			wantKind: PingNetworkError,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet && r.URL.Path != endpointLogin {
+					t.Errorf("unexpected %s request to %s", r.Method, r.URL.Path)
+				}
+
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+				switch r.URL.Path {
+				case endpointLogin:
+					w.WriteHeader(tc.login)
+					fmt.Fprint(w, `{"access_token":"token"}`)
+
+				case endpointCountersCertificatesIssued:
+					w.WriteHeader(tc.counter)
+					fmt.Fprint(w, `{"value":42}`)
+
+				default:
+					t.Errorf("unexpected request to %s", r.URL.Path)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+
+			var serverURL = server.URL
+			if tc.closed {
+				server.Close()
+			} else {
+				defer server.Close()
+			}
+
+			var clnt = newTestClient(t, serverURL, &RetryPolicy{})
+
+			var err = clnt.Ping(context.Background())
+			if tc.wantKind == 0 {
+				if err != nil {
+					t.Fatalf("ping failed: %v", err)
+				}
+
+				return
+			}
+
+			var pingErr PingError
+			if !errors.As(err, &pingErr) {
+				t.Fatalf("got error %v, want PingError", err)
+			}
+
+			if pingErr.Kind != tc.wantKind {
+				t.Errorf("got kind %v, want %v", pingErr.Kind, tc.wantKind)
+			}
+
+			if tc.wantKind == PingAuthError && !errors.Is(err, ErrAuthFailed) {
+				t.Errorf("got error %v, want ErrAuthFailed", err)
+			}
+		})
+	}
+}
+
+func TestPingLoginIfExpired(t *testing.T) {
+	t.Parallel()
+
+	var logins int
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+		switch r.URL.Path {
+		case endpointLogin:
+			logins++
+			fmt.Fprint(w, `{"access_token":"token"}`)
+
+		default:
+			fmt.Fprint(w, `{"value":42}`)
+		}
+	}))
+	defer server.Close()
+
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+	clnt.tokenReset()
+
+	if err := clnt.Ping(context.Background()); err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+
+	if logins != 1 {
+		t.Errorf("got %d logins, want 1", logins)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// NamedPolicy is one of the validation policies of an HVCA account which has
+// more than one, along with its identifier.
+type NamedPolicy struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Policy Policy `json:"policy"`
+}
+
+// Policies returns all the validation policies of the calling account. The
+// /validationpolicies endpoint it uses is not a documented HVCA endpoint. If
+// HVCA does not support multiple validation policies for the account, the
+// account's single policy, as returned by Policy, is returned with an empty
+// identifier.
+func (c *Client) Policies(ctx context.Context) ([]NamedPolicy, error) {
+	var policies []NamedPolicy
+	var _, err = c.makeRequest(
+		ctx,
+		endpointPolicies,
+		http.MethodGet,
+		nil,
+		&policies,
+	)
+	if errors.Is(err, ErrNotFound) {
+		var pol *Policy
+		if pol, err = c.Policy(ctx); err != nil {
+			return nil, err
+		}
+
+		return []NamedPolicy{{Policy: *pol}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// PolicyByID returns the validation policy of the calling account with the
+// specified identifier, as returned by Policies. If the identifier is empty,
+// the account's primary policy is returned, as for Policy. Only the primary
+// policy is cached according to Config.PolicyCacheTTL.
+func (c *Client) PolicyByID(ctx context.Context, id string) (*Policy, error) {
+	if id == "" {
+		return c.Policy(ctx)
+	}
+
+	var pol Policy
+	var _, err = c.makeRequest(
+		ctx,
+		endpointPolicies+"/"+url.PathEscape(id),
+		http.MethodGet,
+		nil,
+		&pol,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve validation policy %q: %w", id, err)
+	}
+
+	return &pol, nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+// newPoliciesServer returns a test server with a primary validation policy
+// allowing a minimum validity of one second, and, if multiple is true, two
+// named validation policies with minimum validities of two and three seconds.
+func newPoliciesServer(t *testing.T, multiple bool) *httptest.Server {
+	t.Helper()
+
+	var policy = func(min int) string {
+		return fmt.Sprintf(`{"validity":{"secondsmin":%d,"secondsmax":3600},"public_key_signature":"OPTIONAL"}`, min)
+	}
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+		switch {
+		case r.URL.Path == endpointPolicy:
+			fmt.Fprint(w, policy(1))
+
+		case multiple && r.URL.Path == endpointPolicies:
+			fmt.Fprintf(w, `[{"id":"servers","name":"TLS servers","policy":%s},{"id":"clients","policy":%s}]`,
+				policy(2), policy(3))
+
+		case multiple && r.URL.Path == endpointPolicies+"/servers":
+			fmt.Fprint(w, policy(2))
+
+		case multiple && r.URL.Path == endpointPolicies+"/clients":
+			fmt.Fprint(w, policy(3))
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"description":"not found"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestPolicies(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		multiple bool
+		wantIDs  []string
+		wantMins []int64
+	}{
+		{
+			name:     "Multiple",
+			multiple: true,
+			wantIDs:  []string{"servers", "clients"},
+			wantMins: []int64{2, 3},
+		},
+		{
+			name:     "Single",
+			wantIDs:  []string{""},
+			wantMins: []int64{1},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var clnt = newTestClient(t, newPoliciesServer(t, tc.multiple).URL, &RetryPolicy{})
+
+			var got, err = clnt.Policies(context.Background())
+			if err != nil {
+				t.Fatalf("failed to list policies: %v", err)
+			}
+
+			if len(got) != len(tc.wantIDs) {
+				t.Fatalf("got %d policies, want %d", len(got), len(tc.wantIDs))
+			}
+
+			for i := range got {
+				if got[i].ID != tc.wantIDs[i] {
+					t.Errorf("got policy ID %q, want %q", got[i].ID, tc.wantIDs[i])
+				}
+
+				if got[i].Policy.Validity == nil || got[i].Policy.Validity.SecondsMin != tc.wantMins[i] {
+					t.Errorf("got policy %d validity %+v, want minimum %d", i, got[i].Policy.Validity, tc.wantMins[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyByID(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name    string
+		id      string
+		wantMin int64
+		err     error
+	}{
+		{
+			name:    "Primary",
+			wantMin: 1,
+		},
+		{
+			name:    "Named",
+			id:      "clients",
+			wantMin: 3,
+		},
+		{
+			name: "Unknown",
+			id:   "email",
+			err:  ErrNotFound,
+		},
+	}
+
+	var server = newPoliciesServer(t, true)
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+			var got, err = clnt.PolicyByID(context.Background(), tc.id)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+
+			if tc.err == nil && got.Validity.SecondsMin != tc.wantMin {
+				t.Errorf("got minimum validity %d, want %d", got.Validity.SecondsMin, tc.wantMin)
+			}
+		})
+	}
+}
@@ -31,6 +31,7 @@ type Policy struct {
 	SubjectDN           *SubjectDNPolicy           `json:"subject_dn,omitempty"`
 	SAN                 *SANPolicy                 `json:"san,omitempty"`
 	EKUs                *EKUPolicy                 `json:"extended_key_usages,omitempty"`
+	KeyUsages           *ListPolicy                `json:"key_usages,omitempty"`
 	SubjectDA           *SubjectDAPolicy           `json:"subject_da,omitempty"`
 	QualifiedStatements *QualifiedStatementsPolicy `json:"qualified_statements,omitempty"`
 	MSExtensionTemplate *MSExtensionTemplatePolicy `json:"ms_extension_template,omitempty"`
@@ -261,6 +262,7 @@ const (
 const (
 	RSA KeyType = iota + 1
 	ECDSA
+	ED25519
 )
 
 // Optional static presence values.
@@ -308,14 +310,16 @@ var presenceValues = map[string]Presence{
 
 // keyTypeDescriptions maps key type values to their string descriptions.
 var keyTypeDescriptions = []string{
-	RSA:   "RSA",
-	ECDSA: "ECDSA",
+	RSA:     "RSA",
+	ECDSA:   "ECDSA",
+	ED25519: "ED25519",
 }
 
 // keyTypeValues maps key type string descriptions to their values.
 var keyTypeValues = map[string]KeyType{
-	"RSA":   RSA,
-	"ECDSA": ECDSA,
+	"RSA":     RSA,
+	"ECDSA":   ECDSA,
+	"ED25519": ED25519,
 }
 
 // keyFormatDescriptions maps key format values to their string descriptions.
@@ -703,7 +707,7 @@ func (p *Presence) UnmarshalJSON(b []byte) error {
 
 // isValid checks if a value is within a valid range.
 func (t KeyType) isValid() bool {
-	return t >= RSA && t <= ECDSA
+	return t >= RSA && t <= ED25519
 }
 
 // String returns a description of the key type value.
@@ -0,0 +1,78 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RefreshPolicy retrieves the calling account's validation policy from
+// HVCA, replacing any cached copy, even if it has not expired.
+func (c *Client) RefreshPolicy(ctx context.Context) (*Policy, error) {
+	if c.Config.PolicyCacheTTL <= 0 {
+		return c.retrievePolicy(ctx)
+	}
+
+	c.policyMtx.Lock()
+	defer c.policyMtx.Unlock()
+
+	return c.refreshPolicyLocked(ctx)
+}
+
+// refreshPolicyLocked retrieves the validation policy and caches it. The
+// caller must hold policyMtx.
+func (c *Client) refreshPolicyLocked(ctx context.Context) (*Policy, error) {
+	var pol, err = c.retrievePolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A policy which cannot be encoded is returned without being cached,
+	// since HVCA has already returned it successfully.
+	var data []byte
+	if data, err = json.Marshal(pol); err != nil {
+		return pol, nil
+	}
+
+	c.policyJSON = data
+	c.policyExpiry = time.Now().Add(c.Config.PolicyCacheTTL)
+
+	return pol, nil
+}
+
+// invalidatePolicy discards any cached validation policy, including the copy
+// kept for conditional requests.
+func (c *Client) invalidatePolicy() {
+	c.policyMtx.Lock()
+	defer c.policyMtx.Unlock()
+
+	c.policyJSON = nil
+	c.policyExpiry = time.Time{}
+	c.policyResource.reset()
+}
+
+// decodePolicy returns a new copy of a cached validation policy.
+func decodePolicy(data []byte) (*Policy, error) {
+	var pol Policy
+	if err := json.Unmarshal(data, &pol); err != nil {
+		return nil, fmt.Errorf("failed to decode cached policy: %w", err)
+	}
+
+	return &pol, nil
+}
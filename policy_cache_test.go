@@ -0,0 +1,125 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+func TestPolicyCache(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name      string
+		ttl       time.Duration
+		wantFetch int32
+	}{
+		{
+			name:      "Disabled",
+			wantFetch: 3,
+		},
+		{
+			name:      "Enabled",
+			ttl:       time.Hour,
+			wantFetch: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var fetches int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var n = atomic.AddInt32(&fetches, 1)
+
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+				fmt.Fprintf(w, `{"validity":{"secondsmin":%d,"secondsmax":3600},"public_key_signature":"OPTIONAL"}`, n)
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+			clnt.Config.PolicyCacheTTL = tc.ttl
+
+			var ctx = context.Background()
+
+			for i := 0; i < 3; i++ {
+				var pol, err = clnt.Policy(ctx)
+				if err != nil {
+					t.Fatalf("failed to get policy: %v", err)
+				}
+
+				// Modifying the returned policy must not modify the cached
+				// copy.
+				pol.Validity.SecondsMax = 0
+			}
+
+			if got := atomic.LoadInt32(&fetches); got != tc.wantFetch {
+				t.Fatalf("got %d retrievals from HVCA, want %d", got, tc.wantFetch)
+			}
+
+			var pol, err = clnt.Policy(ctx)
+			if err != nil {
+				t.Fatalf("failed to get policy: %v", err)
+			}
+
+			if pol.Validity.SecondsMax != 3600 {
+				t.Errorf("cached policy modified through returned copy")
+			}
+
+			// Refreshing the policy always retrieves it, and the refreshed
+			// policy is returned by subsequent calls.
+			var before = atomic.LoadInt32(&fetches)
+			if pol, err = clnt.RefreshPolicy(ctx); err != nil {
+				t.Fatalf("failed to refresh policy: %v", err)
+			}
+
+			if got := atomic.LoadInt32(&fetches); got != before+1 {
+				t.Errorf("got %d retrievals from HVCA, want %d", got, before+1)
+			}
+
+			var cached *Policy
+			if cached, err = clnt.Policy(ctx); err != nil {
+				t.Fatalf("failed to get policy: %v", err)
+			}
+
+			if tc.ttl != 0 && cached.Validity.SecondsMin != pol.Validity.SecondsMin {
+				t.Errorf("got cached policy %d, want refreshed policy %d",
+					cached.Validity.SecondsMin, pol.Validity.SecondsMin)
+			}
+
+			// Closing the client discards the cached policy.
+			clnt.Close()
+
+			clnt.policyMtx.Lock()
+			defer clnt.policyMtx.Unlock()
+
+			if clnt.policyJSON != nil {
+				t.Errorf("cached policy not discarded on close")
+			}
+		})
+	}
+}
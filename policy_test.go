@@ -937,6 +937,7 @@ func TestKeyTypeString(t *testing.T) {
 		{hvclient.KeyType(0), "UNKNOWN KEY TYPE VALUE"},
 		{hvclient.RSA, "RSA"},
 		{hvclient.ECDSA, "ECDSA"},
+		{hvclient.ED25519, "ED25519"},
 	}
 
 	for _, tc := range testcases {
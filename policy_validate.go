@@ -0,0 +1,715 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/oids"
+)
+
+// Constraint is a constraint imposed by a validation policy on a single field
+// in a certificate request. Fields are named using the names in the HVCA API,
+// for example "subject_dn.common_name" or "san.dns_names".
+type Constraint struct {
+	Field string
+
+	// Presence indicates whether the field is optional, required, forbidden
+	// or static. It is zero for list fields, for which MinCount and MaxCount
+	// should be used instead.
+	Presence Presence
+
+	// Format is a regular expression which a string value must match, or
+	// the required value if Presence is Static.
+	Format string
+
+	// Allowed is the list of values permitted in a list field. If Static is
+	// true, values must be exactly one of the list. Otherwise, each entry in
+	// the list is a regular expression, and values must match at least one.
+	Allowed []string
+	Static  bool
+
+	// MinCount and MaxCount are the minimum and maximum number of values in
+	// a list field. A MaxCount of zero is treated as no upper limit.
+	MinCount int
+	MaxCount int
+}
+
+// PolicyViolation is an error describing a way in which a certificate request
+// fails to comply with a validation policy.
+type PolicyViolation struct {
+	Field  string
+	Reason string
+}
+
+// Error returns a string representation of the policy violation.
+func (v PolicyViolation) Error() string {
+	return fmt.Sprintf("%s: %s", v.Field, v.Reason)
+}
+
+// PolicyViolationsError is returned by CertificateRequestValidate if a
+// certificate request fails to comply with the validation policy. It matches
+// ErrPolicyViolation.
+type PolicyViolationsError struct {
+	Violations []error
+}
+
+// Error returns a string representation of the error.
+func (e PolicyViolationsError) Error() string {
+	var reasons = make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		reasons = append(reasons, v.Error())
+	}
+
+	return fmt.Sprintf("request does not comply with validation policy: %s", strings.Join(reasons, "; "))
+}
+
+// Is reports whether the error matches the target, which is the case if the
+// target is ErrPolicyViolation.
+func (e PolicyViolationsError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+// stringField is a string policy entry together with the corresponding value
+// from a certificate request.
+type stringField struct {
+	name   string
+	policy *StringPolicy
+	value  string
+}
+
+// listField is a list policy entry together with the corresponding values
+// from a certificate request.
+type listField struct {
+	name   string
+	policy *ListPolicy
+	values []string
+}
+
+// AllowedKeyTypes returns the public key types permitted by the policy, or
+// nil if the policy does not constrain the key type.
+func (p *Policy) AllowedKeyTypes() []KeyType {
+	if p.PublicKey == nil || !p.PublicKey.KeyType.isValid() {
+		return nil
+	}
+
+	return []KeyType{p.PublicKey.KeyType}
+}
+
+// SubjectDNConstraints returns the constraints imposed by the policy on the
+// subject distinguished name. Fields absent from the policy are not
+// constrained, and are omitted.
+func (p *Policy) SubjectDNConstraints() []Constraint {
+	var strs, lists = p.subjectDNFields(nil)
+
+	return constraints(strs, lists)
+}
+
+// SANConstraints returns the constraints imposed by the policy on the subject
+// alternative names. Fields absent from the policy are not constrained, and
+// are omitted.
+func (p *Policy) SANConstraints() []Constraint {
+	return constraints(nil, p.sanFields(nil))
+}
+
+// ValidityRange returns the minimum and maximum certificate validity
+// durations permitted by the policy. A zero minimum means there is no lower
+// bound, and a zero maximum means there is no upper bound.
+func (p *Policy) ValidityRange() (min, max time.Duration) {
+	if p.Validity == nil {
+		return 0, 0
+	}
+
+	if p.Validity.SecondsMin > 0 {
+		min = time.Duration(p.Validity.SecondsMin) * time.Second
+	}
+
+	if p.Validity.SecondsMax > 0 {
+		max = time.Duration(p.Validity.SecondsMax) * time.Second
+	}
+
+	return min, max
+}
+
+// AllowsCustomSANs reports whether the policy permits a certificate request
+// to include subject alternative names chosen by the requester, which is the
+// case if the policy does not constrain subject alternative names, or if at
+// least one subject alternative name field is not static.
+func (p *Policy) AllowsCustomSANs() bool {
+	if p.SAN == nil {
+		return true
+	}
+
+	for _, field := range p.sanFields(nil) {
+		if !field.policy.Static {
+			return true
+		}
+	}
+
+	for _, other := range p.SAN.OtherNames {
+		if !other.Static {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequiredSubjectFields returns the names of the subject distinguished name
+// fields which the policy requires a certificate request to include, for
+// example "subject_dn.common_name", or nil if no fields are required.
+func (p *Policy) RequiredSubjectFields() []string {
+	var result []string
+
+	var strs, lists = p.subjectDNFields(nil)
+	for _, field := range strs {
+		if field.policy.Presence == Required {
+			result = append(result, field.name)
+		}
+	}
+
+	for _, field := range lists {
+		if field.policy.MinCount > 0 {
+			result = append(result, field.name)
+		}
+	}
+
+	return result
+}
+
+// Validate checks a certificate request against the validity, subject
+// distinguished name, subject alternative names, key usages, extended key
+// usages, public key, signature and custom extensions sections of the policy,
+// and returns every violation found, or nil if none were found. Each returned
+// error is a PolicyViolation. Fields absent from the policy are not
+// constrained, except that if the policy lists any custom extensions, other
+// custom extensions are not permitted. Since HVCA is the final
+// arbiter of whether a request complies with its policy, a request which
+// passes this check may still be rejected.
+func (p *Policy) Validate(req *Request) []error {
+	var errs []error
+
+	errs = append(errs, p.validateValidity(req.Validity)...)
+
+	var strs, lists = p.subjectDNFields(req.Subject)
+	for _, field := range strs {
+		errs = append(errs, field.validate()...)
+	}
+
+	lists = append(lists, p.sanFields(req.SAN)...)
+
+	for _, field := range lists {
+		errs = append(errs, field.validate()...)
+	}
+
+	errs = append(errs, p.validateUsages(req)...)
+
+	errs = append(errs, p.validatePublicKey(req)...)
+
+	errs = append(errs, p.validateSignature(req)...)
+
+	errs = append(errs, p.validateCustomExtensions(req.CustomExtensions)...)
+
+	return errs
+}
+
+// subjectDNFields returns the subject distinguished name fields in the policy
+// together with the corresponding values from the specified distinguished
+// name, which may be nil.
+func (p *Policy) subjectDNFields(dn *DN) ([]stringField, []listField) {
+	if p.SubjectDN == nil {
+		return nil, nil
+	}
+
+	if dn == nil {
+		dn = &DN{}
+	}
+
+	// Fields which may also be requested by OID are looked for in the extra
+	// attributes if the corresponding field in DN is empty.
+	var extra = func(value string, oid []int) string {
+		if value != "" {
+			return value
+		}
+
+		for _, attr := range dn.ExtraAttributes {
+			if attr.OID.Equal(oid) {
+				return attr.Value
+			}
+		}
+
+		return ""
+	}
+
+	var pol = p.SubjectDN
+	var strs []stringField
+
+	for _, field := range []stringField{
+		{"subject_dn.common_name", pol.CommonName, dn.CommonName},
+		{"subject_dn.given_name", pol.GivenName, extra(dn.GivenName, oids.OIDSubjectGivenName)},
+		{"subject_dn.surname", pol.Surname, extra(dn.Surname, oids.OIDSubjectSurname)},
+		{"subject_dn.organization", pol.Organization, dn.Organization},
+		{"subject_dn.organization_identifier", pol.OrganizationalIdentifier, extra(dn.OrganizationIdentifier, oids.OIDSubjectOrganizationIdentifier)},
+		{"subject_dn.country", pol.Country, dn.Country},
+		{"subject_dn.state", pol.State, dn.State},
+		{"subject_dn.locality", pol.Locality, dn.Locality},
+		{"subject_dn.street_address", pol.StreetAddress, dn.StreetAddress},
+		{"subject_dn.postal_code", pol.PostalCode, extra(dn.PostalCode, oids.OIDSubjectPostalCode)},
+		{"subject_dn.email", pol.Email, dn.Email},
+		{"subject_dn.jurisdiction_of_incorporation_locality_name", pol.JOILocality, dn.JOILocality},
+		{"subject_dn.jurisdiction_of_incorporation_state_or_province_name", pol.JOIState, dn.JOIState},
+		{"subject_dn.jurisdiction_of_incorporation_country_name", pol.JOICountry, dn.JOICountry},
+		{"subject_dn.business_category", pol.BusinessCategory, dn.BusinessCategory},
+		{"subject_dn.serial_number", pol.SerialNumber, dn.SerialNumber},
+	} {
+		if field.policy != nil {
+			strs = append(strs, field)
+		}
+	}
+
+	var lists []listField
+	if pol.OrganizationalUnit != nil {
+		lists = append(lists, listField{"subject_dn.organizational_unit", pol.OrganizationalUnit, dn.OrganizationalUnit})
+	}
+
+	return strs, lists
+}
+
+// sanFields returns the subject alternative name fields in the policy together
+// with the corresponding values from the specified subject alternative names,
+// which may be nil.
+func (p *Policy) sanFields(san *SAN) []listField {
+	if p.SAN == nil {
+		return nil
+	}
+
+	if san == nil {
+		san = &SAN{}
+	}
+
+	var ips = make([]string, 0, len(san.IPAddresses))
+	for _, ip := range san.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+
+	var uris = make([]string, 0, len(san.URIs))
+	for _, uri := range san.URIs {
+		uris = append(uris, uri.String())
+	}
+
+	var lists []listField
+
+	for _, field := range []listField{
+		{"san.dns_names", p.SAN.DNSNames, san.DNSNames},
+		{"san.emails", p.SAN.Emails, san.Emails},
+		{"san.ip_addresses", p.SAN.IPAddresses, ips},
+		{"san.uris", p.SAN.URIs, uris},
+	} {
+		if field.policy != nil {
+			lists = append(lists, field)
+		}
+	}
+
+	return lists
+}
+
+// validateValidity checks the requested validity period against the policy.
+func (p *Policy) validateValidity(v *Validity) []error {
+	if v == nil {
+		return nil
+	}
+
+	if err := v.validate(); err != nil {
+		return []error{PolicyViolation{"validity", err.Error()}}
+	}
+
+	// A not-after time of the UNIX epoch requests the maximum duration
+	// allowed by the policy, so it always complies.
+	if p.Validity == nil || v.maximumDuration() {
+		return nil
+	}
+
+	var secs = v.seconds()
+
+	switch {
+	case secs < p.Validity.SecondsMin:
+		return []error{PolicyViolation{"validity", fmt.Sprintf("duration of %d seconds is less than minimum of %d", secs, p.Validity.SecondsMin)}}
+
+	case p.Validity.SecondsMax > 0 && secs > p.Validity.SecondsMax:
+		return []error{PolicyViolation{"validity", fmt.Sprintf("duration of %d seconds is greater than maximum of %d", secs, p.Validity.SecondsMax)}}
+	}
+
+	return nil
+}
+
+// validateCustomExtensions checks the custom extensions in a request against
+// the policy. Values are checked in the same way as string fields, using the
+// value format of the extension, except that extensions with a value type of
+// NIL must have an empty value.
+func (p *Policy) validateCustomExtensions(exts []OIDAndString) []error {
+	if len(p.CustomExtensions) == 0 {
+		return nil
+	}
+
+	var errs []error
+	var requested = make(map[string]string, len(exts))
+
+	for _, ext := range exts {
+		var name = "custom_extensions." + ext.OID.String()
+
+		var permitted bool
+		for _, pol := range p.CustomExtensions {
+			if pol.OID.Equal(ext.OID) {
+				permitted = true
+				break
+			}
+		}
+
+		if !permitted {
+			errs = append(errs, PolicyViolation{name, "extension is not permitted by policy"})
+			continue
+		}
+
+		requested[ext.OID.String()] = ext.Value
+	}
+
+	for _, pol := range p.CustomExtensions {
+		var name = "custom_extensions." + pol.OID.String()
+		var value, present = requested[pol.OID.String()]
+
+		if pol.ValueType == Nil {
+			switch {
+			case pol.Presence == Required && !present:
+				errs = append(errs, PolicyViolation{name, "extension is required"})
+
+			case pol.Presence == Forbidden && present:
+				errs = append(errs, PolicyViolation{name, "extension is forbidden"})
+
+			case value != "":
+				errs = append(errs, PolicyViolation{name, "value must be empty for value type NIL"})
+			}
+
+			continue
+		}
+
+		if pol.Presence == Forbidden && present && value == "" {
+			errs = append(errs, PolicyViolation{name, "extension is forbidden"})
+			continue
+		}
+
+		// The value format of a custom extension is always a regular
+		// expression, even if its presence is static.
+		var presence = pol.Presence
+		if presence == Static {
+			presence = Optional
+		}
+
+		errs = append(errs, stringField{
+			name:   name,
+			policy: &StringPolicy{Presence: presence, Format: pol.ValueFormat},
+			value:  value,
+		}.validate()...)
+	}
+
+	return errs
+}
+
+// validateUsages checks the key usages and extended key usages in a request
+// against the policy. Violations by standard extended key usages name the
+// usage as well as its OID.
+func (p *Policy) validateUsages(req *Request) []error {
+	var errs []error
+
+	if p.KeyUsages != nil {
+		var names, err = keyUsageStrings(req.KeyUsages)
+		if err != nil {
+			errs = append(errs, PolicyViolation{"key_usages", err.Error()})
+		} else {
+			errs = append(errs, listField{"key_usages", p.KeyUsages, names}.validate()...)
+		}
+	}
+
+	if p.EKUs != nil {
+		var oidList, err = req.allEKUs()
+		if err != nil {
+			return append(errs, PolicyViolation{"extended_key_usages", err.Error()})
+		}
+
+		var ekus = make([]string, 0, len(oidList))
+		var names = make(map[string]string)
+		for _, oid := range oidList {
+			ekus = append(ekus, oid.String())
+			names[oid.String()] = extKeyUsageName(oid)
+		}
+
+		errs = append(errs, listField{"extended_key_usages", &p.EKUs.EKUs, ekus}.validateNamed(names)...)
+	}
+
+	return errs
+}
+
+// validatePublicKey checks the public key in a request against the policy.
+func (p *Policy) validatePublicKey(req *Request) []error {
+	var errs []error
+
+	if p.PublicKey != nil {
+		var keyType, bits, ok = publicKeyTypeAndSize(requestPublicKey(req))
+
+		switch {
+		case !ok:
+			errs = append(errs, PolicyViolation{"public_key", "no public key, or unsupported public key type or curve"})
+
+		case p.PublicKey.KeyType.isValid() && keyType != p.PublicKey.KeyType:
+			errs = append(errs, PolicyViolation{"public_key", fmt.Sprintf("key type %s is not the required type %s", keyType, p.PublicKey.KeyType)})
+
+		case len(p.PublicKey.AllowedLengths) > 0 && !containsInt(p.PublicKey.AllowedLengths, bits):
+			errs = append(errs, PolicyViolation{"public_key", fmt.Sprintf("key length %d is not one of %v", bits, p.PublicKey.AllowedLengths)})
+		}
+
+		switch {
+		case p.PublicKey.KeyFormat == PKCS10 && req.CSR == nil:
+			errs = append(errs, PolicyViolation{"public_key", "policy requires a PKCS#10 certificate signing request"})
+
+		case p.PublicKey.KeyFormat == PKCS8 && req.CSR != nil:
+			errs = append(errs, PolicyViolation{"public_key", "policy requires a PKCS#8 public key, but certificate signing request provided"})
+		}
+	}
+
+	// A public key signature can only be generated if a private key is
+	// provided. It is not applicable when a CSR is provided.
+	if req.CSR == nil {
+		switch p.PublicKeySignature {
+		case Required:
+			if req.PrivateKey == nil {
+				errs = append(errs, PolicyViolation{"public_key_signature", "policy requires a public key signature, but no private key provided"})
+			}
+
+		case Forbidden:
+			if req.PrivateKey != nil {
+				errs = append(errs, PolicyViolation{"public_key_signature", "policy forbids a public key signature, but private key provided"})
+			}
+		}
+	}
+
+	return errs
+}
+
+// validate checks a string value against its policy.
+func (f stringField) validate() []error {
+	switch f.policy.Presence {
+	case Required:
+		if f.value == "" {
+			return []error{PolicyViolation{f.name, "value is required"}}
+		}
+
+	case Forbidden:
+		if f.value != "" {
+			return []error{PolicyViolation{f.name, "value is forbidden"}}
+		}
+
+		return nil
+
+	case Static:
+		if f.value != "" && f.value != f.policy.Format {
+			return []error{PolicyViolation{f.name, fmt.Sprintf("value must be %q", f.policy.Format)}}
+		}
+
+		return nil
+	}
+
+	if f.value == "" || f.policy.Format == "" {
+		return nil
+	}
+
+	var re, err = regexp.Compile(f.policy.Format)
+	if err != nil {
+		return []error{PolicyViolation{f.name, fmt.Sprintf("invalid format in policy: %v", err)}}
+	}
+
+	if !re.MatchString(f.value) {
+		return []error{PolicyViolation{f.name, fmt.Sprintf("value %q does not match format %q", f.value, f.policy.Format)}}
+	}
+
+	return nil
+}
+
+// validate checks a list of values against its policy.
+func (f listField) validate() []error {
+	return f.validateNamed(nil)
+}
+
+// validateNamed checks a list of values against its policy. If a value which
+// is not permitted has an entry in the names map, the name is included in the
+// violation.
+func (f listField) validateNamed(names map[string]string) []error {
+	var errs []error
+
+	if len(f.values) < f.policy.MinCount {
+		errs = append(errs, PolicyViolation{f.name, fmt.Sprintf("got %d values, minimum is %d", len(f.values), f.policy.MinCount)})
+	}
+
+	if f.policy.MaxCount > 0 && len(f.values) > f.policy.MaxCount {
+		errs = append(errs, PolicyViolation{f.name, fmt.Sprintf("got %d values, maximum is %d", len(f.values), f.policy.MaxCount)})
+	}
+
+	if len(f.policy.List) == 0 {
+		return errs
+	}
+
+	// Compile the patterns once for all values.
+	var patterns []*regexp.Regexp
+	if !f.policy.Static {
+		for _, s := range f.policy.List {
+			var re, err = regexp.Compile(s)
+			if err != nil {
+				return append(errs, PolicyViolation{f.name, fmt.Sprintf("invalid format in policy: %v", err)})
+			}
+
+			patterns = append(patterns, re)
+		}
+	}
+
+	for _, value := range f.values {
+		var ok bool
+
+		if f.policy.Static {
+			ok = containsString(f.policy.List, value)
+		} else {
+			for _, re := range patterns {
+				if re.MatchString(value) {
+					ok = true
+					break
+				}
+			}
+		}
+
+		if !ok {
+			if name := names[value]; name != "" {
+				errs = append(errs, PolicyViolation{f.name, fmt.Sprintf("value %q (%s) is not permitted", value, name)})
+			} else {
+				errs = append(errs, PolicyViolation{f.name, fmt.Sprintf("value %q is not permitted", value)})
+			}
+		}
+	}
+
+	return errs
+}
+
+// constraints converts policy fields into constraints.
+func constraints(strs []stringField, lists []listField) []Constraint {
+	var result = make([]Constraint, 0, len(strs)+len(lists))
+
+	for _, field := range strs {
+		result = append(result, Constraint{
+			Field:    field.name,
+			Presence: field.policy.Presence,
+			Format:   field.policy.Format,
+		})
+	}
+
+	for _, field := range lists {
+		result = append(result, Constraint{
+			Field:    field.name,
+			Allowed:  field.policy.List,
+			Static:   field.policy.Static,
+			MinCount: field.policy.MinCount,
+			MaxCount: field.policy.MaxCount,
+		})
+	}
+
+	return result
+}
+
+// requestPublicKey returns the public key in a request, whether provided
+// directly, or via a private key or a PKCS#10 certificate signing request.
+func requestPublicKey(req *Request) interface{} {
+	switch {
+	case req.PublicKey != nil:
+		return req.PublicKey
+
+	case req.PrivateKey != nil:
+		if signer, ok := req.PrivateKey.(crypto.Signer); ok {
+			return signer.Public()
+		}
+
+	case req.CSR != nil:
+		return req.CSR.PublicKey
+	}
+
+	return nil
+}
+
+// publicKeyTypeAndSize returns the type and size in bits of a public key.
+// For an ECDSA key, the size is that of the curve. It returns false for an
+// unsupported key type, or an ECDSA key on a curve other than one of the
+// NIST curves P-256, P-384 and P-521.
+func publicKeyTypeAndSize(key interface{}) (KeyType, int, bool) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return RSA, k.N.BitLen(), true
+	case rsa.PublicKey:
+		return RSA, k.N.BitLen(), true
+	case *ecdsa.PublicKey:
+		return ECDSA, k.Curve.Params().BitSize, isSupportedCurve(k.Curve)
+	case ecdsa.PublicKey:
+		return ECDSA, k.Curve.Params().BitSize, isSupportedCurve(k.Curve)
+	case ed25519.PublicKey:
+		return ED25519, len(k) * 8, len(k) == ed25519.PublicKeySize
+	}
+
+	return 0, 0, false
+}
+
+// isSupportedCurve returns true if an elliptic curve is one of the NIST
+// curves which may be encoded in a certificate request.
+func isSupportedCurve(curve elliptic.Curve) bool {
+	switch curve {
+	case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		return true
+	}
+
+	return false
+}
+
+// containsInt returns true if a slice contains the specified value.
+func containsInt(s []int, n int) bool {
+	for _, v := range s {
+		if v == n {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsString returns true if a slice contains the specified value.
+func containsString(s []string, str string) bool {
+	for _, v := range s {
+		if v == str {
+			return true
+		}
+	}
+
+	return false
+}
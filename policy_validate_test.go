@@ -0,0 +1,704 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vsglobalsign/hvclient"
+	"github.com/vsglobalsign/hvclient/internal/testhelpers"
+)
+
+func TestPolicyAllowedKeyTypes(t *testing.T) {
+	t.Parallel()
+
+	if got, want := testFullPolicy.AllowedKeyTypes(), []hvclient.KeyType{hvclient.RSA}; !cmp.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got := (&hvclient.Policy{}).AllowedKeyTypes(); got != nil {
+		t.Errorf("got %v, want nil", got)
+	}
+}
+
+func TestPolicyMetadata(t *testing.T) {
+	t.Parallel()
+
+	var min, max = testFullPolicy.ValidityRange()
+	if min != time.Hour || max != 24*time.Hour {
+		t.Errorf("got validity range (%v, %v), want (%v, %v)", min, max, time.Hour, 24*time.Hour)
+	}
+
+	if !testFullPolicy.AllowsCustomSANs() {
+		t.Errorf("got false, want true")
+	}
+
+	if got, want := testFullPolicy.RequiredSubjectFields(), []string{
+		"subject_dn.common_name",
+		"subject_dn.organizational_unit",
+	}; !cmp.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// An empty policy imposes no constraints.
+	var empty = &hvclient.Policy{}
+
+	if min, max = empty.ValidityRange(); min != 0 || max != 0 {
+		t.Errorf("got validity range (%v, %v), want (0, 0)", min, max)
+	}
+
+	if !empty.AllowsCustomSANs() {
+		t.Errorf("got false, want true")
+	}
+
+	if got := empty.RequiredSubjectFields(); got != nil {
+		t.Errorf("got %v, want nil", got)
+	}
+
+	var static = &hvclient.Policy{
+		SAN: &hvclient.SANPolicy{
+			DNSNames: &hvclient.ListPolicy{Static: true, List: []string{"example.com"}},
+		},
+	}
+
+	if static.AllowsCustomSANs() {
+		t.Errorf("got true, want false")
+	}
+}
+
+func TestPolicyConstraints(t *testing.T) {
+	t.Parallel()
+
+	var dn = testFullPolicy.SubjectDNConstraints()
+	if len(dn) != 17 {
+		t.Fatalf("got %d subject DN constraints, want 17", len(dn))
+	}
+
+	if got, want := dn[0], (hvclient.Constraint{
+		Field:    "subject_dn.common_name",
+		Presence: hvclient.Required,
+		Format:   "^[A-Za-z][A-Za-z -]+$",
+	}); !cmp.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got, want := dn[len(dn)-1], (hvclient.Constraint{
+		Field:    "subject_dn.organizational_unit",
+		Allowed:  []string{"^[A-Za-z][A-Za-z \\-]+$"},
+		MinCount: 1,
+		MaxCount: 3,
+	}); !cmp.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	var san = testFullPolicy.SANConstraints()
+	if len(san) != 4 {
+		t.Fatalf("got %d SAN constraints, want 4", len(san))
+	}
+
+	if got, want := san[1], (hvclient.Constraint{
+		Field:    "san.emails",
+		Allowed:  []string{"^\\w[-._\\w]*\\w@\\w[-._\\w]*\\w.\\w{2,3}$"},
+		MaxCount: 1,
+	}); !cmp.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got := (&hvclient.Policy{}).SubjectDNConstraints(); len(got) != 0 {
+		t.Errorf("got %v, want no constraints", got)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	t.Parallel()
+
+	var notBefore = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// validRequest returns a request which complies with the full test policy.
+	var validRequest = func() *hvclient.Request {
+		return &hvclient.Request{
+			Validity: &hvclient.Validity{
+				NotBefore: notBefore,
+				NotAfter:  notBefore.Add(time.Hour * 2),
+			},
+			Subject: &hvclient.DN{
+				CommonName:         "John Doe",
+				Organization:       "GMO GlobalSign",
+				OrganizationalUnit: []string{"Operations"},
+				Country:            "GB",
+			},
+			SAN: &hvclient.SAN{
+				Emails: []string{"john.doe@example.com"},
+			},
+			EKUs:       []asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 2}},
+			PrivateKey: testhelpers.MustGetPrivateKeyFromFile(t, "testdata/rsa_priv.key"),
+		}
+	}
+
+	var testcases = []struct {
+		name   string
+		modify func(r *hvclient.Request)
+		want   []string
+	}{
+		{
+			name:   "Valid",
+			modify: func(r *hvclient.Request) {},
+		},
+		{
+			name: "MaximumValidity",
+			modify: func(r *hvclient.Request) {
+				r.Validity.NotAfter = time.Unix(0, 0)
+			},
+		},
+		{
+			name: "ValidityTooShort",
+			modify: func(r *hvclient.Request) {
+				r.Validity.NotAfter = notBefore.Add(time.Minute)
+			},
+			want: []string{"validity"},
+		},
+		{
+			name: "ValidityNotAfterBeforeNotBefore",
+			modify: func(r *hvclient.Request) {
+				r.Validity.NotAfter = notBefore.Add(-time.Hour)
+			},
+			want: []string{"validity"},
+		},
+		{
+			name: "ValidityZeroNotBefore",
+			modify: func(r *hvclient.Request) {
+				r.Validity = &hvclient.Validity{NotAfter: time.Now().Add(time.Hour * 2)}
+			},
+		},
+		{
+			name: "ValidityTooLong",
+			modify: func(r *hvclient.Request) {
+				r.Validity.NotAfter = notBefore.Add(time.Hour * 48)
+			},
+			want: []string{"validity"},
+		},
+		{
+			name: "ValidityDuration",
+			modify: func(r *hvclient.Request) {
+				r.Validity = &hvclient.Validity{Duration: time.Hour * 2}
+			},
+		},
+		{
+			name: "ValidityDurationTooLong",
+			modify: func(r *hvclient.Request) {
+				r.Validity = &hvclient.Validity{Duration: time.Hour * 48}
+			},
+			want: []string{"validity"},
+		},
+		{
+			name: "ValidityDurationWithNotAfter",
+			modify: func(r *hvclient.Request) {
+				r.Validity.Duration = time.Hour
+			},
+			want: []string{"validity"},
+		},
+		{
+			name: "MissingRequired",
+			modify: func(r *hvclient.Request) {
+				r.Subject.CommonName = ""
+			},
+			want: []string{"subject_dn.common_name"},
+		},
+		{
+			name: "BadFormat",
+			modify: func(r *hvclient.Request) {
+				r.Subject.CommonName = "1234"
+			},
+			want: []string{"subject_dn.common_name"},
+		},
+		{
+			name: "WrongStatic",
+			modify: func(r *hvclient.Request) {
+				r.Subject.Organization = "ACME Inc"
+				r.Subject.Country = "US"
+			},
+			want: []string{"subject_dn.country", "subject_dn.organization"},
+		},
+		{
+			name: "Forbidden",
+			modify: func(r *hvclient.Request) {
+				r.Subject.Email = "john.doe@example.com"
+				r.Subject.SerialNumber = "1234"
+			},
+			want: []string{"subject_dn.email", "subject_dn.serial_number"},
+		},
+		{
+			name: "ExtraAttribute",
+			modify: func(r *hvclient.Request) {
+				r.Subject.ExtraAttributes = []hvclient.OIDAndString{
+					{OID: asn1.ObjectIdentifier{2, 5, 4, 17}, Value: "ABCDE"},
+				}
+			},
+			want: []string{"subject_dn.postal_code"},
+		},
+		{
+			name: "PostalCodeField",
+			modify: func(r *hvclient.Request) {
+				r.Subject.PostalCode = "ABCDE"
+			},
+			want: []string{"subject_dn.postal_code"},
+		},
+		{
+			name: "ListTooFew",
+			modify: func(r *hvclient.Request) {
+				r.Subject.OrganizationalUnit = nil
+			},
+			want: []string{"subject_dn.organizational_unit"},
+		},
+		{
+			name: "ListTooMany",
+			modify: func(r *hvclient.Request) {
+				r.Subject.OrganizationalUnit = []string{"One", "Two", "Three", "Four"}
+			},
+			want: []string{"subject_dn.organizational_unit"},
+		},
+		{
+			name: "ListBadValue",
+			modify: func(r *hvclient.Request) {
+				r.SAN.Emails = []string{"not an email"}
+			},
+			want: []string{"san.emails"},
+		},
+		{
+			name: "BadEKU",
+			modify: func(r *hvclient.Request) {
+				r.EKUs = []asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 4}}
+			},
+			want: []string{"extended_key_usages"},
+		},
+		{
+			name: "StandardEKU",
+			modify: func(r *hvclient.Request) {
+				r.EKUs = nil
+				r.ExtendedKeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+			},
+		},
+		{
+			name: "BadStandardEKU",
+			modify: func(r *hvclient.Request) {
+				r.ExtendedKeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection}
+			},
+			want: []string{"extended_key_usages"},
+		},
+		{
+			name: "WrongKeyType",
+			modify: func(r *hvclient.Request) {
+				r.PrivateKey = testhelpers.MustGetPrivateKeyFromFile(t, "testdata/ec_priv.key")
+			},
+			want: []string{"public_key"},
+		},
+		{
+			name: "NoPrivateKey",
+			modify: func(r *hvclient.Request) {
+				r.PrivateKey = nil
+				r.PublicKey = testhelpers.MustGetPublicKeyFromFile(t, "testdata/rsa_pub.key")
+			},
+			want: []string{"public_key_signature"},
+		},
+		{
+			name: "NoKey",
+			modify: func(r *hvclient.Request) {
+				r.PrivateKey = nil
+			},
+			want: []string{"public_key", "public_key_signature"},
+		},
+		{
+			name: "CSRWithPKCS8",
+			modify: func(r *hvclient.Request) {
+				r.PrivateKey = nil
+				r.CSR = testhelpers.MustGetCSRFromFile(t, "testdata/test_csr.pem")
+			},
+			// The test CSR also contains an ECDSA key.
+			want: []string{"public_key", "public_key"},
+		},
+		{
+			name: "Multiple",
+			modify: func(r *hvclient.Request) {
+				r.Subject = nil
+				r.SAN = nil
+				r.EKUs = nil
+			},
+			want: []string{"extended_key_usages", "subject_dn.common_name", "subject_dn.organizational_unit"},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var req = validRequest()
+			tc.modify(req)
+
+			var got []string
+			for _, err := range testFullPolicy.Validate(req) {
+				var violation hvclient.PolicyViolation
+				if !errors.As(err, &violation) {
+					t.Fatalf("got error of type %T, want PolicyViolation", err)
+				}
+
+				got = append(got, violation.Field)
+			}
+
+			sort.Strings(got)
+
+			if !cmp.Equal(got, tc.want) {
+				t.Errorf("got violations %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPolicyValidateUsages(t *testing.T) {
+	t.Parallel()
+
+	var policy = hvclient.Policy{
+		KeyUsages: &hvclient.ListPolicy{
+			Static: true,
+			List:   []string{"digital_signature", "key_encipherment"},
+		},
+		EKUs: &hvclient.EKUPolicy{
+			EKUs: hvclient.ListPolicy{
+				Static: true,
+				List:   []string{"1.3.6.1.5.5.7.3.1", "1.3.6.1.5.5.7.3.2"},
+			},
+		},
+	}
+
+	var testcases = []struct {
+		name string
+		req  hvclient.Request
+		want []string
+	}{
+		{
+			name: "Valid",
+			req: hvclient.Request{
+				KeyUsages:         x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+				ExtendedKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+				EKUs:              []asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 2}},
+			},
+		},
+		{
+			name: "KeyUsageForbidden",
+			req: hvclient.Request{
+				KeyUsages: x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			},
+			want: []string{`key_usages: value "key_cert_sign" is not permitted`},
+		},
+		{
+			name: "StandardEKUForbidden",
+			req: hvclient.Request{
+				ExtendedKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageOCSPSigning},
+			},
+			want: []string{`extended_key_usages: value "1.3.6.1.5.5.7.3.9" (OCSP signing) is not permitted`},
+		},
+		{
+			name: "CustomEKUForbidden",
+			req: hvclient.Request{
+				EKUs: []asn1.ObjectIdentifier{{1, 2, 3, 4}},
+			},
+			want: []string{`extended_key_usages: value "1.2.3.4" is not permitted`},
+		},
+		{
+			name: "UnknownEKU",
+			req: hvclient.Request{
+				ExtendedKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsage(999)},
+			},
+			want: []string{"extended_key_usages: unsupported extended key usage: 999"},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			for _, err := range policy.Validate(&tc.req) {
+				got = append(got, err.Error())
+			}
+
+			if !cmp.Equal(got, tc.want) {
+				t.Errorf("got violations %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPolicyValidatePublicKeyTypes(t *testing.T) {
+	t.Parallel()
+
+	var mustGenerateEC = func(curve elliptic.Curve) crypto.PublicKey {
+		var key, err = ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+
+		return key.Public()
+	}
+
+	var edKey, _, err = ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var testcases = []struct {
+		name    string
+		policy  hvclient.PublicKeyPolicy
+		key     crypto.PublicKey
+		invalid bool
+	}{
+		{
+			name:   "P256",
+			policy: hvclient.PublicKeyPolicy{KeyType: hvclient.ECDSA, AllowedLengths: []int{256, 384}},
+			key:    mustGenerateEC(elliptic.P256()),
+		},
+		{
+			name:   "P384",
+			policy: hvclient.PublicKeyPolicy{KeyType: hvclient.ECDSA, AllowedLengths: []int{256, 384}},
+			key:    mustGenerateEC(elliptic.P384()),
+		},
+		{
+			name:    "P521NotAllowed",
+			policy:  hvclient.PublicKeyPolicy{KeyType: hvclient.ECDSA, AllowedLengths: []int{256, 384}},
+			key:     mustGenerateEC(elliptic.P521()),
+			invalid: true,
+		},
+		{
+			name:    "UnsupportedCurve",
+			policy:  hvclient.PublicKeyPolicy{KeyType: hvclient.ECDSA},
+			key:     mustGenerateEC(elliptic.P224()),
+			invalid: true,
+		},
+		{
+			name:   "Ed25519",
+			policy: hvclient.PublicKeyPolicy{KeyType: hvclient.ED25519},
+			key:    edKey,
+		},
+		{
+			name:    "Ed25519NotAllowed",
+			policy:  hvclient.PublicKeyPolicy{KeyType: hvclient.ECDSA},
+			key:     edKey,
+			invalid: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var policy = hvclient.Policy{PublicKey: &tc.policy}
+			var errs = policy.Validate(&hvclient.Request{PublicKey: tc.key})
+
+			if (len(errs) != 0) != tc.invalid {
+				t.Errorf("got violations %v, want invalid %t", errs, tc.invalid)
+			}
+		})
+	}
+}
+
+func TestPolicyValidateSignature(t *testing.T) {
+	t.Parallel()
+
+	var policy = hvclient.Policy{
+		SignaturePolicy: &hvclient.SignaturePolicy{
+			Algorithm: &hvclient.AlgorithmPolicy{
+				Presence: hvclient.Optional,
+				List:     []string{"RSA-PSS", "ECDSA"},
+			},
+			HashAlgorithm: &hvclient.AlgorithmPolicy{
+				Presence: hvclient.Required,
+				List:     []string{"SHA-384", "SHA-512"},
+			},
+		},
+	}
+
+	var testcases = []struct {
+		name string
+		req  hvclient.Request
+		want []string
+	}{
+		{
+			name: "RSAPSS",
+			req:  hvclient.Request{SignatureAlgorithm: x509.SHA384WithRSAPSS},
+		},
+		{
+			name: "ECDSA",
+			req:  hvclient.Request{SignatureAlgorithm: x509.ECDSAWithSHA512},
+		},
+		{
+			name: "Signature",
+			req: hvclient.Request{
+				Signature: &hvclient.Signature{Algorithm: "ECDSA", HashAlgorithm: "SHA-384"},
+			},
+		},
+		{
+			name: "AlgorithmNotPermitted",
+			req:  hvclient.Request{SignatureAlgorithm: x509.SHA384WithRSA},
+			want: []string{`signature.algorithm: value "RSA" is not permitted (allowed: RSA-PSS, ECDSA)`},
+		},
+		{
+			name: "HashNotPermitted",
+			req:  hvclient.Request{SignatureAlgorithm: x509.ECDSAWithSHA256},
+			want: []string{`signature.hash_algorithm: value "SHA-256" is not permitted (allowed: SHA-384, SHA-512)`},
+		},
+		{
+			name: "Unset",
+			req:  hvclient.Request{},
+		},
+		{
+			name: "Unsupported",
+			req:  hvclient.Request{SignatureAlgorithm: x509.PureEd25519},
+			want: []string{"signature: unsupported signature algorithm: Ed25519"},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			for _, err := range policy.Validate(&tc.req) {
+				got = append(got, err.Error())
+			}
+
+			if !cmp.Equal(got, tc.want) {
+				t.Errorf("got violations %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPolicyValidateCustomExtensions(t *testing.T) {
+	t.Parallel()
+
+	var policy = hvclient.Policy{
+		CustomExtensions: []hvclient.CustomExtensionsPolicy{
+			{
+				OID:         asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1},
+				Presence:    hvclient.Required,
+				ValueType:   hvclient.DER,
+				ValueFormat: "^([A-Fa-f0-9]{2})+$",
+			},
+			{
+				OID:       asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 2},
+				Presence:  hvclient.Optional,
+				ValueType: hvclient.Nil,
+			},
+			{
+				OID:       asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 3},
+				Presence:  hvclient.Forbidden,
+				Critical:  true,
+				ValueType: hvclient.UTF8String,
+			},
+		},
+	}
+
+	var testcases = []struct {
+		name string
+		exts []hvclient.OIDAndString
+		want []string
+	}{
+		{
+			name: "OK",
+			exts: []hvclient.OIDAndString{
+				{OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}, Value: "0c03414243"},
+				{OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 2}},
+			},
+		},
+		{
+			name: "Missing",
+			want: []string{"custom_extensions.1.3.6.1.4.1.99999.1: value is required"},
+		},
+		{
+			name: "BadFormat",
+			exts: []hvclient.OIDAndString{
+				{OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}, Value: "device-1234"},
+			},
+			want: []string{`custom_extensions.1.3.6.1.4.1.99999.1: value "device-1234" does not match format "^([A-Fa-f0-9]{2})+$"`},
+		},
+		{
+			name: "NilWithValue",
+			exts: []hvclient.OIDAndString{
+				{OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}, Value: "0500"},
+				{OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 2}, Value: "0500"},
+			},
+			want: []string{"custom_extensions.1.3.6.1.4.1.99999.2: value must be empty for value type NIL"},
+		},
+		{
+			name: "Forbidden",
+			exts: []hvclient.OIDAndString{
+				{OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}, Value: "0500"},
+				{OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 3}, Value: "secret"},
+			},
+			want: []string{"custom_extensions.1.3.6.1.4.1.99999.3: value is forbidden"},
+		},
+		{
+			name: "NotPermitted",
+			exts: []hvclient.OIDAndString{
+				{OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}, Value: "0500"},
+				{OID: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: "0500"},
+			},
+			want: []string{"custom_extensions.1.2.3.4: extension is not permitted by policy"},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			for _, err := range policy.Validate(&hvclient.Request{CustomExtensions: tc.exts}) {
+				got = append(got, err.Error())
+			}
+
+			if !cmp.Equal(got, tc.want) {
+				t.Errorf("got violations %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// A policy which lists no custom extensions does not constrain them.
+	if errs := (&hvclient.Policy{}).Validate(&hvclient.Request{
+		CustomExtensions: []hvclient.OIDAndString{{OID: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: "x"}},
+	}); errs != nil {
+		t.Errorf("got violations %v, want none", errs)
+	}
+}
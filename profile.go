@@ -0,0 +1,118 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Profile is an issuance profile available to an HVCA account, which may be
+// selected for a certificate request with the Profile field of Request.
+type Profile struct {
+	ID      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+	Default bool   `json:"default,omitempty"`
+}
+
+// ErrUnknownProfile is returned when a certificate request selects a profile
+// which is not one of the profiles available to the account.
+var ErrUnknownProfile = errors.New("hvclient: unknown certificate profile")
+
+// defaultProfileCacheTTL is the time for which the list of issuance
+// profiles used to check the profile selected by a certificate request is
+// cached, if no policy cache TTL was specified in the configuration.
+const defaultProfileCacheTTL = time.Minute * 5
+
+// Profiles returns the issuance profiles available to the calling account.
+// The /profiles endpoint is not part of the documented HVCA API, and may not
+// be supported by every HVCA instance, in which case an error matching
+// ErrNotFound is returned.
+func (c *Client) Profiles(ctx context.Context) ([]Profile, error) {
+	var profiles []Profile
+	var _, err = c.makeRequest(
+		ctx,
+		endpointProfiles,
+		http.MethodGet,
+		nil,
+		&profiles,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// validateProfile checks that a profile selected for a certificate request
+// is available to the account. No check is made if no profile was selected,
+// or if HVCA does not support listing profiles, in which case HVCA itself
+// rejects an unknown profile. The list of profiles, or the fact that HVCA
+// does not support listing them, is cached so that a batch of requests does
+// not retrieve it for every request.
+func (c *Client) validateProfile(ctx context.Context, id string) error {
+	if id == "" {
+		return nil
+	}
+
+	var profiles, supported, err = c.cachedProfiles(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve profiles: %w", err)
+	} else if !supported {
+		return nil
+	}
+
+	for _, p := range profiles {
+		if p.ID == id {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %q", ErrUnknownProfile, id)
+}
+
+// cachedProfiles returns the issuance profiles available to the calling
+// account, and whether HVCA supports listing them, retrieving them from HVCA
+// only if the cached list has expired.
+func (c *Client) cachedProfiles(ctx context.Context) ([]Profile, bool, error) {
+	c.profilesMtx.Lock()
+	defer c.profilesMtx.Unlock()
+
+	var now = time.Now()
+	if now.Before(c.profilesExpiry) {
+		return c.profiles, c.profilesSupported, nil
+	}
+
+	var profiles, err = c.Profiles(ctx)
+	var supported = !errors.Is(err, ErrNotFound)
+	if err != nil && supported {
+		return nil, false, err
+	}
+
+	var ttl = c.Config.PolicyCacheTTL
+	if ttl <= 0 {
+		ttl = defaultProfileCacheTTL
+	}
+
+	c.profiles = profiles
+	c.profilesSupported = supported
+	c.profilesExpiry = now.Add(ttl)
+
+	return profiles, supported, nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+func TestCertificateRequestProfile(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		profile  string
+		profiles bool
+		err      error
+	}{
+		{
+			name: "NoProfile",
+		},
+		{
+			name:     "Known",
+			profile:  "tls-client",
+			profiles: true,
+		},
+		{
+			name:     "Unknown",
+			profile:  "code-signing",
+			profiles: true,
+			err:      ErrUnknownProfile,
+		},
+		{
+			name:    "ProfilesNotSupported",
+			profile: "code-signing",
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotProfile string
+			var profileCalls int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+				switch r.URL.Path {
+				case endpointLogin:
+					w.Write([]byte(`{"access_token":"token"}`))
+
+				case endpointProfiles:
+					atomic.AddInt32(&profileCalls, 1)
+
+					if !tc.profiles {
+						w.WriteHeader(http.StatusNotFound)
+						w.Write([]byte(`{"description":"not found"}`))
+						return
+					}
+
+					json.NewEncoder(w).Encode([]Profile{
+						{ID: "tls-server", Name: "TLS server", Default: true},
+						{ID: "tls-client", Name: "TLS client"},
+					})
+
+				case endpointCertificates:
+					var body struct {
+						Profile string `json:"profile"`
+					}
+					json.NewDecoder(r.Body).Decode(&body)
+					gotProfile = body.Profile
+
+					w.Header().Set(certSNHeaderName, endpointCertificates+"/0123")
+					w.WriteHeader(http.StatusCreated)
+
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+			// Make two requests, to check that the list of profiles is
+			// cached.
+			for i := 0; i < 2; i++ {
+				var _, err = clnt.CertificateRequest(context.Background(), &Request{
+					Profile: tc.profile,
+					Subject: &DN{CommonName: "John Doe"},
+				})
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("got error %v, want %v", err, tc.err)
+				}
+
+				if tc.err == nil && gotProfile != tc.profile {
+					t.Errorf("got profile %q, want %q", gotProfile, tc.profile)
+				}
+			}
+
+			var wantCalls int32
+			if tc.profile != "" {
+				wantCalls = 1
+			}
+
+			if got := atomic.LoadInt32(&profileCalls); got != wantCalls {
+				t.Errorf("got %d profile retrievals, want %d", got, wantCalls)
+			}
+		})
+	}
+}
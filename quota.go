@@ -0,0 +1,212 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// Quota is the certificate issuance quota of an HVCA account.
+type Quota struct {
+	// Used is the number of certificates issued by the account.
+	Used int64
+
+	// Total is the number of certificates which the account may issue,
+	// including those already issued. It is zero if Unlimited is true.
+	Total int64
+
+	// Unlimited is true if the account has no issuance quota.
+	Unlimited bool
+}
+
+// newQuota returns a quota from the number of certificates issued and the
+// remaining quota reported by HVCA, which reports a negative remaining quota
+// for an account with no issuance quota.
+func newQuota(used, remaining int64) *Quota {
+	if remaining < 0 {
+		return &Quota{Used: used, Unlimited: true}
+	}
+
+	return &Quota{Used: used, Total: used + remaining}
+}
+
+// Remaining returns the number of certificates which the account may still
+// issue. It returns math.MaxInt64 if the quota is unlimited, and never
+// returns a negative number.
+func (q *Quota) Remaining() int64 {
+	if q.Unlimited {
+		return math.MaxInt64
+	}
+
+	if q.Used >= q.Total {
+		return 0
+	}
+
+	return q.Total - q.Used
+}
+
+// PercentUsed returns the percentage of the quota which has been used, from
+// 0 to 100. It returns NaN if the quota is unlimited, so that comparisons
+// against an alerting threshold are always false.
+func (q *Quota) PercentUsed() float64 {
+	if q.Unlimited {
+		return math.NaN()
+	}
+
+	if q.Total <= 0 {
+		return 100
+	}
+
+	return math.Min(float64(q.Used)/float64(q.Total)*100, 100)
+}
+
+// Below returns true if the number of certificates which the account may
+// still issue is less than the specified threshold. It always returns false
+// if the quota is unlimited.
+func (q *Quota) Below(threshold int64) bool {
+	return !q.Unlimited && q.Remaining() < threshold
+}
+
+// IssuanceQuota returns the certificate issuance quota of the calling
+// account, including the number of certificates already issued. Unlike
+// QuotaIssuance, which returns only the remaining quota, it reports an
+// account with no issuance quota as unlimited.
+func (c *Client) IssuanceQuota(ctx context.Context) (*Quota, error) {
+	var remaining, err = c.QuotaIssuance(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var used int64
+	if used, err = c.CounterCertsIssued(ctx); err != nil {
+		return nil, err
+	}
+
+	return newQuota(used, remaining), nil
+}
+
+// QuotaProjection is an estimate of when the certificate issuance quota of
+// an HVCA account will be exhausted, assuming certificates continue to be
+// issued at the rate observed over a trailing window.
+type QuotaProjection struct {
+	// Quota is the current issuance quota.
+	Quota Quota
+
+	// Window is the trailing window over which the issuance rate was
+	// measured, ending at At.
+	Window time.Duration
+
+	// At is the time at which the projection was made, according to HVCA's
+	// clock.
+	At time.Time
+
+	// Issued is the number of certificates issued during the window.
+	Issued int64
+
+	// Never is true if the quota is not expected to be exhausted, either
+	// because it is unlimited or because no certificates were issued
+	// during the window. ExhaustedAt is zero if Never is true.
+	Never bool
+
+	// ExhaustedAt is the estimated time at which the quota will be
+	// exhausted. It is equal to At if the quota is already exhausted.
+	ExhaustedAt time.Time
+}
+
+// RatePerDay returns the number of certificates issued per day during the
+// window.
+func (p *QuotaProjection) RatePerDay() float64 {
+	if p.Window <= 0 {
+		return 0
+	}
+
+	return float64(p.Issued) * float64(time.Hour*24) / float64(p.Window)
+}
+
+// TimeRemaining returns the estimated time until the quota is exhausted, or
+// the maximum time.Duration if Never is true.
+func (p *QuotaProjection) TimeRemaining() time.Duration {
+	if p.Never {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return p.ExhaustedAt.Sub(p.At)
+}
+
+// newQuotaProjection returns the projection for the specified quota, given
+// the number of certificates issued during the window ending at the
+// specified time. An exhaustion time too distant to be represented is
+// treated as never.
+func newQuotaProjection(quota Quota, issued int64, window time.Duration, at time.Time) *QuotaProjection {
+	var p = QuotaProjection{
+		Quota:  quota,
+		Window: window,
+		At:     at,
+		Issued: issued,
+	}
+
+	var remaining = quota.Remaining()
+
+	switch {
+	case quota.Unlimited:
+		p.Never = true
+
+	case remaining == 0:
+		p.ExhaustedAt = at
+
+	case issued <= 0:
+		p.Never = true
+
+	default:
+		var d = float64(window) * float64(remaining) / float64(issued)
+		if d >= math.MaxInt64 {
+			p.Never = true
+		} else {
+			p.ExhaustedAt = at.Add(time.Duration(d))
+		}
+	}
+
+	return &p
+}
+
+// QuotaProjection estimates when the certificate issuance quota of the
+// calling account will be exhausted, from the current quota and the number
+// of certificates issued during the specified trailing window. A longer
+// window gives a projection which is less sensitive to bursts of issuance.
+// For an account with no issuance quota, the projection reports that the
+// quota will never be exhausted.
+func (c *Client) QuotaProjection(ctx context.Context, window time.Duration) (*QuotaProjection, error) {
+	if window <= 0 {
+		return nil, errors.New("window must be positive")
+	}
+
+	var quota, err = c.IssuanceQuota(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var at = c.serverTime()
+
+	var issued int64
+	if issued, err = c.CountIssued(ctx, at.Add(-window), at); err != nil {
+		return nil, err
+	}
+
+	return newQuotaProjection(*quota, issued, window, at), nil
+}
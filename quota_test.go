@@ -0,0 +1,202 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestQuota(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name        string
+		used        int64
+		remaining   int64
+		want        Quota
+		wantLeft    int64
+		wantPercent float64
+		below10     bool
+	}{
+		{
+			name:        "Partial",
+			used:        75,
+			remaining:   25,
+			want:        Quota{Used: 75, Total: 100},
+			wantLeft:    25,
+			wantPercent: 75,
+		},
+		{
+			name:        "NearlyExhausted",
+			used:        95,
+			remaining:   5,
+			want:        Quota{Used: 95, Total: 100},
+			wantLeft:    5,
+			wantPercent: 95,
+			below10:     true,
+		},
+		{
+			name:        "Exhausted",
+			used:        100,
+			remaining:   0,
+			want:        Quota{Used: 100, Total: 100},
+			wantLeft:    0,
+			wantPercent: 100,
+			below10:     true,
+		},
+		{
+			name:        "NoneIssued",
+			used:        0,
+			remaining:   0,
+			want:        Quota{},
+			wantLeft:    0,
+			wantPercent: 100,
+			below10:     true,
+		},
+		{
+			name:      "Unlimited",
+			used:      1234,
+			remaining: -1,
+			want:      Quota{Used: 1234, Unlimited: true},
+			wantLeft:  math.MaxInt64,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got = newQuota(tc.used, tc.remaining)
+
+			if *got != tc.want {
+				t.Fatalf("got %+v, want %+v", *got, tc.want)
+			}
+
+			if left := got.Remaining(); left != tc.wantLeft {
+				t.Errorf("got %d remaining, want %d", left, tc.wantLeft)
+			}
+
+			if pct := got.PercentUsed(); tc.want.Unlimited && !math.IsNaN(pct) {
+				t.Errorf("got %f percent used, want NaN", pct)
+			} else if !tc.want.Unlimited && pct != tc.wantPercent {
+				t.Errorf("got %f percent used, want %f", pct, tc.wantPercent)
+			}
+
+			if below := got.Below(10); below != tc.below10 {
+				t.Errorf("got below threshold %t, want %t", below, tc.below10)
+			}
+		})
+	}
+}
+
+func TestQuotaProjection(t *testing.T) {
+	t.Parallel()
+
+	var at = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	const day = time.Hour * 24
+
+	var testcases = []struct {
+		name      string
+		quota     Quota
+		issued    int64
+		window    time.Duration
+		never     bool
+		exhausted time.Time
+		perDay    float64
+	}{
+		{
+			name:      "Steady",
+			quota:     Quota{Used: 70, Total: 100},
+			issued:    30,
+			window:    day * 30,
+			exhausted: at.Add(day * 30),
+			perDay:    1,
+		},
+		{
+			name:      "Fast",
+			quota:     Quota{Used: 10, Total: 100},
+			issued:    180,
+			window:    day,
+			exhausted: at.Add(time.Hour * 12),
+			perDay:    180,
+		},
+		{
+			name:      "AlreadyExhausted",
+			quota:     Quota{Used: 100, Total: 100},
+			issued:    5,
+			window:    day,
+			exhausted: at,
+			perDay:    5,
+		},
+		{
+			name:   "NoneIssued",
+			quota:  Quota{Used: 10, Total: 100},
+			window: day,
+			never:  true,
+		},
+		{
+			name:   "Unlimited",
+			quota:  Quota{Used: 1000, Unlimited: true},
+			issued: 100,
+			window: day,
+			never:  true,
+			perDay: 100,
+		},
+		{
+			name:   "TooDistant",
+			quota:  Quota{Used: 0, Total: math.MaxInt64},
+			issued: 1,
+			window: day * 365,
+			never:  true,
+			perDay: 1.0 / 365,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got = newQuotaProjection(tc.quota, tc.issued, tc.window, at)
+
+			if got.Never != tc.never {
+				t.Fatalf("got never %t, want %t", got.Never, tc.never)
+			}
+
+			if !got.ExhaustedAt.Equal(tc.exhausted) {
+				t.Errorf("got exhausted at %v, want %v", got.ExhaustedAt, tc.exhausted)
+			}
+
+			var wantRemaining = tc.exhausted.Sub(at)
+			if tc.never {
+				wantRemaining = time.Duration(math.MaxInt64)
+			}
+
+			if remaining := got.TimeRemaining(); remaining != wantRemaining {
+				t.Errorf("got time remaining %v, want %v", remaining, wantRemaining)
+			}
+
+			if rate := got.RatePerDay(); math.Abs(rate-tc.perDay) > 1e-9 {
+				t.Errorf("got rate %f per day, want %f", rate, tc.perDay)
+			}
+		})
+	}
+}
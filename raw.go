@@ -0,0 +1,87 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GetRaw sends an authenticated GET request to the specified HVCA API
+// endpoint and returns the raw JSON response body, for accessing endpoints
+// or response fields which this package does not yet support. The path is
+// relative to the configured base URL, must begin with a slash, and may
+// include a query string, for example "/certificates/01AB?fields=all". Login,
+// retries and errors are handled in the same way as for other methods.
+func (c *Client) GetRaw(ctx context.Context, path string) (json.RawMessage, error) {
+	return c.doRaw(ctx, http.MethodGet, path, nil)
+}
+
+// PostRaw sends an authenticated POST request with the JSON encoding of body
+// to the specified HVCA API endpoint, and returns the raw JSON response body,
+// which is nil if the response has no body. If body is nil, the request has
+// no body. The path is subject to the same requirements as for GetRaw. Since
+// a POST request is not idempotent, it is retried only if the retry policy
+// allows non-idempotent requests to be retried.
+func (c *Client) PostRaw(ctx context.Context, path string, body interface{}) (json.RawMessage, error) {
+	return c.doRaw(ctx, http.MethodPost, path, body)
+}
+
+// doRaw sends a request to the specified HVCA API endpoint and returns the
+// raw response body.
+func (c *Client) doRaw(ctx context.Context, method, path string, body interface{}) (json.RawMessage, error) {
+	if err := checkRawPath(path); err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if _, err := c.makeRequest(ctx, path, method, body, &raw); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// checkRawPath returns an error if the path is not an absolute path relative
+// to the base URL, such as if it contains a scheme or host, or leaves the
+// base path with a ".." segment. Login requests are also rejected, since
+// they are made automatically.
+func checkRawPath(path string) error {
+	var u, err = url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("invalid path %q: %w", path, err)
+	}
+
+	if u.Scheme != "" || u.Host != "" || u.Opaque != "" || !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return fmt.Errorf("invalid path %q: must be relative to the base URL and begin with a slash", path)
+	}
+
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == ".." {
+			return fmt.Errorf("invalid path %q: must not contain \"..\" segments", path)
+		}
+	}
+
+	if strings.HasPrefix(u.Path, endpointLogin) {
+		return fmt.Errorf("invalid path %q: login is performed automatically", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+func TestClientRaw(t *testing.T) {
+	t.Parallel()
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(httputils.AuthorizationHeader); got != "Bearer token" {
+			t.Errorf("got authorization header %q, want %q", got, "Bearer token")
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/widgets/1" && r.URL.RawQuery == "fields=all":
+			w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+			io.WriteString(w, `{"id":1,"new_field":"value"}`)
+
+		case r.Method == http.MethodPost && r.URL.Path == "/widgets":
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["name"] != "gadget" {
+				t.Errorf("got body %v (error %v), want name gadget", body, err)
+			}
+
+			w.Header().Set("Location", "/widgets/2")
+			w.WriteHeader(http.StatusCreated)
+
+		default:
+			w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"description":"not found"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+	t.Run("Get", func(t *testing.T) {
+		t.Parallel()
+
+		var raw, err = clnt.GetRaw(context.Background(), "/widgets/1?fields=all")
+		if err != nil {
+			t.Fatalf("failed to get raw JSON: %v", err)
+		}
+
+		if got, want := string(raw), `{"id":1,"new_field":"value"}`; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("PostEmptyResponse", func(t *testing.T) {
+		t.Parallel()
+
+		var raw, err = clnt.PostRaw(context.Background(), "/widgets", map[string]string{"name": "gadget"})
+		if err != nil {
+			t.Fatalf("failed to post raw JSON: %v", err)
+		}
+
+		if raw != nil {
+			t.Errorf("got %s, want nil", raw)
+		}
+	})
+
+	t.Run("APIError", func(t *testing.T) {
+		t.Parallel()
+
+		var _, err = clnt.GetRaw(context.Background(), "/missing")
+
+		var apiErr APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+			t.Errorf("got error %v, want API error with status %d", err, http.StatusNotFound)
+		}
+	})
+}
+
+func TestCheckRawPath(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		path string
+		err  bool
+	}{
+		{path: "/certificates/01AB"},
+		{path: "/stats/issued?page=1&per_page=10"},
+		{path: "/claims/domains/..abc"},
+		{path: "", err: true},
+		{path: "certificates", err: true},
+		{path: "//evil.example.com/certificates", err: true},
+		{path: "https://evil.example.com/certificates", err: true},
+		{path: "/certificates/../../admin", err: true},
+		{path: "/login", err: true},
+		{path: "/%zz", err: true},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.path, func(t *testing.T) {
+			t.Parallel()
+
+			if err := checkRawPath(tc.path); (err != nil) != tc.err {
+				t.Errorf("got error %v, want error %t", err, tc.err)
+			}
+		})
+	}
+}
@@ -16,23 +16,24 @@ limitations under the License.
 package hvclient
 
 import (
-	"crypto"
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
-	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
-	"math/big"
 	"net"
 	"net/url"
 	"sort"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/vsglobalsign/hvclient/internal/oids"
 	"github.com/vsglobalsign/hvclient/internal/pki"
@@ -51,24 +52,58 @@ import (
 // 3. Provide a signed PKCS#10 certificate signing request.
 //
 // For case 1, simply assign the public key in question to the PublicKey field
-// of the Request. For case 2, leave the PublicKey field empty and assign the
-// private key to the PrivateKey field of the Request, and the public key will
-// be automatically extracted and the appropriate signature generated. For case
-// 3, leave both the PublicKey and PrivateKey fields empty and assign the
-// PKCS#10 certificate signed request to the CSR field. Note that when providing
-// a PKCS#10 certificate signing request, none of the fields in the CSR are
-// examined by HVCA except for the public key and the signature, and none of
-// the fields in the CSR are automatically copied to the Request object.
+// of the Request. For case 2, assign the private key to the PrivateKey field
+// of the Request, and the public key will be automatically extracted and the
+// appropriate signature generated. If the PublicKey field is also set, it must
+// match the private key. Case 2 applies to validation policies for which
+// Policy.PublicKeySignature is Required, and HVCA rejects requests without a
+// signature under such policies. For case 3, leave both the PublicKey and
+// PrivateKey fields empty and assign the PKCS#10 certificate signed request to
+// the CSR field. Note that when providing a PKCS#10 certificate signing
+// request, none of the fields in the CSR are examined by HVCA except for the
+// public key and the signature, and none of the fields in the CSR are
+// automatically copied to the Request object.
+//
+// The PublicKey field may be an RSA public key, an ECDSA public key on one of
+// the curves P-256, P-384 or P-521, or an Ed25519 public key, and is encoded
+// as a PEM-encoded PKIX SubjectPublicKeyInfo. The PrivateKey field may be any
+// crypto.Signer with an RSA or ECDSA public key, such as an *rsa.PrivateKey,
+// an *ecdsa.PrivateKey or a key held in a hardware security module, which is
+// used to sign the SHA-256 digest of the DER-encoded public key. Whether a key
+// type is accepted is determined by the account's validation policy, against
+// which a request may be checked with Policy.Validate.
+//
+// Extended key usages may be specified with the standard library constants in
+// the ExtendedKeyUsages field, and any others by OID in the EKUs field. Both
+// are sent to HVCA in a single list. Key usages may be specified by setting
+// bits in the KeyUsages field. Whichever usages are requested, the usages in
+// the issued certificate are determined by the validation policy.
+//
+// The algorithm with which HVCA signs the certificate may be requested with
+// the SignatureAlgorithm field, which supports the RSA, RSA-PSS and ECDSA
+// algorithms with SHA-256, SHA-384 or SHA-512, and is sent to HVCA in the
+// same form as the Signature field. If both are set they must agree. If
+// neither is set, HVCA applies the default from the validation policy. A
+// request which unmarshals from JSON always has the Signature field set
+// instead of SignatureAlgorithm.
+//
+// The Profile field selects one of the issuance profiles available to the
+// account, as returned by Client.Profiles, by its identifier. If it is
+// empty, the account's default profile is used.
 type Request struct {
+	Profile             string
 	Validity            *Validity
 	Subject             *DN
 	SAN                 *SAN
 	EKUs                []asn1.ObjectIdentifier
+	ExtendedKeyUsages   []x509.ExtKeyUsage
+	KeyUsages           x509.KeyUsage
 	DA                  *DA
 	QualifiedStatements *QualifiedStatements
 	MSExtension         *MSExtension
 	CustomExtensions    []OIDAndString
 	Signature           *Signature
+	SignatureAlgorithm  x509.SignatureAlgorithm
 	CSR                 *x509.CertificateRequest
 	PrivateKey          interface{}
 	PublicKey           interface{}
@@ -80,30 +115,54 @@ type Signature struct {
 }
 
 // Validity contains the requested not-before and not-after times for a
-// certificate. If NotAfter is set to time.Unix(0, 0), the maximum duration
-// allowed by the validation policy will be applied.
+// certificate, which are sent to HVCA unchanged as Unix timestamps, truncated
+// to whole seconds. The not-before time may be in the future, subject to the
+// validation policy. If NotBefore is zero, the current time is used. If
+// NotAfter is set to time.Unix(0, 0), the maximum duration allowed by the
+// validation policy will be applied. Otherwise, NotAfter must be after
+// NotBefore.
+//
+// Alternatively, Duration may be set to request a certificate valid for that
+// long from the time of issuance, in which case NotBefore and NotAfter must
+// both be zero. HVCA accepts only absolute times, so when a certificate is
+// requested through a Client, the times sent are computed from HVCA's clock
+// as reported in the Date header of its most recent response, to avoid any
+// skew between the local clock and HVCA's. Otherwise, such as when the
+// request is marshalled directly, they are computed from the local clock. To
+// store a request as a template which remains relative to the time of
+// issuance, use Request.MarshalTemplate instead.
 type Validity struct {
 	NotBefore time.Time
 	NotAfter  time.Time
+	Duration  time.Duration
 }
 
 // DN is a list of Distinguished Name attributes to include in a
-// certificate. See RFC 5280 4.1.2.6.
+// certificate. See RFC 5280 4.1.2.6. Each field corresponds to a field of
+// the same JSON name in the subject DN section of the validation policy,
+// and any other attribute permitted by the policy may be requested by OID
+// in ExtraAttributes. The jurisdiction of incorporation and business
+// category fields, together with SerialNumber and OrganizationIdentifier,
+// are typically required for extended validation certificates.
 type DN struct {
-	Country            string         `json:"country,omitempty"`
-	State              string         `json:"state,omitempty"`
-	Locality           string         `json:"locality,omitempty"`
-	StreetAddress      string         `json:"street_address,omitempty"`
-	Organization       string         `json:"organization,omitempty"`
-	OrganizationalUnit []string       `json:"organizational_unit,omitempty"`
-	CommonName         string         `json:"common_name,omitempty"`
-	SerialNumber       string         `json:"serial_number,omitempty"`
-	Email              string         `json:"email,omitempty"`
-	JOILocality        string         `json:"jurisdiction_of_incorporation_locality_name,omitempty"`
-	JOIState           string         `json:"jurisdiction_of_incorporation_state_or_province_name,omitempty"`
-	JOICountry         string         `json:"jurisdiction_of_incorporation_country_name,omitempty"`
-	BusinessCategory   string         `json:"business_category,omitempty"`
-	ExtraAttributes    []OIDAndString `json:"extra_attributes,omitempty"`
+	Country                string         `json:"country,omitempty"`
+	State                  string         `json:"state,omitempty"`
+	Locality               string         `json:"locality,omitempty"`
+	StreetAddress          string         `json:"street_address,omitempty"`
+	PostalCode             string         `json:"postal_code,omitempty"`
+	Organization           string         `json:"organization,omitempty"`
+	OrganizationalUnit     []string       `json:"organizational_unit,omitempty"`
+	OrganizationIdentifier string         `json:"organization_identifier,omitempty"`
+	CommonName             string         `json:"common_name,omitempty"`
+	GivenName              string         `json:"given_name,omitempty"`
+	Surname                string         `json:"surname,omitempty"`
+	SerialNumber           string         `json:"serial_number,omitempty"`
+	Email                  string         `json:"email,omitempty"`
+	JOILocality            string         `json:"jurisdiction_of_incorporation_locality_name,omitempty"`
+	JOIState               string         `json:"jurisdiction_of_incorporation_state_or_province_name,omitempty"`
+	JOICountry             string         `json:"jurisdiction_of_incorporation_country_name,omitempty"`
+	BusinessCategory       string         `json:"business_category,omitempty"`
+	ExtraAttributes        []OIDAndString `json:"extra_attributes,omitempty"`
 }
 
 // OIDAndString is an ASN.1 object identifier (OID) together with an
@@ -116,11 +175,27 @@ type OIDAndString struct {
 // SAN is a list of Subject Alternative Name attributes to include in a
 // certificate. See RFC 5280 4.2.1.6.
 type SAN struct {
-	DNSNames    []string
-	Emails      []string
-	IPAddresses []net.IP
-	URIs        []*url.URL
-	OtherNames  []OIDAndString
+	DNSNames      []string
+	Emails        []string
+	IPAddresses   []net.IP
+	URIs          []*url.URL
+	OtherNames    []OtherName
+	RegisteredIDs []asn1.ObjectIdentifier
+}
+
+// OtherName is an otherName subject alternative name, consisting of an
+// ASN.1 object identifier (OID) and a string value. See RFC 5280 4.2.1.6.
+type OtherName struct {
+	OID asn1.ObjectIdentifier
+
+	// ValueType is the ASN.1 string type with which the value is encoded in
+	// a PKCS#10 certificate signing request. Only IA5String, PrintableString
+	// and UTF8String are supported. If zero, UTF8String is used. The value
+	// type is not sent to HVCA, which encodes the value according to the
+	// validation policy.
+	ValueType ValueType
+
+	Value string
 }
 
 // DA is a list of Subject Directory Attributes to include in a
@@ -162,10 +237,12 @@ type MSExtension struct {
 
 // jsonRequest is used internally for JSON marshalling/unmarshalling.
 type jsonRequest struct {
+	Profile             string               `json:"profile,omitempty"`
 	Validity            *Validity            `json:"validity,omitempty"`
 	Subject             *DN                  `json:"subject_dn,omitempty"`
 	SAN                 *SAN                 `json:"san,omitempty"`
 	EKUs                []jsonOID            `json:"extended_key_usages,omitempty"`
+	KeyUsages           []string             `json:"key_usages,omitempty"`
 	DA                  *DA                  `json:"subject_da,omitempty"`
 	QualifiedStatements *QualifiedStatements `json:"qualified_statements,omitempty"`
 	MSExtension         *MSExtension         `json:"ms_extension_template,omitempty"`
@@ -181,17 +258,34 @@ type jsonOID asn1.ObjectIdentifier
 
 // jsonValidity is used internally for JSON marshalling/unmarshalling.
 type jsonValidity struct {
-	NotBefore int64 `json:"not_before"`
-	NotAfter  int64 `json:"not_after"`
+	NotBefore jsonTime `json:"not_before"`
+	NotAfter  jsonTime `json:"not_after"`
+	Duration  int64    `json:"duration,omitempty"`
 }
 
+// jsonRelativeValidity is used internally for JSON marshalling of a validity
+// relative to the time of issuance in a template.
+type jsonRelativeValidity struct {
+	Duration int64 `json:"duration"`
+}
+
+// templateValidity is used internally for JSON marshalling of a validity in
+// a template, which unlike HVCA's encoding may be relative to the time of
+// issuance.
+type templateValidity Validity
+
+// jsonTime is used internally for JSON marshalling/unmarshalling of
+// validity times, which are encoded as Unix timestamps.
+type jsonTime int64
+
 // jsonSAN is used internally for JSON marshalling/unmarshalling.
 type jsonSAN struct {
-	DNSNames    []string       `json:"dns_names,omitempty"`
-	Emails      []string       `json:"emails,omitempty"`
-	IPAddresses []string       `json:"ip_addresses,omitempty"`
-	URIs        []string       `json:"uris,omitempty"`
-	OtherNames  []OIDAndString `json:"other_names,omitempty"`
+	DNSNames      []string    `json:"dns_names,omitempty"`
+	Emails        []string    `json:"emails,omitempty"`
+	IPAddresses   []string    `json:"ip_addresses,omitempty"`
+	URIs          []string    `json:"uris,omitempty"`
+	OtherNames    []OtherName `json:"other_names,omitempty"`
+	RegisteredIDs []jsonOID   `json:"registered_ids,omitempty"`
 }
 
 // jsonOIDAndString is used internally for JSON marshalling/unmarshalling.
@@ -238,13 +332,28 @@ const dobLayout = `2006-01-02`
 
 // Equal checks if two certificate requests are equivalent.
 func (r Request) Equal(other Request) bool {
-	// Check for equality of extended key usages.
-	if len(r.EKUs) != len(other.EKUs) {
+	// Check for equality of key usages and extended key usages. Standard
+	// extended key usages are equivalent to the corresponding OIDs.
+	if r.KeyUsages != other.KeyUsages {
 		return false
 	}
 
-	for i := range r.EKUs {
-		if !r.EKUs[i].Equal(other.EKUs[i]) {
+	var ekus, err = r.allEKUs()
+	if err != nil {
+		return false
+	}
+
+	var otherEKUs []asn1.ObjectIdentifier
+	if otherEKUs, err = other.allEKUs(); err != nil {
+		return false
+	}
+
+	if len(ekus) != len(otherEKUs) {
+		return false
+	}
+
+	for i := range ekus {
+		if !ekus[i].Equal(otherEKUs[i]) {
 			return false
 		}
 	}
@@ -261,7 +370,8 @@ func (r Request) Equal(other Request) bool {
 	}
 
 	// Check for equality of other fields.
-	return r.Validity.Equal(other.Validity) &&
+	return r.Profile == other.Profile &&
+		r.Validity.Equal(other.Validity) &&
 		r.Subject.Equal(other.Subject) &&
 		r.SAN.Equal(other.SAN) &&
 		r.DA.Equal(other.DA) &&
@@ -269,40 +379,95 @@ func (r Request) Equal(other Request) bool {
 		r.MSExtension.Equal(other.MSExtension)
 }
 
-// MarshalJSON returns the JSON encoding of a certificate request.
+// MarshalJSON returns the JSON encoding of a certificate request, in the
+// format accepted by HVCA. A relative validity duration is converted to
+// absolute times using the local clock.
 func (r Request) MarshalJSON() ([]byte, error) {
+	var jsonreq, err = r.jsonRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonreq)
+}
+
+// MarshalTemplate returns the JSON encoding of a certificate request for
+// storage as a template. It is the same as that produced by MarshalJSON,
+// except that a relative validity duration is encoded as a whole number of
+// seconds in a "duration" field, so that the request remains relative to the
+// time of issuance when it is unmarshalled. HVCA does not accept this field,
+// so the result must not be sent to HVCA directly.
+func (r Request) MarshalTemplate() ([]byte, error) {
+	var jsonreq, err = r.jsonRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	// The outer validity field takes precedence over the one in jsonRequest.
+	return json.Marshal(struct {
+		Validity *templateValidity `json:"validity,omitempty"`
+		*jsonRequest
+	}{
+		Validity:    (*templateValidity)(r.Validity),
+		jsonRequest: jsonreq,
+	})
+}
+
+// jsonRequest converts the request to its internal JSON representation.
+func (r Request) jsonRequest() (*jsonRequest, error) {
 	// Marshal the custom extensions if any are present.
 	var raw json.RawMessage
 	if len(r.CustomExtensions) > 0 {
 		raw = json.RawMessage("{")
 
 		for i, ext := range r.CustomExtensions {
+			// Encode the value as a JSON string, since it may contain
+			// characters such as quotes which must be escaped.
+			var value, err = json.Marshal(ext.Value)
+			if err != nil {
+				return nil, err
+			}
+
 			var item string
 
 			if i+1 == len(r.CustomExtensions) {
 				// Close object encoding if this is the last extension.
-				item = fmt.Sprintf(`"%s":"%s"}`, ext.OID.String(), ext.Value)
+				item = fmt.Sprintf(`"%s":%s}`, ext.OID.String(), value)
 			} else {
 				// Otherwise add a trailing comma.
-				item = fmt.Sprintf(`"%s":"%s",`, ext.OID.String(), ext.Value)
+				item = fmt.Sprintf(`"%s":%s,`, ext.OID.String(), value)
 			}
 
 			raw = append(raw, []byte(item)...)
 		}
 	}
 
-	// Convert extended key usages.
-	var ekus = make([]jsonOID, len(r.EKUs))
-	for i := range r.EKUs {
-		ekus[i] = jsonOID(r.EKUs[i])
+	// Convert key usages and extended key usages.
+	var keyUsages, err = keyUsageStrings(r.KeyUsages)
+	if err != nil {
+		return nil, err
+	}
+
+	var oidList []asn1.ObjectIdentifier
+	if oidList, err = r.allEKUs(); err != nil {
+		return nil, err
+	}
+
+	var ekus = make([]jsonOID, len(oidList))
+	for i := range oidList {
+		ekus[i] = jsonOID(oidList[i])
 	}
 
 	// Convert PKCS#10 certificate request, if present.
 	var publicKey string
 	var publicKeySig string
-	var err error
 
 	switch {
+	case r.PrivateKey != nil:
+		if publicKey, publicKeySig, err = r.signPublicKey(); err != nil {
+			return nil, err
+		}
+
 	case r.PublicKey != nil:
 		switch k := r.PublicKey.(type) {
 		case rsa.PublicKey:
@@ -327,51 +492,6 @@ func (r Request) MarshalJSON() ([]byte, error) {
 			}
 		}
 
-	case r.PrivateKey != nil:
-		switch k := r.PrivateKey.(type) {
-		case *rsa.PrivateKey:
-			var pubKeyBytes []byte
-			var err error
-
-			if pubKeyBytes, publicKey, err = publicKeyBytesAndString(&k.PublicKey); err != nil {
-				return nil, err
-			}
-
-			var h = sha256.Sum256(pubKeyBytes)
-
-			var signedBytes []byte
-			if signedBytes, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, h[:]); err != nil {
-				return nil, err
-			}
-
-			publicKeySig = base64.StdEncoding.EncodeToString(signedBytes)
-
-		case *ecdsa.PrivateKey:
-			var pubKeyBytes []byte
-			var err error
-
-			if pubKeyBytes, publicKey, err = publicKeyBytesAndString(&k.PublicKey); err != nil {
-				return nil, err
-			}
-
-			var h = sha256.Sum256(pubKeyBytes)
-
-			var r, s *big.Int
-			if r, s, err = ecdsa.Sign(rand.Reader, k, h[:]); err != nil {
-				return nil, err
-			}
-
-			var signedBytes []byte
-			if signedBytes, err = asn1.Marshal([]*big.Int{r, s}); err != nil {
-				return nil, err
-			}
-
-			publicKeySig = base64.StdEncoding.EncodeToString(signedBytes)
-
-		default:
-			return nil, fmt.Errorf("unsupported private key type: %T", k)
-		}
-
 	case r.CSR != nil:
 		publicKey = pki.CSRToPEMString(r.CSR)
 
@@ -382,22 +502,42 @@ func (r Request) MarshalJSON() ([]byte, error) {
 
 	}
 
-	return json.Marshal(jsonRequest{
+	var sig *Signature
+	if sig, err = r.signature(); err != nil {
+		return nil, err
+	}
+
+	return &jsonRequest{
+		Profile:             r.Profile,
 		Validity:            r.Validity,
 		Subject:             r.Subject,
 		SAN:                 r.SAN,
 		DA:                  r.DA,
 		EKUs:                ekus,
+		KeyUsages:           keyUsages,
 		QualifiedStatements: r.QualifiedStatements,
 		MSExtension:         r.MSExtension,
 		CustomExtensions:    raw,
 		PublicKey:           publicKey,
 		PublicKeySignature:  publicKeySig,
-	})
+		Signature:           sig,
+	}, nil
 }
 
 // UnmarshalJSON parses a JSON-encoded certificate request and stores the
-// result in the object.
+// result in the object. The JSON encoding is the same as that produced by
+// MarshalJSON or MarshalTemplate, except that the not-before and not-after
+// times may also be RFC 3339 strings, so requests stored as JSON may be
+// round-tripped. A PEM
+// encoded public key is stored in the PublicKey field, and a PEM encoded
+// PKCS#10 certificate signing request in the CSR field. Since private keys
+// are never encoded, a request marshalled with a PrivateKey is unmarshalled
+// with the corresponding PublicKey instead, and any public key signature is
+// discarded.
+//
+// YAML is not supported directly, to avoid a dependency on a YAML package,
+// but a request may be authored in YAML and converted to JSON before being
+// unmarshalled, for example with the sigs.k8s.io/yaml package.
 func (r *Request) UnmarshalJSON(b []byte) error {
 	var jsonreq *jsonRequest
 	var err = json.Unmarshal(b, &jsonreq)
@@ -439,23 +579,61 @@ func (r *Request) UnmarshalJSON(b []byte) error {
 		}
 	}
 
-	// Convert extended key usages.
+	// Convert key usages and extended key usages. All extended key usages
+	// are stored by OID in the EKUs field.
+	var keyUsages x509.KeyUsage
+	if keyUsages, err = keyUsageFromStrings(jsonreq.KeyUsages); err != nil {
+		return err
+	}
+
 	var ekus = make([]asn1.ObjectIdentifier, 0, len(jsonreq.EKUs))
 	for _, oid := range jsonreq.EKUs {
 		ekus = append(ekus, asn1.ObjectIdentifier(oid))
 	}
 
+	// Convert the public key or PKCS#10 certificate request, if present.
+	var publicKey interface{}
+	var csr *x509.CertificateRequest
+
+	if jsonreq.PublicKey != "" {
+		var block, rest = pem.Decode([]byte(jsonreq.PublicKey))
+		if block == nil {
+			return errors.New("public key is not PEM encoded")
+		} else if len(bytes.TrimSpace(rest)) > 0 {
+			return errors.New("trailing data after public key")
+		}
+
+		switch block.Type {
+		case "PUBLIC KEY":
+			if publicKey, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+				return fmt.Errorf("couldn't parse public key: %w", err)
+			}
+
+		case "CERTIFICATE REQUEST", "NEW CERTIFICATE REQUEST":
+			if csr, err = x509.ParseCertificateRequest(block.Bytes); err != nil {
+				return fmt.Errorf("couldn't parse certificate request: %w", err)
+			}
+
+		default:
+			return fmt.Errorf("unsupported public key PEM type: %s", block.Type)
+		}
+	}
+
 	// Store the result in the object.
 	*r = Request{
+		Profile:             jsonreq.Profile,
 		Validity:            jsonreq.Validity,
 		Subject:             jsonreq.Subject,
 		SAN:                 jsonreq.SAN,
 		DA:                  jsonreq.DA,
 		EKUs:                ekus,
+		KeyUsages:           keyUsages,
 		QualifiedStatements: jsonreq.QualifiedStatements,
 		MSExtension:         jsonreq.MSExtension,
 		CustomExtensions:    exts,
 		Signature:           jsonreq.Signature,
+		CSR:                 csr,
+		PublicKey:           publicKey,
 	}
 
 	return nil
@@ -465,8 +643,8 @@ func (r *Request) UnmarshalJSON(b []byte) error {
 //
 // BUG(paul): Not all fields are currently marshalled into the PKCS#10 request.
 // The fields currently marshalled include: subject distinguished name (all
-// fields, including extra attributes); subject alternative names (excluding
-// other names); and extended key usages.
+// fields, including extra attributes); subject alternative names; key
+// usages; and extended key usages.
 func (r *Request) PKCS10() (*x509.CertificateRequest, error) {
 	// We need a private key to sign the CSR, so abandon immediately if
 	// the request doesn't contain one.
@@ -481,15 +659,29 @@ func (r *Request) PKCS10() (*x509.CertificateRequest, error) {
 		csrtemplate.Subject = r.Subject.PKIXName()
 	}
 
-	if r.SAN != nil {
+	// The standard library cannot encode other names or registered IDs, so
+	// build the whole extension ourselves if any are present.
+	if r.SAN != nil && r.SAN.hasExtraNames() {
+		var ext, err = r.SAN.extension()
+		if err != nil {
+			return nil, err
+		}
+
+		csrtemplate.ExtraExtensions = append(csrtemplate.ExtraExtensions, ext)
+	} else if r.SAN != nil {
 		csrtemplate.DNSNames = r.SAN.DNSNames
 		csrtemplate.EmailAddresses = r.SAN.Emails
 		csrtemplate.IPAddresses = r.SAN.IPAddresses
 		csrtemplate.URIs = r.SAN.URIs
 	}
 
-	if len(r.EKUs) > 0 {
-		var value, err = asn1.Marshal(r.EKUs)
+	var ekus, err = r.allEKUs()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ekus) > 0 {
+		var value, err = asn1.Marshal(ekus)
 		if err != nil {
 			return nil, fmt.Errorf("couldn't marshal extended key usages: %v", err)
 		}
@@ -507,8 +699,18 @@ func (r *Request) PKCS10() (*x509.CertificateRequest, error) {
 		)
 	}
 
+	if r.KeyUsages != 0 {
+		var ext, err = keyUsageExtension(r.KeyUsages)
+		if err != nil {
+			return nil, err
+		}
+
+		csrtemplate.ExtraExtensions = append(csrtemplate.ExtraExtensions, ext)
+	}
+
 	// Create and marshal the PKCS#10 certificate signing request.
-	var data, err = x509.CreateCertificateRequest(
+	var data []byte
+	data, err = x509.CreateCertificateRequest(
 		rand.Reader,
 		csrtemplate,
 		r.PrivateKey,
@@ -539,31 +741,162 @@ func (v *Validity) Equal(other *Validity) bool {
 
 	// Check for equality of fields.
 	return v.NotBefore.Equal(other.NotBefore) &&
-		v.NotAfter.Equal(other.NotAfter)
+		v.NotAfter.Equal(other.NotAfter) &&
+		v.Duration == other.Duration
 }
 
-// MarshalJSON returns the JSON encoding of a validity object.
+// MarshalJSON returns the JSON encoding of a validity object, in the format
+// accepted by HVCA. A relative duration is converted to absolute times
+// starting at the current local time. An error is returned if the not-after
+// time is not after the not-before time.
 func (v *Validity) MarshalJSON() ([]byte, error) {
+	if err := v.validate(); err != nil {
+		return nil, err
+	}
+
+	var abs = v.absolute(time.Now())
+
 	return json.Marshal(&jsonValidity{
-		NotBefore: v.NotBefore.Unix(),
-		NotAfter:  v.NotAfter.Unix(),
+		NotBefore: jsonTime(abs.notBefore().Unix()),
+		NotAfter:  jsonTime(abs.NotAfter.Unix()),
 	})
 }
 
+// MarshalJSON returns the template encoding of a validity object, in which a
+// relative duration is encoded in whole seconds rather than as absolute
+// times.
+func (v *templateValidity) MarshalJSON() ([]byte, error) {
+	var validity = (*Validity)(v)
+
+	if v.Duration == 0 {
+		return validity.MarshalJSON()
+	}
+
+	if err := validity.validate(); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&jsonRelativeValidity{Duration: validity.seconds()})
+}
+
+// absolute returns the validity with a relative duration converted to
+// absolute times starting at the specified time. A validity with absolute
+// times is returned unchanged.
+func (v *Validity) absolute(now time.Time) *Validity {
+	if v.Duration == 0 {
+		return v
+	}
+
+	return &Validity{
+		NotBefore: now,
+		NotAfter:  now.Add(v.Duration),
+	}
+}
+
+// seconds returns the requested validity duration in whole seconds.
+func (v *Validity) seconds() int64 {
+	if v.Duration != 0 {
+		return int64(v.Duration / time.Second)
+	}
+
+	return v.NotAfter.Unix() - v.notBefore().Unix()
+}
+
+// notBefore returns the not-before time, or the current time if none was
+// specified.
+func (v *Validity) notBefore() time.Time {
+	if v.NotBefore.IsZero() {
+		return time.Now()
+	}
+
+	return v.NotBefore
+}
+
+// maximumDuration returns true if the not-after time requests the maximum
+// duration allowed by the validation policy.
+func (v *Validity) maximumDuration() bool {
+	return v.NotAfter.Equal(time.Unix(0, 0))
+}
+
+// validate returns an error if the not-after time is not after the
+// not-before time, after both have been truncated to whole seconds, or if a
+// relative duration is not positive or is specified along with either time.
+func (v *Validity) validate() error {
+	if v.Duration != 0 {
+		if !v.NotBefore.IsZero() || !v.NotAfter.IsZero() {
+			return errors.New("validity duration specified with not-before or not-after time")
+		}
+
+		if v.Duration < time.Second {
+			return fmt.Errorf("validity duration %v is less than one second", v.Duration)
+		}
+
+		return nil
+	}
+
+	if v.maximumDuration() {
+		return nil
+	}
+
+	if v.NotAfter.Unix() <= v.notBefore().Unix() {
+		return fmt.Errorf("not-after time %s is not after not-before time %s",
+			v.NotAfter.UTC().Format(time.RFC3339), v.notBefore().UTC().Format(time.RFC3339))
+	}
+
+	return nil
+}
+
 // UnmarshalJSON parses a JSON-encoded validity object and stores the result in
-// the object.
+// the object. The times may be either Unix timestamps or RFC 3339 strings, or
+// the object may instead contain a relative duration in seconds, as produced
+// by Request.MarshalTemplate.
 func (v *Validity) UnmarshalJSON(b []byte) error {
 	var jsonobj jsonValidity
 	if err := json.Unmarshal(b, &jsonobj); err != nil {
 		return err
 	}
 
+	if jsonobj.Duration != 0 {
+		if jsonobj.NotBefore != 0 || jsonobj.NotAfter != 0 {
+			return errors.New("validity duration specified with not-before or not-after time")
+		}
+
+		*v = Validity{Duration: time.Duration(jsonobj.Duration) * time.Second}
+
+		return nil
+	}
+
 	// Store result in object.
 	*v = Validity{
-		NotBefore: time.Unix(jsonobj.NotBefore, 0),
-		NotAfter:  time.Unix(jsonobj.NotAfter, 0),
+		NotBefore: time.Unix(int64(jsonobj.NotBefore), 0),
+		NotAfter:  time.Unix(int64(jsonobj.NotAfter), 0),
+	}
+
+	return nil
+}
+
+// UnmarshalJSON parses a JSON-encoded validity time, which may be either a
+// Unix timestamp or an RFC 3339 string, and stores the result in the object.
+func (t *jsonTime) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n int64
+		if err = json.Unmarshal(b, &n); err != nil {
+			return err
+		}
+
+		*t = jsonTime(n)
+
+		return nil
+	}
+
+	var parsed, err = time.Parse(time.RFC3339, s)
+	if err != nil {
+		return err
 	}
 
+	*t = jsonTime(parsed.Unix())
+
 	return nil
 }
 
@@ -605,8 +938,12 @@ func (n *DN) Equal(other *DN) bool {
 		n.State == other.State &&
 		n.Locality == other.Locality &&
 		n.StreetAddress == other.StreetAddress &&
+		n.PostalCode == other.PostalCode &&
 		n.Organization == other.Organization &&
+		n.OrganizationIdentifier == other.OrganizationIdentifier &&
 		n.CommonName == other.CommonName &&
+		n.GivenName == other.GivenName &&
+		n.Surname == other.Surname &&
 		n.Email == other.Email &&
 		n.JOILocality == other.JOILocality &&
 		n.JOIState == other.JOIState &&
@@ -631,6 +968,7 @@ func (n *DN) PKIXName() pkix.Name {
 	}{
 		{n.Organization, &name.Organization},
 		{n.StreetAddress, &name.StreetAddress},
+		{n.PostalCode, &name.PostalCode},
 		{n.Locality, &name.Locality},
 		{n.State, &name.Province},
 		{n.Country, &name.Country},
@@ -650,6 +988,9 @@ func (n *DN) PKIXName() pkix.Name {
 		value string
 		oid   asn1.ObjectIdentifier
 	}{
+		{n.GivenName, oids.OIDSubjectGivenName},
+		{n.Surname, oids.OIDSubjectSurname},
+		{n.OrganizationIdentifier, oids.OIDSubjectOrganizationIdentifier},
 		{n.JOILocality, oids.OIDSubjectJOILocality},
 		{n.JOIState, oids.OIDSubjectJOIState},
 		{n.JOICountry, oids.OIDSubjectJOICountry},
@@ -737,6 +1078,98 @@ func (o OIDAndString) AttributeTypeAndValue() pkix.AttributeTypeAndValue {
 	}
 }
 
+// Equal checks if two other names are equivalent.
+func (o OtherName) Equal(other OtherName) bool {
+	return o.OID.Equal(other.OID) &&
+		o.valueType() == other.valueType() &&
+		o.Value == other.Value
+}
+
+// MarshalJSON returns the JSON encoding of an other name. An error is
+// returned if the value type is not supported, or if the value cannot be
+// encoded with it.
+func (o OtherName) MarshalJSON() ([]byte, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonOIDAndString{
+		Type:  jsonOID(o.OID),
+		Value: o.Value,
+	})
+}
+
+// UnmarshalJSON parses a JSON-encoded other name and stores the result in
+// the object.
+func (o *OtherName) UnmarshalJSON(b []byte) error {
+	var jsonObj *jsonOIDAndString
+	if err := json.Unmarshal(b, &jsonObj); err != nil {
+		return err
+	}
+
+	// Store the result in the object.
+	*o = OtherName{
+		OID:   asn1.ObjectIdentifier(jsonObj.Type),
+		Value: jsonObj.Value,
+	}
+
+	return nil
+}
+
+// valueType returns the value type of the other name, or UTF8String if
+// none was specified.
+func (o OtherName) valueType() ValueType {
+	if o.ValueType == 0 {
+		return UTF8String
+	}
+
+	return o.ValueType
+}
+
+// validate returns an error if the other name has no OID, if its value type
+// is not supported, or if its value contains characters which cannot be
+// encoded with its value type.
+func (o OtherName) validate() error {
+	if len(o.OID) == 0 {
+		return errors.New("other name has no OID")
+	}
+
+	switch o.valueType() {
+	case IA5String:
+		for _, r := range o.Value {
+			if r > unicode.MaxASCII {
+				return fmt.Errorf("other name %s: value is not a valid IA5String", o.OID)
+			}
+		}
+
+	case PrintableString:
+		for _, r := range o.Value {
+			if !isPrintable(r) {
+				return fmt.Errorf("other name %s: value is not a valid PrintableString", o.OID)
+			}
+		}
+
+	case UTF8String:
+		if !utf8.ValidString(o.Value) {
+			return fmt.Errorf("other name %s: value is not a valid UTF8String", o.OID)
+		}
+
+	default:
+		return fmt.Errorf("other name %s: unsupported value type: %v", o.OID, o.ValueType)
+	}
+
+	return nil
+}
+
+// isPrintable reports whether a character may appear in an ASN.1
+// PrintableString.
+func isPrintable(r rune) bool {
+	return 'a' <= r && r <= 'z' ||
+		'A' <= r && r <= 'Z' ||
+		'0' <= r && r <= '9' ||
+		strings.ContainsRune(" '()+,-./:=?", r)
+}
+
 // Equal checks if two subject alternative names lists are equivalent.
 func (s *SAN) Equal(other *SAN) bool {
 	// Check for nil in both objects.
@@ -803,6 +1236,17 @@ func (s *SAN) Equal(other *SAN) bool {
 		}
 	}
 
+	// Check equality of registered IDs.
+	if len(s.RegisteredIDs) != len(other.RegisteredIDs) {
+		return false
+	}
+
+	for i := range s.RegisteredIDs {
+		if !s.RegisteredIDs[i].Equal(other.RegisteredIDs[i]) {
+			return false
+		}
+	}
+
 	return true
 }
 
@@ -820,12 +1264,19 @@ func (s *SAN) MarshalJSON() ([]byte, error) {
 		uris = append(uris, uri.String())
 	}
 
+	// Convert registered IDs.
+	var regIDs = make([]jsonOID, 0, len(s.RegisteredIDs))
+	for _, oid := range s.RegisteredIDs {
+		regIDs = append(regIDs, jsonOID(oid))
+	}
+
 	return json.Marshal(jsonSAN{
-		DNSNames:    s.DNSNames,
-		Emails:      s.Emails,
-		IPAddresses: ips,
-		URIs:        uris,
-		OtherNames:  s.OtherNames,
+		DNSNames:      s.DNSNames,
+		Emails:        s.Emails,
+		IPAddresses:   ips,
+		URIs:          uris,
+		OtherNames:    s.OtherNames,
+		RegisteredIDs: regIDs,
 	})
 }
 
@@ -855,13 +1306,20 @@ func (s *SAN) UnmarshalJSON(b []byte) error {
 		uris = append(uris, uri)
 	}
 
+	// Convert registered IDs.
+	var regIDs []asn1.ObjectIdentifier
+	for _, oid := range jsonsan.RegisteredIDs {
+		regIDs = append(regIDs, asn1.ObjectIdentifier(oid))
+	}
+
 	// Store result in object.
 	*s = SAN{
-		DNSNames:    jsonsan.DNSNames,
-		Emails:      jsonsan.Emails,
-		IPAddresses: ips,
-		URIs:        uris,
-		OtherNames:  jsonsan.OtherNames,
+		DNSNames:      jsonsan.DNSNames,
+		Emails:        jsonsan.Emails,
+		IPAddresses:   ips,
+		URIs:          uris,
+		OtherNames:    jsonsan.OtherNames,
+		RegisteredIDs: regIDs,
 	}
 
 	return nil
@@ -1153,9 +1611,13 @@ func (m *MSExtension) UnmarshalJSON(b []byte) error {
 // publicKeyBytesAndString key extracts and returns the raw DER bytes and a
 // PEM-encoded string representation of a public key.
 func publicKeyBytesAndString(key interface{}) ([]byte, string, error) {
+	if _, _, ok := publicKeyTypeAndSize(key); !ok {
+		return nil, "", fmt.Errorf("unsupported public key type or curve: %T", key)
+	}
+
 	var keyBytes, err = x509.MarshalPKIXPublicKey(key)
 	if err != nil {
-		return nil, "", fmt.Errorf("type was: %T: %v", key, err)
+		return nil, "", fmt.Errorf("failed to marshal public key of type %T: %v", key, err)
 	}
 
 	var keyString string
@@ -0,0 +1,250 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/oids"
+)
+
+// RequestBuilder assembles a certificate request for an issuance profile,
+// and checks it against the account's validation policy when it is built.
+// It is created by Client.NewRequestForProfile, and its setters may be
+// chained. A RequestBuilder is not safe for concurrent use.
+type RequestBuilder struct {
+	policy *Policy
+	req    Request
+}
+
+// NewRequestForProfile returns a request builder for the specified issuance
+// profile, as returned by Profiles, or for the account's default profile if
+// it is empty. The account's validation policy is retrieved, since HVCA
+// applies it to requests for every profile, and the builder is seeded with
+// the values the policy requires: the extended key usages and key usages, if
+// the policy fixes them, and a validity period of the maximum duration the
+// policy allows, starting now. An error wrapping ErrUnknownProfile is
+// returned if the profile is not available to the account.
+func (c *Client) NewRequestForProfile(ctx context.Context, profile string) (*RequestBuilder, error) {
+	if err := c.validateProfile(ctx, profile); err != nil {
+		return nil, err
+	}
+
+	var pol, err = c.Policy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var b = RequestBuilder{
+		policy: pol,
+		req: Request{
+			Profile:  profile,
+			Subject:  &DN{},
+			Validity: &Validity{NotAfter: time.Unix(0, 0)},
+		},
+	}
+
+	if pol.EKUs != nil && pol.EKUs.EKUs.Static {
+		for _, s := range pol.EKUs.EKUs.List {
+			var oid asn1.ObjectIdentifier
+			if oid, err = oids.StringToOID(s); err != nil {
+				return nil, fmt.Errorf("invalid extended key usage %q in policy: %w", s, err)
+			}
+
+			b.req.EKUs = append(b.req.EKUs, oid)
+		}
+	}
+
+	if pol.KeyUsages != nil && pol.KeyUsages.Static {
+		if b.req.KeyUsages, err = keyUsageFromStrings(pol.KeyUsages.List); err != nil {
+			return nil, fmt.Errorf("invalid key usages in policy: %w", err)
+		}
+	}
+
+	return &b, nil
+}
+
+// Policy returns the validation policy against which the request will be
+// checked.
+func (b *RequestBuilder) Policy() *Policy {
+	return b.policy
+}
+
+// SetSubject sets the subject distinguished name, replacing any fields set
+// previously.
+func (b *RequestBuilder) SetSubject(dn DN) *RequestBuilder {
+	b.req.Subject = &dn
+
+	return b
+}
+
+// SetCommonName sets the common name of the subject distinguished name.
+func (b *RequestBuilder) SetCommonName(name string) *RequestBuilder {
+	b.req.Subject.CommonName = name
+
+	return b
+}
+
+// AddDNS adds DNS names to the subject alternative names.
+func (b *RequestBuilder) AddDNS(names ...string) *RequestBuilder {
+	b.san().DNSNames = append(b.san().DNSNames, names...)
+
+	return b
+}
+
+// AddEmail adds email addresses to the subject alternative names.
+func (b *RequestBuilder) AddEmail(emails ...string) *RequestBuilder {
+	b.san().Emails = append(b.san().Emails, emails...)
+
+	return b
+}
+
+// AddIPAddress adds IP addresses to the subject alternative names.
+func (b *RequestBuilder) AddIPAddress(ips ...net.IP) *RequestBuilder {
+	b.san().IPAddresses = append(b.san().IPAddresses, ips...)
+
+	return b
+}
+
+// AddURI adds URIs to the subject alternative names.
+func (b *RequestBuilder) AddURI(uris ...*url.URL) *RequestBuilder {
+	b.san().URIs = append(b.san().URIs, uris...)
+
+	return b
+}
+
+// SetValidity sets the requested not-before and not-after times, as
+// described for Validity.
+func (b *RequestBuilder) SetValidity(notBefore, notAfter time.Time) *RequestBuilder {
+	b.req.Validity = &Validity{NotBefore: notBefore, NotAfter: notAfter}
+
+	return b
+}
+
+// SetExtendedKeyUsages sets the requested extended key usages, replacing
+// any set previously, including those seeded from the policy.
+func (b *RequestBuilder) SetExtendedKeyUsages(usages ...x509.ExtKeyUsage) *RequestBuilder {
+	b.req.ExtendedKeyUsages = append([]x509.ExtKeyUsage(nil), usages...)
+	b.req.EKUs = nil
+
+	return b
+}
+
+// AddEKU adds an extended key usage by OID.
+func (b *RequestBuilder) AddEKU(oid asn1.ObjectIdentifier) *RequestBuilder {
+	b.req.EKUs = append(b.req.EKUs, oid)
+
+	return b
+}
+
+// SetKeyUsages sets the requested key usages, replacing any seeded from the
+// policy.
+func (b *RequestBuilder) SetKeyUsages(usages x509.KeyUsage) *RequestBuilder {
+	b.req.KeyUsages = usages
+
+	return b
+}
+
+// SetPublicKey sets the public key, as described for Request.
+func (b *RequestBuilder) SetPublicKey(key interface{}) *RequestBuilder {
+	b.req.PublicKey = key
+
+	return b
+}
+
+// SetPrivateKey sets the private key with which the public key is signed,
+// as described for Request. Use it if the policy requires a public key
+// signature.
+func (b *RequestBuilder) SetPrivateKey(key interface{}) *RequestBuilder {
+	b.req.PrivateKey = key
+
+	return b
+}
+
+// SetCSR sets the PKCS#10 certificate signing request, as described for
+// Request. Use it if the policy requires the PKCS#10 key format.
+func (b *RequestBuilder) SetCSR(csr *x509.CertificateRequest) *RequestBuilder {
+	b.req.CSR = csr
+
+	return b
+}
+
+// Build returns the assembled request, after checking it against the
+// validation policy. If the request fails to comply with the policy, a
+// PolicyViolationsError is returned. An error is also returned if the request
+// cannot be encoded. The builder may continue to be used, and changes made
+// to it do not affect requests already built.
+func (b *RequestBuilder) Build() (*Request, error) {
+	var req = b.req.clone()
+
+	if _, err := json.Marshal(req); err != nil {
+		return nil, fmt.Errorf("invalid certificate request: %w", err)
+	}
+
+	if violations := b.policy.Validate(req); len(violations) > 0 {
+		return nil, PolicyViolationsError{Violations: violations}
+	}
+
+	return req, nil
+}
+
+// san returns the subject alternative names of the request, creating them
+// if necessary.
+func (b *RequestBuilder) san() *SAN {
+	if b.req.SAN == nil {
+		b.req.SAN = &SAN{}
+	}
+
+	return b.req.SAN
+}
+
+// clone returns a copy of the request which shares no slices or fields set
+// by RequestBuilder with the original.
+func (r *Request) clone() *Request {
+	var c = *r
+
+	if r.Validity != nil {
+		var v = *r.Validity
+		c.Validity = &v
+	}
+
+	if r.Subject != nil {
+		var dn = *r.Subject
+		dn.OrganizationalUnit = append([]string(nil), dn.OrganizationalUnit...)
+		c.Subject = &dn
+	}
+
+	if r.SAN != nil {
+		var san = *r.SAN
+		san.DNSNames = append([]string(nil), san.DNSNames...)
+		san.Emails = append([]string(nil), san.Emails...)
+		san.IPAddresses = append([]net.IP(nil), san.IPAddresses...)
+		san.URIs = append([]*url.URL(nil), san.URIs...)
+		c.SAN = &san
+	}
+
+	c.EKUs = append([]asn1.ObjectIdentifier(nil), r.EKUs...)
+	c.ExtendedKeyUsages = append([]x509.ExtKeyUsage(nil), r.ExtendedKeyUsages...)
+
+	return &c
+}
@@ -0,0 +1,221 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+// newProfilePolicyServer returns a test server with a "servers" profile and a
+// validation policy which requires a common name, allows one or two DNS
+// names under example.com, and fixes the extended key usages and key usages.
+func newProfilePolicyServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	const policy = `{
+		"validity": {"secondsmin": 60, "secondsmax": 86400},
+		"subject_dn": {"common_name": {"presence": "REQUIRED", "format": "^[a-z.]+$"}},
+		"san": {"dns_names": {"static": false, "list": ["(^|\\.)example\\.com$"], "mincount": 1, "maxcount": 2}},
+		"extended_key_usages": {"ekus": {"static": true, "list": ["1.3.6.1.5.5.7.3.1"], "mincount": 1, "maxcount": 1}},
+		"key_usages": {"static": true, "list": ["digital_signature", "key_encipherment"]},
+		"public_key_signature": "OPTIONAL"
+	}`
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+		switch r.URL.Path {
+		case endpointPolicy:
+			fmt.Fprint(w, policy)
+
+		case endpointProfiles:
+			fmt.Fprint(w, `[{"id":"servers","default":true}]`)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"description":"not found"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestNewRequestForProfile(t *testing.T) {
+	t.Parallel()
+
+	var server = newProfilePolicyServer(t)
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+	var b, err = clnt.NewRequestForProfile(context.Background(), "servers")
+	if err != nil {
+		t.Fatalf("failed to create request builder: %v", err)
+	}
+
+	// An incomplete request should report every missing field.
+	var _, buildErr = b.Build()
+
+	var verr PolicyViolationsError
+	if !errors.As(buildErr, &verr) {
+		t.Fatalf("got error %v, want %T", buildErr, verr)
+	}
+
+	var fields []string
+	for _, v := range verr.Violations {
+		fields = append(fields, v.(PolicyViolation).Field)
+	}
+
+	if want := []string{"subject_dn.common_name", "san.dns_names"}; !cmp.Equal(fields, want) {
+		t.Errorf("got violations of %v, want %v", fields, want)
+	}
+
+	var req *Request
+	req, err = b.SetCommonName("www.example.com").AddDNS("www.example.com", "example.com").Build()
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+
+	var want = &Request{
+		Profile:   "servers",
+		Subject:   &DN{CommonName: "www.example.com"},
+		SAN:       &SAN{DNSNames: []string{"www.example.com", "example.com"}},
+		Validity:  &Validity{NotAfter: time.Unix(0, 0)},
+		EKUs:      []asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 1}},
+		KeyUsages: x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+
+	if !req.Equal(*want) || req.Profile != want.Profile {
+		t.Errorf("got %+v, want %+v", req, want)
+	}
+
+	// Later changes to the builder should not affect the built request.
+	b.AddDNS("mail.example.com").SetCommonName("changed")
+	if !req.Equal(*want) {
+		t.Errorf("built request changed to %+v", req)
+	}
+
+	if _, err = b.Build(); err == nil {
+		t.Errorf("unexpectedly built request with too many DNS names")
+	}
+}
+
+func TestRequestBuilderSetters(t *testing.T) {
+	t.Parallel()
+
+	var server = newProfilePolicyServer(t)
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+	var testcases = []struct {
+		name   string
+		modify func(b *RequestBuilder)
+		fields []string
+	}{
+		{
+			name: "ValidityTooLong",
+			modify: func(b *RequestBuilder) {
+				b.SetValidity(time.Unix(1600000000, 0), time.Unix(1600000000, 0).Add(time.Hour*48))
+			},
+			fields: []string{"validity"},
+		},
+		{
+			name: "ValidityInRange",
+			modify: func(b *RequestBuilder) {
+				b.SetValidity(time.Unix(1600000000, 0), time.Unix(1600000000, 0).Add(time.Hour))
+			},
+		},
+		{
+			name: "ExtendedKeyUsageNotAllowed",
+			modify: func(b *RequestBuilder) {
+				b.SetExtendedKeyUsages(x509.ExtKeyUsageClientAuth)
+			},
+			fields: []string{"extended_key_usages"},
+		},
+		{
+			name: "ExtendedKeyUsageReplaced",
+			modify: func(b *RequestBuilder) {
+				b.SetExtendedKeyUsages(x509.ExtKeyUsageServerAuth)
+			},
+		},
+		{
+			name: "KeyUsageNotAllowed",
+			modify: func(b *RequestBuilder) {
+				b.SetKeyUsages(x509.KeyUsageCertSign)
+			},
+			fields: []string{"key_usages"},
+		},
+		{
+			name: "EmailAndIPAddress",
+			modify: func(b *RequestBuilder) {
+				b.AddEmail("admin@example.com").AddIPAddress(net.ParseIP("192.0.2.1"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var b, err = clnt.NewRequestForProfile(context.Background(), "")
+			if err != nil {
+				t.Fatalf("failed to create request builder: %v", err)
+			}
+
+			b.SetSubject(DN{CommonName: "example.com"}).AddDNS("example.com")
+			tc.modify(b)
+
+			_, err = b.Build()
+
+			var fields []string
+			var verr PolicyViolationsError
+			if errors.As(err, &verr) {
+				for _, v := range verr.Violations {
+					fields = append(fields, v.(PolicyViolation).Field)
+				}
+			} else if err != nil {
+				t.Fatalf("failed to build request: %v", err)
+			}
+
+			if !cmp.Equal(fields, tc.fields) {
+				t.Errorf("got violations of %v, want %v", fields, tc.fields)
+			}
+		})
+	}
+}
+
+func TestNewRequestForProfileUnknown(t *testing.T) {
+	t.Parallel()
+
+	var server = newProfilePolicyServer(t)
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+
+	if _, err := clnt.NewRequestForProfile(context.Background(), "clients"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("got error %v, want %v", err, ErrUnknownProfile)
+	}
+}
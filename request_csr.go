@@ -0,0 +1,233 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/vsglobalsign/hvclient/internal/oids"
+)
+
+// RequestFromCSR creates a new Request from a PKCS#10 certificate signing
+// request, populating the subject distinguished name, the subject
+// alternative names, and the public key. An error is returned if the
+// signature on the CSR is invalid, or if any field in the CSR cannot be
+// represented in a Request. Options such as WithoutSANTypes may be provided
+// to control which fields are copied.
+//
+// Extensions in the CSR other than the standard certificate extensions
+// defined in RFC 5280 are ignored, unless the WithCustomExtensionsFromCSR
+// option is provided, in which case they are preserved as custom extensions.
+//
+// The returned Request contains the public key from the CSR rather than the
+// CSR itself. If the HVCA account requires a signed PKCS#10 certificate
+// signing request, set the CSR field of the returned Request to the CSR and
+// the PublicKey field to nil.
+func RequestFromCSR(csr *x509.CertificateRequest, opts ...RequestOption) (*Request, error) {
+	if csr == nil {
+		return nil, errors.New("no certificate signing request provided")
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid certificate signing request signature: %w", err)
+	}
+
+	var subject, err = dnFromName(csr.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	var san *SAN
+	if san, err = sanFromNames(csr.DNSNames, csr.EmailAddresses, csr.IPAddresses, csr.URIs, csr.Extensions); err != nil {
+		return nil, err
+	}
+
+	var options = newRequestOptions(opts)
+
+	var req = Request{
+		Subject:   subject,
+		SAN:       options.filterSAN(san),
+		PublicKey: csr.PublicKey,
+	}
+
+	if options.customExtensions {
+		req.CustomExtensions = customExtensionsFromPKIX(csr.Extensions)
+	}
+
+	return &req, nil
+}
+
+// RequestFromPEM creates a new Request from a PEM-encoded PKCS#10
+// certificate signing request. See RequestFromCSR for details.
+func RequestFromPEM(data []byte, opts ...RequestOption) (*Request, error) {
+	var csr, err = parseCSRPEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return RequestFromCSR(csr, opts...)
+}
+
+// parseCSRPEM parses a PEM-encoded PKCS#10 certificate signing request.
+func parseCSRPEM(data []byte) (*x509.CertificateRequest, error) {
+	var block, rest = pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after PEM block")
+	}
+
+	switch block.Type {
+	case "CERTIFICATE REQUEST", "NEW CERTIFICATE REQUEST":
+	default:
+		return nil, fmt.Errorf("unexpected PEM block type: %s", block.Type)
+	}
+
+	var csr, err = x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate signing request: %w", err)
+	}
+
+	return csr, nil
+}
+
+// requestFromCertificate creates a new Request from a certificate,
+// populating the subject distinguished name and the subject alternative
+// names, subject to the request options. An error is returned if any field
+// in the certificate cannot be represented in a Request.
+func requestFromCertificate(cert *x509.Certificate, opts ...RequestOption) (*Request, error) {
+	var subject, err = dnFromName(cert.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	var san *SAN
+	if san, err = sanFromNames(cert.DNSNames, cert.EmailAddresses, cert.IPAddresses, cert.URIs, cert.Extensions); err != nil {
+		return nil, err
+	}
+
+	return &Request{
+		Subject: subject,
+		SAN:     newRequestOptions(opts).filterSAN(san),
+	}, nil
+}
+
+// sanFromNames returns the subject alternative names from a certificate or
+// certificate signing request, including any other names and registered IDs
+// in its extensions, or nil if there are none.
+func sanFromNames(
+	dnsNames, emails []string,
+	ips []net.IP,
+	uris []*url.URL,
+	exts []pkix.Extension,
+) (*SAN, error) {
+	var otherNames, regIDs, err = extraNamesFromExtensions(exts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dnsNames) == 0 && len(emails) == 0 && len(ips) == 0 && len(uris) == 0 &&
+		len(otherNames) == 0 && len(regIDs) == 0 {
+		return nil, nil
+	}
+
+	return &SAN{
+		DNSNames:      dnsNames,
+		Emails:        emails,
+		IPAddresses:   ips,
+		URIs:          uris,
+		OtherNames:    otherNames,
+		RegisteredIDs: regIDs,
+	}, nil
+}
+
+// dnFromName converts a distinguished name into a subject distinguished
+// name, or returns nil if the name is empty.
+func dnFromName(name pkix.Name) (*DN, error) {
+	if len(name.Names) == 0 {
+		return nil, nil
+	}
+
+	var dn = &DN{}
+
+	for _, attr := range name.Names {
+		var value, ok = attr.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("subject attribute %s has non-string value of type %T", attr.Type, attr.Value)
+		}
+
+		// Organizational unit is the only multi-valued field in a DN.
+		if attr.Type.Equal(oids.OIDSubjectOrganizationalUnit) {
+			dn.OrganizationalUnit = append(dn.OrganizationalUnit, value)
+			continue
+		}
+
+		var location *string
+
+		for _, field := range []struct {
+			oid      asn1.ObjectIdentifier
+			location *string
+		}{
+			{oids.OIDSubjectCommonName, &dn.CommonName},
+			{oids.OIDSubjectSerialNumber, &dn.SerialNumber},
+			{oids.OIDSubjectCountry, &dn.Country},
+			{oids.OIDSubjectLocality, &dn.Locality},
+			{oids.OIDSubjectState, &dn.State},
+			{oids.OIDSubjectStreetAddress, &dn.StreetAddress},
+			{oids.OIDSubjectPostalCode, &dn.PostalCode},
+			{oids.OIDSubjectOrganization, &dn.Organization},
+			{oids.OIDSubjectOrganizationIdentifier, &dn.OrganizationIdentifier},
+			{oids.OIDSubjectGivenName, &dn.GivenName},
+			{oids.OIDSubjectSurname, &dn.Surname},
+			{oids.OIDSubjectEmail, &dn.Email},
+			{oids.OIDSubjectJOILocality, &dn.JOILocality},
+			{oids.OIDSubjectJOIState, &dn.JOIState},
+			{oids.OIDSubjectJOICountry, &dn.JOICountry},
+			{oids.OIDSubjectBusinessCategory, &dn.BusinessCategory},
+		} {
+			if attr.Type.Equal(field.oid) {
+				location = field.location
+				break
+			}
+		}
+
+		// Attributes which have no corresponding field are included as
+		// extra attributes.
+		if location == nil {
+			dn.ExtraAttributes = append(dn.ExtraAttributes, OIDAndString{
+				OID:   attr.Type,
+				Value: value,
+			})
+
+			continue
+		}
+
+		if *location != "" {
+			return nil, fmt.Errorf("subject attribute %s has multiple values", attr.Type)
+		}
+
+		*location = value
+	}
+
+	return dn, nil
+}
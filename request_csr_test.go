@@ -0,0 +1,391 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient_test
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vsglobalsign/hvclient"
+	"github.com/vsglobalsign/hvclient/internal/testhelpers"
+)
+
+func TestRequestFromCSR(t *testing.T) {
+	t.Parallel()
+
+	var key = testhelpers.MustGetPrivateKeyFromFile(t, "testdata/ec_priv.key")
+
+	var testcases = []struct {
+		name     string
+		template *x509.CertificateRequest
+		want     hvclient.Request
+	}{
+		{
+			name: "SubjectAndSANs",
+			template: &x509.CertificateRequest{
+				Subject: pkix.Name{
+					CommonName:         "John Doe",
+					Organization:       []string{"GMO GlobalSign"},
+					OrganizationalUnit: []string{"Operations", "Development"},
+					Country:            []string{"GB"},
+					ExtraNames: []pkix.AttributeTypeAndValue{
+						{Type: asn1.ObjectIdentifier{2, 5, 4, 15}, Value: "Private Organization"},
+						{Type: asn1.ObjectIdentifier{2, 5, 4, 4}, Value: "Doe"},
+					},
+				},
+				DNSNames:       []string{"example.com", "www.example.com"},
+				EmailAddresses: []string{"john.doe@example.com"},
+				IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+				URIs:           []*url.URL{testhelpers.MustParseURI(t, "https://example.com/")},
+			},
+			want: hvclient.Request{
+				Subject: &hvclient.DN{
+					CommonName:         "John Doe",
+					Organization:       "GMO GlobalSign",
+					OrganizationalUnit: []string{"Operations", "Development"},
+					Country:            "GB",
+					BusinessCategory:   "Private Organization",
+					Surname:            "Doe",
+				},
+				SAN: &hvclient.SAN{
+					DNSNames:    []string{"example.com", "www.example.com"},
+					Emails:      []string{"john.doe@example.com"},
+					IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+					URIs:        []*url.URL{testhelpers.MustParseURI(t, "https://example.com/")},
+				},
+			},
+		},
+		{
+			name:     "Empty",
+			template: &x509.CertificateRequest{},
+			want:     hvclient.Request{},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var der, err = x509.CreateCertificateRequest(rand.Reader, tc.template, key)
+			if err != nil {
+				t.Fatalf("failed to create CSR: %v", err)
+			}
+
+			var data = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+
+			var got *hvclient.Request
+			if got, err = hvclient.RequestFromPEM(data); err != nil {
+				t.Fatalf("failed to create request from CSR: %v", err)
+			}
+
+			if !got.Equal(tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+
+			if got.PublicKey == nil {
+				t.Fatalf("public key not populated")
+			}
+		})
+	}
+}
+
+func TestRequestFromCSRFailure(t *testing.T) {
+	t.Parallel()
+
+	var key = testhelpers.MustGetPrivateKeyFromFile(t, "testdata/ec_priv.key")
+
+	// Create a CSR with a multi-valued organization, which a Request can't
+	// represent.
+	var der, err = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{Organization: []string{"One", "Two"}},
+	}, key)
+	if err != nil {
+		t.Fatalf("failed to create CSR: %v", err)
+	}
+
+	var multiValued = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+
+	// Create a copy of a valid CSR with a corrupted signature.
+	var csr = testhelpers.MustParseCSR(t, testRequestCSRPEM)
+	var corrupted = append([]byte(nil), csr.Raw...)
+	corrupted[len(corrupted)-1] ^= 0xff
+
+	var badSignature = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: corrupted})
+
+	// Create a CSR with an other name with an INTEGER value, which a Request
+	// can't represent.
+	var value = mustMarshalASN1(t, asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      mustMarshalASN1(t, 42),
+	})
+
+	var otherName = mustMarshalASN1(t, asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      append(mustMarshalASN1(t, asn1.ObjectIdentifier{1, 2, 3, 4}), value...),
+	})
+
+	if der, err = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		ExtraExtensions: []pkix.Extension{
+			{
+				Id:    asn1.ObjectIdentifier{2, 5, 29, 17},
+				Value: mustMarshalASN1(t, []asn1.RawValue{{FullBytes: otherName}}),
+			},
+		},
+	}, key); err != nil {
+		t.Fatalf("failed to create CSR: %v", err)
+	}
+
+	var integerOtherName = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+
+	var testcases = []struct {
+		name string
+		data []byte
+	}{
+		{"MultiValued", multiValued},
+		{"BadSignature", badSignature},
+		{"IntegerOtherName", integerOtherName},
+		{"NotPEM", []byte("not a CSR")},
+		{"WrongType", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: csr.Raw})},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := hvclient.RequestFromPEM(tc.data); err == nil {
+				t.Fatalf("unexpectedly created request from CSR")
+			}
+		})
+	}
+}
+
+func TestRequestFromCSRExtraNames(t *testing.T) {
+	t.Parallel()
+
+	var req = hvclient.Request{
+		SAN: &hvclient.SAN{
+			DNSNames:    []string{"device.example.com"},
+			Emails:      []string{"device@example.com"},
+			IPAddresses: []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1")},
+			URIs:        []*url.URL{testhelpers.MustParseURI(t, "urn:example:device:1")},
+			OtherNames: []hvclient.OtherName{
+				{
+					OID:   asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2, 3},
+					Value: "device@example.com",
+				},
+				{
+					OID:       asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 8, 4},
+					ValueType: hvclient.IA5String,
+					Value:     "HW-1234",
+				},
+				{
+					OID:       asn1.ObjectIdentifier{1, 2, 3, 4},
+					ValueType: hvclient.PrintableString,
+					Value:     "Device 1",
+				},
+			},
+			RegisteredIDs: []asn1.ObjectIdentifier{
+				{1, 3, 6, 1, 4, 1, 4146, 1, 1},
+			},
+		},
+		PrivateKey: testhelpers.MustGetPrivateKeyFromFile(t, "testdata/ec_priv.key"),
+	}
+
+	var csr, err = req.PKCS10()
+	if err != nil {
+		t.Fatalf("failed to create CSR: %v", err)
+	}
+
+	// Check the standard library can still parse the other names.
+	if len(csr.DNSNames) != 1 || len(csr.EmailAddresses) != 1 ||
+		len(csr.IPAddresses) != 2 || len(csr.URIs) != 1 {
+		t.Fatalf("standard names not parsed from CSR: %v %v %v %v",
+			csr.DNSNames, csr.EmailAddresses, csr.IPAddresses, csr.URIs)
+	}
+
+	var got *hvclient.Request
+	if got, err = hvclient.RequestFromCSR(csr); err != nil {
+		t.Fatalf("failed to create request from CSR: %v", err)
+	}
+
+	if !got.SAN.Equal(req.SAN) {
+		t.Fatalf("got %v, want %v", got.SAN, req.SAN)
+	}
+}
+
+func TestRequestFromCSRCustomExtensions(t *testing.T) {
+	t.Parallel()
+
+	var key = testhelpers.MustGetPrivateKeyFromFile(t, "testdata/ec_priv.key")
+
+	var der, err = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "device"},
+		DNSNames: []string{"device.example.com"},
+		ExtraExtensions: []pkix.Extension{
+			{
+				Id:    asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1},
+				Value: []byte{0x0c, 0x07, 'H', 'W', '-', '1', '2', '3', '4'},
+			},
+			{
+				// Key usages are a standard extension, and are not
+				// preserved as a custom extension.
+				Id:       asn1.ObjectIdentifier{2, 5, 29, 15},
+				Critical: true,
+				Value:    []byte{0x03, 0x02, 0x07, 0x80},
+			},
+		},
+	}, key)
+	if err != nil {
+		t.Fatalf("failed to create CSR: %v", err)
+	}
+
+	var csr *x509.CertificateRequest
+	if csr, err = x509.ParseCertificateRequest(der); err != nil {
+		t.Fatalf("failed to parse CSR: %v", err)
+	}
+
+	// Custom extensions are not copied by default.
+	var got *hvclient.Request
+	if got, err = hvclient.RequestFromCSR(csr); err != nil {
+		t.Fatalf("failed to create request from CSR: %v", err)
+	}
+
+	if got.CustomExtensions != nil {
+		t.Fatalf("got custom extensions %v, want none", got.CustomExtensions)
+	}
+
+	if got, err = hvclient.RequestFromCSR(csr, hvclient.WithCustomExtensionsFromCSR()); err != nil {
+		t.Fatalf("failed to create request from CSR: %v", err)
+	}
+
+	var want = []hvclient.OIDAndString{
+		{OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}, Value: "0c0748572d31323334"},
+	}
+
+	if !cmp.Equal(got.CustomExtensions, want) {
+		t.Fatalf("got custom extensions %v, want %v", got.CustomExtensions, want)
+	}
+}
+
+func TestRequestFromCSRWithoutSANTypes(t *testing.T) {
+	t.Parallel()
+
+	var dnsNames = []string{"device.example.com"}
+	var emails = []string{"device@example.com"}
+	var ips = []net.IP{net.ParseIP("10.0.0.1")}
+	var uris = []*url.URL{testhelpers.MustParseURI(t, "urn:example:device:1")}
+
+	var req = hvclient.Request{
+		Subject: &hvclient.DN{CommonName: "device"},
+		SAN: &hvclient.SAN{
+			DNSNames:    dnsNames,
+			Emails:      emails,
+			IPAddresses: ips,
+			URIs:        uris,
+		},
+		PrivateKey: testhelpers.MustGetPrivateKeyFromFile(t, "testdata/ec_priv.key"),
+	}
+
+	var csr, err = req.PKCS10()
+	if err != nil {
+		t.Fatalf("failed to create CSR: %v", err)
+	}
+
+	var testcases = []struct {
+		name string
+		opts []hvclient.RequestOption
+		want *hvclient.SAN
+	}{
+		{
+			name: "None",
+			want: req.SAN,
+		},
+		{
+			name: "EmailAndURI",
+			opts: []hvclient.RequestOption{hvclient.WithoutSANTypes(hvclient.SANEmail, hvclient.SANURI)},
+			want: &hvclient.SAN{DNSNames: dnsNames, IPAddresses: ips},
+		},
+		{
+			name: "MultipleOptions",
+			opts: []hvclient.RequestOption{
+				hvclient.WithoutSANTypes(hvclient.SANDNS),
+				hvclient.WithoutSANTypes(hvclient.SANIPAddress),
+			},
+			want: &hvclient.SAN{Emails: emails, URIs: uris},
+		},
+		{
+			name: "All",
+			opts: []hvclient.RequestOption{
+				hvclient.WithoutSANTypes(hvclient.SANDNS, hvclient.SANEmail, hvclient.SANIPAddress, hvclient.SANURI),
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got, err = hvclient.RequestFromCSR(csr, tc.opts...)
+			if err != nil {
+				t.Fatalf("failed to create request from CSR: %v", err)
+			}
+
+			if tc.want == nil {
+				if got.SAN != nil {
+					t.Fatalf("got %v, want nil", got.SAN)
+				}
+
+				return
+			}
+
+			if got.SAN == nil || !got.SAN.Equal(tc.want) {
+				t.Fatalf("got %v, want %v", got.SAN, tc.want)
+			}
+
+			if !got.Subject.Equal(req.Subject) {
+				t.Errorf("got subject %v, want %v", got.Subject, req.Subject)
+			}
+		})
+	}
+}
+
+func mustMarshalASN1(t *testing.T, val interface{}) []byte {
+	t.Helper()
+
+	var der, err = asn1.Marshal(val)
+	if err != nil {
+		t.Fatalf("failed to marshal ASN.1 value: %v", err)
+	}
+
+	return der
+}
@@ -0,0 +1,64 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+)
+
+// oidCertificateExtensionArc is the arc under which the standard certificate
+// extensions defined in RFC 5280 are assigned, such as subject alternative
+// names and key usages.
+var oidCertificateExtensionArc = asn1.ObjectIdentifier{2, 5, 29}
+
+// CustomExtensionFromPKIX returns a custom extension with the OID and value
+// of a certificate extension, with the value hex-encoded, as required for
+// custom extensions with a value type of DER in the validation policy. HVCA
+// marks the extension critical or non-critical according to the validation
+// policy, so the Critical field of the extension is not used.
+func CustomExtensionFromPKIX(ext pkix.Extension) OIDAndString {
+	return OIDAndString{
+		OID:   ext.Id,
+		Value: hex.EncodeToString(ext.Value),
+	}
+}
+
+// customExtensionsFromPKIX returns custom extensions for each extension in
+// the list which is not a standard certificate extension, or nil if there
+// are none. Standard extensions, such as subject alternative names, are
+// either represented by other fields in a Request or set by HVCA itself.
+func customExtensionsFromPKIX(exts []pkix.Extension) []OIDAndString {
+	var result []OIDAndString
+
+	for _, ext := range exts {
+		if isStandardExtension(ext.Id) {
+			continue
+		}
+
+		result = append(result, CustomExtensionFromPKIX(ext))
+	}
+
+	return result
+}
+
+// isStandardExtension reports whether an OID identifies one of the standard
+// certificate extensions defined in RFC 5280.
+func isStandardExtension(oid asn1.ObjectIdentifier) bool {
+	return len(oid) > len(oidCertificateExtensionArc) &&
+		oid[:len(oidCertificateExtensionArc)].Equal(oidCertificateExtensionArc)
+}
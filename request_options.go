@@ -0,0 +1,121 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+// SANType is a category of subject alternative name.
+type SANType int
+
+// Subject alternative name type constants.
+const (
+	SANDNS SANType = iota + 1
+	SANEmail
+	SANIPAddress
+	SANURI
+	SANOtherName
+	SANRegisteredID
+)
+
+// RequestOption customizes a Request created from an existing certificate
+// signing request or certificate, such as by RequestFromCSR or
+// CertificateReissue.
+type RequestOption func(*requestOptions)
+
+// requestOptions holds the customizations made by request options.
+type requestOptions struct {
+	excludedSANs     map[SANType]bool
+	customExtensions bool
+}
+
+// WithoutSANTypes returns a request option which causes subject alternative
+// names of the specified types not to be copied into the request. By
+// default, subject alternative names of all types are copied.
+func WithoutSANTypes(types ...SANType) RequestOption {
+	return func(o *requestOptions) {
+		if o.excludedSANs == nil {
+			o.excludedSANs = make(map[SANType]bool)
+		}
+
+		for _, t := range types {
+			o.excludedSANs[t] = true
+		}
+	}
+}
+
+// WithCustomExtensionsFromCSR returns a request option which causes
+// extensions in a certificate signing request other than the standard
+// certificate extensions defined in RFC 5280 to be copied into the request as
+// custom extensions, as described for CustomExtensionFromPKIX. By default,
+// they are not copied, since CSRs commonly contain extensions, such as
+// Microsoft-specific ones, which HVCA will reject unless the account's
+// validation policy allows them. It has no effect on requests created from
+// certificates.
+func WithCustomExtensionsFromCSR() RequestOption {
+	return func(o *requestOptions) {
+		o.customExtensions = true
+	}
+}
+
+// newRequestOptions returns the customizations made by the specified request
+// options.
+func newRequestOptions(opts []RequestOption) *requestOptions {
+	var o requestOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &o
+}
+
+// filterSAN removes subject alternative names of any excluded types from
+// the list, and returns nil if none remain.
+func (o *requestOptions) filterSAN(san *SAN) *SAN {
+	if san == nil || len(o.excludedSANs) == 0 {
+		return san
+	}
+
+	var filtered = *san
+
+	if o.excludedSANs[SANDNS] {
+		filtered.DNSNames = nil
+	}
+
+	if o.excludedSANs[SANEmail] {
+		filtered.Emails = nil
+	}
+
+	if o.excludedSANs[SANIPAddress] {
+		filtered.IPAddresses = nil
+	}
+
+	if o.excludedSANs[SANURI] {
+		filtered.URIs = nil
+	}
+
+	if o.excludedSANs[SANOtherName] {
+		filtered.OtherNames = nil
+	}
+
+	if o.excludedSANs[SANRegisteredID] {
+		filtered.RegisteredIDs = nil
+	}
+
+	if len(filtered.DNSNames) == 0 && len(filtered.Emails) == 0 && len(filtered.IPAddresses) == 0 &&
+		len(filtered.URIs) == 0 && len(filtered.OtherNames) == 0 && len(filtered.RegisteredIDs) == 0 {
+		return nil
+	}
+
+	return &filtered
+}
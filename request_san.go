@@ -0,0 +1,259 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	"github.com/vsglobalsign/hvclient/internal/oids"
+)
+
+// GeneralName tags. See RFC 5280 4.2.1.6.
+const (
+	sanTagOtherName     = 0
+	sanTagEmail         = 1
+	sanTagDNSName       = 2
+	sanTagURI           = 6
+	sanTagIPAddress     = 7
+	sanTagRegisteredID  = 8
+	otherNameValueTagID = 0
+)
+
+// asn1OtherName is the ASN.1 structure of an otherName GeneralName, with
+// the explicit tag on the value represented by the raw value.
+type asn1OtherName struct {
+	TypeID asn1.ObjectIdentifier
+	Value  asn1.RawValue
+}
+
+// otherNameValueParams maps supported other name value types to their
+// encoding/asn1 parameters.
+var otherNameValueParams = map[ValueType]string{
+	IA5String:       "ia5",
+	PrintableString: "printable",
+	UTF8String:      "utf8",
+}
+
+// otherNameValueTypes maps ASN.1 tags of supported other name values to
+// their value types.
+var otherNameValueTypes = map[int]ValueType{
+	asn1.TagIA5String:       IA5String,
+	asn1.TagPrintableString: PrintableString,
+	asn1.TagUTF8String:      UTF8String,
+}
+
+// hasExtraNames reports whether the subject alternative names list contains
+// any names which the standard library cannot encode in a certificate
+// signing request.
+func (s *SAN) hasExtraNames() bool {
+	return len(s.OtherNames) > 0 || len(s.RegisteredIDs) > 0
+}
+
+// extension returns a subject alternative names extension containing all
+// the names in the list.
+func (s *SAN) extension() (pkix.Extension, error) {
+	var names []asn1.RawValue
+
+	for _, name := range s.Emails {
+		names = append(names, asn1.RawValue{
+			Class: asn1.ClassContextSpecific,
+			Tag:   sanTagEmail,
+			Bytes: []byte(name),
+		})
+	}
+
+	for _, name := range s.DNSNames {
+		names = append(names, asn1.RawValue{
+			Class: asn1.ClassContextSpecific,
+			Tag:   sanTagDNSName,
+			Bytes: []byte(name),
+		})
+	}
+
+	for _, uri := range s.URIs {
+		names = append(names, asn1.RawValue{
+			Class: asn1.ClassContextSpecific,
+			Tag:   sanTagURI,
+			Bytes: []byte(uri.String()),
+		})
+	}
+
+	for _, ip := range s.IPAddresses {
+		var b = ip.To4()
+		if b == nil {
+			b = ip.To16()
+		}
+
+		if b == nil {
+			return pkix.Extension{}, fmt.Errorf("invalid IP address: %v", ip)
+		}
+
+		names = append(names, asn1.RawValue{
+			Class: asn1.ClassContextSpecific,
+			Tag:   sanTagIPAddress,
+			Bytes: b,
+		})
+	}
+
+	for _, on := range s.OtherNames {
+		var name, err = on.rawValue()
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+
+		names = append(names, name)
+	}
+
+	for _, oid := range s.RegisteredIDs {
+		var der, err = asn1.MarshalWithParams(oid, fmt.Sprintf("tag:%d", sanTagRegisteredID))
+		if err != nil {
+			return pkix.Extension{}, fmt.Errorf("couldn't marshal registered ID %s: %v", oid, err)
+		}
+
+		names = append(names, asn1.RawValue{FullBytes: der})
+	}
+
+	var value, err = asn1.Marshal(names)
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("couldn't marshal subject alternative names: %v", err)
+	}
+
+	return pkix.Extension{
+		Id:    oids.OIDSubjectAltName,
+		Value: value,
+	}, nil
+}
+
+// rawValue returns the otherName GeneralName for the other name.
+func (o OtherName) rawValue() (asn1.RawValue, error) {
+	if err := o.validate(); err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	var value, err = asn1.MarshalWithParams(o.Value, otherNameValueParams[o.valueType()])
+	if err != nil {
+		return asn1.RawValue{}, fmt.Errorf("couldn't marshal other name %s: %v", o.OID, err)
+	}
+
+	var der []byte
+	der, err = asn1.MarshalWithParams(
+		asn1OtherName{
+			TypeID: o.OID,
+			Value: asn1.RawValue{
+				Class:      asn1.ClassContextSpecific,
+				Tag:        otherNameValueTagID,
+				IsCompound: true,
+				Bytes:      value,
+			},
+		},
+		fmt.Sprintf("tag:%d", sanTagOtherName),
+	)
+	if err != nil {
+		return asn1.RawValue{}, fmt.Errorf("couldn't marshal other name %s: %v", o.OID, err)
+	}
+
+	return asn1.RawValue{FullBytes: der}, nil
+}
+
+// extraNamesFromExtensions returns the other names and registered IDs in
+// the subject alternative names extension, if present, in a list of
+// extensions. An error is returned if an other name has a value which is
+// not one of the supported string types.
+func extraNamesFromExtensions(exts []pkix.Extension) ([]OtherName, []asn1.ObjectIdentifier, error) {
+	var otherNames []OtherName
+	var regIDs []asn1.ObjectIdentifier
+
+	for _, ext := range exts {
+		if !ext.Id.Equal(oids.OIDSubjectAltName) {
+			continue
+		}
+
+		var names []asn1.RawValue
+		if rest, err := asn1.Unmarshal(ext.Value, &names); err != nil {
+			return nil, nil, fmt.Errorf("couldn't parse subject alternative names: %v", err)
+		} else if len(rest) > 0 {
+			return nil, nil, errors.New("trailing data after subject alternative names")
+		}
+
+		for _, name := range names {
+			if name.Class != asn1.ClassContextSpecific {
+				continue
+			}
+
+			switch name.Tag {
+			case sanTagOtherName:
+				var on, err = otherNameFromRawValue(name)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				otherNames = append(otherNames, on)
+
+			case sanTagRegisteredID:
+				var oid asn1.ObjectIdentifier
+				if _, err := asn1.UnmarshalWithParams(
+					name.FullBytes, &oid, fmt.Sprintf("tag:%d", sanTagRegisteredID),
+				); err != nil {
+					return nil, nil, fmt.Errorf("couldn't parse registered ID: %v", err)
+				}
+
+				regIDs = append(regIDs, oid)
+			}
+		}
+	}
+
+	return otherNames, regIDs, nil
+}
+
+// otherNameFromRawValue parses an otherName GeneralName.
+func otherNameFromRawValue(name asn1.RawValue) (OtherName, error) {
+	var parsed asn1OtherName
+	if _, err := asn1.UnmarshalWithParams(
+		name.FullBytes, &parsed, fmt.Sprintf("tag:%d", sanTagOtherName),
+	); err != nil {
+		return OtherName{}, fmt.Errorf("couldn't parse other name: %v", err)
+	}
+
+	if parsed.Value.Class != asn1.ClassContextSpecific || parsed.Value.Tag != otherNameValueTagID {
+		return OtherName{}, fmt.Errorf("other name %s: malformed value", parsed.TypeID)
+	}
+
+	var value asn1.RawValue
+	if _, err := asn1.Unmarshal(parsed.Value.Bytes, &value); err != nil {
+		return OtherName{}, fmt.Errorf("other name %s: couldn't parse value: %v", parsed.TypeID, err)
+	}
+
+	var valueType, ok = otherNameValueTypes[value.Tag]
+	if !ok || value.Class != asn1.ClassUniversal {
+		return OtherName{}, fmt.Errorf("other name %s: unsupported value encoding with tag %d",
+			parsed.TypeID, value.Tag)
+	}
+
+	var on = OtherName{
+		OID:       parsed.TypeID,
+		ValueType: valueType,
+		Value:     string(value.Bytes),
+	}
+
+	if err := on.validate(); err != nil {
+		return OtherName{}, err
+	}
+
+	return on, nil
+}
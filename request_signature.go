@@ -0,0 +1,166 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// signatureAlgorithms maps the supported standard library signature
+// algorithms to the corresponding HVCA signature and hash algorithm names.
+var signatureAlgorithms = map[x509.SignatureAlgorithm]Signature{
+	x509.SHA256WithRSA:    {Algorithm: "RSA", HashAlgorithm: "SHA-256"},
+	x509.SHA384WithRSA:    {Algorithm: "RSA", HashAlgorithm: "SHA-384"},
+	x509.SHA512WithRSA:    {Algorithm: "RSA", HashAlgorithm: "SHA-512"},
+	x509.SHA256WithRSAPSS: {Algorithm: "RSA-PSS", HashAlgorithm: "SHA-256"},
+	x509.SHA384WithRSAPSS: {Algorithm: "RSA-PSS", HashAlgorithm: "SHA-384"},
+	x509.SHA512WithRSAPSS: {Algorithm: "RSA-PSS", HashAlgorithm: "SHA-512"},
+	x509.ECDSAWithSHA256:  {Algorithm: "ECDSA", HashAlgorithm: "SHA-256"},
+	x509.ECDSAWithSHA384:  {Algorithm: "ECDSA", HashAlgorithm: "SHA-384"},
+	x509.ECDSAWithSHA512:  {Algorithm: "ECDSA", HashAlgorithm: "SHA-512"},
+}
+
+// signature returns the signature algorithm preferences to be sent to HVCA,
+// taken from the SignatureAlgorithm field if it is set, or the Signature
+// field otherwise. The result is nil if neither is set, in which case HVCA
+// applies the default from the validation policy.
+func (r *Request) signature() (*Signature, error) {
+	if r.SignatureAlgorithm == x509.UnknownSignatureAlgorithm {
+		return r.Signature, nil
+	}
+
+	var sig, ok = signatureAlgorithms[r.SignatureAlgorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signature algorithm: %v", r.SignatureAlgorithm)
+	}
+
+	if r.Signature != nil && *r.Signature != sig {
+		return nil, fmt.Errorf("signature algorithm %v conflicts with signature %s with %s",
+			r.SignatureAlgorithm, r.Signature.Algorithm, r.Signature.HashAlgorithm)
+	}
+
+	return &sig, nil
+}
+
+// signPublicKey returns the PEM encoding of the public key of the private key
+// in the request, and the base64 encoding of the private key's signature of
+// the SHA-256 digest of the DER-encoded public key, which HVCA accepts as
+// proof of possession of the private key. If the request also contains a
+// public key, it must match the private key.
+func (r *Request) signPublicKey() (string, string, error) {
+	var signer, ok = r.PrivateKey.(crypto.Signer)
+	if !ok {
+		return "", "", fmt.Errorf("unsupported private key type: %T", r.PrivateKey)
+	}
+
+	var pub = signer.Public()
+	switch pub.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return "", "", fmt.Errorf("unsupported private key type: %T with public key type %T", r.PrivateKey, pub)
+	}
+
+	if r.PublicKey != nil && !publicKeysEqual(r.PublicKey, pub) {
+		return "", "", errors.New("public key does not match private key")
+	}
+
+	var pubKeyBytes, publicKey, err = publicKeyBytesAndString(pub)
+	if err != nil {
+		return "", "", err
+	}
+
+	var h = sha256.Sum256(pubKeyBytes)
+
+	// An RSA signer produces a PKCS#1 v1.5 signature and an ECDSA signer an
+	// ASN.1 encoded signature when passed a crypto.Hash, as HVCA expects.
+	var signedBytes []byte
+	if signedBytes, err = signer.Sign(rand.Reader, h[:], crypto.SHA256); err != nil {
+		return "", "", fmt.Errorf("failed to sign public key: %w", err)
+	}
+
+	return publicKey, base64.StdEncoding.EncodeToString(signedBytes), nil
+}
+
+// publicKeysEqual returns true if two public keys are equal. Either may be
+// an RSA or ECDSA public key value rather than a pointer.
+func publicKeysEqual(a, b interface{}) bool {
+	var pointer = func(key interface{}) interface{} {
+		switch k := key.(type) {
+		case rsa.PublicKey:
+			return &k
+		case ecdsa.PublicKey:
+			return &k
+		}
+
+		return key
+	}
+
+	var k, ok = pointer(a).(interface{ Equal(crypto.PublicKey) bool })
+
+	return ok && k.Equal(pointer(b))
+}
+
+// validateSignature checks the requested signature algorithm against the
+// policy.
+func (p *Policy) validateSignature(req *Request) []error {
+	if p.SignaturePolicy == nil {
+		return nil
+	}
+
+	var sig, err = req.signature()
+	if err != nil {
+		return []error{PolicyViolation{"signature", err.Error()}}
+	}
+
+	if sig == nil {
+		sig = &Signature{}
+	}
+
+	var errs []error
+	errs = append(errs, validateAlgorithm("signature.algorithm", p.SignaturePolicy.Algorithm, sig.Algorithm)...)
+	errs = append(errs, validateAlgorithm("signature.hash_algorithm", p.SignaturePolicy.HashAlgorithm, sig.HashAlgorithm)...)
+
+	return errs
+}
+
+// validateAlgorithm checks an algorithm name against its policy, which may
+// be nil. An empty value is always permitted, even if the policy requires a
+// value, since HVCA then applies the default from the policy.
+func validateAlgorithm(name string, policy *AlgorithmPolicy, value string) []error {
+	if policy == nil || value == "" {
+		return nil
+	}
+
+	switch {
+	case policy.Presence == Forbidden:
+		return []error{PolicyViolation{name, "value is forbidden"}}
+
+	case len(policy.List) > 0 && !containsString(policy.List, value):
+		return []error{PolicyViolation{name, fmt.Sprintf("value %q is not permitted (allowed: %s)",
+			value, strings.Join(policy.List, ", "))}}
+	}
+
+	return nil
+}
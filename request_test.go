@@ -16,14 +16,28 @@ limitations under the License.
 package hvclient_test
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/asn1"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"math/big"
 	"net"
 	"net/url"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/vsglobalsign/hvclient"
 	"github.com/vsglobalsign/hvclient/internal/testhelpers"
 )
@@ -135,6 +149,9 @@ const testRequestFullJSON = `{
                 "type": "1.3.6.1.4.1.311.20.2.3",
                 "value": "upn@demo.hvca.globalsign.com"
             }
+        ],
+        "registered_ids": [
+            "1.3.6.1.4.1.4146.1.1"
         ]
     },
     "extended_key_usages": [
@@ -233,12 +250,15 @@ var testRequestFullRequest = hvclient.Request{
 		URIs: []*url.URL{
 			mustParseURI("http://test.demo.hvca.globalsign.com/uri"),
 		},
-		OtherNames: []hvclient.OIDAndString{
+		OtherNames: []hvclient.OtherName{
 			{
 				OID:   asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2, 3},
 				Value: "upn@demo.hvca.globalsign.com",
 			},
 		},
+		RegisteredIDs: []asn1.ObjectIdentifier{
+			{1, 3, 6, 1, 4, 1, 4146, 1, 1},
+		},
 	},
 	EKUs: []asn1.ObjectIdentifier{
 		{1, 3, 6, 1, 5, 5, 7, 3, 1},
@@ -379,6 +399,241 @@ func TestRequestMarshalJSON(t *testing.T) {
 	}
 }
 
+func TestValidityMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	var future = time.Now().Add(time.Hour * 24 * 30).Truncate(time.Second)
+
+	var testcases = []struct {
+		name          string
+		validity      hvclient.Validity
+		wantNotBefore int64
+		wantNotAfter  int64
+	}{
+		{
+			name: "FutureNotBefore",
+			validity: hvclient.Validity{
+				NotBefore: future,
+				NotAfter:  future.Add(time.Hour * 24 * 90),
+			},
+			wantNotBefore: future.Unix(),
+			wantNotAfter:  future.Add(time.Hour * 24 * 90).Unix(),
+		},
+		{
+			name: "SubSecond",
+			validity: hvclient.Validity{
+				NotBefore: time.Unix(1550000000, int64(time.Millisecond*999)),
+				NotAfter:  time.Unix(1560000000, int64(time.Millisecond*999)),
+			},
+			wantNotBefore: 1550000000,
+			wantNotAfter:  1560000000,
+		},
+		{
+			name: "MaximumDuration",
+			validity: hvclient.Validity{
+				NotBefore: future,
+				NotAfter:  time.Unix(0, 0),
+			},
+			wantNotBefore: future.Unix(),
+			wantNotAfter:  0,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var data, err = json.Marshal(&tc.validity)
+			if err != nil {
+				t.Fatalf("couldn't marshal JSON: %v", err)
+			}
+
+			var want = fmt.Sprintf(`{"not_before":%d,"not_after":%d}`, tc.wantNotBefore, tc.wantNotAfter)
+			if string(data) != want {
+				t.Errorf("got %s, want %s", data, want)
+			}
+		})
+	}
+}
+
+func TestValidityMarshalJSONZeroNotBefore(t *testing.T) {
+	t.Parallel()
+
+	var before = time.Now().Unix()
+
+	var data, err = json.Marshal(&hvclient.Validity{NotAfter: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("couldn't marshal JSON: %v", err)
+	}
+
+	var got struct {
+		NotBefore int64 `json:"not_before"`
+	}
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("couldn't unmarshal JSON: %v", err)
+	}
+
+	if got.NotBefore < before || got.NotBefore > time.Now().Unix() {
+		t.Errorf("got not-before %d, want current time", got.NotBefore)
+	}
+}
+
+func TestValidityMarshalJSONDuration(t *testing.T) {
+	t.Parallel()
+
+	var before = time.Now().Unix()
+
+	var data, err = json.Marshal(&hvclient.Validity{Duration: time.Hour * 24 * 90})
+	if err != nil {
+		t.Fatalf("couldn't marshal JSON: %v", err)
+	}
+
+	var got struct {
+		NotBefore int64 `json:"not_before"`
+		NotAfter  int64 `json:"not_after"`
+	}
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("couldn't unmarshal JSON: %v", err)
+	}
+
+	if got.NotBefore < before || got.NotBefore > time.Now().Unix() {
+		t.Errorf("got not-before %d, want current time", got.NotBefore)
+	}
+
+	if want := got.NotBefore + 60*60*24*90; got.NotAfter != want {
+		t.Errorf("got not-after %d, want %d", got.NotAfter, want)
+	}
+}
+
+func TestRequestMarshalTemplate(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		validity *hvclient.Validity
+		want     string
+	}{
+		{
+			name:     "Duration",
+			validity: &hvclient.Validity{Duration: time.Hour * 24 * 90},
+			want:     `{"validity":{"duration":7776000},"subject_dn":{"common_name":"John Doe"}}`,
+		},
+		{
+			name: "Absolute",
+			validity: &hvclient.Validity{
+				NotBefore: time.Unix(1477958400, 0),
+				NotAfter:  time.Unix(1509494400, 0),
+			},
+			want: `{"validity":{"not_before":1477958400,"not_after":1509494400},"subject_dn":{"common_name":"John Doe"}}`,
+		},
+		{
+			name: "NoValidity",
+			want: `{"subject_dn":{"common_name":"John Doe"}}`,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var req = hvclient.Request{
+				Validity: tc.validity,
+				Subject:  &hvclient.DN{CommonName: "John Doe"},
+			}
+
+			var data, err = req.MarshalTemplate()
+			if err != nil {
+				t.Fatalf("couldn't marshal template: %v", err)
+			}
+
+			if string(data) != tc.want {
+				t.Fatalf("got %s, want %s", data, tc.want)
+			}
+
+			// The template should unmarshal to the original request, with
+			// any duration still relative to the time of issuance.
+			var got hvclient.Request
+			if err = json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("couldn't unmarshal JSON: %v", err)
+			}
+
+			if !got.Equal(req) {
+				t.Errorf("got %v, want %v", got, req)
+			}
+		})
+	}
+}
+
+func TestValidityUnmarshalJSONDuration(t *testing.T) {
+	t.Parallel()
+
+	var got hvclient.Validity
+	if err := json.Unmarshal([]byte(`{"duration":3600,"not_after":1600000000}`), &got); err == nil {
+		t.Errorf("unexpectedly unmarshalled duration with not-after time")
+	}
+}
+
+func TestRequestMarshalJSONSignatureAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name string
+		req  hvclient.Request
+		want *hvclient.Signature
+	}{
+		{
+			name: "RSAPSS",
+			req:  hvclient.Request{SignatureAlgorithm: x509.SHA384WithRSAPSS},
+			want: &hvclient.Signature{Algorithm: "RSA-PSS", HashAlgorithm: "SHA-384"},
+		},
+		{
+			name: "ECDSA",
+			req:  hvclient.Request{SignatureAlgorithm: x509.ECDSAWithSHA384},
+			want: &hvclient.Signature{Algorithm: "ECDSA", HashAlgorithm: "SHA-384"},
+		},
+		{
+			name: "AgreesWithSignature",
+			req: hvclient.Request{
+				Signature:          &hvclient.Signature{Algorithm: "RSA", HashAlgorithm: "SHA-512"},
+				SignatureAlgorithm: x509.SHA512WithRSA,
+			},
+			want: &hvclient.Signature{Algorithm: "RSA", HashAlgorithm: "SHA-512"},
+		},
+		{
+			name: "Unset",
+			req:  hvclient.Request{},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var data, err = json.Marshal(tc.req)
+			if err != nil {
+				t.Fatalf("couldn't marshal JSON: %v", err)
+			}
+
+			var got struct {
+				Signature *hvclient.Signature `json:"signature"`
+			}
+			if err = json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("couldn't unmarshal JSON: %v", err)
+			}
+
+			if !cmp.Equal(got.Signature, tc.want) {
+				t.Errorf("got signature %v, want %v", got.Signature, tc.want)
+			}
+		})
+	}
+}
+
 func TestRequestMarshalJSONFailure(t *testing.T) {
 	t.Parallel()
 
@@ -393,10 +648,248 @@ func TestRequestMarshalJSONFailure(t *testing.T) {
 			},
 		},
 		{
-			name: "BadPrivateKey",
-			req: hvclient.Request{
-				PrivateKey: "not a private key",
+			name: "BadPrivateKey",
+			req: hvclient.Request{
+				PrivateKey: "not a private key",
+			},
+		},
+		{
+			name: "ValidityNotAfterBeforeNotBefore",
+			req: hvclient.Request{
+				Validity: &hvclient.Validity{
+					NotBefore: time.Unix(1560000000, 0),
+					NotAfter:  time.Unix(1550000000, 0),
+				},
+			},
+		},
+		{
+			name: "ValidityEqualWholeSeconds",
+			req: hvclient.Request{
+				Validity: &hvclient.Validity{
+					NotBefore: time.Unix(1560000000, 0),
+					NotAfter:  time.Unix(1560000000, int64(time.Millisecond*500)),
+				},
+			},
+		},
+		{
+			name: "ValidityDurationWithNotBefore",
+			req: hvclient.Request{
+				Validity: &hvclient.Validity{
+					NotBefore: time.Unix(1560000000, 0),
+					Duration:  time.Hour,
+				},
+			},
+		},
+		{
+			name: "ValidityDurationWithMaximumNotAfter",
+			req: hvclient.Request{
+				Validity: &hvclient.Validity{
+					NotAfter: time.Unix(0, 0),
+					Duration: time.Hour,
+				},
+			},
+		},
+		{
+			name: "ValidityNegativeDuration",
+			req: hvclient.Request{
+				Validity: &hvclient.Validity{
+					Duration: -time.Hour,
+				},
+			},
+		},
+		{
+			name: "OtherNameUnsupportedValueType",
+			req: hvclient.Request{
+				SAN: &hvclient.SAN{
+					OtherNames: []hvclient.OtherName{
+						{
+							OID:       asn1.ObjectIdentifier{1, 2, 3, 4},
+							ValueType: hvclient.Integer,
+							Value:     "42",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "OtherNameBadPrintableString",
+			req: hvclient.Request{
+				SAN: &hvclient.SAN{
+					OtherNames: []hvclient.OtherName{
+						{
+							OID:       asn1.ObjectIdentifier{1, 2, 3, 4},
+							ValueType: hvclient.PrintableString,
+							Value:     "device@example.com",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "OtherNameBadIA5String",
+			req: hvclient.Request{
+				SAN: &hvclient.SAN{
+					OtherNames: []hvclient.OtherName{
+						{
+							OID:       asn1.ObjectIdentifier{1, 2, 3, 4},
+							ValueType: hvclient.IA5String,
+							Value:     "Zoë",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "OtherNameNoOID",
+			req: hvclient.Request{
+				SAN: &hvclient.SAN{
+					OtherNames: []hvclient.OtherName{
+						{
+							Value: "value",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "UnsupportedSignatureAlgorithm",
+			req: hvclient.Request{
+				SignatureAlgorithm: x509.SHA1WithRSA,
+			},
+		},
+		{
+			name: "ConflictingSignatureAlgorithm",
+			req: hvclient.Request{
+				Signature:          &hvclient.Signature{Algorithm: "RSA", HashAlgorithm: "SHA-256"},
+				SignatureAlgorithm: x509.SHA384WithRSAPSS,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := json.Marshal(tc.req); err == nil {
+				t.Fatalf("unexpectedly marshalled JSON: %v", err)
+			}
+		})
+	}
+}
+
+func TestRequestUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name string
+		json string
+		want hvclient.Request
+	}{
+		{
+			name: "Full",
+			json: testRequestFullJSON,
+			want: testRequestFullRequest,
+		},
+		{
+			name: "Validity",
+			json: `{"validity":{"not_before":1550000000,"not_after":1560000000}}`,
+			want: hvclient.Request{
+				Validity: &hvclient.Validity{
+					NotBefore: time.Unix(1550000000, 0),
+					NotAfter:  time.Unix(1560000000, 0),
+				},
+			},
+		},
+		{
+			name: "ValidityRFC3339",
+			json: `{"validity":{"not_before":"2019-02-12T19:33:20Z","not_after":"2019-06-08T15:20:00+02:00"}}`,
+			want: hvclient.Request{
+				Validity: &hvclient.Validity{
+					NotBefore: time.Unix(1550000000, 0),
+					NotAfter:  time.Unix(1560000000, 0),
+				},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got *hvclient.Request
+			var err = json.Unmarshal([]byte(tc.json), &got)
+			if err != nil {
+				t.Fatalf("couldn't unmarshal JSON: %v", err)
+			}
+
+			if !got.Equal(tc.want) {
+				t.Errorf("not equal")
+			}
+		})
+	}
+}
+
+func TestRequestPublicKeyTypesRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		generate func() (crypto.PublicKey, error)
+		err      bool
+	}{
+		{
+			name: "RSA2048",
+			generate: func() (crypto.PublicKey, error) {
+				var key, err = rsa.GenerateKey(rand.Reader, 2048)
+				return &key.PublicKey, err
+			},
+		},
+		{
+			name: "RSA3072",
+			generate: func() (crypto.PublicKey, error) {
+				var key, err = rsa.GenerateKey(rand.Reader, 3072)
+				return &key.PublicKey, err
+			},
+		},
+		{
+			name: "RSA4096",
+			generate: func() (crypto.PublicKey, error) {
+				var key, err = rsa.GenerateKey(rand.Reader, 4096)
+				return &key.PublicKey, err
+			},
+		},
+		{
+			name: "P256",
+			generate: func() (crypto.PublicKey, error) {
+				var key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				return &key.PublicKey, err
+			},
+		},
+		{
+			name: "P384",
+			generate: func() (crypto.PublicKey, error) {
+				var key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+				return &key.PublicKey, err
+			},
+		},
+		{
+			name: "Ed25519",
+			generate: func() (crypto.PublicKey, error) {
+				var key, _, err = ed25519.GenerateKey(rand.Reader)
+				return key, err
+			},
+		},
+		{
+			name: "UnsupportedCurve",
+			generate: func() (crypto.PublicKey, error) {
+				var key, err = ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+				return &key.PublicKey, err
 			},
+			err: true,
 		},
 	}
 
@@ -406,36 +899,119 @@ func TestRequestMarshalJSONFailure(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			if _, err := json.Marshal(tc.req); err == nil {
-				t.Fatalf("unexpectedly marshalled JSON: %v", err)
+			var key, err = tc.generate()
+			if err != nil {
+				t.Fatalf("failed to generate key: %v", err)
+			}
+
+			var data []byte
+			data, err = json.Marshal(hvclient.Request{PublicKey: key})
+			if tc.err {
+				if err == nil {
+					t.Fatal("unexpectedly marshalled request")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("couldn't marshal JSON: %v", err)
+			}
+
+			var got hvclient.Request
+			if err = json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("couldn't unmarshal JSON: %v", err)
+			}
+
+			var equal, ok = key.(interface{ Equal(crypto.PublicKey) bool })
+			if !ok || !equal.Equal(got.PublicKey) {
+				t.Errorf("got public key %v, want %v", got.PublicKey, key)
 			}
 		})
 	}
 }
 
-func TestRequestUnmarshalJSON(t *testing.T) {
+func TestRequestJSONRoundTrip(t *testing.T) {
 	t.Parallel()
 
+	var rsaKey = testhelpers.MustGetPrivateKeyFromFile(t, "testdata/rsa_priv.key")
+
 	var testcases = []struct {
 		name string
-		json string
+		req  hvclient.Request
 		want hvclient.Request
 	}{
 		{
-			name: "Full",
-			json: testRequestFullJSON,
-			want: testRequestFullRequest,
+			name: "PublicKey",
+			req: hvclient.Request{
+				Subject:   &hvclient.DN{CommonName: "John Doe"},
+				PublicKey: testhelpers.MustGetPublicKeyFromFile(t, "testdata/ec_pub.key"),
+			},
 		},
 		{
-			name: "Validity",
-			json: `{"validity":{"not_before":1550000000,"not_after":1560000000}}`,
+			name: "PrivateKey",
+			req: hvclient.Request{
+				Subject:    &hvclient.DN{CommonName: "John Doe"},
+				PrivateKey: rsaKey,
+			},
 			want: hvclient.Request{
-				Validity: &hvclient.Validity{
-					NotBefore: time.Unix(1550000000, 0),
-					NotAfter:  time.Unix(1560000000, 0),
+				Subject:   &hvclient.DN{CommonName: "John Doe"},
+				PublicKey: rsaKey.(*rsa.PrivateKey).Public(),
+			},
+		},
+		{
+			name: "CSR",
+			req: hvclient.Request{
+				Subject: &hvclient.DN{CommonName: "John Doe"},
+				CSR:     testhelpers.MustParseCSR(t, testRequestCSRPEM),
+			},
+		},
+		{
+			name: "Signature",
+			req: hvclient.Request{
+				Subject: &hvclient.DN{CommonName: "John Doe"},
+				Signature: &hvclient.Signature{
+					Algorithm:     "RSA",
+					HashAlgorithm: "SHA-256",
+				},
+			},
+		},
+		{
+			name: "CustomExtensionEscaped",
+			req: hvclient.Request{
+				Subject: &hvclient.DN{CommonName: "John Doe"},
+				CustomExtensions: []hvclient.OIDAndString{
+					{OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}, Value: `device "A\1"`},
+				},
+			},
+		},
+		{
+			name: "EVSubject",
+			req: hvclient.Request{
+				Subject: &hvclient.DN{
+					CommonName:             "example.com",
+					GivenName:              "John",
+					Surname:                "Doe",
+					Organization:           "GMO GlobalSign",
+					OrganizationIdentifier: "VATGB-123456789",
+					StreetAddress:          "1 GlobalSign Road",
+					PostalCode:             "AB1 2CD",
+					Country:                "GB",
+					SerialNumber:           "01234567",
+					JOILocality:            "London",
+					JOIState:               "London",
+					JOICountry:             "GB",
+					BusinessCategory:       "Private Organization",
 				},
 			},
 		},
+		{
+			name: "Profile",
+			req: hvclient.Request{
+				Profile: "tls-server",
+				Subject: &hvclient.DN{CommonName: "John Doe"},
+			},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -444,14 +1020,36 @@ func TestRequestUnmarshalJSON(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			var got *hvclient.Request
-			var err = json.Unmarshal([]byte(tc.json), &got)
+			var want = tc.want
+			if want.Subject == nil {
+				want = tc.req
+			}
+
+			var data, err = json.Marshal(tc.req)
 			if err != nil {
+				t.Fatalf("couldn't marshal JSON: %v", err)
+			}
+
+			var got hvclient.Request
+			if err = json.Unmarshal(data, &got); err != nil {
 				t.Fatalf("couldn't unmarshal JSON: %v", err)
 			}
 
-			if !got.Equal(tc.want) {
-				t.Errorf("not equal")
+			if !got.Equal(want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+
+			if !cmp.Equal(got.PublicKey, want.PublicKey) {
+				t.Errorf("got public key %v, want %v", got.PublicKey, want.PublicKey)
+			}
+
+			if (got.CSR == nil) != (want.CSR == nil) ||
+				got.CSR != nil && !bytes.Equal(got.CSR.Raw, want.CSR.Raw) {
+				t.Errorf("got CSR %v, want %v", got.CSR, want.CSR)
+			}
+
+			if !cmp.Equal(got.Signature, want.Signature) {
+				t.Errorf("got signature %v, want %v", got.Signature, want.Signature)
 			}
 		})
 	}
@@ -465,10 +1063,16 @@ func TestRequestUnmarshalJSONFailure(t *testing.T) {
 		`{"custom_extensions":{"not.numbers":"NIL"}}`,
 		`{"san":{"uris":["$http://bad.url"]}}`,
 		`{"san":{"other_names":[{"type":"a.b.c","value":"value"}]}}`,
+		`{"san":{"registered_ids":["a.b.c"]}}`,
 		`{"subject_da":{"date_of_birth":"tuesday"}}`,
 		`{"subject_da":{"date_of_birth":true}}`,
 		`{"qualified_statements":{"semantics":{"identifier":true}}}`,
 		`{"ms_extension_template":{"id":true}}`,
+		`{"validity":{"not_before":"yesterday","not_after":1560000000}}`,
+		`{"public_key":"not a PEM block"}`,
+		`{"public_key":"-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----"}`,
+		`{"public_key":"-----BEGIN PUBLIC KEY-----\nAAAA\n-----END PUBLIC KEY-----"}`,
+		`{"key_usages":["digital_signature","proof_of_life"]}`,
 	}
 
 	for _, tc := range testcases {
@@ -971,7 +1575,7 @@ func TestRequestNotEqual(t *testing.T) {
 			name: "SANOtherNamesLength",
 			first: hvclient.Request{
 				SAN: &hvclient.SAN{
-					OtherNames: []hvclient.OIDAndString{
+					OtherNames: []hvclient.OtherName{
 						{
 							OID:   asn1.ObjectIdentifier{1, 2, 3, 4},
 							Value: "a value",
@@ -985,7 +1589,7 @@ func TestRequestNotEqual(t *testing.T) {
 			},
 			second: hvclient.Request{
 				SAN: &hvclient.SAN{
-					OtherNames: []hvclient.OIDAndString{
+					OtherNames: []hvclient.OtherName{
 						{
 							OID:   asn1.ObjectIdentifier{1, 2, 3, 4},
 							Value: "a value",
@@ -998,7 +1602,7 @@ func TestRequestNotEqual(t *testing.T) {
 			name: "SANOtherNamesValue",
 			first: hvclient.Request{
 				SAN: &hvclient.SAN{
-					OtherNames: []hvclient.OIDAndString{
+					OtherNames: []hvclient.OtherName{
 						{
 							OID:   asn1.ObjectIdentifier{1, 2, 3, 4},
 							Value: "a value",
@@ -1008,7 +1612,7 @@ func TestRequestNotEqual(t *testing.T) {
 			},
 			second: hvclient.Request{
 				SAN: &hvclient.SAN{
-					OtherNames: []hvclient.OIDAndString{
+					OtherNames: []hvclient.OtherName{
 						{
 							OID:   asn1.ObjectIdentifier{1, 2, 3, 5},
 							Value: "a different value",
@@ -1017,6 +1621,43 @@ func TestRequestNotEqual(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "SANOtherNamesValueType",
+			first: hvclient.Request{
+				SAN: &hvclient.SAN{
+					OtherNames: []hvclient.OtherName{
+						{
+							OID:       asn1.ObjectIdentifier{1, 2, 3, 4},
+							ValueType: hvclient.IA5String,
+							Value:     "a value",
+						},
+					},
+				},
+			},
+			second: hvclient.Request{
+				SAN: &hvclient.SAN{
+					OtherNames: []hvclient.OtherName{
+						{
+							OID:   asn1.ObjectIdentifier{1, 2, 3, 4},
+							Value: "a value",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "SANRegisteredIDs",
+			first: hvclient.Request{
+				SAN: &hvclient.SAN{
+					RegisteredIDs: []asn1.ObjectIdentifier{{1, 2, 3, 4}},
+				},
+			},
+			second: hvclient.Request{
+				SAN: &hvclient.SAN{
+					RegisteredIDs: []asn1.ObjectIdentifier{{1, 2, 3, 5}},
+				},
+			},
+		},
 		{
 			name:  "DAFirstNil",
 			first: hvclient.Request{},
@@ -1496,6 +2137,9 @@ func TestRequestPKCS10(t *testing.T) {
 					JOICountry:         "United Kingdom",
 					Email:              "jdoe@acme.com",
 					BusinessCategory:   "Retail",
+					GivenName:          "John",
+					Surname:            "Doe",
+					PostalCode:         "LD1 5AA",
 					ExtraAttributes: []hvclient.OIDAndString{
 						{
 							OID:   asn1.ObjectIdentifier{2, 5, 4, 4},
@@ -1544,6 +2188,288 @@ func TestRequestPKCS10(t *testing.T) {
 	}
 }
 
+// opaqueSigner is a crypto.Signer which is not one of the standard library
+// private key types, such as a key held in a hardware security module.
+type opaqueSigner struct {
+	signer crypto.Signer
+}
+
+func (s opaqueSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+func (s opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.Sign(rand, digest, opts)
+}
+
+func TestRequestPublicKeySignature(t *testing.T) {
+	t.Parallel()
+
+	var rsaKey = testhelpers.MustGetPrivateKeyFromFile(t, "testdata/rsa_priv.key").(*rsa.PrivateKey)
+	var ecKey = testhelpers.MustGetPrivateKeyFromFile(t, "testdata/ec_priv.key").(*ecdsa.PrivateKey)
+
+	var testcases = []struct {
+		name string
+		req  hvclient.Request
+		want crypto.PublicKey
+	}{
+		{
+			name: "RSA",
+			req:  hvclient.Request{PrivateKey: rsaKey},
+			want: &rsaKey.PublicKey,
+		},
+		{
+			name: "ECDSA",
+			req:  hvclient.Request{PrivateKey: ecKey},
+			want: &ecKey.PublicKey,
+		},
+		{
+			name: "SignerRSA",
+			req:  hvclient.Request{PrivateKey: opaqueSigner{rsaKey}},
+			want: &rsaKey.PublicKey,
+		},
+		{
+			name: "SignerECDSA",
+			req:  hvclient.Request{PrivateKey: opaqueSigner{ecKey}},
+			want: &ecKey.PublicKey,
+		},
+		{
+			name: "MatchingPublicKey",
+			req:  hvclient.Request{PrivateKey: opaqueSigner{ecKey}, PublicKey: &ecKey.PublicKey},
+			want: &ecKey.PublicKey,
+		},
+		{
+			name: "MatchingPublicKeyValue",
+			req:  hvclient.Request{PrivateKey: rsaKey, PublicKey: rsaKey.PublicKey},
+			want: &rsaKey.PublicKey,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var data, err = json.Marshal(tc.req)
+			if err != nil {
+				t.Fatalf("failed to marshal request: %v", err)
+			}
+
+			var got struct {
+				PublicKey          string `json:"public_key"`
+				PublicKeySignature string `json:"public_key_signature"`
+			}
+			if err = json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("failed to unmarshal JSON: %v", err)
+			}
+
+			var block, _ = pem.Decode([]byte(got.PublicKey))
+			if block == nil {
+				t.Fatalf("failed to decode public key PEM: %q", got.PublicKey)
+			}
+
+			var pub crypto.PublicKey
+			if pub, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+				t.Fatalf("failed to parse public key: %v", err)
+			}
+
+			if !pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(tc.want) {
+				t.Errorf("got public key %v, want %v", pub, tc.want)
+			}
+
+			var sig []byte
+			if sig, err = base64.StdEncoding.DecodeString(got.PublicKeySignature); err != nil {
+				t.Fatalf("failed to decode signature: %v", err)
+			}
+
+			var digest = sha256.Sum256(block.Bytes)
+
+			switch k := pub.(type) {
+			case *rsa.PublicKey:
+				if err = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err != nil {
+					t.Errorf("failed to verify signature: %v", err)
+				}
+
+			case *ecdsa.PublicKey:
+				if !ecdsa.VerifyASN1(k, digest[:], sig) {
+					t.Errorf("failed to verify signature")
+				}
+			}
+		})
+	}
+}
+
+func TestRequestPublicKeySignatureFailure(t *testing.T) {
+	t.Parallel()
+
+	var rsaKey = testhelpers.MustGetPrivateKeyFromFile(t, "testdata/rsa_priv.key").(*rsa.PrivateKey)
+	var ecKey = testhelpers.MustGetPrivateKeyFromFile(t, "testdata/ec_priv.key").(*ecdsa.PrivateKey)
+
+	var _, edKey, err = ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+
+	var testcases = []struct {
+		name string
+		req  hvclient.Request
+	}{
+		{
+			name: "MismatchedPublicKey",
+			req:  hvclient.Request{PrivateKey: opaqueSigner{rsaKey}, PublicKey: &ecKey.PublicKey},
+		},
+		{
+			name: "Ed25519",
+			req:  hvclient.Request{PrivateKey: edKey},
+		},
+		{
+			name: "SignerEd25519",
+			req:  hvclient.Request{PrivateKey: opaqueSigner{edKey}},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := json.Marshal(tc.req); err == nil {
+				t.Errorf("unexpectedly marshalled request")
+			}
+		})
+	}
+}
+
+func TestRequestUsages(t *testing.T) {
+	t.Parallel()
+
+	var req = hvclient.Request{
+		Subject:           &hvclient.DN{CommonName: "John Doe"},
+		KeyUsages:         x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment | x509.KeyUsageDecipherOnly,
+		ExtendedKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning, x509.ExtKeyUsageOCSPSigning},
+		EKUs: []asn1.ObjectIdentifier{
+			{1, 3, 6, 1, 5, 5, 7, 3, 9},
+			{1, 3, 6, 1, 4, 1, 311, 10, 3, 12},
+		},
+		PrivateKey: testhelpers.MustGetPrivateKeyFromFile(t, "testdata/rsa_priv.key"),
+	}
+
+	var data, err = json.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+
+	var got struct {
+		EKUs      []string `json:"extended_key_usages"`
+		KeyUsages []string `json:"key_usages"`
+	}
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to unmarshal JSON: %v", err)
+	}
+
+	// The duplicated OCSP signing OID should appear only once.
+	if want := []string{"1.3.6.1.5.5.7.3.3", "1.3.6.1.5.5.7.3.9", "1.3.6.1.4.1.311.10.3.12"}; !cmp.Equal(got.EKUs, want) {
+		t.Errorf("got extended key usages %v, want %v", got.EKUs, want)
+	}
+
+	if want := []string{"digital_signature", "content_commitment", "decipher_only"}; !cmp.Equal(got.KeyUsages, want) {
+		t.Errorf("got key usages %v, want %v", got.KeyUsages, want)
+	}
+
+	var roundTrip hvclient.Request
+	if err = json.Unmarshal(data, &roundTrip); err != nil {
+		t.Fatalf("failed to unmarshal request: %v", err)
+	}
+
+	if roundTrip.KeyUsages != req.KeyUsages {
+		t.Errorf("got key usages %v, want %v", roundTrip.KeyUsages, req.KeyUsages)
+	}
+
+	if roundTrip.Equal(hvclient.Request{EKUs: roundTrip.EKUs}) {
+		t.Errorf("request with key usages unexpectedly equal to request without")
+	}
+
+	if !roundTrip.Equal(hvclient.Request{
+		Subject:   req.Subject,
+		KeyUsages: req.KeyUsages,
+		EKUs: []asn1.ObjectIdentifier{
+			{1, 3, 6, 1, 5, 5, 7, 3, 3},
+			{1, 3, 6, 1, 5, 5, 7, 3, 9},
+			{1, 3, 6, 1, 4, 1, 311, 10, 3, 12},
+		},
+	}) {
+		t.Errorf("round-tripped request not equal to original")
+	}
+
+	var csr *x509.CertificateRequest
+	if csr, err = req.PKCS10(); err != nil {
+		t.Fatalf("couldn't build PKCS10 request: %v", err)
+	}
+
+	// Issue a self-signed certificate from the CSR to have the standard
+	// library parse the extensions.
+	var template = &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		NotBefore:       time.Now(),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: csr.Extensions,
+	}
+
+	var der []byte
+	if der, err = x509.CreateCertificate(rand.Reader, template, template, csr.PublicKey, req.PrivateKey); err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	var cert *x509.Certificate
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	if cert.KeyUsage != req.KeyUsages {
+		t.Errorf("got key usage %v, want %v", cert.KeyUsage, req.KeyUsages)
+	}
+
+	if want := []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning, x509.ExtKeyUsageOCSPSigning}; !cmp.Equal(cert.ExtKeyUsage, want) {
+		t.Errorf("got extended key usages %v, want %v", cert.ExtKeyUsage, want)
+	}
+
+	if want := []asn1.ObjectIdentifier{{1, 3, 6, 1, 4, 1, 311, 10, 3, 12}}; !cmp.Equal(cert.UnknownExtKeyUsage, want) {
+		t.Errorf("got unknown extended key usages %v, want %v", cert.UnknownExtKeyUsage, want)
+	}
+}
+
+func TestRequestUsagesFailure(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name    string
+		request hvclient.Request
+	}{
+		{
+			name:    "UnknownExtendedKeyUsage",
+			request: hvclient.Request{ExtendedKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsage(999)}},
+		},
+		{
+			name:    "UnknownKeyUsage",
+			request: hvclient.Request{KeyUsages: x509.KeyUsage(1 << 12)},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := json.Marshal(tc.request); err == nil {
+				t.Fatalf("unexpectedly marshalled request")
+			}
+		})
+	}
+}
+
 func TestRequestPKCS10Failure(t *testing.T) {
 	t.Parallel()
 
@@ -1560,6 +2486,21 @@ func TestRequestPKCS10Failure(t *testing.T) {
 				PublicKey: testhelpers.MustGetPublicKeyFromFile(t, "testdata/rsa_pub.key"),
 			},
 		},
+		{
+			name: "OtherNameUnsupportedValueType",
+			request: hvclient.Request{
+				SAN: &hvclient.SAN{
+					OtherNames: []hvclient.OtherName{
+						{
+							OID:       asn1.ObjectIdentifier{1, 2, 3, 4},
+							ValueType: hvclient.DER,
+							Value:     "BQA=",
+						},
+					},
+				},
+				PrivateKey: testhelpers.MustGetPrivateKeyFromFile(t, "testdata/rsa_priv.key"),
+			},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -0,0 +1,190 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/bits"
+
+	"github.com/vsglobalsign/hvclient/internal/oids"
+)
+
+// extKeyUsage is a standard extended key usage together with its OID and a
+// name suitable for use in error messages.
+type extKeyUsage struct {
+	usage x509.ExtKeyUsage
+	oid   asn1.ObjectIdentifier
+	name  string
+}
+
+// extKeyUsages lists the extended key usages defined by the standard library.
+var extKeyUsages = []extKeyUsage{
+	{x509.ExtKeyUsageAny, asn1.ObjectIdentifier{2, 5, 29, 37, 0}, "any"},
+	{x509.ExtKeyUsageServerAuth, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 1}, "server authentication"},
+	{x509.ExtKeyUsageClientAuth, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 2}, "client authentication"},
+	{x509.ExtKeyUsageCodeSigning, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 3}, "code signing"},
+	{x509.ExtKeyUsageEmailProtection, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 4}, "email protection"},
+	{x509.ExtKeyUsageIPSECEndSystem, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 5}, "IPsec end system"},
+	{x509.ExtKeyUsageIPSECTunnel, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 6}, "IPsec tunnel"},
+	{x509.ExtKeyUsageIPSECUser, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 7}, "IPsec user"},
+	{x509.ExtKeyUsageTimeStamping, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}, "time stamping"},
+	{x509.ExtKeyUsageOCSPSigning, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 9}, "OCSP signing"},
+	{x509.ExtKeyUsageMicrosoftServerGatedCrypto, asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 10, 3, 3}, "Microsoft server gated crypto"},
+	{x509.ExtKeyUsageNetscapeServerGatedCrypto, asn1.ObjectIdentifier{2, 16, 840, 1, 113730, 4, 1}, "Netscape server gated crypto"},
+	{x509.ExtKeyUsageMicrosoftCommercialCodeSigning, asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 22}, "Microsoft commercial code signing"},
+	{x509.ExtKeyUsageMicrosoftKernelCodeSigning, asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 61, 1, 1}, "Microsoft kernel code signing"},
+}
+
+// keyUsageNames maps key usage bits to their names in the HVCA API, in the
+// order of the bits in the key usage extension. See RFC 5280 4.2.1.3.
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digital_signature"},
+	{x509.KeyUsageContentCommitment, "content_commitment"},
+	{x509.KeyUsageKeyEncipherment, "key_encipherment"},
+	{x509.KeyUsageDataEncipherment, "data_encipherment"},
+	{x509.KeyUsageKeyAgreement, "key_agreement"},
+	{x509.KeyUsageCertSign, "key_cert_sign"},
+	{x509.KeyUsageCRLSign, "crl_sign"},
+	{x509.KeyUsageEncipherOnly, "encipher_only"},
+	{x509.KeyUsageDecipherOnly, "decipher_only"},
+}
+
+// extKeyUsageOID returns the OID of a standard extended key usage.
+func extKeyUsageOID(usage x509.ExtKeyUsage) (asn1.ObjectIdentifier, error) {
+	for _, eku := range extKeyUsages {
+		if eku.usage == usage {
+			return eku.oid, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported extended key usage: %d", usage)
+}
+
+// extKeyUsageName returns the name of the standard extended key usage with
+// the specified OID, or the empty string if the OID is not that of a standard
+// extended key usage.
+func extKeyUsageName(oid asn1.ObjectIdentifier) string {
+	for _, eku := range extKeyUsages {
+		if eku.oid.Equal(oid) {
+			return eku.name
+		}
+	}
+
+	return ""
+}
+
+// allEKUs returns the OIDs of the standard extended key usages in the
+// request, followed by the custom extended key usage OIDs, with any
+// duplicates removed.
+func (r *Request) allEKUs() ([]asn1.ObjectIdentifier, error) {
+	var result = make([]asn1.ObjectIdentifier, 0, len(r.ExtendedKeyUsages)+len(r.EKUs))
+
+	var add = func(oid asn1.ObjectIdentifier) {
+		for _, existing := range result {
+			if existing.Equal(oid) {
+				return
+			}
+		}
+
+		result = append(result, oid)
+	}
+
+	for _, usage := range r.ExtendedKeyUsages {
+		var oid, err = extKeyUsageOID(usage)
+		if err != nil {
+			return nil, err
+		}
+
+		add(oid)
+	}
+
+	for _, oid := range r.EKUs {
+		add(oid)
+	}
+
+	return result, nil
+}
+
+// keyUsageStrings returns the HVCA API names of the bits set in a key usage.
+// An error is returned if any unknown bits are set.
+func keyUsageStrings(usage x509.KeyUsage) ([]string, error) {
+	var names []string
+
+	for _, ku := range keyUsageNames {
+		if usage&ku.usage != 0 {
+			names = append(names, ku.name)
+			usage &^= ku.usage
+		}
+	}
+
+	if usage != 0 {
+		return nil, fmt.Errorf("unsupported key usage: %d", usage)
+	}
+
+	return names, nil
+}
+
+// keyUsageFromStrings returns the key usage with the bits named in the list
+// set. An error is returned if any name is unknown.
+func keyUsageFromStrings(names []string) (x509.KeyUsage, error) {
+	var usage x509.KeyUsage
+
+outer:
+	for _, name := range names {
+		for _, ku := range keyUsageNames {
+			if ku.name == name {
+				usage |= ku.usage
+				continue outer
+			}
+		}
+
+		return 0, fmt.Errorf("unsupported key usage: %q", name)
+	}
+
+	return usage, nil
+}
+
+// keyUsageExtension returns a key usage extension with the bits in the
+// specified key usage set. See RFC 5280 4.2.1.3.
+func keyUsageExtension(usage x509.KeyUsage) (pkix.Extension, error) {
+	// In an ASN.1 BIT STRING the first bit is the most significant bit of
+	// the first byte, so the bits must be reversed.
+	var b = bits.Reverse16(uint16(usage))
+	var bs = asn1.BitString{
+		Bytes:     []byte{byte(b >> 8), byte(b)},
+		BitLength: 16 - bits.TrailingZeros16(b),
+	}
+
+	if bs.Bytes[1] == 0 {
+		bs.Bytes = bs.Bytes[:1]
+	}
+
+	var value, err = asn1.Marshal(bs)
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("couldn't marshal key usage: %v", err)
+	}
+
+	return pkix.Extension{
+		Id:    oids.OIDKeyUsage,
+		Value: value,
+	}, nil
+}
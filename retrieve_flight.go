@@ -0,0 +1,97 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// detachedContext is a context which carries the values of its parent, such
+// as tracing spans, but is never cancelled and has no deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+// Deadline returns no deadline.
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done returns nil, since the context is never cancelled.
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+// Err returns nil, since the context is never cancelled.
+func (detachedContext) Err() error {
+	return nil
+}
+
+// Value returns the value associated with the key in the parent context.
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// retrieveShared retrieves the certificate with the specified key, which is
+// its serial number in hexadecimal. Concurrent retrievals of the same
+// certificate share a single API call, and every caller receives its own
+// copy of the result. The shared call is made with a context which is not
+// cancelled if the context of the first caller is cancelled, and which
+// instead times out after the timeout specified in the configuration, so a
+// caller whose context is done returns promptly without failing the others.
+func (c *Client) retrieveShared(ctx context.Context, key string) (*CertInfo, error) {
+	var ch = c.retrieveGroup.DoChan(key, func() (interface{}, error) {
+		var sharedCtx context.Context = detachedContext{ctx}
+		if c.Config != nil && c.Config.Timeout > 0 {
+			var cancel context.CancelFunc
+			sharedCtx, cancel = context.WithTimeout(sharedCtx, c.Config.Timeout)
+			defer cancel()
+		}
+
+		var r CertInfo
+		var _, err = c.makeRequest(
+			sharedCtx,
+			endpointCertificates+"/"+url.QueryEscape(key),
+			http.MethodGet,
+			nil,
+			&r,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		c.cache().put(key, &r, time.Now())
+
+		return &r, nil
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		var info = *res.Val.(*CertInfo)
+
+		return &info, nil
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
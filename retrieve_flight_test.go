@@ -0,0 +1,153 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+	"github.com/vsglobalsign/hvclient/internal/pki"
+	"github.com/vsglobalsign/hvclient/internal/testhelpers"
+)
+
+func TestCertificateRetrieveCoalesced(t *testing.T) {
+	t.Parallel()
+
+	var cert = testhelpers.MustGetCertFromFile(t, "testdata/test_cert.pem")
+
+	var testcases = []struct {
+		name   string
+		status int
+		err    error
+	}{
+		{
+			name:   "OK",
+			status: http.StatusOK,
+		},
+		{
+			name:   "Error",
+			status: http.StatusNotFound,
+			err:    ErrNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var fetches int32
+			var started = make(chan struct{})
+			var release = make(chan struct{})
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&fetches, 1) == 1 {
+					close(started)
+				}
+
+				<-release
+
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+				w.WriteHeader(tc.status)
+
+				if tc.status == http.StatusOK {
+					fmt.Fprintf(w, `{"certificate":%q,"status":"ISSUED","updated_at":1600000000}`, pki.CertToPEMString(cert))
+				} else {
+					fmt.Fprint(w, `{"status":404,"description":"certificate not found"}`)
+				}
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, &RetryPolicy{})
+			var serial = big.NewInt(0x1234)
+
+			// The first caller's context is cancelled while the shared call
+			// is in flight, which must not affect the other callers.
+			var firstCtx, cancel = context.WithCancel(context.Background())
+			var firstErr = make(chan error, 1)
+
+			go func() {
+				var _, err = clnt.CertificateRetrieve(firstCtx, serial)
+				firstErr <- err
+			}()
+
+			<-started
+
+			const callers = 5
+
+			var wg sync.WaitGroup
+			var infos = make([]*CertInfo, callers)
+			var errs = make([]error, callers)
+
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+
+				go func(i int) {
+					defer wg.Done()
+
+					infos[i], errs[i] = clnt.CertificateRetrieve(context.Background(), serial)
+				}(i)
+			}
+
+			cancel()
+
+			if err := <-firstErr; !errors.Is(err, context.Canceled) {
+				t.Errorf("got error %v, want %v", err, context.Canceled)
+			}
+
+			// Give the other callers time to join the shared call.
+			time.Sleep(time.Millisecond * 100)
+			close(release)
+			wg.Wait()
+
+			if got := atomic.LoadInt32(&fetches); got != 1 {
+				t.Errorf("got %d retrievals from HVCA, want 1", got)
+			}
+
+			for i := 0; i < callers; i++ {
+				if tc.err != nil {
+					if !errors.Is(errs[i], tc.err) {
+						t.Errorf("got error %v, want %v", errs[i], tc.err)
+					}
+
+					continue
+				}
+
+				if errs[i] != nil {
+					t.Fatalf("failed to retrieve certificate: %v", errs[i])
+				}
+
+				if infos[i].Status != StatusIssued {
+					t.Errorf("got status %v, want %v", infos[i].Status, StatusIssued)
+				}
+			}
+
+			// Each caller receives its own copy of the result.
+			if tc.err == nil && infos[0] == infos[1] {
+				t.Errorf("callers unexpectedly share a result")
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls the automatic retrying of HVCA API requests which
+// fail with a transient error, such as a HTTP 503 service unavailable
+// response or a connection reset by the server.
+//
+// Only idempotent requests (for example, certificate and claim retrievals,
+// counters and statistics) are retried by default. Non-idempotent requests
+// such as certificate requests are retried only if RetryNonIdempotent is
+// set, since retrying them could result in the duplicate issuance of a
+// certificate.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of times a failed request will be
+	// retried. If zero, requests will not be retried.
+	MaxRetries int
+
+	// BaseDelay is the delay before the first retry. Each subsequent delay
+	// is double the previous one, subject to MaxDelay, with random jitter
+	// applied. If zero, a reasonable default will be used.
+	BaseDelay time.Duration
+
+	// MaxDelay is the maximum delay between retries. If zero, a reasonable
+	// default will be used.
+	MaxDelay time.Duration
+
+	// RetryNonIdempotent enables the retrying of non-idempotent requests,
+	// such as certificate requests.
+	RetryNonIdempotent bool
+
+	// Retryable decides whether a failed request should be retried. It is
+	// called with the HTTP status code of the response and a nil error if a
+	// response was received, or with a zero status code and the error if
+	// the request failed to execute. If nil, DefaultRetryable will be used.
+	//
+	// If a HTTP 429 too many requests response is retried and includes a
+	// Retry-After header, the delay it specifies is used instead of the
+	// computed delay, subject to MaxDelay. The same applies to a HTTP 503
+	// service unavailable response.
+	Retryable func(statusCode int, err error) bool
+
+	// MaintenanceDelay is the delay before retrying a request which failed
+	// because HVCA is undergoing maintenance, if the response did not
+	// include a Retry-After header. Maintenance typically lasts far longer
+	// than other transient failures, so the exponentially increasing
+	// delay is not used. If zero, MaxDelay will be used.
+	MaintenanceDelay time.Duration
+}
+
+const (
+	// defaultMaxRetries is the number of times to retry a request if no
+	// retry policy is specified.
+	defaultMaxRetries = 5
+
+	// defaultRetryBaseDelay is the initial time to wait before retrying.
+	// Subsequent retries will be more widely spaced.
+	defaultRetryBaseDelay = time.Second
+
+	// defaultRetryMaxDelay is the maximum time to wait between retries.
+	defaultRetryMaxDelay = time.Second * 30
+)
+
+// defaultRetryPolicy is used if no retry policy is specified in the
+// configuration.
+var defaultRetryPolicy = RetryPolicy{
+	MaxRetries: defaultMaxRetries,
+	BaseDelay:  defaultRetryBaseDelay,
+	MaxDelay:   defaultRetryMaxDelay,
+}
+
+// DefaultRetryable returns true for HTTP 502, 503 and 504 status codes, for
+// HTTP 202 status codes (which HVCA returns when a resource is not yet
+// available), and for network timeouts, connection resets and unexpected
+// connection closures.
+func DefaultRetryable(statusCode int, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+
+		return errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, syscall.ECONNREFUSED) ||
+			errors.Is(err, io.ErrUnexpectedEOF) ||
+			errors.Is(err, io.EOF)
+	}
+
+	switch statusCode {
+	case http.StatusAccepted,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// retryable returns true if a request with the specified method which failed
+// with the specified status code or error should be retried.
+func (p *RetryPolicy) retryable(method string, statusCode int, err error) bool {
+	if !p.RetryNonIdempotent && !isIdempotent(method) {
+		return false
+	}
+
+	if p.Retryable != nil {
+		return p.Retryable(statusCode, err)
+	}
+
+	return DefaultRetryable(statusCode, err)
+}
+
+// delay returns the time to wait before making the specified retry attempt,
+// where the first retry is attempt zero. The delay grows exponentially from
+// the base delay up to the maximum delay, and is randomly reduced by up to
+// half to avoid many clients retrying in lockstep.
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	var base = p.BaseDelay
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+
+	var max = p.maxDelay()
+
+	var d = base
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+
+	if d > max {
+		d = max
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// maxDelay returns the maximum delay between retries.
+func (p *RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelay <= 0 {
+		return defaultRetryMaxDelay
+	}
+
+	return p.MaxDelay
+}
+
+// maintenanceDelay returns the delay before retrying a request which failed
+// because HVCA is undergoing maintenance, if the response did not specify
+// one.
+func (p *RetryPolicy) maintenanceDelay() time.Duration {
+	if p.MaintenanceDelay > 0 {
+		return p.MaintenanceDelay
+	}
+
+	return p.maxDelay()
+}
+
+// retryAfter returns the delay requested by the Retry-After header of a HTTP
+// 429 too many requests or 503 service unavailable response, capped at the
+// maximum delay. It returns false if the response has a different status
+// code, or if the header is missing or malformed.
+func (p *RetryPolicy) retryAfter(response *http.Response, now time.Time) (time.Duration, bool) {
+	if response.StatusCode != http.StatusTooManyRequests && response.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	var d, ok = parseRetryAfter(response.Header.Get(retryAfterHeaderName), now)
+	if !ok {
+		return 0, false
+	}
+
+	if max := p.maxDelay(); d > max {
+		d = max
+	}
+
+	return d, true
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which may be
+// either a number of seconds or a HTTP date. A date in the past results in
+// a zero delay. See RFC 9110 10.2.3.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+
+		// Avoid overflow for absurdly large values, which will be capped
+		// by the caller in any case.
+		if secs > int64(math.MaxInt64/time.Second) {
+			return time.Duration(math.MaxInt64), true
+		}
+
+		return time.Duration(secs) * time.Second, true
+	}
+
+	var t, err = http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+
+	return 0, true
+}
+
+// retryPolicy returns the retry policy specified in the configuration, or
+// the default retry policy if none was specified.
+func (c *Config) retryPolicy() *RetryPolicy {
+	if c.RetryPolicy != nil {
+		return c.RetryPolicy
+	}
+
+	return &defaultRetryPolicy
+}
+
+// retryPolicyKey is the context key for a retry policy which overrides the
+// one in the configuration.
+type retryPolicyKey struct{}
+
+// withRetryPolicy returns a copy of the context which causes requests made
+// with it to use the specified retry policy instead of the one in the
+// configuration.
+func withRetryPolicy(ctx context.Context, policy *RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+// retryPolicyFromContext returns the retry policy from the context, if one
+// was added with withRetryPolicy, or the retry policy from the configuration
+// otherwise.
+func retryPolicyFromContext(ctx context.Context, conf *Config) *RetryPolicy {
+	if policy, ok := ctx.Value(retryPolicyKey{}).(*RetryPolicy); ok {
+		return policy
+	}
+
+	return conf.retryPolicy()
+}
+
+// isIdempotent returns true if the specified HTTP method is idempotent.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// sleepContext pauses for the specified duration, returning early with the
+// context's error if the context is done before the duration has elapsed.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	var timer = time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAbortedError is returned when the context is done while waiting to
+// retry a request which failed with an unsuccessful response. It matches
+// both the context error and the error from the last response with
+// errors.Is and errors.As.
+type retryAbortedError struct {
+	err  error
+	last error
+}
+
+// Error returns a string representation of the error.
+func (e retryAbortedError) Error() string {
+	return fmt.Sprintf("%v (last response: %v)", e.err, e.last)
+}
+
+// Unwrap returns the context error.
+func (e retryAbortedError) Unwrap() error {
+	return e.err
+}
+
+// Is returns true if the error from the last response matches the target.
+func (e retryAbortedError) Is(target error) bool {
+	return errors.Is(e.last, target)
+}
+
+// As finds the first error in the chain of the error from the last response
+// which matches the target, and if one is found, sets the target to that
+// error value and returns true.
+func (e retryAbortedError) As(target interface{}) bool {
+	return errors.As(e.last, target)
+}
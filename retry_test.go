@@ -0,0 +1,811 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+func TestDefaultRetryable(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name   string
+		status int
+		err    error
+		want   bool
+	}{
+		{"Accepted", http.StatusAccepted, nil, true},
+		{"BadGateway", http.StatusBadGateway, nil, true},
+		{"ServiceUnavailable", http.StatusServiceUnavailable, nil, true},
+		{"GatewayTimeout", http.StatusGatewayTimeout, nil, true},
+		{"BadRequest", http.StatusBadRequest, nil, false},
+		{"NotFound", http.StatusNotFound, nil, false},
+		{"ConnReset", 0, fmt.Errorf("wrapped: %w", syscall.ECONNRESET), true},
+		{"UnexpectedEOF", 0, io.ErrUnexpectedEOF, true},
+		{"Canceled", 0, context.Canceled, false},
+		{"DeadlineExceeded", 0, context.DeadlineExceeded, false},
+		{"Other", 0, errors.New("some other error"), false},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := DefaultRetryable(tc.status, tc.err); got != tc.want {
+				t.Errorf("got %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	t.Parallel()
+
+	var policy = RetryPolicy{
+		BaseDelay: time.Second,
+		MaxDelay:  time.Second * 10,
+	}
+
+	var testcases = []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second * 2},
+		{2, time.Second * 4},
+		{3, time.Second * 8},
+		{4, time.Second * 10},
+		{50, time.Second * 10},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(fmt.Sprintf("Attempt%d", tc.attempt), func(t *testing.T) {
+			t.Parallel()
+
+			for i := 0; i < 100; i++ {
+				var got = policy.delay(tc.attempt)
+				if got < tc.max/2 || got > tc.max {
+					t.Fatalf("got %v, want between %v and %v", got, tc.max/2, tc.max)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	var now = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var testcases = []struct {
+		name  string
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"Seconds", "120", time.Minute * 2, true},
+		{"Zero", "0", 0, true},
+		{"Padded", " 5 ", time.Second * 5, true},
+		{"Date", "Tue, 01 Jun 2021 12:00:30 GMT", time.Second * 30, true},
+		{"DatePast", "Tue, 01 Jun 2021 11:00:00 GMT", 0, true},
+		{"Huge", "99999999999999999", time.Duration(math.MaxInt64), true},
+		{"Empty", "", 0, false},
+		{"Negative", "-1", 0, false},
+		{"Fraction", "1.5", 0, false},
+		{"Garbage", "soon", 0, false},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got, ok = parseRetryAfter(tc.value, now)
+			if got != tc.want || ok != tc.ok {
+				t.Errorf("got %v, %t, want %v, %t", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestMakeRequestRetry(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		method   string
+		failures int32
+		policy   *RetryPolicy
+		want     int32
+		err      bool
+	}{
+		{
+			name:     "IdempotentRecovers",
+			method:   http.MethodGet,
+			failures: 2,
+			policy:   &RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond},
+			want:     3,
+		},
+		{
+			name:     "IdempotentExhausted",
+			method:   http.MethodGet,
+			failures: 10,
+			policy:   &RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+			want:     3,
+			err:      true,
+		},
+		{
+			name:     "NoRetries",
+			method:   http.MethodGet,
+			failures: 1,
+			policy:   &RetryPolicy{},
+			want:     1,
+			err:      true,
+		},
+		{
+			name:     "NonIdempotent",
+			method:   http.MethodPost,
+			failures: 1,
+			policy:   &RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond},
+			want:     1,
+			err:      true,
+		},
+		{
+			name:     "NonIdempotentOptIn",
+			method:   http.MethodPost,
+			failures: 1,
+			policy:   &RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, RetryNonIdempotent: true},
+			want:     2,
+		},
+		{
+			name:     "CustomPredicate",
+			method:   http.MethodGet,
+			failures: 1,
+			policy: &RetryPolicy{
+				MaxRetries: 3,
+				BaseDelay:  time.Millisecond,
+				Retryable:  func(int, error) bool { return false },
+			},
+			want: 1,
+			err:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) <= tc.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, tc.policy)
+
+			var ctx, cancel = context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			var _, err = clnt.makeRequest(ctx, "/test", tc.method, nil, nil)
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error %t", err, tc.err)
+			}
+
+			if got := atomic.LoadInt32(&calls); got != tc.want {
+				t.Errorf("got %d calls, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMakeRequestRetryContextCancelled(t *testing.T) {
+	t.Parallel()
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var clnt = newTestClient(t, server.URL, &RetryPolicy{
+		MaxRetries: 5,
+		BaseDelay:  time.Hour,
+		MaxDelay:   time.Hour,
+	})
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	var start = time.Now()
+
+	var _, err = clnt.makeRequest(ctx, "/test", http.MethodGet, nil, nil)
+	if err == nil {
+		t.Fatal("unexpectedly succeeded")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second*5 {
+		t.Errorf("request took %v, expected to abort on context cancellation", elapsed)
+	}
+
+	// The error should report both the context error and the last response.
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	var apiErr APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got error %v, want API error with status %d", err, http.StatusServiceUnavailable)
+	}
+}
+
+// releaseCheckingTransport is a HTTP transport which fails every request
+// but the last with a service unavailable status, and records whether the
+// response body and the request context of each failed attempt had been
+// released by the time the next attempt was made.
+type releaseCheckingTransport struct {
+	attempts int
+	bodies   []*closeRecordingBody
+	contexts []context.Context
+	leaked   []string
+}
+
+// closeRecordingBody is a response body which records whether it was
+// closed.
+type closeRecordingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeRecordingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (rt *releaseCheckingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	for i := range rt.bodies {
+		if !rt.bodies[i].closed {
+			rt.leaked = append(rt.leaked, fmt.Sprintf("attempt %d response body not closed", i+1))
+		}
+
+		if rt.contexts[i].Err() == nil {
+			rt.leaked = append(rt.leaked, fmt.Sprintf("attempt %d context not cancelled", i+1))
+		}
+	}
+
+	var status = http.StatusServiceUnavailable
+	if rt.attempts++; rt.attempts == 3 {
+		status = http.StatusNoContent
+	}
+
+	var body = &closeRecordingBody{Reader: strings.NewReader(`{"description":"unavailable"}`)}
+	rt.bodies = append(rt.bodies, body)
+	rt.contexts = append(rt.contexts, r.Context())
+
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{httputils.ContentTypeHeader: []string{httputils.ContentTypeJSON}},
+		Body:       body,
+		Request:    r,
+	}, nil
+}
+
+func TestMakeRequestReleasesFailedAttempts(t *testing.T) {
+	t.Parallel()
+
+	var rt = &releaseCheckingTransport{}
+
+	var clnt = newTestClient(t, "http://hvca.invalid", &RetryPolicy{
+		MaxRetries: 5,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   time.Millisecond,
+	})
+	clnt.HTTPClient = &http.Client{Transport: rt}
+	clnt.Config.RequestTimeout = time.Minute
+
+	if _, err := clnt.makeRequest(context.Background(), "/test", http.MethodGet, nil, nil); err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+
+	if rt.attempts != 3 {
+		t.Fatalf("got %d attempts, want 3", rt.attempts)
+	}
+
+	for _, leak := range rt.leaked {
+		t.Errorf("%s before retrying", leak)
+	}
+
+	// The final attempt is released on return.
+	if !rt.bodies[2].closed || rt.contexts[2].Err() == nil {
+		t.Errorf("final attempt not released on return")
+	}
+}
+
+func TestMakeRequestTimeout(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name     string
+		hangs    int32
+		policy   *RetryPolicy
+		timeout  time.Duration
+		deadline time.Duration
+		want     int32
+		err      error
+	}{
+		{
+			name:     "RetriedWithNewBudget",
+			hangs:    2,
+			policy:   &RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond},
+			timeout:  time.Millisecond * 50,
+			deadline: time.Second * 5,
+			want:     3,
+		},
+		{
+			name:     "NoRetries",
+			hangs:    1,
+			policy:   &RetryPolicy{},
+			timeout:  time.Millisecond * 50,
+			deadline: time.Second * 5,
+			want:     1,
+			err:      requestTimeoutError{timeout: time.Millisecond * 50},
+		},
+		{
+			name:     "EarlierCallerDeadline",
+			hangs:    10,
+			policy:   &RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond},
+			timeout:  time.Hour,
+			deadline: time.Millisecond * 50,
+			want:     1,
+			err:      context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) <= tc.hangs {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(time.Second * 5):
+					}
+
+					return
+				}
+
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, tc.policy)
+			clnt.Config.RequestTimeout = tc.timeout
+
+			var ctx, cancel = context.WithTimeout(context.Background(), tc.deadline)
+			defer cancel()
+
+			var _, err = clnt.makeRequest(ctx, "/test", http.MethodGet, nil, nil)
+			if (err != nil) != (tc.err != nil) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+
+			if tc.err != nil && !errors.Is(err, tc.err) {
+				t.Errorf("got error %v, want %v", err, tc.err)
+			}
+
+			if got := atomic.LoadInt32(&calls); got != tc.want {
+				t.Errorf("got %d calls, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMakeRequestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	var retry429 = func(status int, err error) bool {
+		return status == http.StatusTooManyRequests
+	}
+
+	var testcases = []struct {
+		name       string
+		header     string
+		policy     *RetryPolicy
+		calls      int32
+		minElapsed time.Duration
+		retryAfter time.Duration
+	}{
+		{
+			name:       "NoRetries",
+			header:     "3",
+			policy:     &RetryPolicy{},
+			calls:      1,
+			retryAfter: time.Second * 3,
+		},
+		{
+			name:       "NoRetriesCapped",
+			header:     "3600",
+			policy:     &RetryPolicy{MaxDelay: time.Minute},
+			calls:      1,
+			retryAfter: time.Minute,
+		},
+		{
+			name:   "NoRetriesNoHeader",
+			policy: &RetryPolicy{},
+			calls:  1,
+		},
+		{
+			// The large base delay would cause the test to time out if
+			// the Retry-After header were not honored.
+			name:   "Retried",
+			header: "0",
+			policy: &RetryPolicy{
+				MaxRetries: 2,
+				BaseDelay:  time.Hour,
+				MaxDelay:   time.Hour,
+				Retryable:  retry429,
+			},
+			calls: 3,
+		},
+		{
+			name:   "RetriedCapped",
+			header: "3600",
+			policy: &RetryPolicy{
+				MaxRetries: 1,
+				BaseDelay:  time.Millisecond,
+				MaxDelay:   time.Millisecond * 50,
+				Retryable:  retry429,
+			},
+			calls:      2,
+			minElapsed: time.Millisecond * 50,
+			retryAfter: time.Millisecond * 50,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+
+				if tc.header != "" {
+					w.Header().Set(retryAfterHeaderName, tc.header)
+				}
+
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeProblemJSON)
+				w.WriteHeader(http.StatusTooManyRequests)
+				fmt.Fprint(w, `{"description":"too many requests"}`)
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, tc.policy)
+
+			var ctx, cancel = context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			var start = time.Now()
+
+			var _, err = clnt.makeRequest(ctx, "/test", http.MethodGet, nil, nil)
+
+			if elapsed := time.Since(start); elapsed < tc.minElapsed {
+				t.Errorf("request took %v, want at least %v", elapsed, tc.minElapsed)
+			}
+
+			if got := atomic.LoadInt32(&calls); got != tc.calls {
+				t.Errorf("got %d calls, want %d", got, tc.calls)
+			}
+
+			for _, sentinel := range []error{ErrRateLimited, ErrQuotaExceeded} {
+				if !errors.Is(err, sentinel) {
+					t.Errorf("got error %v, want %v", err, sentinel)
+				}
+			}
+
+			var rlErr RateLimitError
+			if !errors.As(err, &rlErr) {
+				t.Fatalf("got error %T, want RateLimitError", err)
+			}
+
+			if got := rlErr.RetryAfter(); got != tc.retryAfter {
+				t.Errorf("got retry after %v, want %v", got, tc.retryAfter)
+			}
+
+			var apiErr APIError
+			if !errors.As(err, &apiErr) || apiErr.Description != "too many requests" {
+				t.Errorf("got API error %v, want description %q", apiErr, "too many requests")
+			}
+		})
+	}
+}
+
+func TestMakeRequestMaintenance(t *testing.T) {
+	t.Parallel()
+
+	// A sample maintenance response, as returned by HVCA during a scheduled
+	// maintenance window.
+	const maintenanceBody = `{"code":503,"description":"HVCA is undergoing scheduled maintenance until 02:00 UTC"}`
+
+	var testcases = []struct {
+		name        string
+		header      string
+		body        string
+		policy      *RetryPolicy
+		calls       int32
+		minElapsed  time.Duration
+		maintenance bool
+		retryAfter  time.Duration
+	}{
+		{
+			name:        "NoRetries",
+			header:      "120",
+			body:        maintenanceBody,
+			policy:      &RetryPolicy{MaxDelay: time.Hour},
+			calls:       1,
+			maintenance: true,
+			retryAfter:  time.Minute * 2,
+		},
+		{
+			name:        "NoRetriesNoHeader",
+			body:        maintenanceBody,
+			policy:      &RetryPolicy{},
+			calls:       1,
+			maintenance: true,
+		},
+		{
+			// The exponential delay would be a millisecond, so the elapsed
+			// time shows that the maintenance delay was used instead.
+			name: "RetriedWithMaintenanceDelay",
+			body: maintenanceBody,
+			policy: &RetryPolicy{
+				MaxRetries:       1,
+				BaseDelay:        time.Millisecond,
+				MaintenanceDelay: time.Millisecond * 100,
+			},
+			calls:       2,
+			minElapsed:  time.Millisecond * 100,
+			maintenance: true,
+		},
+		{
+			name:        "RetriedWithMaxDelay",
+			body:        maintenanceBody,
+			policy:      &RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond * 100},
+			calls:       2,
+			minElapsed:  time.Millisecond * 100,
+			maintenance: true,
+		},
+		{
+			// The large base delay would cause the test to time out if
+			// the Retry-After header were not honored.
+			name:   "RetriedWithRetryAfter",
+			header: "0",
+			body:   maintenanceBody,
+			policy: &RetryPolicy{
+				MaxRetries:       2,
+				BaseDelay:        time.Hour,
+				MaxDelay:         time.Hour,
+				MaintenanceDelay: time.Hour,
+			},
+			calls:       3,
+			maintenance: true,
+		},
+		{
+			name:   "NotMaintenance",
+			header: "0",
+			body:   `{"description":"service overloaded"}`,
+			policy: &RetryPolicy{MaxRetries: 1, BaseDelay: time.Hour},
+			calls:  2,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls int32
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+
+				if tc.header != "" {
+					w.Header().Set(retryAfterHeaderName, tc.header)
+				}
+
+				w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeProblemJSON)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, tc.policy)
+
+			var ctx, cancel = context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			var start = time.Now()
+
+			var _, err = clnt.makeRequest(ctx, "/test", http.MethodGet, nil, nil)
+
+			if elapsed := time.Since(start); elapsed < tc.minElapsed {
+				t.Errorf("request took %v, want at least %v", elapsed, tc.minElapsed)
+			}
+
+			if got := atomic.LoadInt32(&calls); got != tc.calls {
+				t.Errorf("got %d calls, want %d", got, tc.calls)
+			}
+
+			if got := errors.Is(err, ErrServiceUnavailable); got != tc.maintenance {
+				t.Fatalf("got error %v matching %v %t, want %t", err, ErrServiceUnavailable, got, tc.maintenance)
+			}
+
+			if !tc.maintenance {
+				return
+			}
+
+			var suErr ServiceUnavailableError
+			if !errors.As(err, &suErr) {
+				t.Fatalf("got error %T, want ServiceUnavailableError", err)
+			}
+
+			if got := suErr.RetryAfter(); got != tc.retryAfter {
+				t.Errorf("got retry after %v, want %v", got, tc.retryAfter)
+			}
+
+			if got, want := suErr.Message(), "HVCA is undergoing scheduled maintenance until 02:00 UTC"; got != want {
+				t.Errorf("got message %q, want %q", got, want)
+			}
+
+			var apiErr APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("got API error %v, want status %d", apiErr, http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+func TestMakeRequestMaxResponseBytes(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name   string
+		size   int
+		status int
+		limit  int64
+		err    error
+	}{
+		{
+			name:   "WithinLimit",
+			size:   100,
+			status: http.StatusOK,
+			limit:  100,
+		},
+		{
+			name:   "ExceedsLimit",
+			size:   101,
+			status: http.StatusOK,
+			limit:  100,
+			err:    ErrResponseTooLarge,
+		},
+		{
+			name:   "ExceedsDefaultLimit",
+			size:   defaultMaxResponseBytes + 1,
+			status: http.StatusOK,
+			err:    ErrResponseTooLarge,
+		},
+		{
+			name:   "ErrorBodyExceedsLimit",
+			size:   101,
+			status: http.StatusBadRequest,
+			limit:  100,
+			err:    APIError{StatusCode: http.StatusBadRequest, Description: "unknown API error"},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Respond with a JSON string of the specified total size.
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var contentType = httputils.ContentTypeJSON
+				if tc.status != http.StatusOK {
+					contentType = httputils.ContentTypeProblemJSON
+				}
+
+				w.Header().Set(httputils.ContentTypeHeader, contentType)
+				w.WriteHeader(tc.status)
+				fmt.Fprintf(w, `"%s"`, strings.Repeat("a", tc.size-2))
+			}))
+			defer server.Close()
+
+			var clnt = newTestClient(t, server.URL, nil)
+			clnt.Config.MaxResponseBytes = tc.limit
+
+			var out string
+			var _, err = clnt.makeRequest(context.Background(), "/test", http.MethodGet, nil, &out)
+			if (err != nil) != (tc.err != nil) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("got error %v, want %v", err, tc.err)
+				}
+
+				return
+			}
+
+			if len(out) != tc.size-2 {
+				t.Errorf("got %d bytes, want %d", len(out), tc.size-2)
+			}
+		})
+	}
+}
+
+// newTestClient returns a client for the specified server URL which
+// has a token set, so no login is attempted.
+func newTestClient(t *testing.T, serverURL string, policy *RetryPolicy) *Client {
+	t.Helper()
+
+	var u, err = url.Parse(serverURL)
+	if err != nil {
+		t.Fatalf("failed to parse URL: %v", err)
+	}
+
+	var clnt = &Client{
+		BaseURL:    u,
+		HTTPClient: &http.Client{},
+		Config:     &Config{URL: serverURL, RetryPolicy: policy},
+	}
+	clnt.SetToken("token")
+
+	return clnt
+}
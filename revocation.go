@@ -0,0 +1,167 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// RevocationReason is the reason why a certificate is being revoked, as
+// defined in RFC 5280 5.3.1. The numeric value of each reason is its
+// CRLReason code.
+type RevocationReason int
+
+// Revocation reason value constants. Not all reasons defined by RFC 5280 are
+// supported by HVCA, and attempting to revoke a certificate with an
+// unsupported reason will fail.
+const (
+	ReasonUnspecified          RevocationReason = 0
+	ReasonKeyCompromise        RevocationReason = 1
+	ReasonCACompromise         RevocationReason = 2
+	ReasonAffiliationChanged   RevocationReason = 3
+	ReasonSuperseded           RevocationReason = 4
+	ReasonCessationOfOperation RevocationReason = 5
+	ReasonCertificateHold      RevocationReason = 6
+	ReasonRemoveFromCRL        RevocationReason = 8
+	ReasonPrivilegeWithdrawn   RevocationReason = 9
+	ReasonAACompromise         RevocationReason = 10
+)
+
+// ReasonUnknown is reported in CertInfo.RevocationReason if HVCA returns a
+// revocation reason which is not recognized, in which case the reason as
+// returned by HVCA is stored in CertInfo.RawRevocationReason. It cannot be
+// used to revoke a certificate.
+const ReasonUnknown RevocationReason = -1
+
+// Revocation reason constants retained for compatibility.
+//
+// Deprecated: use the Reason constants instead.
+const (
+	RevocationReasonUnspecified          = ReasonUnspecified
+	RevocationReasonAffiliationChanged   = ReasonAffiliationChanged
+	RevocationReasonKeyCompromise        = ReasonKeyCompromise
+	RevocationReasonSuperseded           = ReasonSuperseded
+	RevocationReasonCessationOfOperation = ReasonCessationOfOperation
+	RevocationReasonPrivilegeWithdrawn   = ReasonPrivilegeWithdrawn
+)
+
+// revocationReasonDescriptions maps revocation reason values to their string
+// descriptions, which are the names used in RFC 5280 and by HVCA.
+var revocationReasonDescriptions = map[RevocationReason]string{
+	ReasonUnspecified:          "unspecified",
+	ReasonKeyCompromise:        "keyCompromise",
+	ReasonCACompromise:         "cACompromise",
+	ReasonAffiliationChanged:   "affiliationChanged",
+	ReasonSuperseded:           "superseded",
+	ReasonCessationOfOperation: "cessationOfOperation",
+	ReasonCertificateHold:      "certificateHold",
+	ReasonRemoveFromCRL:        "removeFromCRL",
+	ReasonPrivilegeWithdrawn:   "privilegeWithdrawn",
+	ReasonAACompromise:         "aACompromise",
+}
+
+// revocationReasonValues maps revocation reason string descriptions to their
+// values.
+var revocationReasonValues = map[string]RevocationReason{
+	"unspecified":          ReasonUnspecified,
+	"keyCompromise":        ReasonKeyCompromise,
+	"cACompromise":         ReasonCACompromise,
+	"affiliationChanged":   ReasonAffiliationChanged,
+	"superseded":           ReasonSuperseded,
+	"cessationOfOperation": ReasonCessationOfOperation,
+	"certificateHold":      ReasonCertificateHold,
+	"removeFromCRL":        ReasonRemoveFromCRL,
+	"privilegeWithdrawn":   ReasonPrivilegeWithdrawn,
+	"aACompromise":         ReasonAACompromise,
+}
+
+// parseRevocationReason returns the revocation reason with the specified
+// string description, ignoring case, and whether one was found.
+func parseRevocationReason(s string) (RevocationReason, bool) {
+	if reason, ok := revocationReasonValues[s]; ok {
+		return reason, true
+	}
+
+	for desc, reason := range revocationReasonValues {
+		if strings.EqualFold(desc, s) {
+			return reason, true
+		}
+	}
+
+	return ReasonUnknown, false
+}
+
+// isValid checks if a value is a revocation reason defined by RFC 5280.
+func (r RevocationReason) isValid() bool {
+	var _, ok = revocationReasonDescriptions[r]
+
+	return ok
+}
+
+// isSupported checks if a value is a revocation reason supported by HVCA.
+func (r RevocationReason) isSupported() bool {
+	switch r {
+	case ReasonUnspecified,
+		ReasonKeyCompromise,
+		ReasonAffiliationChanged,
+		ReasonSuperseded,
+		ReasonCessationOfOperation,
+		ReasonPrivilegeWithdrawn:
+		return true
+	}
+
+	return false
+}
+
+// String returns a description of the revocation reason value.
+func (r RevocationReason) String() string {
+	if !r.isValid() {
+		return "UNKNOWN REVOCATION REASON VALUE"
+	}
+
+	return revocationReasonDescriptions[r]
+}
+
+// MarshalJSON returns the JSON encoding of a revocation reason value. An
+// error is returned if the reason is not supported by HVCA.
+func (r RevocationReason) MarshalJSON() ([]byte, error) {
+	if !r.isSupported() {
+		return nil, fmt.Errorf("unsupported revocation reason: %s", r)
+	}
+
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON parses a JSON-encoded revocation reason value and stores the
+// result in the object.
+func (r *RevocationReason) UnmarshalJSON(b []byte) error {
+	var data string
+	var err = json.Unmarshal(b, &data)
+	if err != nil {
+		return err
+	}
+
+	var value, ok = revocationReasonValues[data]
+	if !ok {
+		return fmt.Errorf("unknown revocation reason value %q", data)
+	}
+
+	*r = value
+
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vsglobalsign/hvclient"
+)
+
+func TestRevocationReasonString(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		value hvclient.RevocationReason
+		want  string
+	}{
+		{hvclient.RevocationReason(7), "UNKNOWN REVOCATION REASON VALUE"},
+		{hvclient.ReasonUnspecified, "unspecified"},
+		{hvclient.ReasonKeyCompromise, "keyCompromise"},
+		{hvclient.ReasonCACompromise, "cACompromise"},
+		{hvclient.ReasonAffiliationChanged, "affiliationChanged"},
+		{hvclient.ReasonSuperseded, "superseded"},
+		{hvclient.ReasonCessationOfOperation, "cessationOfOperation"},
+		{hvclient.ReasonCertificateHold, "certificateHold"},
+		{hvclient.ReasonRemoveFromCRL, "removeFromCRL"},
+		{hvclient.ReasonPrivilegeWithdrawn, "privilegeWithdrawn"},
+		{hvclient.ReasonAACompromise, "aACompromise"},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.want, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.value.String(); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRevocationReasonMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		value hvclient.RevocationReason
+		want  string
+		err   bool
+	}{
+		{value: hvclient.ReasonUnspecified, want: `"unspecified"`},
+		{value: hvclient.ReasonKeyCompromise, want: `"keyCompromise"`},
+		{value: hvclient.ReasonAffiliationChanged, want: `"affiliationChanged"`},
+		{value: hvclient.ReasonSuperseded, want: `"superseded"`},
+		{value: hvclient.ReasonCessationOfOperation, want: `"cessationOfOperation"`},
+		{value: hvclient.ReasonPrivilegeWithdrawn, want: `"privilegeWithdrawn"`},
+		{value: hvclient.ReasonCACompromise, err: true},
+		{value: hvclient.ReasonCertificateHold, err: true},
+		{value: hvclient.ReasonRemoveFromCRL, err: true},
+		{value: hvclient.ReasonAACompromise, err: true},
+		{value: hvclient.RevocationReason(7), err: true},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.value.String(), func(t *testing.T) {
+			t.Parallel()
+
+			var got, err = json.Marshal(tc.value)
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error %t", err, tc.err)
+			}
+
+			if string(got) != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+
+			if tc.err {
+				return
+			}
+
+			var reason hvclient.RevocationReason
+			if err = json.Unmarshal(got, &reason); err != nil {
+				t.Fatalf("failed to unmarshal JSON: %v", err)
+			}
+
+			if reason != tc.value {
+				t.Errorf("got %v, want %v", reason, tc.value)
+			}
+		})
+	}
+}
+
+func TestRevocationReasonUnmarshalJSONFailure(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []string{
+		`"notAReason"`,
+		`"KEYCOMPROMISE"`,
+		`1`,
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc, func(t *testing.T) {
+			t.Parallel()
+
+			var reason hvclient.RevocationReason
+			if err := json.Unmarshal([]byte(tc), &reason); err == nil {
+				t.Errorf("unexpectedly unmarshalled %s", tc)
+			}
+		})
+	}
+}
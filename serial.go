@@ -0,0 +1,78 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// SerialToString returns the representation of a certificate serial number
+// used by HVCA, which is uppercase hexadecimal with no leading zeros and no
+// separators, for example "7A3F0C01". It returns an empty string if the
+// serial number is nil.
+func SerialToString(serial *big.Int) string {
+	if serial == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%X", serial)
+}
+
+// SerialFromString parses a certificate serial number in hexadecimal. In
+// addition to the representation used by HVCA, lowercase digits, leading
+// zeros and colon-separated bytes such as "7a:3f:0c:01" are accepted, so
+// serial numbers copied from other tools are parsed consistently. Decimal
+// serial numbers cannot be distinguished from hexadecimal ones in general,
+// and are not supported.
+func SerialFromString(s string) (*big.Int, error) {
+	var digits = s
+
+	if strings.Contains(s, ":") {
+		var parts = strings.Split(s, ":")
+		for _, part := range parts {
+			if len(part) != 2 {
+				return nil, fmt.Errorf("invalid serial number %q: expected two digits per byte", s)
+			}
+		}
+
+		digits = strings.Join(parts, "")
+	}
+
+	if digits == "" {
+		return nil, errors.New("empty serial number")
+	}
+
+	for _, r := range digits {
+		if !isHexDigit(r) {
+			return nil, fmt.Errorf("invalid serial number %q: invalid hexadecimal digit %q", s, r)
+		}
+	}
+
+	var serial, ok = big.NewInt(0).SetString(digits, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid serial number %q", s)
+	}
+
+	return serial, nil
+}
+
+// isHexDigit reports whether a rune is a hexadecimal digit.
+func isHexDigit(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
@@ -0,0 +1,169 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/vsglobalsign/hvclient"
+)
+
+func TestSerialToString(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name   string
+		serial *big.Int
+		want   string
+	}{
+		{
+			name:   "Typical",
+			serial: mustBigInt(t, "741DAF9EC2D5F7DC"),
+			want:   "741DAF9EC2D5F7DC",
+		},
+		{
+			name:   "Long",
+			serial: mustBigInt(t, "1F6CE13E8A2C4EF0C4A2D7B3A9F05E2C1B"),
+			want:   "1F6CE13E8A2C4EF0C4A2D7B3A9F05E2C1B",
+		},
+		{
+			name:   "LeadingZeroByte",
+			serial: big.NewInt(0x0ABC),
+			want:   "ABC",
+		},
+		{
+			name:   "Zero",
+			serial: big.NewInt(0),
+			want:   "0",
+		},
+		{
+			name: "Nil",
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := hvclient.SerialToString(tc.serial); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSerialFromString(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "HVCA",
+			value: "741DAF9EC2D5F7DC",
+			want:  "741DAF9EC2D5F7DC",
+		},
+		{
+			name:  "Lowercase",
+			value: "741daf9ec2d5f7dc",
+			want:  "741DAF9EC2D5F7DC",
+		},
+		{
+			name:  "LeadingZeros",
+			value: "00741DAF9EC2D5F7DC",
+			want:  "741DAF9EC2D5F7DC",
+		},
+		{
+			name:  "Colons",
+			value: "00:74:1d:af:9e:c2:d5:f7:dc",
+			want:  "741DAF9EC2D5F7DC",
+		},
+		{
+			name:    "Empty",
+			value:   "",
+			wantErr: true,
+		},
+		{
+			name:    "Prefix",
+			value:   "0x741DAF9EC2D5F7DC",
+			wantErr: true,
+		},
+		{
+			name:    "Negative",
+			value:   "-741DAF9EC2D5F7DC",
+			wantErr: true,
+		},
+		{
+			name:    "Space",
+			value:   " 741DAF9EC2D5F7DC",
+			wantErr: true,
+		},
+		{
+			name:    "Underscore",
+			value:   "741D_AF9E",
+			wantErr: true,
+		},
+		{
+			name:    "BadColons",
+			value:   "74:1DA:F9",
+			wantErr: true,
+		},
+		{
+			name:    "TrailingColon",
+			value:   "74:1D:",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got, err = hvclient.SerialFromString(tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+
+			if tc.wantErr {
+				return
+			}
+
+			if s := hvclient.SerialToString(got); s != tc.want {
+				t.Errorf("got %q, want %q", s, tc.want)
+			}
+		})
+	}
+}
+
+// mustBigInt parses a hexadecimal integer, and fails the test on error.
+func mustBigInt(t *testing.T, s string) *big.Int {
+	t.Helper()
+
+	var n, ok = big.NewInt(0).SetString(s, 16)
+	if !ok {
+		t.Fatalf("invalid hexadecimal integer %q", s)
+	}
+
+	return n
+}
@@ -0,0 +1,56 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// ErrServerCertPinMismatch is returned when the TLS certificate presented by
+// HVCA does not match any of the pins in Config.ServerCertPins.
+var ErrServerCertPinMismatch = errors.New("hvclient: server certificate does not match any pin")
+
+// ServerCertPin returns the pin of a certificate suitable for use in
+// Config.ServerCertPins, being the SHA-256 hash of its DER-encoded subject
+// public key info.
+func ServerCertPin(cert *x509.Certificate) []byte {
+	var sum = sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return sum[:]
+}
+
+// verifyServerCertPin checks that the leaf certificate presented by the
+// server in a TLS handshake matches one of the configured pins. It is called
+// after the usual certificate verification, if any, has succeeded.
+func (c *Config) verifyServerCertPin(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("%w: no certificate presented", ErrServerCertPinMismatch)
+	}
+
+	var pin = ServerCertPin(cs.PeerCertificates[0])
+	for _, want := range c.ServerCertPins {
+		if bytes.Equal(pin, want) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrServerCertPinMismatch, cs.PeerCertificates[0].Subject)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"time"
+)
+
+// StatsIterator iterates over the certificates returned by one of the
+// paginated HVCA statistics endpoints, transparently fetching subsequent
+// pages as required. A typical usage is:
+//
+//	var iter = clnt.StatsIssuedIterator(ctx, from, to)
+//	for iter.Next() {
+//		var meta = iter.Item()
+//		// Do something with meta.
+//	}
+//	if err := iter.Err(); err != nil {
+//		// Handle error.
+//	}
+type StatsIterator struct {
+	// PageSize is the number of certificates to request in each page. It
+	// may be changed before the first call to Next. The HVCA API enforces a
+	// maximum number of certificates per page.
+	PageSize int
+
+	ctx      context.Context
+	client   *Client
+	path     string
+	from, to time.Time
+	page     int
+	items    []CertMeta
+	index    int
+	seen     int64
+	item     CertMeta
+	total    int64
+	done     bool
+	err      error
+}
+
+// defaultStatsPageSize is the default number of certificates to request in
+// each page when iterating over statistics.
+const defaultStatsPageSize = 100
+
+// StatsExpiringIterator returns an iterator over the certificates which
+// expired or which will expire during the specified time window.
+func (c *Client) StatsExpiringIterator(ctx context.Context, from, to time.Time) *StatsIterator {
+	return c.newStatsIterator(ctx, endpointStatsExpiring, from, to)
+}
+
+// StatsIssuedIterator returns an iterator over the certificates which were
+// issued during the specified time window.
+func (c *Client) StatsIssuedIterator(ctx context.Context, from, to time.Time) *StatsIterator {
+	return c.newStatsIterator(ctx, endpointStatsIssued, from, to)
+}
+
+// StatsRevokedIterator returns an iterator over the certificates which were
+// revoked during the specified time window.
+func (c *Client) StatsRevokedIterator(ctx context.Context, from, to time.Time) *StatsIterator {
+	return c.newStatsIterator(ctx, endpointStatsRevoked, from, to)
+}
+
+// newStatsIterator returns an iterator for the specified statistics
+// endpoint.
+func (c *Client) newStatsIterator(
+	ctx context.Context,
+	path string,
+	from, to time.Time,
+) *StatsIterator {
+	return &StatsIterator{
+		PageSize: defaultStatsPageSize,
+		ctx:      ctx,
+		client:   c,
+		path:     path,
+		from:     from,
+		to:       to,
+	}
+}
+
+// Next advances the iterator to the next certificate, fetching the next page
+// if necessary, and returns false when there are no more certificates or an
+// error occurred.
+func (s *StatsIterator) Next() bool {
+	for {
+		if s.done || s.err != nil {
+			return false
+		}
+
+		// Return the next certificate in the current page, if any remain.
+		if s.index < len(s.items) {
+			s.item = s.items[s.index]
+			s.index++
+			s.seen++
+
+			return true
+		}
+
+		// Stop if we've already seen all the certificates. A partial page
+		// doesn't indicate the last page, since HVCA may return fewer
+		// certificates per page than were requested.
+		if s.page > 0 && s.seen >= s.total {
+			s.done = true
+			return false
+		}
+
+		// Otherwise fetch the next page, unless the context is done.
+		if s.err = s.ctx.Err(); s.err != nil {
+			return false
+		}
+
+		s.page++
+
+		var items, total, err = s.client.statsCommon(s.ctx, s.path, s.page, s.PageSize, s.from, s.to)
+		if err != nil {
+			s.err = err
+			return false
+		}
+
+		s.items = items
+		s.index = 0
+		s.total = total
+
+		if len(items) == 0 {
+			s.done = true
+			return false
+		}
+	}
+}
+
+// Item returns the current certificate. It should only be called after a
+// call to Next has returned true.
+func (s *StatsIterator) Item() CertMeta {
+	return s.item
+}
+
+// Err returns the error, if any, which caused Next to return false.
+func (s *StatsIterator) Err() error {
+	return s.err
+}
+
+// Total returns the total count of certificates as reported by HVCA in the
+// most recently fetched page. It returns zero before the first call to Next.
+func (s *StatsIterator) Total() int64 {
+	return s.total
+}
@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// TokenSource supplies the authentication tokens sent with HVCA API calls by
+// a client whose configuration has it as its TokenSource. A single token
+// source may be shared by several clients using the same account, so that
+// they share a single login. Implementations must be safe for concurrent
+// use.
+type TokenSource interface {
+	// Token returns an authentication token which is believed to be
+	// unexpired, logging in first if necessary.
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenInvalidator may optionally be implemented by a TokenSource to be
+// told when HVCA rejects a token it supplied as unauthorized, so that the
+// next call to Token returns a different one. A client whose token is
+// rejected by HVCA retries the API call once with a new token if its token
+// source implements TokenInvalidator, and otherwise returns the error.
+type TokenInvalidator interface {
+	// InvalidateToken reports that HVCA rejected the specified token.
+	InvalidateToken(token string)
+}
+
+// LoginTokenSource is a TokenSource which logs into HVCA with the account
+// credentials in a configuration, in the same way as a client created by
+// NewClient. Concurrent calls to Token while a login is in progress wait for
+// that login and share the resulting token, rather than each logging in.
+type LoginTokenSource struct {
+	client *Client
+}
+
+// NewLoginTokenSource creates a token source which logs in with the account
+// credentials, mTLS certificate and other connection settings in the
+// configuration. No login is made until Token is first called, unless an
+// unexpired initial token was provided in the configuration, and the login
+// hook in the configuration, if any, is called after every login. The
+// configuration's TokenSource, AutoRefresh and DisableAutoLogin fields are
+// ignored.
+func NewLoginTokenSource(conf *Config) (*LoginTokenSource, error) {
+	// The token source logs in itself, so credentials are always required.
+	var source = *conf
+	source.TokenSource = nil
+
+	var err = source.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var hc *http.Client
+	if hc, err = source.newHTTPClient(); err != nil {
+		return nil, err
+	}
+
+	var client = Client{
+		Config:     &source,
+		BaseURL:    source.url,
+		HTTPClient: hc,
+	}
+
+	if source.InitialToken != "" {
+		client.SetTokenWithExpiry(source.InitialToken, source.InitialTokenExpiry)
+	}
+
+	return &LoginTokenSource{client: &client}, nil
+}
+
+// Token returns the stored authentication token, logging in first if it is
+// believed to be expired or if there is no stored token.
+func (s *LoginTokenSource) Token(ctx context.Context) (string, error) {
+	if err := s.client.loginIfTokenHasExpired(ctx); err != nil {
+		return "", err
+	}
+
+	// The token may have been invalidated by another goroutine since the
+	// login, in which case the caller can try again.
+	var token = s.client.GetToken()
+	if token == "" {
+		return "", ErrTokenExpired
+	}
+
+	return token, nil
+}
+
+// InvalidateToken forgets the stored authentication token if it is the
+// specified token, so that the next call to Token logs in again. It does
+// nothing if the stored token has already been replaced, such as by another
+// client sharing the token source whose token was also rejected.
+func (s *LoginTokenSource) InvalidateToken(token string) {
+	s.client.TokenMtx.Lock()
+	defer s.client.TokenMtx.Unlock()
+
+	if s.client.Token != token {
+		return
+	}
+
+	s.client.Token = ""
+	s.client.LastLogin = time.Time{}
+	s.client.tokenExpiry = time.Time{}
+}
+
+// Close releases any idle connections held by the token source. Clients
+// using the token source should not be used after it is closed.
+func (s *LoginTokenSource) Close() error {
+	return s.client.Close()
+}
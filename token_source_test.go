@@ -0,0 +1,164 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+// staticTokenSource is a token source which always returns the same token,
+// and which doesn't implement TokenInvalidator.
+type staticTokenSource string
+
+func (s staticTokenSource) Token(ctx context.Context) (string, error) {
+	return string(s), nil
+}
+
+func TestLoginTokenSource(t *testing.T) {
+	t.Parallel()
+
+	// Each login issues a new token, and only the most recently issued
+	// token is accepted.
+	var logins, calls int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+		if r.URL.Path == endpointLogin {
+			fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":600}`, atomic.AddInt32(&logins, 1))
+			return
+		}
+
+		atomic.AddInt32(&calls, 1)
+
+		if r.Header.Get(httputils.AuthorizationHeader) != fmt.Sprintf("Bearer token-%d", atomic.LoadInt32(&logins)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, `{"value":42}`)
+	}))
+	defer server.Close()
+
+	var source, err = NewLoginTokenSource(&Config{
+		URL:       server.URL,
+		APIKey:    "key",
+		APISecret: "secret",
+	})
+	if err != nil {
+		t.Fatalf("failed to create token source: %v", err)
+	}
+	defer source.Close()
+
+	// Clients sharing the token source need no credentials, and don't
+	// login when created.
+	var clients []*Client
+	for i := 0; i < 2; i++ {
+		var clnt *Client
+		if clnt, err = NewClient(context.Background(), &Config{URL: server.URL, TokenSource: source}); err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		defer clnt.Close()
+
+		clients = append(clients, clnt)
+	}
+
+	if got := atomic.LoadInt32(&logins); got != 0 {
+		t.Fatalf("got %d logins after creating clients, want 0", got)
+	}
+
+	// Simultaneous calls from both clients should share a single login.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		var clnt = clients[i%len(clients)]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := clnt.CounterCertsIssued(context.Background()); err != nil {
+				t.Errorf("failed to get counter: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&logins); got != 1 {
+		t.Fatalf("got %d logins, want 1", got)
+	}
+
+	// Make HVCA reject the shared token. The first client should login
+	// again and retry, and the second should use the new token without
+	// logging in.
+	atomic.AddInt32(&logins, 1)
+
+	for _, clnt := range clients {
+		if _, err = clnt.CounterCertsIssued(context.Background()); err != nil {
+			t.Fatalf("failed to get counter after token rejected: %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&logins); got != 3 {
+		t.Errorf("got %d logins, want 3", got)
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 13 {
+		t.Errorf("got %d calls, want 13", got)
+	}
+}
+
+func TestTokenSourceWithoutInvalidator(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == endpointLogin {
+			t.Errorf("unexpected login")
+		}
+
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	var clnt, err = NewClient(context.Background(), &Config{
+		URL:         server.URL,
+		TokenSource: staticTokenSource("token"),
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer clnt.Close()
+
+	// A rejected token should not be retried, since the source can't be
+	// told to supply a different one.
+	var apiErr APIError
+	if _, err = clnt.CounterCertsIssued(context.Background()); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("got error %v, want API error with status %d", err, http.StatusUnauthorized)
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("got %d calls, want 1", got)
+	}
+}
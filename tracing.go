@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Tracer starts tracing spans around HVCA API calls. It is deliberately
+// minimal so that this package does not depend on any particular tracing
+// library. An OpenTelemetry trace.Tracer can be adapted with a few lines of
+// code, for example:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, hvclient.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+// where otelSpan implements Span by calling SetAttributes with an
+// attribute.KeyValue, RecordError and End on the wrapped trace.Span.
+type Tracer interface {
+	// Start starts a span with the specified name as a child of any span in
+	// the context, and returns a context containing the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a tracing span started by a Tracer.
+type Span interface {
+	// SetAttribute sets an attribute on the span. The value is a string, an
+	// int or an int64.
+	SetAttribute(key string, value interface{})
+
+	// RecordError records an error which occurred during the span.
+	RecordError(err error)
+
+	// End ends the span.
+	End()
+}
+
+// Names of attributes set on tracing spans.
+const (
+	spanAttrHTTPMethod     = "http.method"
+	spanAttrHTTPStatusCode = "http.status_code"
+	spanAttrEndpoint       = "hvca.endpoint"
+	spanAttrSerialNumber   = "hvca.serial_number"
+)
+
+// spanNamePrefix is prefixed to the operation name to give the span name.
+const spanNamePrefix = "hvca."
+
+// startSpan starts a tracing span for an HVCA API request, if a tracer was
+// provided in the configuration. The returned span is nil otherwise.
+func (c *Client) startSpan(ctx context.Context, method, path string) (context.Context, Span) {
+	if c.Config == nil || c.Config.Tracer == nil {
+		return ctx, nil
+	}
+
+	var span Span
+	ctx, span = c.Config.Tracer.Start(ctx, spanNamePrefix+operationName(method, path))
+
+	span.SetAttribute(spanAttrHTTPMethod, method)
+	span.SetAttribute(spanAttrEndpoint, endpointTemplate(path))
+
+	return ctx, span
+}
+
+// endSpan records the outcome of an HVCA API request on a tracing span, and
+// ends it. It does nothing if the span is nil.
+func endSpan(span Span, path string, response *http.Response, err error) {
+	if span == nil {
+		return
+	}
+
+	if status := responseStatus(response, err); status != 0 {
+		span.SetAttribute(spanAttrHTTPStatusCode, status)
+	}
+
+	// Record the serial number of the certificate, either from the request
+	// path or, for a new certificate, from the response.
+	if endpointTemplate(path) == endpointCertificates+"/{serial}" {
+		span.SetAttribute(spanAttrSerialNumber, strings.TrimPrefix(path, endpointCertificates+"/"))
+	} else if path == endpointCertificates && response != nil {
+		if sn, snErr := basePathHeaderFromResponse(response, certSNHeaderName); snErr == nil {
+			span.SetAttribute(spanAttrSerialNumber, sn)
+		}
+	}
+
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	span.End()
+}
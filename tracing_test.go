@@ -0,0 +1,199 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/vsglobalsign/hvclient/internal/httputils"
+)
+
+// recordingTracer is a tracer which records the spans it starts.
+type recordingTracer struct {
+	sync.Mutex
+	spans []*recordingSpan
+}
+
+// recordingSpan is a span started by a recordingTracer.
+type recordingSpan struct {
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	errs   []error
+	ended  bool
+}
+
+type recordingSpanKey struct{}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	var span = &recordingSpan{name: name, attrs: make(map[string]interface{})}
+	if parent, ok := ctx.Value(recordingSpanKey{}).(*recordingSpan); ok {
+		span.parent = parent.name
+	}
+
+	t.Lock()
+	t.spans = append(t.spans, span)
+	t.Unlock()
+
+	return context.WithValue(ctx, recordingSpanKey{}, span), span
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *recordingSpan) RecordError(err error)                      { s.errs = append(s.errs, err) }
+func (s *recordingSpan) End()                                       { s.ended = true }
+
+func TestOperationName(t *testing.T) {
+	t.Parallel()
+
+	var testcases = []struct {
+		method, path, want string
+	}{
+		{http.MethodPost, "/login", "Login"},
+		{http.MethodPost, "/certificates", "CertificateRequest"},
+		{http.MethodGet, "/certificates/741DAF9EC2D5F7DC", "CertificateRetrieve"},
+		{http.MethodPatch, "/certificates/741DAF9EC2D5F7DC", "CertificateRevoke"},
+		{http.MethodGet, "/stats/issued?page=1&per_page=10", "StatsIssued"},
+		{http.MethodGet, "/claims/domains?status=VERIFIED", "ClaimsDomains"},
+		{http.MethodDelete, "/claims/domains/ABCD", "ClaimDelete"},
+		{http.MethodPost, "/claims/domains/ABCD/dns", "ClaimDNS"},
+		{http.MethodGet, "/claims/domains/ABCD/email", "ClaimEmailRetrieve"},
+		{http.MethodGet, "/validationpolicies", "Policies"},
+		{http.MethodGet, "/validationpolicies/secondary%20policy", "PolicyByID"},
+		{http.MethodGet, "/profiles", "Profiles"},
+		{http.MethodPut, "/certificates/1234", "PUT /certificates/{serial}"},
+		{http.MethodGet, "/unknown", "GET /unknown"},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+
+		t.Run(tc.want, func(t *testing.T) {
+			t.Parallel()
+
+			if got := operationName(tc.method, tc.path); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMakeRequestTracing(t *testing.T) {
+	t.Parallel()
+
+	// Return unauthorized for the first certificate request to force a
+	// re-login.
+	var calls int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httputils.ContentTypeHeader, httputils.ContentTypeJSON)
+
+		switch {
+		case r.URL.Path == endpointLogin:
+			fmt.Fprint(w, `{"access_token":"token"}`)
+
+		case strings.HasPrefix(r.URL.Path, endpointCertificates) && atomic.AddInt32(&calls, 1) == 1:
+			w.WriteHeader(http.StatusUnauthorized)
+
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	var tracer recordingTracer
+
+	var clnt = newTestClient(t, server.URL, nil)
+	clnt.Config.Tracer = &tracer
+
+	if err := clnt.CertificateRevoke(context.Background(), big.NewInt(0x741daf9ec2d5f7dc)); err != nil {
+		t.Fatalf("failed to revoke certificate: %v", err)
+	}
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(tracer.spans))
+	}
+
+	var call, login = tracer.spans[0], tracer.spans[1]
+
+	if call.name != "hvca.CertificateRevoke" || call.parent != "" {
+		t.Errorf("got span %q with parent %q, want hvca.CertificateRevoke with no parent", call.name, call.parent)
+	}
+
+	if login.name != "hvca.Login" || login.parent != call.name {
+		t.Errorf("got span %q with parent %q, want hvca.Login with parent %s", login.name, login.parent, call.name)
+	}
+
+	if got := call.attrs[spanAttrHTTPStatusCode]; got != http.StatusNoContent {
+		t.Errorf("got status code attribute %v, want %d", got, http.StatusNoContent)
+	}
+
+	if got := call.attrs[spanAttrSerialNumber]; got != "741DAF9EC2D5F7DC" {
+		t.Errorf("got serial number attribute %v, want 741DAF9EC2D5F7DC", got)
+	}
+
+	if got := call.attrs[spanAttrEndpoint]; got != "/certificates/{serial}" {
+		t.Errorf("got endpoint attribute %v, want /certificates/{serial}", got)
+	}
+
+	for _, span := range tracer.spans {
+		if !span.ended {
+			t.Errorf("span %q not ended", span.name)
+		}
+
+		if len(span.errs) != 0 {
+			t.Errorf("span %q unexpectedly recorded errors %v", span.name, span.errs)
+		}
+	}
+}
+
+func TestMakeRequestTracingError(t *testing.T) {
+	t.Parallel()
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	var tracer recordingTracer
+
+	var clnt = newTestClient(t, server.URL, nil)
+	clnt.Config.Tracer = &tracer
+
+	if _, err := clnt.CertificateRetrieve(context.Background(), big.NewInt(1)); err == nil {
+		t.Fatal("unexpectedly retrieved certificate")
+	}
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(tracer.spans))
+	}
+
+	var span = tracer.spans[0]
+
+	if got := span.attrs[spanAttrHTTPStatusCode]; got != http.StatusNotFound {
+		t.Errorf("got status code attribute %v, want %d", got, http.StatusNotFound)
+	}
+
+	if len(span.errs) != 1 {
+		t.Errorf("got %d recorded errors, want 1", len(span.errs))
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright (c) 2019-2021 GMO GlobalSign Pte. Ltd.
+
+Licensed under the MIT License (the "License"); you may not use this file except
+in compliance with the License. You may obtain a copy of the License at
+
+https://opensource.org/licenses/MIT
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hvclient
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// userAgentHeaderName is the name of the HTTP header which identifies the
+// client making a request.
+const userAgentHeaderName = "User-Agent"
+
+// modulePath is the path of this module, used to look up its version in the
+// build information of the running program.
+const modulePath = "github.com/vsglobalsign/hvclient"
+
+// defaultUserAgent is the User-Agent header sent with requests if none is
+// specified in the configuration.
+var defaultUserAgent = "hvclient/" + moduleVersion()
+
+// moduleVersion returns the version of this module in the running program,
+// or "devel" if it is not known, for example when running its own tests.
+func moduleVersion() string {
+	var info, ok = debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+
+	var mod = &info.Main
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			mod = dep
+			break
+		}
+	}
+
+	if mod.Path != modulePath || mod.Version == "" || mod.Version == "(devel)" {
+		return "devel"
+	}
+
+	return mod.Version
+}
+
+// setUserAgent sets the User-Agent header of a request to the one specified
+// in the configuration. If none was specified, the default is used unless
+// the extra headers already provided one.
+func (c *Config) setUserAgent(request *http.Request) {
+	if c.UserAgent != "" {
+		request.Header.Set(userAgentHeaderName, c.UserAgent)
+	} else if request.Header.Get(userAgentHeaderName) == "" {
+		request.Header.Set(userAgentHeaderName, defaultUserAgent)
+	}
+}